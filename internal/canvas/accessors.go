@@ -10,5 +10,11 @@ func (c *Canvas) Reset() {
 	c.boxes = c.boxes[:0]
 	c.texts = c.texts[:0]
 	c.connections = c.connections[:0]
-	c.highlights = make(map[string]int)
+	c.highlights = make(map[string]highlight)
+	c.nextBoxID, c.nextTextID, c.nextConnID = 0, 0, 0
+}
+
+// Empty reports whether the canvas has no objects and no highlights.
+func (c *Canvas) Empty() bool {
+	return len(c.boxes) == 0 && len(c.texts) == 0 && len(c.connections) == 0 && len(c.highlights) == 0
 }
@@ -0,0 +1,111 @@
+package canvas
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BackupName returns the name of the nth most recent backup of filename:
+// chart.sav~ for the first, then chart.sav~2, chart.sav~3 and so on.
+func BackupName(filename string, n int) string {
+	if n <= 1 {
+		return filename + "~"
+	}
+	return fmt.Sprintf("%s~%d", filename, n)
+}
+
+// WriteFileAtomic replaces filename with what write produces, so a crash or
+// a full disk leaves either the old file or the new one, never a mix. The
+// data goes to a temporary file in the same directory, which is synced and
+// then renamed over filename. When backups is above zero the file being
+// replaced is kept as its first backup and older ones move down, dropping
+// the oldest.
+func WriteFileAtomic(filename string, backups int, write func(w io.Writer) error) error {
+	// Write through symlinks rather than replacing them with a file.
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	perm := os.FileMode(0644)
+	info, statErr := os.Stat(filename)
+	if statErr == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if statErr == nil && backups > 0 {
+		if err := rotateBackups(filename, backups); err != nil {
+			return fmt.Errorf("backing up %s: %w", filename, err)
+		}
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+	committed = true
+
+	// Make the rename itself durable. Not every platform can sync a
+	// directory, and the data is already safe, so failures are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// rotateBackups moves each backup of filename one place down, dropping the
+// oldest, and keeps the current file as the first backup. The file itself
+// stays where it is until the new version is renamed over it.
+func rotateBackups(filename string, backups int) error {
+	if err := os.Remove(BackupName(filename, backups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := backups - 1; n >= 1; n-- {
+		if err := os.Rename(BackupName(filename, n), BackupName(filename, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	first := BackupName(filename, 1)
+	if err := os.Link(filename, first); err == nil {
+		return nil
+	}
+	// Hard links aren't available everywhere; fall back to a copy.
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(first, data, info.Mode().Perm())
+}
@@ -0,0 +1,105 @@
+package canvas
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeString(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func TestWriteFileAtomicRotatesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chart.sav")
+	for _, version := range []string{"one", "two", "three", "four"} {
+		if err := WriteFileAtomic(path, 2, writeString(version)); err != nil {
+			t.Fatalf("writing %s: %v", version, err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:                "four",
+		BackupName(path, 1): "three",
+		BackupName(path, 2): "two",
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s holds %q, expected %q", filepath.Base(name), data, want)
+		}
+	}
+	if _, err := os.Stat(BackupName(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("expected only two backups, found %s", BackupName(path, 3))
+	}
+}
+
+func TestWriteFileAtomicKeepsOldFileWhenWriteFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chart.sav")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("disk full")
+	err := WriteFileAtomic(path, 1, func(w io.Writer) error {
+		io.WriteString(w, "half")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Errorf("file holds %q after a failed save, expected the old contents", data)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "chart.sav" {
+			t.Errorf("unexpected file %s left behind", e.Name())
+		}
+	}
+
+	if err := WriteFileAtomic(path, 1, writeString("new")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("save changed the mode to %v", info.Mode().Perm())
+	}
+}
+
+func TestSaveKeepsNoBackupsByDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chart.sav")
+	c := NewCanvas()
+	c.AddBox(0, 0, "A")
+	for i := 0; i < 2; i++ {
+		if err := c.SaveToFile(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(BackupName(path, 1)); !os.IsNotExist(err) {
+		t.Errorf("SaveToFile left a backup behind")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "FLOWCHART\n") {
+		t.Errorf("unexpected save: %q", data)
+	}
+}
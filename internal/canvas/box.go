@@ -29,6 +29,7 @@ type Box struct {
 	ID           int
 	ZLevel       int
 	BorderStyle  BorderStyle
+	Shape        Shape
 	OriginalText string
 	Title        string
 	Color        int
@@ -58,7 +59,7 @@ func (b *Box) UpdateSize() {
 	if b.Title != "" {
 		titleLines = strings.Split(b.Title, "\n")
 		for _, titleLine := range titleLines {
-			titleWidth := len(titleLine) + 2
+			titleWidth := StringWidth(titleLine) + 2
 			if titleWidth > maxWidth {
 				maxWidth = titleWidth
 			}
@@ -66,8 +67,8 @@ func (b *Box) UpdateSize() {
 	}
 
 	for _, line := range b.Lines {
-		if len(line)+2 > maxWidth {
-			maxWidth = len(line) + 2
+		if StringWidth(line)+2 > maxWidth {
+			maxWidth = StringWidth(line) + 2
 		}
 	}
 	b.Width = maxWidth
@@ -100,7 +101,7 @@ func (b *Box) fitTextToSize(newWidth, newHeight int) {
 
 	fitsWidth := true
 	for _, line := range originalLines {
-		if len(line) > contentWidth {
+		if StringWidth(line) > contentWidth {
 			fitsWidth = false
 			break
 		}
@@ -123,22 +124,22 @@ func (b *Box) fitTextToSize(newWidth, newHeight int) {
 
 		if i == contentHeight-1 && (len(originalLines) > contentHeight || !fitsWidth) {
 
-			if len(line) > contentWidth-3 {
-				line = line[:contentWidth-3] + "..."
+			if StringWidth(line) > contentWidth-3 {
+				line = TruncateWidth(line, contentWidth-3) + "..."
 			} else if len(originalLines) > contentHeight {
 
-				if len(line)+3 <= contentWidth {
+				if StringWidth(line)+3 <= contentWidth {
 					line = line + "..."
 				} else {
-					line = line[:contentWidth-3] + "..."
+					line = TruncateWidth(line, contentWidth-3) + "..."
 				}
 			}
-		} else if len(line) > contentWidth {
+		} else if StringWidth(line) > contentWidth {
 
 			if contentWidth > 3 {
-				line = line[:contentWidth-3] + "..."
+				line = TruncateWidth(line, contentWidth-3) + "..."
 			} else {
-				line = line[:contentWidth]
+				line = TruncateWidth(line, contentWidth)
 			}
 		}
 
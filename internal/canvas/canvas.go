@@ -1,10 +1,20 @@
 package canvas
 
+// Canvas holds the objects of a chart. Methods that take a box, text or
+// connection argument take its current index in Boxes, Texts or Connections;
+// the ID fields are stable identities that survive deletes and reloads, and
+// BoxIndex, TextIndex and ConnectionIndex map them back to indices.
 type Canvas struct {
 	boxes       []Box
 	connections []Connection
 	texts       []Text
-	highlights  map[string]int
+	highlights  map[string]highlight
+
+	// IDs are never reused within a canvas, so a stored ID can't come to
+	// name a different object.
+	nextBoxID  int
+	nextTextID int
+	nextConnID int
 }
 
 func NewCanvas() *Canvas {
@@ -12,7 +22,7 @@ func NewCanvas() *Canvas {
 		boxes:       make([]Box, 0),
 		connections: make([]Connection, 0),
 		texts:       make([]Text, 0),
-		highlights:  make(map[string]int),
+		highlights:  make(map[string]highlight),
 	}
 }
 
@@ -46,6 +56,7 @@ func (c *Canvas) GetFullBounds() (int, int, int, int) {
 		points := []Point{{X: conn.FromX, Y: conn.FromY}}
 		points = append(points, conn.Waypoints...)
 		points = append(points, Point{X: conn.ToX, Y: conn.ToY})
+		points = append(points, conn.labelCells()...)
 
 		for _, pt := range points {
 			if !hasElements {
@@ -85,8 +96,8 @@ func (c *Canvas) GetFullBounds() (int, int, int, int) {
 
 		maxTextX := text.X
 		for _, line := range text.Lines {
-			if text.X+len(line) > maxTextX {
-				maxTextX = text.X + len(line)
+			if text.X+StringWidth(line) > maxTextX {
+				maxTextX = text.X + StringWidth(line)
 			}
 		}
 		if maxTextX > maxX {
@@ -105,13 +116,15 @@ func (c *Canvas) GetFullBounds() (int, int, int, int) {
 }
 
 func (c *Canvas) AddBox(x, y int, text string) {
-	c.AddBoxWithID(x, y, text, len(c.boxes))
+	c.AddBoxWithID(x, y, text, c.nextBoxID)
 }
 
 func (c *Canvas) AddText(x, y int, text string) {
-	c.AddTextWithID(x, y, text, len(c.texts))
+	c.AddTextWithID(x, y, text, c.nextTextID)
 }
 
+// AddTextWithID appends a text that keeps an ID handed out earlier, as when
+// redoing its creation.
 func (c *Canvas) AddTextWithID(x, y int, text string, id int) {
 	textObj := Text{
 		X:     x,
@@ -120,12 +133,11 @@ func (c *Canvas) AddTextWithID(x, y int, text string, id int) {
 		Color: -1,
 	}
 	textObj.SetText(text)
-	c.texts = append(c.texts, textObj)
-	for i := id + 1; i < len(c.texts); i++ {
-		c.texts[i].ID = i
-	}
+	c.InsertText(len(c.texts), textObj)
 }
 
+// AddBoxWithID appends a box that keeps an ID handed out earlier, as when
+// redoing its creation.
 func (c *Canvas) AddBoxWithID(x, y int, text string, id int) {
 	box := Box{
 		X:     x,
@@ -134,19 +146,26 @@ func (c *Canvas) AddBoxWithID(x, y int, text string, id int) {
 		Color: -1,
 	}
 	box.SetText(text)
-	if id >= len(c.boxes) {
-		for len(c.boxes) <= id {
-			c.boxes = append(c.boxes, Box{})
-		}
-		c.boxes[id] = box
-	} else {
-		c.boxes = append(c.boxes, Box{})
-		copy(c.boxes[id+1:], c.boxes[id:])
-		c.boxes[id] = box
-		for i := id + 1; i < len(c.boxes); i++ {
-			c.boxes[i].ID = i
-		}
-	}
+	c.InsertBox(len(c.boxes), box)
+}
+
+// InsertBox puts box back at index, keeping its ID. Undo uses it to restore
+// a deleted box in its old stacking position.
+func (c *Canvas) InsertBox(index int, box Box) {
+	index = min(max(index, 0), len(c.boxes))
+	c.boxes = append(c.boxes, Box{})
+	copy(c.boxes[index+1:], c.boxes[index:])
+	c.boxes[index] = box
+	c.nextBoxID = max(c.nextBoxID, box.ID+1)
+}
+
+// InsertText puts text back at index, keeping its ID.
+func (c *Canvas) InsertText(index int, text Text) {
+	index = min(max(index, 0), len(c.texts))
+	c.texts = append(c.texts, Text{})
+	copy(c.texts[index+1:], c.texts[index:])
+	c.texts[index] = text
+	c.nextTextID = max(c.nextTextID, text.ID+1)
 }
 
 func (c *Canvas) GetBoxAt(x, y int) int {
@@ -164,7 +183,7 @@ func (c *Canvas) GetTextAt(x, y int) int {
 
 		for lineIdx, line := range text.Lines {
 			lineY := text.Y + lineIdx
-			if y == lineY && x >= text.X && x < text.X+len(line) {
+			if y == lineY && x >= text.X && x < text.X+StringWidth(line) {
 				return i
 			}
 		}
@@ -176,9 +195,6 @@ func (c *Canvas) DeleteText(id int) {
 	if id >= 0 && id < len(c.texts) {
 		c.deleteHighlightsForText(id)
 		c.texts = append(c.texts[:id], c.texts[id+1:]...)
-		for i := id; i < len(c.texts); i++ {
-			c.texts[i].ID = i
-		}
 	}
 }
 
@@ -211,19 +227,11 @@ func (c *Canvas) SetTextText(id int, text string) {
 func (c *Canvas) DeleteBox(id int) {
 	if id >= 0 && id < len(c.boxes) {
 		c.deleteHighlightsForBox(id)
+		boxID := c.boxes[id].ID
 		c.boxes = append(c.boxes[:id], c.boxes[id+1:]...)
-		for i := id; i < len(c.boxes); i++ {
-			c.boxes[i].ID = i
-		}
 		newConnections := make([]Connection, 0)
 		for _, connection := range c.connections {
-			if connection.FromID != id && connection.ToID != id {
-				if connection.FromID > id {
-					connection.FromID--
-				}
-				if connection.ToID > id {
-					connection.ToID--
-				}
+			if connection.FromID != boxID && connection.ToID != boxID {
 				newConnections = append(newConnections, connection)
 			}
 		}
@@ -262,13 +270,13 @@ func (c *Canvas) reanchorConnectionsForResize(id, oldBoxX, oldBoxWidth int) {
 	box := &c.boxes[id]
 	for i := range c.connections {
 		conn := &c.connections[i]
-		if conn.FromID == id && conn.ToID >= 0 && conn.ToID < len(c.boxes) {
+		if to := c.BoxIndex(conn.ToID); conn.FromID == box.ID && to >= 0 {
 			wasHorizontal := (conn.FromY == conn.ToY)
 			oldFromX := conn.FromX
 			oldToX := conn.ToX
-			newFromX, newFromY, newToX, newToY := c.calculateConnectionPointsPreservingOrientation(id, conn.ToID, wasHorizontal)
+			newFromX, newFromY, newToX, newToY := c.calculateConnectionPointsPreservingOrientation(id, to, wasHorizontal)
 			if wasHorizontal {
-				oldToBox := c.boxes[conn.ToID]
+				oldToBox := c.boxes[to]
 				wasOnLeft := (oldToX == oldToBox.X || (oldToX < oldToBox.X+oldToBox.Width/2))
 				if wasOnLeft {
 					newToX = oldToBox.X
@@ -287,11 +295,11 @@ func (c *Canvas) reanchorConnectionsForResize(id, oldBoxX, oldBoxWidth int) {
 			conn.ToX = newToX
 			conn.ToY = newToY
 		}
-		if conn.ToID == id && conn.FromID >= 0 && conn.FromID < len(c.boxes) {
+		if from := c.BoxIndex(conn.FromID); conn.ToID == box.ID && from >= 0 {
 			wasHorizontal := (conn.FromY == conn.ToY)
 			oldToX := conn.ToX
 			oldFromX := conn.FromX
-			newFromX, newFromY, newToX, newToY := c.calculateConnectionPointsPreservingOrientation(conn.FromID, id, wasHorizontal)
+			newFromX, newFromY, newToX, newToY := c.calculateConnectionPointsPreservingOrientation(from, id, wasHorizontal)
 			if wasHorizontal {
 				wasOnLeft := (oldToX == oldBoxX || (oldToX < oldBoxX+oldBoxWidth/2))
 				if wasOnLeft {
@@ -299,7 +307,7 @@ func (c *Canvas) reanchorConnectionsForResize(id, oldBoxX, oldBoxWidth int) {
 				} else {
 					newToX = box.X + box.Width - 1
 				}
-				oldFromBox := c.boxes[conn.FromID]
+				oldFromBox := c.boxes[from]
 				wasFromRight := (oldFromX == oldFromBox.X+oldFromBox.Width-1 || (oldFromX > oldFromBox.X+oldFromBox.Width/2))
 				if wasFromRight {
 					newFromX = oldFromBox.X + oldFromBox.Width - 1
@@ -356,6 +364,16 @@ func (c *Canvas) SetBoxPositionOnly(id int, x, y int) {
 	}
 }
 
+// SetBoxPosition moves the box to x, y with the highlights painted on it,
+// leaving its lines for the caller to reroute or restore.
+func (c *Canvas) SetBoxPosition(id int, x, y int) {
+	if id >= 0 && id < len(c.boxes) {
+		oldX, oldY := c.boxes[id].X, c.boxes[id].Y
+		c.SetBoxPositionOnly(id, x, y)
+		c.moveBoxHighlights(id, c.boxes[id].X-oldX, c.boxes[id].Y-oldY)
+	}
+}
+
 func (c *Canvas) MoveText(id int, deltaX, deltaY int) {
 	if id >= 0 && id < len(c.texts) {
 		text := &c.texts[id]
@@ -437,6 +455,38 @@ func (c *Canvas) SetBorderStyle(boxID int, style BorderStyle) {
 	}
 }
 
+// SetBoxShape gives the box at index boxID a new outline. Line ends on it
+// move to where the shape lets them attach, and the lines that moved are
+// rerouted.
+func (c *Canvas) SetBoxShape(boxID int, shape Shape) {
+	if boxID < 0 || boxID >= len(c.boxes) {
+		return
+	}
+	box := &c.boxes[boxID]
+	box.Shape = shape
+	var updatedConnections []connectionPathInfo
+	for i := range c.connections {
+		conn := &c.connections[i]
+		if conn.FromID != box.ID && conn.ToID != box.ID {
+			continue
+		}
+		path := conn.drawnPath()
+		fromX, fromY, toX, toY := conn.FromX, conn.FromY, conn.ToX, conn.ToY
+		if conn.FromID == box.ID {
+			conn.FromX, conn.FromY = c.shapeAnchor(*box, conn.FromX, conn.FromY)
+		}
+		if conn.ToID == box.ID {
+			conn.ToX, conn.ToY = c.shapeAnchor(*box, conn.ToX, conn.ToY)
+		}
+		if conn.FromX == fromX && conn.FromY == fromY && conn.ToX == toX && conn.ToY == toY {
+			continue
+		}
+		updatedConnections = append(updatedConnections, connectionPathInfo{connIdx: i, points: path})
+		c.rerouteConnection(i)
+	}
+	c.updateBranchConnections(updatedConnections)
+}
+
 func (c *Canvas) SetBoxColor(boxID, color int) {
 	if boxID >= 0 && boxID < len(c.boxes) {
 		c.boxes[boxID].Color = color
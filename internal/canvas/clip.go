@@ -0,0 +1,312 @@
+package canvas
+
+import "sort"
+
+// Group is a set of objects that were pasted into or cut from a canvas
+// together, kept so the change can be undone and redone as one step. Boxes
+// and texts remember the index they had, and highlight cells hold both the
+// group's color (Color) and the color underneath it (OldColor, -1 for none).
+type Group struct {
+	Boxes       []GroupBox
+	Texts       []GroupText
+	Connections []Connection
+	Highlights  []HighlightCell
+}
+
+type GroupBox struct {
+	Index int
+	Box   Box
+}
+
+type GroupText struct {
+	Index int
+	Text  Text
+}
+
+// Empty reports whether the group holds nothing.
+func (g Group) Empty() bool {
+	return len(g.Boxes) == 0 && len(g.Texts) == 0 && len(g.Connections) == 0 && len(g.Highlights) == 0
+}
+
+func cloneBox(box Box) Box {
+	box.Lines = append([]string(nil), box.Lines...)
+	return box
+}
+
+func cloneText(text Text) Text {
+	text.Lines = append([]string(nil), text.Lines...)
+	return text
+}
+
+// group collects the boxes, texts and connections at the given indices along
+// with the highlighted cells among cells and under the boxes and texts. A
+// connection attached to one of the boxes is included too, since it can't
+// outlive the box.
+func (c *Canvas) group(boxes, texts, connections []int, cells []Point) Group {
+	var g Group
+	boxIDs := map[int]bool{}
+	highlights := map[Point]bool{}
+	for _, p := range cells {
+		highlights[p] = true
+	}
+
+	for _, i := range sortedIndices(boxes, len(c.boxes)) {
+		g.Boxes = append(g.Boxes, GroupBox{Index: i, Box: cloneBox(c.boxes[i])})
+		boxIDs[c.boxes[i].ID] = true
+		for _, p := range c.GetBoxCells(i) {
+			highlights[p] = true
+		}
+	}
+	for _, i := range sortedIndices(texts, len(c.texts)) {
+		g.Texts = append(g.Texts, GroupText{Index: i, Text: cloneText(c.texts[i])})
+		for _, p := range c.GetTextCells(i) {
+			highlights[p] = true
+		}
+	}
+
+	wanted := map[int]bool{}
+	for _, i := range connections {
+		wanted[i] = true
+	}
+	for i, conn := range c.connections {
+		if wanted[i] || boxIDs[conn.FromID] || boxIDs[conn.ToID] {
+			g.Connections = append(g.Connections, conn.Clone())
+		}
+	}
+
+	for p := range highlights {
+		if color := c.GetHighlight(p.X, p.Y); color >= 0 {
+			g.Highlights = append(g.Highlights, HighlightCell{X: p.X, Y: p.Y, Color: color, HadColor: true, OldColor: -1})
+		}
+	}
+	sort.Slice(g.Highlights, func(i, j int) bool {
+		a, b := g.Highlights[i], g.Highlights[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+	return g
+}
+
+// sortedIndices returns the valid, distinct indices in ascending order.
+func sortedIndices(indices []int, n int) []int {
+	seen := map[int]bool{}
+	var out []int
+	for _, i := range indices {
+		if i >= 0 && i < n && !seen[i] {
+			seen[i] = true
+			out = append(out, i)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// Extract copies the boxes, texts and connections at the given indices, and
+// the highlights among cells and under the copied objects, into a new canvas
+// that a later Paste can insert anywhere. Connections come along only when
+// every end that is attached to a box is attached to a copied one. The copy
+// is moved so its top-left corner is at 0,0.
+func (c *Canvas) Extract(boxes, texts, connections []int, cells []Point) *Canvas {
+	g := c.group(boxes, texts, connections, cells)
+	clip := NewCanvas()
+
+	ids := map[int]int{}
+	for _, gb := range g.Boxes {
+		box := gb.Box
+		ids[box.ID] = len(clip.boxes)
+		box.ID = len(clip.boxes)
+		clip.InsertBox(len(clip.boxes), box)
+	}
+	for _, gt := range g.Texts {
+		text := gt.Text
+		text.ID = len(clip.texts)
+		clip.InsertText(len(clip.texts), text)
+	}
+	for _, conn := range g.Connections {
+		from, fromOK := ids[conn.FromID]
+		to, toOK := ids[conn.ToID]
+		if (conn.FromID >= 0 && !fromOK) || (conn.ToID >= 0 && !toOK) {
+			continue
+		}
+		if conn.FromID >= 0 {
+			conn.FromID = from
+		}
+		if conn.ToID >= 0 {
+			conn.ToID = to
+		}
+		conn.ID = len(clip.connections)
+		clip.RestoreConnection(conn)
+	}
+	for _, h := range g.Highlights {
+		clip.SetHighlight(h.X, h.Y, h.Color)
+	}
+
+	minX, minY := clip.topLeft()
+	clip.translate(-minX, -minY)
+	return clip
+}
+
+// topLeft is the smallest X and Y used by any object or highlight.
+func (c *Canvas) topLeft() (int, int) {
+	cells := c.sortedHighlights()
+	if len(c.boxes) == 0 && len(c.texts) == 0 && len(c.connections) == 0 {
+		if len(cells) == 0 {
+			return 0, 0
+		}
+		minX := cells[0].X
+		for _, cell := range cells {
+			minX = min(minX, cell.X)
+		}
+		return minX, cells[0].Y
+	}
+	minX, minY, _, _ := c.GetFullBounds()
+	for _, cell := range cells {
+		minX, minY = min(minX, cell.X), min(minY, cell.Y)
+	}
+	return minX, minY
+}
+
+// translate moves everything on the canvas by dx, dy.
+func (c *Canvas) translate(dx, dy int) {
+	for i := range c.boxes {
+		c.boxes[i].X += dx
+		c.boxes[i].Y += dy
+	}
+	for i := range c.texts {
+		c.texts[i].X += dx
+		c.texts[i].Y += dy
+	}
+	for i := range c.connections {
+		conn := &c.connections[i]
+		conn.FromX += dx
+		conn.FromY += dy
+		conn.ToX += dx
+		conn.ToY += dy
+		for j := range conn.Waypoints {
+			conn.Waypoints[j].X += dx
+			conn.Waypoints[j].Y += dy
+		}
+	}
+	cells := c.sortedHighlights()
+	c.highlights = make(map[string]highlight, len(cells))
+	for _, cell := range cells {
+		c.SetHighlight(cell.X+dx, cell.Y+dy, cell.Color)
+	}
+}
+
+// Paste inserts a copy of everything on clip, moved by dx, dy, giving the
+// copies new IDs. It returns what was inserted, for undoing with RemoveGroup.
+func (c *Canvas) Paste(clip *Canvas, dx, dy int) Group {
+	var g Group
+	ids := map[int]int{}
+	for _, box := range clip.boxes {
+		box = cloneBox(box)
+		ids[box.ID] = c.nextBoxID
+		box.ID = c.nextBoxID
+		box.X += dx
+		box.Y += dy
+		g.Boxes = append(g.Boxes, GroupBox{Index: len(c.boxes), Box: box})
+		c.InsertBox(len(c.boxes), cloneBox(box))
+	}
+	for _, text := range clip.texts {
+		text = cloneText(text)
+		text.ID = c.nextTextID
+		text.X += dx
+		text.Y += dy
+		g.Texts = append(g.Texts, GroupText{Index: len(c.texts), Text: text})
+		c.InsertText(len(c.texts), cloneText(text))
+	}
+	for _, conn := range clip.connections {
+		conn = conn.Clone()
+		if conn.FromID >= 0 {
+			conn.FromID = ids[conn.FromID]
+		}
+		if conn.ToID >= 0 {
+			conn.ToID = ids[conn.ToID]
+		}
+		conn.ID = c.nextConnID
+		conn.FromX += dx
+		conn.FromY += dy
+		conn.ToX += dx
+		conn.ToY += dy
+		for j := range conn.Waypoints {
+			conn.Waypoints[j].X += dx
+			conn.Waypoints[j].Y += dy
+		}
+		g.Connections = append(g.Connections, conn)
+		c.RestoreConnection(conn.Clone())
+	}
+	for _, cell := range clip.sortedHighlights() {
+		x, y := cell.X+dx, cell.Y+dy
+		old := c.GetHighlight(x, y)
+		g.Highlights = append(g.Highlights, HighlightCell{X: x, Y: y, Color: cell.Color, HadColor: old >= 0, OldColor: old})
+		c.SetHighlight(x, y, cell.Color)
+	}
+	return g
+}
+
+// Cut removes the boxes, texts and connections at the given indices, the
+// connections attached to those boxes, and the highlights among cells and
+// under the removed objects. It returns what was removed, for undoing with
+// InsertGroup.
+func (c *Canvas) Cut(boxes, texts, connections []int, cells []Point) Group {
+	g := c.group(boxes, texts, connections, cells)
+	c.RemoveGroup(g)
+	return g
+}
+
+// RemoveGroup takes the group's objects out of the canvas by ID and puts
+// back the highlight colors that were under it.
+func (c *Canvas) RemoveGroup(g Group) {
+	for _, conn := range g.Connections {
+		c.RemoveSpecificConnection(conn)
+	}
+	// Deleting a box or text clears the highlights under it, including
+	// ones that aren't part of the group, so those are put back.
+	for i := len(g.Texts) - 1; i >= 0; i-- {
+		if idx := c.TextIndex(g.Texts[i].Text.ID); idx >= 0 {
+			under := c.GetHighlightsForText(idx)
+			c.DeleteText(idx)
+			c.restoreHighlights(under)
+		}
+	}
+	for i := len(g.Boxes) - 1; i >= 0; i-- {
+		if idx := c.BoxIndex(g.Boxes[i].Box.ID); idx >= 0 {
+			under := c.GetHighlightsForBox(idx)
+			c.DeleteBox(idx)
+			c.restoreHighlights(under)
+		}
+	}
+	for _, cell := range g.Highlights {
+		if cell.OldColor >= 0 {
+			c.SetHighlight(cell.X, cell.Y, cell.OldColor)
+		} else {
+			c.ClearHighlight(cell.X, cell.Y)
+		}
+	}
+}
+
+// InsertGroup puts the group's objects back where they were, keeping their
+// IDs, and paints its highlights.
+func (c *Canvas) InsertGroup(g Group) {
+	for _, gb := range g.Boxes {
+		c.InsertBox(gb.Index, cloneBox(gb.Box))
+	}
+	for _, gt := range g.Texts {
+		c.InsertText(gt.Index, cloneText(gt.Text))
+	}
+	for _, conn := range g.Connections {
+		c.RestoreConnection(conn.Clone())
+	}
+	for _, cell := range g.Highlights {
+		c.SetHighlight(cell.X, cell.Y, cell.Color)
+	}
+}
+
+func (c *Canvas) restoreHighlights(cells []HighlightCell) {
+	for _, cell := range cells {
+		c.SetHighlight(cell.X, cell.Y, cell.Color)
+	}
+}
@@ -0,0 +1,110 @@
+package canvas
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func jsonOf(t *testing.T, c *Canvas) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestExtractAndPasteRemapsIDs(t *testing.T) {
+	c := idChart() // boxes A(1), B(2), C(3); one connection A->B
+	c.AddConnection(1, 2)
+	c.SetHighlight(21, 1, 2)
+	c.SetHighlight(90, 90, 3)
+
+	// A and B with the line between them, but not the line from B to C.
+	clip := c.Extract([]int{0, 1}, nil, []int{0, 1}, nil)
+	if len(clip.boxes) != 2 || len(clip.connections) != 1 {
+		t.Fatalf("expected 2 boxes and the A->B line, got %d boxes and %d lines", len(clip.boxes), len(clip.connections))
+	}
+	if clip.boxes[0].X != 0 || clip.boxes[0].Y != 0 {
+		t.Errorf("expected the clip to start at 0,0, got %d,%d", clip.boxes[0].X, clip.boxes[0].Y)
+	}
+	if clip.GetHighlight(1, 1) != 2 || len(clip.highlights) != 1 {
+		t.Errorf("expected only the highlight inside A, got %v", clip.highlights)
+	}
+
+	before := jsonOf(t, c)
+	g := c.Paste(clip, 100, 50)
+	if len(c.boxes) != 5 || len(c.connections) != 3 {
+		t.Fatalf("expected 5 boxes and 3 lines after pasting, got %d and %d", len(c.boxes), len(c.connections))
+	}
+	a, b, conn := c.boxes[3], c.boxes[4], c.connections[2]
+	if a.ID != 4 || b.ID != 5 || a.X != 100 || a.Y != 50 {
+		t.Errorf("expected pasted boxes 4 and 5 at 100,50, got %d@%d,%d and %d", a.ID, a.X, a.Y, b.ID)
+	}
+	if conn.FromID != a.ID || conn.ToID != b.ID || conn.ID != 3 {
+		t.Errorf("expected the pasted line to join the pasted boxes, got %+v", conn)
+	}
+	if c.GetHighlight(101, 51) != 2 {
+		t.Error("the highlight wasn't pasted")
+	}
+
+	c.RemoveGroup(g)
+	if got := jsonOf(t, c); got != before {
+		t.Errorf("removing the pasted group didn't restore the chart:\n%s\nwant\n%s", got, before)
+	}
+}
+
+func TestCutAndInsertGroupRoundTrip(t *testing.T) {
+	c := idChart()
+	c.SetHighlight(41, 9, 1)  // inside B
+	c.SetHighlight(5, 30, 4)  // a loose cell
+	c.SetHighlight(70, 30, 5) // left alone
+	before := jsonOf(t, c)
+
+	g := c.Cut([]int{1}, []int{0}, nil, []Point{{X: 5, Y: 30}})
+	if len(c.boxes) != 2 || len(c.texts) != 0 || len(c.connections) != 0 {
+		t.Fatalf("expected B, the note and B's line to go, got %d boxes, %d texts, %d lines", len(c.boxes), len(c.texts), len(c.connections))
+	}
+	if c.GetHighlight(41, 9) != -1 || c.GetHighlight(5, 30) != -1 || c.GetHighlight(70, 30) != 5 {
+		t.Error("expected the cut highlights to go and the others to stay")
+	}
+
+	c.InsertGroup(g)
+	if got := jsonOf(t, c); got != before {
+		t.Errorf("inserting the cut group didn't restore the chart:\n%s\nwant\n%s", got, before)
+	}
+}
+
+func TestFragmentRoundTrip(t *testing.T) {
+	c := idChart()
+	c.SetHighlight(21, 1, 2)
+	clip := c.Extract([]int{0, 1}, nil, []int{0}, nil)
+
+	text, err := clip.EncodeFragment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawing, _, _ := strings.Cut(text, fragmentBegin)
+	if !strings.Contains(drawing, "A") || !strings.Contains(drawing, "B") {
+		t.Errorf("expected the fragment to start with the drawing, got %q", text)
+	}
+
+	// Mail clients and chat windows indent and wrap what is pasted.
+	indented := "see below:\r\n\t" + strings.ReplaceAll(text, "\n", "\r\n\t") + "thanks\r\n"
+	got, err := DecodeFragment(indented)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jsonOf(t, got) != jsonOf(t, clip) {
+		t.Errorf("fragment didn't round-trip:\n%s\nvs\n%s", jsonOf(t, got), jsonOf(t, clip))
+	}
+
+	if _, err := DecodeFragment("A -> B"); !errors.Is(err, ErrNoFragment) {
+		t.Errorf("expected ErrNoFragment for plain text, got %v", err)
+	}
+	if _, err := DecodeFragment(text[:strings.Index(text, fragmentEnd)]); err == nil || errors.Is(err, ErrNoFragment) {
+		t.Errorf("expected a truncated fragment to fail, got %v", err)
+	}
+}
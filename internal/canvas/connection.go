@@ -1,6 +1,9 @@
 package canvas
 
+// Connection joins two boxes, or a box and a point on another line. FromID
+// and ToID are box IDs, with -1 for an end that sits on a line.
 type Connection struct {
+	ID        int
 	FromID    int
 	ToID      int
 	FromX     int
@@ -11,6 +14,10 @@ type Connection struct {
 	ArrowFrom bool
 	ArrowTo   bool
 	Color     int
+	// Label is drawn over the line, in the middle of its LabelSegment-th
+	// segment or, when that is 0, in the middle of the whole line.
+	Label        string
+	LabelSegment int
 }
 
 func (c *Canvas) FindNearestPointOnConnection(cursorX, cursorY int) (int, int, int) {
@@ -115,7 +122,7 @@ func (c *Canvas) FindNearestEdgePoint(box Box, cursorX, cursorY int) (int, int)
 		edgeY = box.Y + box.Height - 1
 	}
 
-	return edgeX, edgeY
+	return c.shapeAnchor(box, edgeX, edgeY)
 }
 
 func (c *Canvas) CalculateConnectionPoints(fromID, toID int) (fromX, fromY, toX, toY int) {
@@ -170,34 +177,44 @@ func (c *Canvas) calculateConnectionPointsPreservingOrientation(fromID, toID int
 	return fromX, fromY, toX, toY
 }
 
-func (c *Canvas) AddConnection(fromID, toID int) {
-	if fromID >= len(c.boxes) || toID >= len(c.boxes) {
+func (c *Canvas) AddConnection(from, to int) {
+	if from < 0 || from >= len(c.boxes) || to < 0 || to >= len(c.boxes) {
 		return
 	}
 
-	fromX, fromY, toX, toY := c.CalculateConnectionPoints(fromID, toID)
+	fromX, fromY, toX, toY := c.CalculateConnectionPoints(from, to)
 
 	connection := Connection{
-		FromID: fromID,
-		ToID:   toID,
+		ID:     c.nextConnID,
+		FromID: c.boxes[from].ID,
+		ToID:   c.boxes[to].ID,
 		FromX:  fromX,
 		FromY:  fromY,
 		ToX:    toX,
 		ToY:    toY,
 		Color:  -1,
 	}
+	c.nextConnID++
 	c.connections = append(c.connections, connection)
 }
 
-func (c *Canvas) AddConnectionWithWaypoints(fromID, toID, fromX, fromY, toX, toY int, waypoints []Point) {
-	if fromID != -1 && fromID >= len(c.boxes) {
-		return
+// AddConnectionWithWaypoints adds a line between the boxes at indices from
+// and to, either of which may be -1 for an end on another line, and returns
+// the new connection's index (-1 if an index is out of range).
+func (c *Canvas) AddConnectionWithWaypoints(from, to, fromX, fromY, toX, toY int, waypoints []Point) int {
+	if from < -1 || from >= len(c.boxes) || to < -1 || to >= len(c.boxes) {
+		return -1
 	}
-	if toID != -1 && toID >= len(c.boxes) {
-		return
+	fromID, toID := -1, -1
+	if from >= 0 {
+		fromID = c.boxes[from].ID
+	}
+	if to >= 0 {
+		toID = c.boxes[to].ID
 	}
 
 	connection := Connection{
+		ID:        c.nextConnID,
 		FromID:    fromID,
 		ToID:      toID,
 		FromX:     fromX,
@@ -209,13 +226,16 @@ func (c *Canvas) AddConnectionWithWaypoints(fromID, toID, fromX, fromY, toX, toY
 		ArrowTo:   true,
 		Color:     -1,
 	}
+	c.nextConnID++
 	c.connections = append(c.connections, connection)
+	return len(c.connections) - 1
 }
 
+// RemoveSpecificConnection removes the connection with target's ID.
 func (c *Canvas) RemoveSpecificConnection(target Connection) {
 	newConnections := make([]Connection, 0)
 	for _, connection := range c.connections {
-		if !c.connectionsEqual(connection, target) {
+		if connection.ID != target.ID {
 			newConnections = append(newConnections, connection)
 		}
 	}
@@ -241,26 +261,20 @@ func (c *Canvas) CycleConnectionArrowState(connIdx int) {
 	}
 }
 
-func (c *Canvas) connectionsEqual(a, b Connection) bool {
-	if a.FromID != b.FromID || a.ToID != b.ToID {
-		return false
-	}
-	if a.FromX != b.FromX || a.FromY != b.FromY || a.ToX != b.ToX || a.ToY != b.ToY {
-		return false
-	}
-	if len(a.Waypoints) != len(b.Waypoints) {
-		return false
-	}
-	for i := range a.Waypoints {
-		if a.Waypoints[i].X != b.Waypoints[i].X || a.Waypoints[i].Y != b.Waypoints[i].Y {
-			return false
-		}
-	}
-	return true
-}
-
+// RestoreConnection adds a removed connection back, keeping its ID.
 func (c *Canvas) RestoreConnection(connection Connection) {
 	c.connections = append(c.connections, connection)
+	c.nextConnID = max(c.nextConnID, connection.ID+1)
+}
+
+// Clone returns a copy of conn that doesn't share its waypoints.
+func (conn Connection) Clone() Connection {
+	if len(conn.Waypoints) > 0 {
+		conn.Waypoints = append([]Point(nil), conn.Waypoints...)
+	} else {
+		conn.Waypoints = nil
+	}
+	return conn
 }
 
 type connectionPathInfo struct {
@@ -278,17 +292,14 @@ func (c *Canvas) updateBranchConnections(updatedConnections []connectionPathInfo
 					continue
 				}
 				if c.PointWasOnPath(conn.FromX, conn.FromY, updated.points) {
-					updatedConn := c.connections[updated.connIdx]
-					newPoints := []Point{{X: updatedConn.FromX, Y: updatedConn.FromY}}
-					newPoints = append(newPoints, updatedConn.Waypoints...)
-					newPoints = append(newPoints, Point{X: updatedConn.ToX, Y: updatedConn.ToY})
+					newPoints := c.connections[updated.connIdx].drawnPath()
 					newX, newY := c.findNearestPointOnPath(conn.FromX, conn.FromY, newPoints)
 					if newX == conn.FromX && newY == conn.FromY {
 						break
 					}
 					if !adjustEndpointKeepingPath(conn, true, newX-conn.FromX, newY-conn.FromY) {
-						if conn.ToID >= 0 && conn.ToID < len(c.boxes) {
-							toBox := c.boxes[conn.ToID]
+						if to := c.BoxIndex(conn.ToID); to >= 0 {
+							toBox := c.boxes[to]
 							conn.Waypoints = c.createFlexibleWaypointsForLineConnection(conn, nil, &toBox)
 						}
 					}
@@ -304,17 +315,14 @@ func (c *Canvas) updateBranchConnections(updatedConnections []connectionPathInfo
 					continue
 				}
 				if c.PointWasOnPath(conn.ToX, conn.ToY, updated.points) {
-					updatedConn := c.connections[updated.connIdx]
-					newPoints := []Point{{X: updatedConn.FromX, Y: updatedConn.FromY}}
-					newPoints = append(newPoints, updatedConn.Waypoints...)
-					newPoints = append(newPoints, Point{X: updatedConn.ToX, Y: updatedConn.ToY})
+					newPoints := c.connections[updated.connIdx].drawnPath()
 					newX, newY := c.findNearestPointOnPath(conn.ToX, conn.ToY, newPoints)
 					if newX == conn.ToX && newY == conn.ToY {
 						break
 					}
 					if !adjustEndpointKeepingPath(conn, false, newX-conn.ToX, newY-conn.ToY) {
-						if conn.FromID >= 0 && conn.FromID < len(c.boxes) {
-							fromBox := c.boxes[conn.FromID]
+						if from := c.BoxIndex(conn.FromID); from >= 0 {
+							fromBox := c.boxes[from]
 							conn.Waypoints = c.createFlexibleWaypointsForLineConnection(conn, &fromBox, nil)
 						}
 					}
@@ -326,6 +334,43 @@ func (c *Canvas) updateBranchConnections(updatedConnections []connectionPathInfo
 	}
 }
 
+// crossesBoxes reports whether conn is drawn through a box other than the
+// ones it is attached to.
+func (c *Canvas) crossesBoxes(conn Connection) bool {
+	path := conn.drawnPath()
+	for _, box := range c.boxes {
+		if box.ID == conn.FromID || box.ID == conn.ToID {
+			continue
+		}
+		for i := 0; i < len(path)-1; i++ {
+			a, b := path[i], path[i+1]
+			if max(a.X, b.X) >= box.X && min(a.X, b.X) < box.X+box.Width &&
+				max(a.Y, b.Y) >= box.Y && min(a.Y, b.Y) < box.Y+box.Height {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// drawnPath is the connection's corners as drawn: its ends and waypoints,
+// plus the corner implied where two of them aren't in line, which is drawn
+// across first and then up or down.
+func (conn Connection) drawnPath() []Point {
+	pts := []Point{{X: conn.FromX, Y: conn.FromY}}
+	pts = append(pts, conn.Waypoints...)
+	pts = append(pts, Point{X: conn.ToX, Y: conn.ToY})
+	path := pts[:1:1]
+	for _, p := range pts[1:] {
+		prev := path[len(path)-1]
+		if prev.X != p.X && prev.Y != p.Y {
+			path = append(path, Point{X: p.X, Y: prev.Y})
+		}
+		path = append(path, p)
+	}
+	return path
+}
+
 func (c *Canvas) PointWasOnPath(x, y int, pathPoints []Point) bool {
 	for i := 0; i < len(pathPoints)-1; i++ {
 		if c.pointOnSegment(x, y, pathPoints[i].X, pathPoints[i].Y, pathPoints[i+1].X, pathPoints[i+1].Y) {
@@ -389,19 +434,15 @@ func (c *Canvas) rerouteConnectionsForMovedBox(id, deltaX, deltaY int) {
 	for i := range c.connections {
 		conn := &c.connections[i]
 
-		isFromThisBox := conn.FromID == id
-		isToThisBox := conn.ToID == id
+		isFromThisBox := conn.FromID == box.ID
+		isToThisBox := conn.ToID == box.ID
 		if !isFromThisBox && !isToThisBox {
 			continue
 		}
 
-		oldPoints := []Point{{X: conn.FromX, Y: conn.FromY}}
-		oldPoints = append(oldPoints, conn.Waypoints...)
-		oldPoints = append(oldPoints, Point{X: conn.ToX, Y: conn.ToY})
-		updatedConnections = append(updatedConnections, connectionPathInfo{connIdx: i, points: oldPoints})
+		updatedConnections = append(updatedConnections, connectionPathInfo{connIdx: i, points: conn.drawnPath()})
 
-		fromIsValidBox := conn.FromID >= 0 && conn.FromID < len(c.boxes)
-		toIsValidBox := conn.ToID >= 0 && conn.ToID < len(c.boxes)
+		from, to := c.BoxIndex(conn.FromID), c.BoxIndex(conn.ToID)
 
 		regenerate := false
 		if isFromThisBox {
@@ -432,9 +473,16 @@ func (c *Canvas) rerouteConnectionsForMovedBox(id, deltaX, deltaY int) {
 			}
 		}
 
+		// Keeping the old path is only worth it if the box didn't move
+		// into the way of it.
+		if !regenerate && c.crossesBoxes(*conn) {
+			regenerate = true
+		}
 		if regenerate {
-			if fromIsValidBox && toIsValidBox {
-				conn.Waypoints = c.createFlexibleWaypoints(conn, c.boxes[conn.FromID], c.boxes[conn.ToID])
+			if waypoints, ok := c.route(conn); ok {
+				conn.Waypoints = waypoints
+			} else if from >= 0 && to >= 0 {
+				conn.Waypoints = c.createFlexibleWaypoints(conn, c.boxes[from], c.boxes[to])
 			} else if isFromThisBox {
 				conn.Waypoints = c.createFlexibleWaypointsForLineConnection(conn, box, nil)
 			} else {
@@ -554,6 +602,11 @@ func simplifyConnectionPath(conn *Connection) {
 }
 
 func (c *Canvas) findBestAnchorPoint(box Box, targetX, targetY int) (int, int) {
+	x, y := c.edgePointFacing(box, targetX, targetY)
+	return c.shapeAnchor(box, x, y)
+}
+
+func (c *Canvas) edgePointFacing(box Box, targetX, targetY int) (int, int) {
 	boxCenterX := box.X + box.Width/2
 	boxCenterY := box.Y + box.Height/2
 
@@ -882,12 +935,7 @@ func (c *Canvas) GetConnectionEdge(box Box, x, y int) string {
 func (c *Canvas) SnapshotConnections() []Connection {
 	snap := make([]Connection, len(c.connections))
 	for i, conn := range c.connections {
-		snap[i] = conn
-		if len(conn.Waypoints) > 0 {
-			snap[i].Waypoints = append([]Point(nil), conn.Waypoints...)
-		} else {
-			snap[i].Waypoints = nil
-		}
+		snap[i] = conn.Clone()
 	}
 	return snap
 }
@@ -897,57 +945,44 @@ func (c *Canvas) RestoreConnectionsSnapshot(snap []Connection) {
 		return
 	}
 	for i, conn := range snap {
-		c.connections[i] = conn
-		if len(conn.Waypoints) > 0 {
-			c.connections[i].Waypoints = append([]Point(nil), conn.Waypoints...)
-		} else {
-			c.connections[i].Waypoints = nil
-		}
+		c.connections[i] = conn.Clone()
 	}
 }
 
+// RestoreConnections puts back saved copies of connections that still exist,
+// matching them by ID.
 func (c *Canvas) RestoreConnections(connections []Connection) {
 	for _, origConn := range connections {
-
-		for i := range c.connections {
-			if c.connections[i].FromID == origConn.FromID && c.connections[i].ToID == origConn.ToID {
-
-				c.connections[i] = origConn
-				break
-			}
+		if i := c.ConnectionIndex(origConn.ID); i >= 0 {
+			c.connections[i] = origConn
 		}
 	}
 }
 
+// GetConnectionsForBox returns copies of the connections attached to the box
+// at index boxID.
 func (c *Canvas) GetConnectionsForBox(boxID int) []Connection {
+	if boxID < 0 || boxID >= len(c.boxes) {
+		return nil
+	}
+	id := c.boxes[boxID].ID
 	var result []Connection
 	for _, conn := range c.connections {
-		if conn.FromID == boxID || conn.ToID == boxID {
-			connCopy := Connection{
-				FromID:    conn.FromID,
-				ToID:      conn.ToID,
-				FromX:     conn.FromX,
-				FromY:     conn.FromY,
-				ToX:       conn.ToX,
-				ToY:       conn.ToY,
-				ArrowFrom: conn.ArrowFrom,
-				ArrowTo:   conn.ArrowTo,
-				Waypoints: make([]Point, len(conn.Waypoints)),
-				Color:     conn.Color,
-			}
-			copy(connCopy.Waypoints, conn.Waypoints)
-			result = append(result, connCopy)
+		if conn.FromID == id || conn.ToID == id {
+			result = append(result, conn.Clone())
 		}
 	}
 	return result
 }
 
+// isPointInBoxScreen reports whether a screen cell is inside a box other
+// than the ones with IDs excludeFromID and excludeToID.
 func (c *Canvas) isPointInBoxScreen(x, y int, excludeFromID, excludeToID int, panX, panY int) bool {
-	for i, box := range c.boxes {
+	for _, box := range c.boxes {
 		boxScreenX := box.X - panX
 		boxScreenY := box.Y - panY
 		if x > boxScreenX && x < boxScreenX+box.Width-1 && y > boxScreenY && y < boxScreenY+box.Height-1 {
-			if i != excludeFromID && i != excludeToID {
+			if box.ID != excludeFromID && box.ID != excludeToID {
 				return true
 			}
 		}
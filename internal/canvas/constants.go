@@ -9,6 +9,19 @@ const (
 	BorderStyleRounded
 )
 
+// Shape is the outline of a box, after the standard flowchart symbols. It is
+// drawn with the runes of the box's BorderStyle where it can be.
+type Shape int
+
+const (
+	ShapeRectangle     Shape = iota // a process step
+	ShapeDiamond                    // a decision
+	ShapeTerminator                 // start or end
+	ShapeParallelogram              // input or output
+	ShapeDatabase                   // stored data
+	ShapeDocument                   // a document or report
+)
+
 const (
 	minBoxWidth      = 8
 	minBoxHeight     = 3
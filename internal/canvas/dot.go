@@ -0,0 +1,110 @@
+package canvas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DOT positions are in points and sizes in inches. One cell maps to the same
+// 8x16 point cell the SVG exporter uses.
+const dotPointsPerInch = 72.0
+
+func (c *Canvas) ExportToDOT(filename string) error {
+	return writeExportFile(filename, c.WriteDOT)
+}
+
+// WriteDOT writes the chart as a Graphviz digraph. Every node carries a pinned
+// pos hint (its center, y growing downwards as negative values) plus its size,
+// so importing the file again or rendering it with `neato -n` keeps the layout.
+// Boxes with a title become record nodes with the title above the text;
+// other boxes with a flowchart shape get the Graphviz shape closest to it.
+func (c *Canvas) WriteDOT(w io.Writer) error {
+	if len(c.boxes) == 0 {
+		return fmt.Errorf("nothing to export")
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph flerm {\n")
+	fmt.Fprintf(bw, "    node [shape=box, fontname=\"monospace\"];\n")
+	for i, box := range c.boxes {
+		attrs := []string{
+			"label=" + dotQuote(dotBoxLabel(box)),
+			fmt.Sprintf("pos=\"%d,%d!\"", (2*box.X+box.Width)*int(svgCellWidth)/2, -(2*box.Y+box.Height)*int(svgCellHeight)/2),
+			fmt.Sprintf("width=\"%.4g\"", float64(box.Width)*svgCellWidth/dotPointsPerInch),
+			fmt.Sprintf("height=\"%.4g\"", float64(box.Height)*svgCellHeight/dotPointsPerInch),
+		}
+		switch {
+		case box.Title != "" && box.BorderStyle == BorderStyleRounded:
+			attrs = append(attrs, "shape=Mrecord")
+		case box.Title != "":
+			attrs = append(attrs, "shape=record")
+		case box.Shape != ShapeRectangle:
+			attrs = append(attrs, "shape="+dotShapes[box.Shape])
+		case box.BorderStyle == BorderStyleRounded:
+			attrs = append(attrs, "style=rounded")
+		}
+		if box.BorderStyle == BorderStyleDouble {
+			attrs = append(attrs, "peripheries=2")
+		}
+		if box.Color >= 0 && box.Color < NumColors {
+			attrs = append(attrs, fmt.Sprintf("color=%q", hexColor(box.Color)), fmt.Sprintf("fontcolor=%q", hexColor(box.Color)))
+		}
+		fmt.Fprintf(bw, "    %s [%s];\n", graphNodeID(i), strings.Join(attrs, ", "))
+	}
+	for _, e := range c.graphEdges() {
+		dir := "none"
+		switch {
+		case e.ArrowFrom && e.ArrowTo:
+			dir = "both"
+		case e.ArrowTo:
+			dir = "forward"
+		case e.ArrowFrom:
+			dir = "back"
+		}
+		attrs := []string{"dir=" + dir}
+		if e.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(strings.ReplaceAll(e.Label, `\`, `\\`)))
+		}
+		if e.Color >= 0 && e.Color < NumColors {
+			attrs = append(attrs, fmt.Sprintf("color=%q", hexColor(e.Color)))
+		}
+		fmt.Fprintf(bw, "    %s -> %s [%s];\n", graphNodeID(e.From), graphNodeID(e.To), strings.Join(attrs, ", "))
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+var dotShapes = map[Shape]string{
+	ShapeDiamond:       "diamond",
+	ShapeTerminator:    "oval",
+	ShapeParallelogram: "parallelogram",
+	ShapeDatabase:      "cylinder",
+	ShapeDocument:      "note",
+}
+
+// dotBoxLabel returns the label text with DOT line breaks. Titled boxes use
+// record syntax, "{title|text}", with the record metacharacters escaped.
+func dotBoxLabel(box Box) string {
+	escape := strings.NewReplacer(`\`, `\\`)
+	if box.Title != "" {
+		escape = strings.NewReplacer(`\`, `\\`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`)
+	}
+	join := func(s string) string {
+		lines := trimmedLines(s)
+		for i, line := range lines {
+			lines[i] = escape.Replace(line)
+		}
+		return strings.Join(lines, `\n`)
+	}
+	if box.Title == "" {
+		return join(box.GetText())
+	}
+	return "{" + join(box.Title) + "|" + join(box.GetText()) + "}"
+}
+
+// dotQuote quotes s as a DOT string. Backslashes are kept as they are since
+// they already form the label escapes (\n, \{ and so on).
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,597 @@
+package canvas
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseDOT reads a Graphviz graph or digraph and lays it out as boxes and
+// connections. Subgraphs and clusters are flattened; ports and attributes
+// other than node and edge labels, colors, arrow directions, shapes and
+// rounded/double borders are ignored.
+func ParseDOT(r io.Reader) (*Canvas, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	g, err := parseDOTGraph(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return g.canvas(), nil
+}
+
+type dotTokenKind int
+
+const (
+	dotID dotTokenKind = iota
+	dotQuoted
+	dotHTML
+	dotPunct
+	dotEdgeOp
+	dotEOF
+)
+
+type dotToken struct {
+	kind dotTokenKind
+	text string
+	line int
+}
+
+func tokenizeDOT(src string) ([]dotToken, error) {
+	var tokens []dotToken
+	line := 1
+	runes := []rune(src)
+	atLineStart := true
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+			atLineStart = true
+			continue
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '#' && atLineStart:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+				if runes[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i += 2
+			continue
+		}
+		atLineStart = false
+
+		start := i
+		switch {
+		case r == '"':
+			var sb strings.Builder
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+					sb.WriteRune('"')
+					i += 2
+					continue
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '\n' {
+					line++
+					i += 2
+					continue
+				}
+				if runes[i] == '\n' {
+					line++
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			i++
+			tokens = append(tokens, dotToken{kind: dotQuoted, text: sb.String(), line: line})
+		case r == '<':
+			depth := 0
+			for i < len(runes) {
+				if runes[i] == '<' {
+					depth++
+				} else if runes[i] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				} else if runes[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("line %d: unterminated HTML label", line)
+			}
+			i++
+			tokens = append(tokens, dotToken{kind: dotHTML, text: string(runes[start+1 : i-1]), line: line})
+		case r == '-' && i+1 < len(runes) && (runes[i+1] == '>' || runes[i+1] == '-'):
+			i += 2
+			tokens = append(tokens, dotToken{kind: dotEdgeOp, text: string(runes[start:i]), line: line})
+		case strings.ContainsRune("{}[]=;,:", r):
+			i++
+			tokens = append(tokens, dotToken{kind: dotPunct, text: string(r), line: line})
+		case r == '_' || r == '-' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			i++
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, dotToken{kind: dotID, text: string(runes[start:i]), line: line})
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, r)
+		}
+	}
+	return append(tokens, dotToken{kind: dotEOF, line: line}), nil
+}
+
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	g        *importGraph
+	directed bool
+	records  map[int]bool
+}
+
+// dotScope holds the default node and edge attributes of a graph or
+// subgraph body; subgraphs start from a copy of their parent's.
+type dotScope struct {
+	node, edge map[string]string
+}
+
+func (s dotScope) child() dotScope {
+	c := dotScope{node: map[string]string{}, edge: map[string]string{}}
+	for k, v := range s.node {
+		c.node[k] = v
+	}
+	for k, v := range s.edge {
+		c.edge[k] = v
+	}
+	return c
+}
+
+func parseDOTGraph(src string) (*importGraph, error) {
+	tokens, err := tokenizeDOT(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &dotParser{tokens: tokens, g: newImportGraph(), records: map[int]bool{}}
+
+	if p.keyword("strict") {
+		p.pos++
+	}
+	switch {
+	case p.keyword("digraph"):
+		p.directed = true
+	case p.keyword("graph"):
+	default:
+		return nil, p.errorf("expected graph or digraph")
+	}
+	p.pos++
+	if p.peek().kind != dotPunct {
+		p.pos++
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	scope := dotScope{}.child()
+	if _, err := p.stmtList(scope); err != nil {
+		return nil, err
+	}
+	if p.peek().kind != dotEOF {
+		return nil, p.errorf("unexpected %q after graph", p.peek().text)
+	}
+	return p.g, nil
+}
+
+func (p *dotParser) peek() dotToken {
+	return p.tokens[p.pos]
+}
+
+func (p *dotParser) next() dotToken {
+	t := p.tokens[p.pos]
+	if t.kind != dotEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *dotParser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == dotID && strings.EqualFold(t.text, word)
+}
+
+func (p *dotParser) punct(s string) bool {
+	t := p.peek()
+	return t.kind == dotPunct && t.text == s
+}
+
+func (p *dotParser) expect(s string) error {
+	if !p.punct(s) {
+		return p.errorf("expected %q, got %q", s, p.peek().text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *dotParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.peek().line, fmt.Sprintf(format, args...))
+}
+
+func (p *dotParser) isID() bool {
+	k := p.peek().kind
+	return k == dotID || k == dotQuoted || k == dotHTML
+}
+
+// stmtList parses statements up to the closing brace and returns the nodes
+// they mention, which is what an edge to or from a subgraph connects.
+func (p *dotParser) stmtList(scope dotScope) ([]int, error) {
+	var nodes []int
+	for !p.punct("}") {
+		if p.peek().kind == dotEOF {
+			return nil, p.errorf("missing closing brace")
+		}
+		stmtNodes, err := p.stmt(scope)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, stmtNodes...)
+		if p.punct(";") {
+			p.pos++
+		}
+	}
+	p.pos++
+	return nodes, nil
+}
+
+func (p *dotParser) stmt(scope dotScope) ([]int, error) {
+	switch {
+	case p.keyword("graph") || p.keyword("node") || p.keyword("edge"):
+		kind := strings.ToLower(p.next().text)
+		attrs, err := p.attrLists()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "graph":
+			p.graphAttrs(attrs)
+		case "node":
+			for k, v := range attrs {
+				scope.node[k] = v
+			}
+		case "edge":
+			for k, v := range attrs {
+				scope.edge[k] = v
+			}
+		}
+		return nil, nil
+	case p.isID() && p.tokens[p.pos+1].kind == dotPunct && p.tokens[p.pos+1].text == "=":
+		key := p.next().text
+		p.pos++
+		if !p.isID() {
+			return nil, p.errorf("expected a value for %s", key)
+		}
+		p.graphAttrs(map[string]string{strings.ToLower(key): p.next().text})
+		return nil, nil
+	}
+
+	first, isNode, err := p.operand(scope)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != dotEdgeOp {
+		if isNode {
+			attrs, err := p.attrLists()
+			if err != nil {
+				return nil, err
+			}
+			p.applyNodeAttrs(first[0], nil, attrs)
+		}
+		return first, nil
+	}
+
+	groups := [][]int{first}
+	for p.peek().kind == dotEdgeOp {
+		p.pos++
+		group, _, err := p.operand(scope)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	attrs, err := p.attrLists()
+	if err != nil {
+		return nil, err
+	}
+	edgeAttrs := scope.edge
+	if len(attrs) > 0 {
+		edgeAttrs = map[string]string{}
+		for k, v := range scope.edge {
+			edgeAttrs[k] = v
+		}
+		for k, v := range attrs {
+			edgeAttrs[k] = v
+		}
+	}
+	var all []int
+	for i := 0; i+1 < len(groups); i++ {
+		for _, from := range groups[i] {
+			for _, to := range groups[i+1] {
+				p.g.edges = append(p.g.edges, p.edge(from, to, edgeAttrs))
+			}
+		}
+		all = append(all, groups[i]...)
+	}
+	return append(all, groups[len(groups)-1]...), nil
+}
+
+// operand parses a node id (with an optional port) or a subgraph.
+func (p *dotParser) operand(scope dotScope) ([]int, bool, error) {
+	if p.keyword("subgraph") || p.punct("{") {
+		if p.keyword("subgraph") {
+			p.pos++
+			if p.isID() {
+				p.pos++
+			}
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, false, err
+		}
+		nodes, err := p.stmtList(scope.child())
+		return nodes, false, err
+	}
+	if !p.isID() {
+		return nil, false, p.errorf("unexpected %q", p.peek().text)
+	}
+	name := p.next().text
+	_, existed := p.g.index[name]
+	node := p.g.node(name)
+	if !existed {
+		p.applyNodeAttrs(node, scope.node, nil)
+	}
+	for p.punct(":") {
+		p.pos++
+		if !p.isID() {
+			return nil, false, p.errorf("expected a port after ':'")
+		}
+		p.pos++
+	}
+	return []int{node}, true, nil
+}
+
+func (p *dotParser) attrLists() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.punct("[") {
+		p.pos++
+		for !p.punct("]") {
+			if !p.isID() {
+				return nil, p.errorf("expected an attribute name, got %q", p.peek().text)
+			}
+			t := p.next()
+			value := "true"
+			if p.punct("=") {
+				p.pos++
+				if !p.isID() {
+					return nil, p.errorf("expected a value for %s", t.text)
+				}
+				v := p.next()
+				value = v.text
+				if v.kind == dotHTML {
+					value = dotHTMLText(value)
+				}
+			}
+			attrs[strings.ToLower(t.text)] = value
+			if p.punct(",") || p.punct(";") {
+				p.pos++
+			}
+		}
+		p.pos++
+	}
+	return attrs, nil
+}
+
+func (p *dotParser) graphAttrs(attrs map[string]string) {
+	switch strings.ToUpper(attrs["rankdir"]) {
+	case "TB":
+		p.g.dir = LayoutTopDown
+	case "BT":
+		p.g.dir = LayoutBottomUp
+	case "LR":
+		p.g.dir = LayoutLeftRight
+	case "RL":
+		p.g.dir = LayoutRightLeft
+	}
+}
+
+// dotImportShapes maps Graphviz node shapes to the flowchart shapes they
+// draw; any other shape becomes a rectangle.
+var dotImportShapes = map[string]Shape{
+	"diamond":       ShapeDiamond,
+	"oval":          ShapeTerminator,
+	"ellipse":       ShapeTerminator,
+	"parallelogram": ShapeParallelogram,
+	"cylinder":      ShapeDatabase,
+	"note":          ShapeDocument,
+}
+
+// applyNodeAttrs applies the scope defaults and then the node's own
+// attributes; explicit attributes always win. pos, width and height hints
+// (as written by WriteDOT) pin the node instead of leaving it to the layout.
+func (p *dotParser) applyNodeAttrs(node int, defaults, attrs map[string]string) {
+	n := &p.g.nodes[node]
+	label, hasLabel := "", false
+	for _, set := range []map[string]string{defaults, attrs} {
+		if value, ok := set["label"]; ok {
+			label, hasLabel = value, true
+		}
+		if value, ok := set["color"]; ok {
+			n.color = paletteColor(value)
+		} else if value, ok := set["fontcolor"]; ok && n.color < 0 {
+			n.color = paletteColor(value)
+		}
+		if style, ok := set["style"]; ok && strings.Contains(strings.ToLower(style), "rounded") {
+			n.borderStyle = BorderStyleRounded
+		}
+		if shape, ok := set["shape"]; ok {
+			p.records[node] = strings.EqualFold(shape, "record") || strings.EqualFold(shape, "Mrecord")
+			if strings.EqualFold(shape, "Mrecord") {
+				n.borderStyle = BorderStyleRounded
+			}
+			n.shape = dotImportShapes[strings.ToLower(shape)]
+		}
+		if peripheries, ok := set["peripheries"]; ok && peripheries != "0" && peripheries != "1" {
+			n.borderStyle = BorderStyleDouble
+		}
+		if value, ok := set["pos"]; ok {
+			if x, y, ok := parseDOTPoint(value); ok {
+				n.center = [2]float64{x / svgCellWidth, -y / svgCellHeight}
+				n.pinned = true
+			}
+		}
+		if value, ok := set["width"]; ok {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 {
+				n.size.X = int(math.Round(f * dotPointsPerInch / svgCellWidth))
+			}
+		}
+		if value, ok := set["height"]; ok {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 {
+				n.size.Y = int(math.Round(f * dotPointsPerInch / svgCellHeight))
+			}
+		}
+	}
+	if hasLabel {
+		n.title, n.label = "", dotLabelText(label, n.name)
+		if p.records[node] {
+			if fields := dotRecordFields(label); len(fields) > 1 {
+				n.title = dotLabelText(fields[0], n.name)
+				for i, field := range fields[1:] {
+					fields[i+1] = dotLabelText(field, n.name)
+				}
+				n.label = strings.Join(fields[1:], "\n")
+			}
+		}
+	}
+}
+
+// parseDOTPoint reads an "x,y" point, ignoring the trailing '!' that pins it.
+func parseDOTPoint(value string) (float64, float64, bool) {
+	xs, ys, ok := strings.Cut(strings.TrimSuffix(strings.TrimSpace(value), "!"), ",")
+	if !ok {
+		return 0, 0, false
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	return x, y, errX == nil && errY == nil
+}
+
+// dotRecordFields splits a "{a|b|c}" record label into its fields, leaving
+// escapes in place and dropping port names. Other record labels (nested or
+// without braces) return nil.
+func dotRecordFields(label string) []string {
+	label = strings.TrimSpace(label)
+	if !strings.HasPrefix(label, "{") || !strings.HasSuffix(label, "}") || strings.HasSuffix(label, `\}`) {
+		return nil
+	}
+	body := label[1 : len(label)-1]
+	var fields []string
+	start := 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '{', '}':
+			return nil
+		case '|':
+			fields = append(fields, body[start:i])
+			start = i + 1
+		}
+	}
+	fields = append(fields, body[start:])
+	for i, field := range fields {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "<") {
+			if end := strings.Index(field, ">"); end >= 0 {
+				field = strings.TrimSpace(field[end+1:])
+			}
+		}
+		fields[i] = field
+	}
+	return fields
+}
+
+func (p *dotParser) edge(from, to int, attrs map[string]string) importEdge {
+	e := importEdge{from: from, to: to, color: -1}
+	dir := strings.ToLower(attrs["dir"])
+	if dir == "" {
+		dir = "none"
+		if p.directed {
+			dir = "forward"
+		}
+	}
+	switch dir {
+	case "forward":
+		e.arrowTo = true
+	case "back":
+		e.arrowFrom = true
+	case "both":
+		e.arrowFrom, e.arrowTo = true, true
+	}
+	if strings.EqualFold(attrs["arrowhead"], "none") {
+		e.arrowTo = false
+	}
+	if strings.EqualFold(attrs["arrowtail"], "none") {
+		e.arrowFrom = false
+	}
+	if value, ok := attrs["color"]; ok {
+		e.color = paletteColor(strings.Split(value, ":")[0])
+	}
+	if value, ok := attrs["label"]; ok {
+		e.label = strings.ReplaceAll(dotLabelText(value, ""), "\n", " ")
+	}
+	return e
+}
+
+// dotLabelText expands the escapes Graphviz understands in labels: \n, \l
+// and \r end a line, \N is the node's name and a backslash before a record
+// metacharacter makes it literal.
+func dotLabelText(label, name string) string {
+	label = strings.NewReplacer(
+		`\N`, name, `\n`, "\n", `\l`, "\n", `\r`, "\n", `\\`, `\`,
+		`\{`, "{", `\}`, "}", `\|`, "|", `\<`, "<", `\>`, ">",
+	).Replace(label)
+	return strings.TrimRight(label, "\n")
+}
+
+var (
+	dotHTMLBreakRe = regexp.MustCompile(`(?i)<br\s*/?>`)
+	dotHTMLTagRe   = regexp.MustCompile(`<[^>]*>`)
+)
+
+func dotHTMLText(html string) string {
+	text := dotHTMLBreakRe.ReplaceAllString(html, `\n`)
+	text = dotHTMLTagRe.ReplaceAllString(text, "")
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", "\"", "&amp;", "&").Replace(strings.TrimSpace(text))
+}
@@ -0,0 +1,84 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func dotChart() *Canvas {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Start")       // 0
+	c.AddBox(30, 2, "a|b {c}")    // 1
+	c.AddBox(2, 14, "Done\nhere") // 2
+	c.boxes[1].Title = "Check"
+	c.boxes[1].UpdateSize()
+	c.SetBorderStyle(0, BorderStyleRounded)
+	c.SetBorderStyle(2, BorderStyleDouble)
+	c.SetBoxColor(1, 1)
+	c.SetBoxSize(2, 14, 6)
+	c.AddConnection(0, 1)
+	c.connections[0].ArrowTo = true
+	c.SetLineColor(0, 4)
+	c.AddConnection(1, 2)
+	c.connections[1].ArrowFrom = true
+	c.connections[1].ArrowTo = true
+	c.AddConnection(2, 0)
+	return c
+}
+
+func TestDOTExport(t *testing.T) {
+	var buf bytes.Buffer
+	if err := dotChart().WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"digraph flerm {\n",
+		`n0 [label="Start", pos="48,-56!"`,
+		"style=rounded",
+		`n1 [label="{Check|a\|b \{c\}}"`,
+		"shape=record",
+		`color="#cd0000", fontcolor="#cd0000"`,
+		`n2 [label="Done\nhere"`,
+		"peripheries=2",
+		`n0 -> n1 [dir=forward, color="#0000dc"];`,
+		"n1 -> n2 [dir=both];",
+		"n2 -> n0 [dir=none];",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected DOT output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestDOTRoundTripKeepsLayout(t *testing.T) {
+	orig := dotChart()
+	var buf bytes.Buffer
+	if err := orig.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ParseDOT(&buf)
+	if err != nil {
+		t.Fatalf("re-importing exported DOT: %v\n%s", err, buf.String())
+	}
+	if len(c.boxes) != len(orig.boxes) {
+		t.Fatalf("expected %d boxes, got %d", len(orig.boxes), len(c.boxes))
+	}
+	for i, want := range orig.boxes {
+		got := c.boxes[i]
+		if got.X != want.X || got.Y != want.Y || got.Width != want.Width || got.Height != want.Height {
+			t.Errorf("box %d: expected %d,%d %dx%d, got %d,%d %dx%d", i, want.X, want.Y, want.Width, want.Height, got.X, got.Y, got.Width, got.Height)
+		}
+		if got.Title != want.Title || got.GetText() != want.GetText() || got.BorderStyle != want.BorderStyle || got.Color != want.Color {
+			t.Errorf("box %d: expected %q/%q style %v color %d, got %q/%q style %v color %d",
+				i, want.Title, want.GetText(), want.BorderStyle, want.Color, got.Title, got.GetText(), got.BorderStyle, got.Color)
+		}
+	}
+	for i, want := range orig.connections {
+		got := c.connections[i]
+		if got.FromID != want.FromID || got.ToID != want.ToID || got.ArrowFrom != want.ArrowFrom || got.ArrowTo != want.ArrowTo || got.Color != want.Color {
+			t.Errorf("connection %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+}
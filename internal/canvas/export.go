@@ -0,0 +1,174 @@
+package canvas
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ExportFormat struct {
+	Name      string
+	Extension string
+	Write     func(c *Canvas, w io.Writer) error
+}
+
+var exportFormats = []ExportFormat{
+	{Name: "txt", Extension: ".txt", Write: (*Canvas).WriteVisualTXT},
+	{Name: "png", Extension: ".png", Write: (*Canvas).WritePNG},
+	{Name: "svg", Extension: ".svg", Write: (*Canvas).WriteSVG},
+	{Name: "ansi", Extension: ".ans", Write: (*Canvas).WriteANSI},
+	{Name: "html", Extension: ".html", Write: (*Canvas).WriteHTML},
+	{Name: "mermaid", Extension: ".mmd", Write: (*Canvas).WriteMermaid},
+	{Name: "dot", Extension: ".dot", Write: (*Canvas).WriteDOT},
+	{Name: "plantuml", Extension: ".puml", Write: (*Canvas).WritePlantUML},
+}
+
+func ExportFormats() []ExportFormat {
+	return append([]ExportFormat(nil), exportFormats...)
+}
+
+func LookupExportFormat(name string) (ExportFormat, bool) {
+	name = strings.TrimPrefix(strings.ToLower(name), ".")
+	for _, f := range exportFormats {
+		if f.Name == name || strings.TrimPrefix(f.Extension, ".") == name {
+			return f, true
+		}
+	}
+	return ExportFormat{}, false
+}
+
+func ExportFormatForFile(filename string) (ExportFormat, bool) {
+	lower := strings.ToLower(filepath.Base(filename))
+	for _, f := range exportFormats {
+		if strings.HasSuffix(lower, f.Extension) {
+			return f, true
+		}
+	}
+	return ExportFormat{}, false
+}
+
+func (c *Canvas) Export(filename string, format ExportFormat) error {
+	return writeExportFile(filename, func(w io.Writer) error {
+		return format.Write(c, w)
+	})
+}
+
+func writeExportFile(filename string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+func (c *Canvas) RenderExport(padding int) (*RenderResult, int, int, error) {
+	minX, minY, maxX, maxY := c.GetFullBounds()
+	if minX > maxX || minY > maxY {
+		return nil, 0, 0, fmt.Errorf("nothing to export")
+	}
+
+	minX -= padding
+	minY -= padding
+	if minX < 0 {
+		minX = 0
+	}
+	if minY < 0 {
+		minY = 0
+	}
+
+	width := maxX - minX + padding + 1
+	height := maxY - minY + padding + 1
+
+	rr := c.RenderRaw(width, height, -1, -1, -1, nil, -1, -1, minX, minY, -1, -1, false, -1, -1, 0, "", -1, -1, -1, -1, -1, -1, false, -1, -1)
+	return rr, minX, minY, nil
+}
+
+func (c *Canvas) WriteVisualTXT(w io.Writer) error {
+	rr, _, _, err := c.RenderExport(1)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, row := range rr.Canvas {
+		bw.WriteString(strings.TrimRight(RowText(row), " "))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func (c *Canvas) ExportToVisualTXT(filename string) error {
+	return writeExportFile(filename, c.WriteVisualTXT)
+}
+
+func (c *Canvas) WriteANSI(w io.Writer) error {
+	rr, _, _, err := c.RenderExport(1)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	for _, line := range rr.ApplyColors() {
+		bw.WriteString(strings.TrimRight(line, " "))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func (c *Canvas) ExportToANSI(filename string) error {
+	return writeExportFile(filename, c.WriteANSI)
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>flerm chart</title>
+<style>
+pre.flerm { font-family: "DejaVu Sans Mono", Menlo, Consolas, monospace; line-height: 1.2; }
+</style>
+</head>
+<body>
+<pre class="flerm">
+`
+
+const htmlFooter = `</pre>
+</body>
+</html>
+`
+
+func (c *Canvas) WriteHTML(w io.Writer) error {
+	rr, _, _, err := c.RenderExport(1)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(htmlHeader)
+	for i := range rr.Canvas {
+		runs := rr.Runs(i)
+		if n := len(runs); n > 0 && runs[n-1].Color == -1 {
+			runs[n-1].Text = strings.TrimRight(runs[n-1].Text, " ")
+		}
+		for _, run := range runs {
+			text := html.EscapeString(run.Text)
+			switch {
+			case run.Color == -1:
+				bw.WriteString(text)
+			case run.Background:
+				fmt.Fprintf(bw, `<span style="background-color:%s">%s</span>`, hexColor(run.Color), text)
+			default:
+				fmt.Fprintf(bw, `<span style="color:%s">%s</span>`, hexColor(run.Color), text)
+			}
+		}
+		bw.WriteByte('\n')
+	}
+	bw.WriteString(htmlFooter)
+	return bw.Flush()
+}
+
+func (c *Canvas) ExportToHTML(filename string) error {
+	return writeExportFile(filename, c.WriteHTML)
+}
@@ -0,0 +1,54 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func coloredChart() *Canvas {
+	c := NewCanvas()
+	c.AddBox(2, 2, "<ok>")
+	c.SetBoxColor(0, 1)
+	c.AddText(2, 7, "plain")
+	c.SetHighlight(9, 6, 2)
+	return c
+}
+
+func TestANSIExportKeepsColors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := coloredChart().WriteANSI(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, getTextColorCode(1)+"+") {
+		t.Fatalf("expected red box border escape, got %q", out)
+	}
+	if !strings.Contains(out, getColorCode(2)+" "+colorReset) {
+		t.Fatalf("expected green highlight background escape, got %q", out)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasSuffix(line, " ") {
+			t.Fatalf("expected trailing blanks trimmed, got %q", line)
+		}
+	}
+}
+
+func TestHTMLExportUsesSpans(t *testing.T) {
+	var buf bytes.Buffer
+	if err := coloredChart().WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<pre class=\"flerm\">",
+		"&lt;ok&gt;",
+		`<span style="color:#cd0000">+`,
+		`<span style="background-color:#00a000"> </span>`,
+		"plain",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+}
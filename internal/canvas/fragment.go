@@ -0,0 +1,63 @@
+package canvas
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+const (
+	fragmentBegin = "-----BEGIN FLERM FRAGMENT-----"
+	fragmentEnd   = "-----END FLERM FRAGMENT-----"
+)
+
+// ErrNoFragment is returned by DecodeFragment for text that doesn't hold a
+// fragment.
+var ErrNoFragment = errors.New("no flerm fragment")
+
+// EncodeFragment turns a copied selection into text for the system
+// clipboard: its Visual TXT rendering, for pasting into other programs,
+// followed by the chart in the JSON format between marker lines, so a flerm
+// session can get the objects back with DecodeFragment.
+func (c *Canvas) EncodeFragment() (string, error) {
+	var b strings.Builder
+	// Highlights alone have no rendering; the JSON still carries them.
+	var txt bytes.Buffer
+	if err := c.WriteVisualTXT(&txt); err == nil {
+		b.Write(txt.Bytes())
+		b.WriteString("\n")
+	}
+	var chart bytes.Buffer
+	if err := c.WriteJSON(&chart, nil); err != nil {
+		return "", err
+	}
+	b.WriteString(fragmentBegin + "\n")
+	b.Write(chart.Bytes())
+	if !strings.HasSuffix(chart.String(), "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(fragmentEnd + "\n")
+	return b.String(), nil
+}
+
+// DecodeFragment finds a fragment written by EncodeFragment in text and
+// returns the canvas it holds. Anything around the marker lines is ignored,
+// as is indentation added by whatever the text passed through. Text without
+// a fragment gives ErrNoFragment.
+func DecodeFragment(text string) (*Canvas, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	start := strings.Index(text, fragmentBegin)
+	if start < 0 {
+		return nil, ErrNoFragment
+	}
+	body := text[start+len(fragmentBegin):]
+	end := strings.Index(body, fragmentEnd)
+	if end < 0 {
+		return nil, errors.New("flerm fragment is cut short")
+	}
+	res, err := Decode("clipboard", []byte(body[:end]), LoadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Canvas, nil
+}
@@ -0,0 +1,94 @@
+package canvas
+
+import (
+	"fmt"
+	"strings"
+)
+
+// graphEdge is a connection reduced to the two boxes it joins, which is what
+// the diagram-language exporters (Mermaid, DOT, PlantUML) need.
+type graphEdge struct {
+	From, To           int
+	ArrowFrom, ArrowTo bool
+	Color              int
+	Label              string
+}
+
+// graphEdges resolves every connection to a box-to-box edge. Connections that
+// start or end on another line are followed back through that line to the box
+// it comes from (for the start) or goes to (for the end). Connections that
+// never reach a box are dropped.
+func (c *Canvas) graphEdges() []graphEdge {
+	edges := make([]graphEdge, 0, len(c.connections))
+	for i, conn := range c.connections {
+		from := c.resolveEndpointBox(i, true, map[int]bool{})
+		to := c.resolveEndpointBox(i, false, map[int]bool{})
+		if from < 0 || to < 0 || from == to {
+			continue
+		}
+		edges = append(edges, graphEdge{
+			From:      from,
+			To:        to,
+			ArrowFrom: conn.ArrowFrom,
+			ArrowTo:   conn.ArrowTo,
+			Color:     conn.Color,
+			Label:     conn.Label,
+		})
+	}
+	return edges
+}
+
+func (c *Canvas) resolveEndpointBox(connIdx int, fromEnd bool, seen map[int]bool) int {
+	if seen[connIdx] {
+		return -1
+	}
+	seen[connIdx] = true
+	conn := c.connections[connIdx]
+	id, x, y := conn.ToID, conn.ToX, conn.ToY
+	if fromEnd {
+		id, x, y = conn.FromID, conn.FromX, conn.FromY
+	}
+	if id >= 0 {
+		return c.BoxIndex(id)
+	}
+	for j, host := range c.connections {
+		if j == connIdx || seen[j] {
+			continue
+		}
+		if c.PointWasOnPath(x, y, connectionPoints(host)) {
+			return c.resolveEndpointBox(j, fromEnd, seen)
+		}
+	}
+	return -1
+}
+
+func connectionPoints(conn Connection) []Point {
+	points := []Point{{X: conn.FromX, Y: conn.FromY}}
+	points = append(points, conn.Waypoints...)
+	return append(points, Point{X: conn.ToX, Y: conn.ToY})
+}
+
+// boxLabelLines returns a box's title lines followed by its text lines, with
+// blank lines dropped.
+func boxLabelLines(box Box) []string {
+	var lines []string
+	if box.Title != "" {
+		lines = trimmedLines(box.Title)
+	}
+	return append(lines, trimmedLines(box.GetText())...)
+}
+
+// trimmedLines splits s into lines, trimming each and dropping blank ones.
+func trimmedLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func graphNodeID(index int) string {
+	return fmt.Sprintf("n%d", index)
+}
@@ -2,19 +2,29 @@ package canvas
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
+// highlight is the color of a highlighted cell and the ID of the box it was
+// painted on, or -1, so the cell can go where that box goes.
+type highlight struct {
+	color int
+	box   int
+}
+
+// SetHighlight paints the cell at x, y, which then belongs to the box under
+// it, if any.
 func (c *Canvas) SetHighlight(x, y int, colorIndex int) {
 	if colorIndex < 0 || colorIndex >= NumColors {
 		return
 	}
-	c.highlights[fmt.Sprintf("%d,%d", x, y)] = colorIndex
+	c.highlights[fmt.Sprintf("%d,%d", x, y)] = highlight{color: colorIndex, box: c.BoxID(c.GetBoxAt(x, y))}
 }
 
 func (c *Canvas) GetHighlight(x, y int) int {
-	if color, ok := c.highlights[fmt.Sprintf("%d,%d", x, y)]; ok {
-		return color
+	if h, ok := c.highlights[fmt.Sprintf("%d,%d", x, y)]; ok {
+		return h.color
 	}
 	return -1
 }
@@ -23,6 +33,24 @@ func (c *Canvas) ClearHighlight(x, y int) {
 	delete(c.highlights, fmt.Sprintf("%d,%d", x, y))
 }
 
+// sortedHighlights returns the highlighted cells ordered by row, then column,
+// so anything written from them comes out the same on every run.
+func (c *Canvas) sortedHighlights() []HighlightCell {
+	cells := make([]HighlightCell, 0, len(c.highlights))
+	for key, h := range c.highlights {
+		var x, y int
+		fmt.Sscanf(key, "%d,%d", &x, &y)
+		cells = append(cells, HighlightCell{X: x, Y: y, Color: h.color})
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
 func (c *Canvas) GetBoxCells(boxID int) []Point {
 	if boxID < 0 || boxID >= len(c.boxes) {
 		return nil
@@ -124,7 +152,7 @@ func (c *Canvas) GetBoxTitleTextCells(boxID int) []Point {
 	for lineIdx, line := range titleLines {
 		titleY := box.Y + 1 + lineIdx
 
-		for i := 0; i < len(line) && i < box.Width-2; i++ {
+		for i := 0; i < StringWidth(line) && i < box.Width-2; i++ {
 			cells = append(cells, Point{X: box.X + 1 + i, Y: titleY})
 		}
 	}
@@ -149,7 +177,7 @@ func (c *Canvas) GetBoxContentTextCells(boxID int) []Point {
 	for lineIdx, line := range box.Lines {
 		lineY := contentStartY + lineIdx
 
-		for i := 0; i < len(line) && i < box.Width-2; i++ {
+		for i := 0; i < StringWidth(line) && i < box.Width-2; i++ {
 			cells = append(cells, Point{X: box.X + 1 + i, Y: lineY})
 		}
 	}
@@ -165,7 +193,7 @@ func (c *Canvas) GetTextCells(textID int) []Point {
 	cells := make([]Point, 0)
 	for lineIdx, line := range text.Lines {
 		lineY := text.Y + lineIdx
-		for x := text.X; x < text.X+len(line); x++ {
+		for x := text.X; x < text.X+StringWidth(line); x++ {
 			cells = append(cells, Point{X: x, Y: lineY})
 		}
 	}
@@ -176,15 +204,28 @@ func (c *Canvas) highlightsForCells(cells []Point) []HighlightCell {
 	highlights := make([]HighlightCell, 0)
 	for _, cell := range cells {
 		key := fmt.Sprintf("%d,%d", cell.X, cell.Y)
-		if colorIndex, exists := c.highlights[key]; exists {
-			highlights = append(highlights, HighlightCell{X: cell.X, Y: cell.Y, Color: colorIndex, HadColor: true, OldColor: colorIndex})
+		if h, exists := c.highlights[key]; exists {
+			highlights = append(highlights, HighlightCell{X: cell.X, Y: cell.Y, Color: h.color, HadColor: true, OldColor: h.color})
 		}
 	}
 	return highlights
 }
 
+// GetHighlightsForBox returns the cells painted on the box at boxID, by row,
+// then column.
 func (c *Canvas) GetHighlightsForBox(boxID int) []HighlightCell {
-	return c.highlightsForCells(c.GetBoxCells(boxID))
+	highlights := make([]HighlightCell, 0)
+	id := c.BoxID(boxID)
+	if id < 0 {
+		return highlights
+	}
+	for _, cell := range c.sortedHighlights() {
+		if c.highlights[fmt.Sprintf("%d,%d", cell.X, cell.Y)].box == id {
+			cell.HadColor, cell.OldColor = true, cell.Color
+			highlights = append(highlights, cell)
+		}
+	}
+	return highlights
 }
 
 func (c *Canvas) GetHighlightsForText(textID int) []HighlightCell {
@@ -205,7 +246,7 @@ func (c *Canvas) GetBoxContentHighlights(boxID int) map[int]int {
 
 	lines := strings.Split(text, "\n")
 
-	for key, colorIndex := range c.highlights {
+	for key, h := range c.highlights {
 		var wx, wy int
 		fmt.Sscanf(key, "%d,%d", &wx, &wy)
 
@@ -215,7 +256,8 @@ func (c *Canvas) GetBoxContentHighlights(boxID int) map[int]int {
 		if relRow < 0 || relRow >= len(lines) {
 			continue
 		}
-		if relCol < 0 || relCol >= len(lines[relRow]) {
+		col := byteAtColumn(lines[relRow], relCol)
+		if col < 0 {
 			continue
 		}
 
@@ -223,19 +265,33 @@ func (c *Canvas) GetBoxContentHighlights(boxID int) map[int]int {
 		for i := 0; i < relRow; i++ {
 			charIndex += len(lines[i]) + 1
 		}
-		charIndex += relCol
+		charIndex += col
 
-		result[charIndex] = colorIndex
+		result[charIndex] = h.color
 	}
 
 	return result
 }
 
 func (c *Canvas) deleteHighlightsForBox(boxID int) {
-	cells := c.GetBoxCells(boxID)
+	for _, cell := range c.GetHighlightsForBox(boxID) {
+		c.ClearHighlight(cell.X, cell.Y)
+	}
+}
+
+// moveBoxHighlights moves the cells painted on the box at boxID by deltaX,
+// deltaY, all at once so that none lands on one not yet moved.
+func (c *Canvas) moveBoxHighlights(boxID, deltaX, deltaY int) {
+	if deltaX == 0 && deltaY == 0 {
+		return
+	}
+	cells := c.GetHighlightsForBox(boxID)
 	for _, cell := range cells {
-		key := fmt.Sprintf("%d,%d", cell.X, cell.Y)
-		delete(c.highlights, key)
+		c.ClearHighlight(cell.X, cell.Y)
+	}
+	id := c.BoxID(boxID)
+	for _, cell := range cells {
+		c.highlights[fmt.Sprintf("%d,%d", cell.X+deltaX, cell.Y+deltaY)] = highlight{color: cell.Color, box: id}
 	}
 }
 
@@ -0,0 +1,107 @@
+package canvas
+
+// BoxID returns the ID of the box at index, or -1 if index is out of range.
+func (c *Canvas) BoxID(index int) int {
+	if index < 0 || index >= len(c.boxes) {
+		return -1
+	}
+	return c.boxes[index].ID
+}
+
+// TextID returns the ID of the text at index, or -1.
+func (c *Canvas) TextID(index int) int {
+	if index < 0 || index >= len(c.texts) {
+		return -1
+	}
+	return c.texts[index].ID
+}
+
+// ConnectionID returns the ID of the connection at index, or -1.
+func (c *Canvas) ConnectionID(index int) int {
+	if index < 0 || index >= len(c.connections) {
+		return -1
+	}
+	return c.connections[index].ID
+}
+
+// BoxIndex returns the index of the box with the given ID, or -1 if there is
+// no such box.
+func (c *Canvas) BoxIndex(id int) int {
+	if id < 0 {
+		return -1
+	}
+	for i := range c.boxes {
+		if c.boxes[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// TextIndex returns the index of the text with the given ID, or -1.
+func (c *Canvas) TextIndex(id int) int {
+	if id < 0 {
+		return -1
+	}
+	for i := range c.texts {
+		if c.texts[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ConnectionIndex returns the index of the connection with the given ID, or
+// -1.
+func (c *Canvas) ConnectionIndex(id int) int {
+	if id < 0 {
+		return -1
+	}
+	for i := range c.connections {
+		if c.connections[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// assignIDs makes every box, text and connection ID unique and non-negative
+// after a load, renumbering the ones that aren't, and moves the counters past
+// the highest ID in use.
+func (c *Canvas) assignIDs() {
+	c.nextBoxID, c.nextTextID, c.nextConnID = 0, 0, 0
+	for _, box := range c.boxes {
+		c.nextBoxID = max(c.nextBoxID, box.ID+1)
+	}
+	for _, text := range c.texts {
+		c.nextTextID = max(c.nextTextID, text.ID+1)
+	}
+	for _, conn := range c.connections {
+		c.nextConnID = max(c.nextConnID, conn.ID+1)
+	}
+
+	seen := map[int]bool{}
+	for i := range c.boxes {
+		if id := c.boxes[i].ID; id < 0 || seen[id] {
+			c.boxes[i].ID = c.nextBoxID
+			c.nextBoxID++
+		}
+		seen[c.boxes[i].ID] = true
+	}
+	seen = map[int]bool{}
+	for i := range c.texts {
+		if id := c.texts[i].ID; id < 0 || seen[id] {
+			c.texts[i].ID = c.nextTextID
+			c.nextTextID++
+		}
+		seen[c.texts[i].ID] = true
+	}
+	seen = map[int]bool{}
+	for i := range c.connections {
+		if id := c.connections[i].ID; id < 0 || seen[id] {
+			c.connections[i].ID = c.nextConnID
+			c.nextConnID++
+		}
+		seen[c.connections[i].ID] = true
+	}
+}
@@ -0,0 +1,135 @@
+package canvas
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// idChart has had its first box and text deleted, so IDs no longer match
+// indices.
+func idChart() *Canvas {
+	c := NewCanvas()
+	c.AddBox(0, 0, "Gone")
+	c.AddBox(20, 0, "A")
+	c.AddBox(40, 8, "B")
+	c.AddConnection(0, 1)
+	c.AddConnection(1, 2)
+	c.AddText(2, 12, "gone")
+	c.AddText(2, 14, "note")
+	c.DeleteBox(0)
+	c.DeleteText(0)
+	c.AddBox(60, 0, "C")
+	return c
+}
+
+func TestDeleteKeepsIDs(t *testing.T) {
+	c := idChart()
+	if got := []int{c.boxes[0].ID, c.boxes[1].ID, c.boxes[2].ID}; !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("expected box IDs [1 2 3], got %v", got)
+	}
+	if len(c.connections) != 1 {
+		t.Fatalf("expected the connection to the deleted box to go, got %+v", c.connections)
+	}
+	if conn := c.connections[0]; conn.ID != 1 || conn.FromID != 1 || conn.ToID != 2 {
+		t.Errorf("expected connection 1 from box 1 to box 2, got %+v", conn)
+	}
+	if c.texts[0].ID != 1 {
+		t.Errorf("expected text ID 1, got %d", c.texts[0].ID)
+	}
+	if c.BoxIndex(2) != 1 || c.BoxIndex(0) != -1 || c.TextIndex(1) != 0 || c.ConnectionIndex(1) != 0 {
+		t.Errorf("unexpected index lookups")
+	}
+
+	// Moving a box still drags the connection held by ID.
+	before := c.connections[0].ToX
+	c.MoveBox(1, 4, 0)
+	if c.connections[0].ToX == before {
+		t.Errorf("expected the connection to follow box B")
+	}
+}
+
+func TestIDsSurviveReload(t *testing.T) {
+	for _, name := range []string{"chart.sav", "chart" + JSONExtension} {
+		t.Run(name, func(t *testing.T) {
+			c := idChart()
+			path := filepath.Join(t.TempDir(), name)
+			if err := c.SaveToFile(path); err != nil {
+				t.Fatal(err)
+			}
+			loaded := NewCanvas()
+			if err := loaded.LoadFromFile(path); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(loaded.boxes, c.boxes) {
+				t.Errorf("boxes changed:\n got %+v\nwant %+v", loaded.boxes, c.boxes)
+			}
+			if !reflect.DeepEqual(loaded.connections, c.connections) {
+				t.Errorf("connections changed:\n got %+v\nwant %+v", loaded.connections, c.connections)
+			}
+			if !reflect.DeepEqual(loaded.texts, c.texts) {
+				t.Errorf("texts changed:\n got %+v\nwant %+v", loaded.texts, c.texts)
+			}
+			loaded.AddBox(0, 20, "D")
+			if id := loaded.boxes[3].ID; id != 4 {
+				t.Errorf("expected a new box to get ID 4, got %d", id)
+			}
+		})
+	}
+}
+
+func TestHighlightsBelongToTheirBox(t *testing.T) {
+	c := idChart()
+	c.SetHighlight(21, 1, 2) // on A
+	c.SetHighlight(41, 9, 3) // on B
+	c.SetHighlight(0, 20, 4) // on nothing
+	if got := c.GetHighlightsForBox(1); len(got) != 1 || got[0].X != 41 || got[0].Color != 3 {
+		t.Fatalf("expected B's one highlight, got %+v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "chart.sav")
+	if err := c.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewCanvas()
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.highlights, c.highlights) {
+		t.Fatalf("highlights changed:\n got %+v\nwant %+v", loaded.highlights, c.highlights)
+	}
+
+	c.DeleteBox(0)
+	if c.GetHighlight(21, 1) != -1 || c.GetHighlight(41, 9) != 3 || c.GetHighlight(0, 20) != 4 {
+		t.Errorf("expected only A's highlight to go with it, got %+v", c.highlights)
+	}
+	if got := c.GetHighlightsForBox(0); len(got) != 1 || got[0].X != 41 {
+		t.Errorf("expected B to keep its highlight, got %+v", got)
+	}
+}
+
+func TestReadJSONVersion1JoinsByIndex(t *testing.T) {
+	res, err := Decode("v1.json", []byte(`{"version": 1,
+		"boxes": [
+			{"x": 0, "y": 0, "width": 8, "height": 3, "borderStyle": "ascii", "text": "A", "color": -1},
+			{"x": 20, "y": 0, "width": 8, "height": 3, "borderStyle": "ascii", "text": "B", "color": -1}
+		],
+		"connections": [{"from": 1, "to": 0, "fromPoint": {"x": 20, "y": 1}, "toPoint": {"x": 7, "y": 1}, "color": -1}]}`), LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := res.Canvas
+	if c.boxes[1].ID != 1 || c.connections[0].FromID != 1 || c.connections[0].ToID != 0 {
+		t.Errorf("expected index IDs, got boxes %+v connections %+v", c.boxes, c.connections)
+	}
+
+	res, err = Decode("v2.json", []byte(`{"version": 2,
+		"boxes": [{"id": 5, "x": 0, "y": 0, "borderStyle": "ascii", "text": "A"}],
+		"connections": [{"id": 0, "from": 5, "to": 6}]}`), LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 1 || res.Canvas.connections[0].ToID != -1 {
+		t.Errorf("expected the end on a missing box to come loose with a warning, got %v %+v", res.Warnings, res.Canvas.connections)
+	}
+}
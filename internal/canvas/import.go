@@ -0,0 +1,219 @@
+package canvas
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type ImportFormat struct {
+	Name       string
+	Extensions []string
+	Parse      func(r io.Reader) (*Canvas, error)
+}
+
+var importFormats = []ImportFormat{
+	{Name: "mermaid", Extensions: []string{".mmd", ".mermaid"}, Parse: ParseMermaid},
+	{Name: "dot", Extensions: []string{".dot", ".gv"}, Parse: ParseDOT},
+}
+
+func ImportFormats() []ImportFormat {
+	return append([]ImportFormat(nil), importFormats...)
+}
+
+// ImportFormatForFile picks an import format from a filename's extension.
+func ImportFormatForFile(filename string) (ImportFormat, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, format := range importFormats {
+		for _, e := range format.Extensions {
+			if e == ext {
+				return format, true
+			}
+		}
+	}
+	return ImportFormat{}, false
+}
+
+func ImportFile(filename string, format ImportFormat) (*Canvas, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := format.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// importGraph is what the diagram-language parsers produce before it is
+// turned into boxes and connections.
+type importGraph struct {
+	nodes []importNode
+	index map[string]int
+	edges []importEdge
+	dir   LayoutDirection
+}
+
+type importNode struct {
+	name        string
+	label       string
+	title       string
+	borderStyle BorderStyle
+	shape       Shape
+	color       int
+	// size and center (in cells) come from position hints; a zero size
+	// means fit the label.
+	size   Point
+	center [2]float64
+	pinned bool
+}
+
+type importEdge struct {
+	from, to           int
+	arrowFrom, arrowTo bool
+	color              int
+	label              string
+}
+
+func newImportGraph() *importGraph {
+	return &importGraph{index: map[string]int{}}
+}
+
+// node returns the index of the named node, adding it on first use.
+func (g *importGraph) node(name string) int {
+	if i, ok := g.index[name]; ok {
+		return i
+	}
+	g.index[name] = len(g.nodes)
+	g.nodes = append(g.nodes, importNode{name: name, label: name, color: -1})
+	return len(g.nodes) - 1
+}
+
+// canvas lays the graph out in ranks along its direction, unless every node
+// carries a position hint, and connects the boxes so each line is routed the
+// same way as one drawn in the editor.
+func (g *importGraph) canvas() *Canvas {
+	c := NewCanvas()
+	sizes := make([]Point, len(g.nodes))
+	pinned := len(g.nodes) > 0
+	for i, node := range g.nodes {
+		c.AddBox(0, 0, node.label)
+		box := &c.boxes[i]
+		box.BorderStyle = node.borderStyle
+		box.Shape = node.shape
+		box.Color = node.color
+		if node.title != "" {
+			box.Title = node.title
+			box.UpdateSize()
+		}
+		if node.size.X > 0 {
+			box.Width = max(node.size.X, minBoxWidth)
+		}
+		if node.size.Y > 0 {
+			box.Height = max(node.size.Y, minBoxHeight)
+		}
+		sizes[i] = Point{X: box.Width, Y: box.Height}
+		pinned = pinned && node.pinned
+	}
+
+	if pinned {
+		for i, node := range g.nodes {
+			x := int(math.Round(node.center[0] - float64(sizes[i].X)/2))
+			y := int(math.Round(node.center[1] - float64(sizes[i].Y)/2))
+			c.SetBoxPositionOnly(i, x, y)
+		}
+	} else {
+		edges := make([][2]int, len(g.edges))
+		for i, e := range g.edges {
+			edges[i] = [2]int{e.from, e.to}
+		}
+		for i, pos := range layeredLayout(sizes, edges, g.dir) {
+			c.SetBoxPositionOnly(i, pos.X+importMarginX, pos.Y+importMarginY)
+		}
+	}
+
+	for _, e := range g.edges {
+		if e.from == e.to {
+			continue
+		}
+		c.AddConnection(e.from, e.to)
+		conn := &c.connections[len(c.connections)-1]
+		conn.FromX, conn.FromY, conn.ToX, conn.ToY = c.calculateConnectionPointsPreservingOrientation(e.from, e.to, g.dir.Horizontal())
+		conn.ArrowFrom, conn.ArrowTo = e.arrowFrom, e.arrowTo
+		conn.Color = e.color
+		conn.Label = e.label
+		if waypoints, ok := c.route(conn); ok {
+			conn.Waypoints = waypoints
+		} else {
+			conn.Waypoints = c.createFlexibleWaypoints(conn, c.boxes[e.from], c.boxes[e.to])
+		}
+		simplifyConnectionPath(conn)
+	}
+	return c
+}
+
+const (
+	importMarginX = 2
+	importMarginY = 1
+)
+
+var namedColors = map[string]color.RGBA{
+	"black":   {0, 0, 0, 255},
+	"gray":    {128, 128, 128, 255},
+	"grey":    {128, 128, 128, 255},
+	"red":     {255, 0, 0, 255},
+	"green":   {0, 128, 0, 255},
+	"yellow":  {255, 255, 0, 255},
+	"orange":  {255, 165, 0, 255},
+	"blue":    {0, 0, 255, 255},
+	"magenta": {255, 0, 255, 255},
+	"purple":  {128, 0, 128, 255},
+	"cyan":    {0, 255, 255, 255},
+	"teal":    {0, 128, 128, 255},
+	"white":   {255, 255, 255, 255},
+}
+
+// paletteColor maps a CSS-style color (#rgb, #rrggbb or a common name) to the
+// nearest entry in the chart palette. Black and anything unrecognised map to
+// -1 so the object keeps the default color.
+func paletteColor(value string) int {
+	value = strings.ToLower(strings.TrimSpace(value))
+	rgb, ok := namedColors[value]
+	if !ok {
+		hex := strings.TrimPrefix(value, "#")
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if !strings.HasPrefix(value, "#") || len(hex) < 6 {
+			return -1
+		}
+		n, err := strconv.ParseUint(hex[:6], 16, 32)
+		if err != nil {
+			return -1
+		}
+		rgb = color.RGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 255}
+	}
+
+	best, bestDist := -1, colorDistance(rgb, color.RGBA{0, 0, 0, 255})
+	for i := 0; i < NumColors; i++ {
+		if d := colorDistance(rgb, pngColor(i)); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func colorDistance(a color.RGBA, b color.Color) int {
+	r, g, bl, _ := b.RGBA()
+	dr := int(a.R) - int(r>>8)
+	dg := int(a.G) - int(g>>8)
+	db := int(a.B) - int(bl>>8)
+	return dr*dr + dg*dg + db*db
+}
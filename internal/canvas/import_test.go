@@ -0,0 +1,194 @@
+package canvas
+
+import (
+	"strings"
+	"testing"
+)
+
+func boxesOverlap(a, b Box) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width && a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+func assertNoOverlaps(t *testing.T, c *Canvas) {
+	t.Helper()
+	for i := range c.boxes {
+		for j := i + 1; j < len(c.boxes); j++ {
+			if boxesOverlap(c.boxes[i], c.boxes[j]) {
+				t.Errorf("boxes %d and %d overlap: %+v %+v", i, j, c.boxes[i], c.boxes[j])
+			}
+		}
+	}
+}
+
+func TestParseMermaid(t *testing.T) {
+	src := `flowchart TD
+    %% a comment
+    A[Start] --> B{Is it ok?}
+    B -- Yes --> C("Great<br/>job") & D[[Fix it]]
+    D -.-> B
+    C --- E:::warn; E <--> F
+    classDef warn stroke:#f00
+    style A stroke:blue
+    linkStyle 0 stroke:#00a000
+`
+	c, err := ParseMermaid(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.boxes) != 6 {
+		t.Fatalf("expected 6 boxes, got %d", len(c.boxes))
+	}
+	if got := c.boxes[2].GetText(); got != "Great\njob" {
+		t.Errorf("expected <br/> to split the label, got %q", got)
+	}
+	if c.boxes[2].BorderStyle != BorderStyleRounded || c.boxes[3].BorderStyle != BorderStyleDouble {
+		t.Errorf("expected rounded and double shapes, got %v and %v", c.boxes[2].BorderStyle, c.boxes[3].BorderStyle)
+	}
+	if c.boxes[0].Color != 4 || c.boxes[4].Color != 1 {
+		t.Errorf("expected style and class colors, got %d and %d", c.boxes[0].Color, c.boxes[4].Color)
+	}
+
+	type edge struct {
+		from, to           int
+		arrowFrom, arrowTo bool
+	}
+	want := []edge{{0, 1, false, true}, {1, 2, false, true}, {1, 3, false, true}, {3, 1, false, true}, {2, 4, false, false}, {4, 5, true, true}}
+	if len(c.connections) != len(want) {
+		t.Fatalf("expected %d connections, got %d", len(want), len(c.connections))
+	}
+	for i, w := range want {
+		conn := c.connections[i]
+		if got := (edge{conn.FromID, conn.ToID, conn.ArrowFrom, conn.ArrowTo}); got != w {
+			t.Errorf("connection %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	if c.connections[0].Color != 2 {
+		t.Errorf("expected linkStyle color on the first edge, got %d", c.connections[0].Color)
+	}
+
+	// Ranks follow the edges downwards.
+	if !(c.boxes[0].Y < c.boxes[1].Y && c.boxes[1].Y < c.boxes[2].Y && c.boxes[2].Y < c.boxes[4].Y) {
+		t.Errorf("expected boxes ranked top to bottom, got y=%d,%d,%d,%d", c.boxes[0].Y, c.boxes[1].Y, c.boxes[2].Y, c.boxes[4].Y)
+	}
+	if c.boxes[2].Y != c.boxes[3].Y {
+		t.Errorf("expected siblings on the same rank, got y=%d and %d", c.boxes[2].Y, c.boxes[3].Y)
+	}
+	assertNoOverlaps(t, c)
+}
+
+func TestParseMermaidLeftToRightInMarkdown(t *testing.T) {
+	src := "# Docs\n\n```mermaid\ngraph LR\n  a --> b\n  a --> c\n```\n\ntrailing text\n"
+	c, err := ParseMermaid(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.boxes) != 3 {
+		t.Fatalf("expected 3 boxes, got %d", len(c.boxes))
+	}
+	if !(c.boxes[0].X+c.boxes[0].Width < c.boxes[1].X && c.boxes[1].X == c.boxes[2].X) {
+		t.Errorf("expected a left-to-right layout, got %+v", c.boxes)
+	}
+	conn := c.connections[0]
+	if conn.FromX != c.boxes[0].X+c.boxes[0].Width-1 || conn.ToX != c.boxes[1].X {
+		t.Errorf("expected the edge to leave the right side and enter the left side, got %+v", conn)
+	}
+	assertNoOverlaps(t, c)
+}
+
+func TestParseMermaidErrors(t *testing.T) {
+	for _, tc := range []struct{ src, want string }{
+		{"sequenceDiagram\nA->>B: hi\n", "line 1: expected a graph or flowchart header"},
+		{"graph TD\n  A --> B\n  A[unclosed\n", "line 3: missing \"]\""},
+		{"", "no graph or flowchart header"},
+	} {
+		_, err := ParseMermaid(strings.NewReader(tc.src))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("expected error containing %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestParseDOT(t *testing.T) {
+	src := `// services
+digraph G {
+    rankdir = LR;
+    node [shape=box, color=red];
+    api [label="API\nGateway", style="rounded,filled"];
+    db [label=<Users<br/>DB>, peripheries=2, color="#0000ff"];
+    api -> auth -> db [color=green];
+    cache -> api [dir=back];
+    subgraph cluster_workers { w1; w2 }
+    db -> { w1 w2 } [arrowhead=none];
+}
+`
+	c, err := ParseDOT(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"API\nGateway", "Users\nDB", "auth", "cache", "w1", "w2"}
+	if len(c.boxes) != len(names) {
+		t.Fatalf("expected %d boxes, got %d", len(names), len(c.boxes))
+	}
+	for i, name := range names {
+		if got := c.boxes[i].GetText(); got != name {
+			t.Errorf("box %d: expected %q, got %q", i, name, got)
+		}
+	}
+	if c.boxes[0].BorderStyle != BorderStyleRounded || c.boxes[1].BorderStyle != BorderStyleDouble {
+		t.Errorf("expected rounded and double borders, got %v and %v", c.boxes[0].BorderStyle, c.boxes[1].BorderStyle)
+	}
+	if c.boxes[0].Color != 1 || c.boxes[1].Color != 4 || c.boxes[2].Color != 1 {
+		t.Errorf("expected node colors from defaults and attributes, got %d %d %d", c.boxes[0].Color, c.boxes[1].Color, c.boxes[2].Color)
+	}
+	if len(c.connections) != 5 {
+		t.Fatalf("expected 5 connections, got %d", len(c.connections))
+	}
+	if conn := c.connections[1]; conn.FromID != 2 || conn.ToID != 1 || conn.Color != 2 || !conn.ArrowTo {
+		t.Errorf("expected auth -> db in green with an arrow, got %+v", conn)
+	}
+	if conn := c.connections[2]; conn.FromID != 3 || conn.ToID != 0 || !conn.ArrowFrom || conn.ArrowTo {
+		t.Errorf("expected dir=back to put the arrow at the start, got %+v", conn)
+	}
+	if conn := c.connections[4]; conn.FromID != 1 || conn.ToID != 5 || conn.ArrowTo {
+		t.Errorf("expected db -> w2 without an arrowhead, got %+v", conn)
+	}
+	if !(c.boxes[3].X < c.boxes[0].X && c.boxes[0].X < c.boxes[2].X && c.boxes[2].X < c.boxes[1].X) {
+		t.Errorf("expected cache, api, auth, db ranked left to right, got x=%d,%d,%d,%d", c.boxes[3].X, c.boxes[0].X, c.boxes[2].X, c.boxes[1].X)
+	}
+	assertNoOverlaps(t, c)
+}
+
+func TestParseDOTUndirectedAndErrors(t *testing.T) {
+	c, err := ParseDOT(strings.NewReader("strict graph { a -- b -- c }"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.connections) != 2 || c.connections[0].ArrowTo || c.connections[0].ArrowFrom {
+		t.Fatalf("expected two plain lines, got %+v", c.connections)
+	}
+
+	_, err = ParseDOT(strings.NewReader("digraph {\n  a -> b\n  c -> [x=1]\n}"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected an error on line 3, got %v", err)
+	}
+}
+
+func TestLayeredLayoutHandlesCycles(t *testing.T) {
+	sizes := []Point{{8, 3}, {8, 3}, {8, 3}}
+	pos := layeredLayout(sizes, [][2]int{{0, 1}, {1, 2}, {2, 0}}, LayoutTopDown)
+	if !(pos[0].Y < pos[1].Y && pos[1].Y < pos[2].Y) {
+		t.Fatalf("expected the cycle to be broken into three ranks, got %+v", pos)
+	}
+}
+
+func TestImportFormatForFile(t *testing.T) {
+	for name, want := range map[string]string{"a.mmd": "mermaid", "b.MERMAID": "mermaid", "c.dot": "dot", "d.gv": "dot"} {
+		format, ok := ImportFormatForFile(name)
+		if !ok || format.Name != want {
+			t.Errorf("%s: expected %s, got %q (%v)", name, want, format.Name, ok)
+		}
+	}
+	if _, ok := ImportFormatForFile("chart.sav"); ok {
+		t.Error("expected .sav not to be an import format")
+	}
+}
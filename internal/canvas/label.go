@@ -0,0 +1,128 @@
+package canvas
+
+// A connection's label is drawn over the line with a space of padding on
+// either side, centered on the middle of the segment it was put on or of the
+// whole line. Its place is worked out from the path each time, so it follows
+// the line when it is moved or rerouted; a segment that no longer exists
+// falls back to the last one.
+
+// SetConnectionLabel sets the label of the connection at index i.
+func (c *Canvas) SetConnectionLabel(i int, label string) {
+	if i >= 0 && i < len(c.connections) {
+		c.connections[i].Label = label
+	}
+}
+
+// SetConnectionLabelSegment puts the label of the connection at index i on
+// its segment-th segment, counting from 1 at the start, or in the middle of
+// the line for 0.
+func (c *Canvas) SetConnectionLabelSegment(i, segment int) {
+	if i >= 0 && i < len(c.connections) {
+		c.connections[i].LabelSegment = max(segment, 0)
+	}
+}
+
+// ConnectionSegmentAt returns the segment of the connection at index i that
+// passes closest to (x, y), counting from 1 at the start, or 0 if there is
+// no such connection.
+func (c *Canvas) ConnectionSegmentAt(i, x, y int) int {
+	if i < 0 || i >= len(c.connections) {
+		return 0
+	}
+	path := c.connections[i].drawnPath()
+	best, bestDist := 0, -1
+	for k := 0; k+1 < len(path); k++ {
+		px, py := c.findClosestPointOnSegment(path[k].X, path[k].Y, path[k+1].X, path[k+1].Y, x, y)
+		if dist := abs(px-x) + abs(py-y); bestDist < 0 || dist < bestDist {
+			best, bestDist = k+1, dist
+		}
+	}
+	return best
+}
+
+// labelText is the label as drawn, padded, or "" for a line without one.
+func (conn Connection) labelText() string {
+	if conn.Label == "" {
+		return ""
+	}
+	return " " + conn.Label + " "
+}
+
+// labelCenter is the cell of the path the label is centered on.
+func (conn Connection) labelCenter() Point {
+	path := conn.drawnPath()
+	if len(path) < 2 {
+		return path[0]
+	}
+	if conn.LabelSegment > 0 {
+		k := min(conn.LabelSegment, len(path)-1)
+		a, b := path[k-1], path[k]
+		return Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+	}
+
+	length := 0
+	for k := 1; k < len(path); k++ {
+		length += abs(path[k].X-path[k-1].X) + abs(path[k].Y-path[k-1].Y)
+	}
+	left := length / 2
+	for k := 1; k < len(path); k++ {
+		a, b := path[k-1], path[k]
+		step := abs(b.X-a.X) + abs(b.Y-a.Y)
+		if left <= step {
+			return Point{X: a.X + sign(b.X-a.X)*left, Y: a.Y + sign(b.Y-a.Y)*left}
+		}
+		left -= step
+	}
+	return path[len(path)-1]
+}
+
+// labelStart is the leftmost cell of the drawn label.
+func (conn Connection) labelStart() Point {
+	center := conn.labelCenter()
+	return Point{X: center.X - StringWidth(conn.labelText())/2, Y: center.Y}
+}
+
+// GetConnectionLabelCells returns the cells the label of the connection at
+// index i covers, padding included.
+func (c *Canvas) GetConnectionLabelCells(i int) []Point {
+	if i < 0 || i >= len(c.connections) {
+		return nil
+	}
+	return c.connections[i].labelCells()
+}
+
+func (conn Connection) labelCells() []Point {
+	width := StringWidth(conn.labelText())
+	if width == 0 {
+		return nil
+	}
+	start := conn.labelStart()
+	cells := make([]Point, width)
+	for k := range cells {
+		cells[k] = Point{X: start.X + k, Y: start.Y}
+	}
+	return cells
+}
+
+func (c *Canvas) drawConnectionLabelWithPan(canvas [][]rune, conn Connection, panX, panY int) {
+	text := conn.labelText()
+	if text == "" {
+		return
+	}
+	start := conn.labelStart()
+	y := start.Y - panY
+	if y < 0 || y >= len(canvas) {
+		return
+	}
+	putText(canvas[y], start.X-panX, len(canvas[y]), text)
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
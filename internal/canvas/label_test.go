@@ -0,0 +1,120 @@
+package canvas
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLabelIsDrawnOverTheLine(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "A")
+	c.AddBox(40, 0, "B")
+	c.AddConnection(0, 1)
+	c.SetConnectionLabel(0, "yes")
+
+	var buf bytes.Buffer
+	if err := c.WriteVisualTXT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	row := strings.Split(buf.String(), "\n")[1]
+	if !strings.Contains(row, "─ yes ─") {
+		t.Errorf("expected the padded label on the line, got %q", row)
+	}
+
+	// Moving a box moves the label with the line.
+	c.MoveBox(1, 0, 6)
+	cells := c.GetConnectionLabelCells(0)
+	path := c.connections[0].drawnPath()
+	center := cells[len(cells)/2]
+	if !onPath(path, center) {
+		t.Errorf("expected the label centered on the moved line %v, got %v", path, cells)
+	}
+}
+
+func TestLabelOnChosenSegment(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "A")
+	c.AddBox(40, 10, "B")
+	c.AddConnection(0, 1)
+	conn := &c.connections[0]
+	conn.FromX, conn.FromY, conn.ToX, conn.ToY = 7, 1, 40, 11
+	conn.Waypoints = []Point{{X: 20, Y: 1}, {X: 20, Y: 11}}
+	c.SetConnectionLabel(0, "no")
+
+	if got := c.ConnectionSegmentAt(0, 21, 5); got != 2 {
+		t.Fatalf("expected the vertical segment 2 near (21,5), got %d", got)
+	}
+	c.SetConnectionLabelSegment(0, 3)
+	if got := c.connections[0].labelCenter(); got != (Point{X: 30, Y: 11}) {
+		t.Errorf("expected the label in the middle of the last segment, got %v", got)
+	}
+
+	// A segment the line no longer has falls back to the last one.
+	conn.Waypoints = []Point{{X: 20, Y: 1}}
+	conn.ToX, conn.ToY = 20, 11
+	if got := c.connections[0].labelCenter(); got != (Point{X: 20, Y: 6}) {
+		t.Errorf("expected the label on the last segment, got %v", got)
+	}
+}
+
+func TestLabelsSurviveSAVAndGraphFormats(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Ok?")
+	c.AddBox(2, 12, "Ship")
+	c.AddConnection(0, 1)
+	c.SetConnectionLabel(0, "yes, 100%")
+	c.SetConnectionLabelSegment(0, 1)
+
+	sav := filepath.Join(t.TempDir(), "chart.sav")
+	if err := c.SaveToFile(sav); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewCanvas()
+	if err := loaded.LoadFromFile(sav); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.connections[0]; got.Label != "yes, 100%" || got.LabelSegment != 1 {
+		t.Errorf("expected the label back from .sav, got %q on segment %d", got.Label, got.LabelSegment)
+	}
+
+	var mermaid, dot, plantuml bytes.Buffer
+	if err := c.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WritePlantUML(&plantuml); err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string]string{"Mermaid": mermaid.String(), "DOT": dot.String(), "PlantUML": plantuml.String()} {
+		if !strings.Contains(got, "yes, 100%") {
+			t.Errorf("expected %s to contain the label, got:\n%s", name, got)
+		}
+	}
+
+	for name, parse := range map[string]func() (*Canvas, error){
+		"Mermaid": func() (*Canvas, error) { return ParseMermaid(&mermaid) },
+		"DOT":     func() (*Canvas, error) { return ParseDOT(&dot) },
+	} {
+		got, err := parse()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.connections[0].Label != "yes, 100%" {
+			t.Errorf("%s: expected the label back, got %q", name, got.connections[0].Label)
+		}
+	}
+}
+
+func onPath(path []Point, p Point) bool {
+	for k := 0; k+1 < len(path); k++ {
+		a, b := path[k], path[k+1]
+		if min(a.X, b.X) <= p.X && p.X <= max(a.X, b.X) && min(a.Y, b.Y) <= p.Y && p.Y <= max(a.Y, b.Y) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,404 @@
+package canvas
+
+import "sort"
+
+type LayoutDirection int
+
+const (
+	LayoutTopDown LayoutDirection = iota
+	LayoutBottomUp
+	LayoutLeftRight
+	LayoutRightLeft
+)
+
+func (d LayoutDirection) Horizontal() bool {
+	return d == LayoutLeftRight || d == LayoutRightLeft
+}
+
+const (
+	layoutRankGapRows = 3
+	layoutRankGapCols = 8
+	layoutNodeGapRows = 2
+	layoutNodeGapCols = 4
+)
+
+// layeredLayout ranks nodes along dir by their edges (longest path from the
+// sources, with cycles broken), orders each rank to reduce crossings and
+// returns the top-left corner of every node. sizes holds width and height as
+// X and Y. The result starts at (0,0).
+func layeredLayout(sizes []Point, edges [][2]int, dir LayoutDirection) []Point {
+	n := len(sizes)
+	if n == 0 {
+		return nil
+	}
+	main := func(p Point) int {
+		if dir.Horizontal() {
+			return p.X
+		}
+		return p.Y
+	}
+	cross := func(p Point) int {
+		if dir.Horizontal() {
+			return p.Y
+		}
+		return p.X
+	}
+	rankGap, nodeGap := layoutRankGapRows, layoutNodeGapCols
+	if dir.Horizontal() {
+		rankGap, nodeGap = layoutRankGapCols, layoutNodeGapRows
+	}
+
+	preds, succs := acyclicAdjacency(n, edges)
+	rank := longestPathRanks(n, preds, succs)
+
+	maxRank := 0
+	for _, r := range rank {
+		maxRank = max(maxRank, r)
+	}
+	ranks := make([][]int, maxRank+1)
+	for v := 0; v < n; v++ {
+		ranks[rank[v]] = append(ranks[rank[v]], v)
+	}
+	orderRanks(ranks, preds, succs)
+
+	mainPos := make([]int, n)
+	offset := 0
+	for _, nodes := range ranks {
+		depth := 0
+		for _, v := range nodes {
+			mainPos[v] = offset
+			depth = max(depth, main(sizes[v]))
+		}
+		offset += depth + rankGap
+	}
+	totalMain := offset - rankGap
+
+	crossPos := make([]int, n)
+	placed := make([]bool, n)
+	minCross := 0
+	for r, nodes := range ranks {
+		nextFree := 0
+		for i, v := range nodes {
+			size := cross(sizes[v])
+			pos := nextFree
+			sum, count := 0, 0
+			for _, u := range preds[v] {
+				if placed[u] {
+					sum += crossPos[u] + cross(sizes[u])/2
+					count++
+				}
+			}
+			if count > 0 {
+				desired := sum/count - size/2
+				if i == 0 && r > 0 {
+					pos = desired
+				} else {
+					pos = max(desired, nextFree)
+				}
+			}
+			crossPos[v] = pos
+			placed[v] = true
+			nextFree = pos + size + nodeGap
+			minCross = min(minCross, pos)
+		}
+	}
+
+	result := make([]Point, n)
+	for v := 0; v < n; v++ {
+		m := mainPos[v]
+		if dir == LayoutBottomUp || dir == LayoutRightLeft {
+			m = totalMain - m - main(sizes[v])
+		}
+		c := crossPos[v] - minCross
+		if dir.Horizontal() {
+			result[v] = Point{X: m, Y: c}
+		} else {
+			result[v] = Point{X: c, Y: m}
+		}
+	}
+	return result
+}
+
+// acyclicAdjacency builds predecessor and successor lists, reversing the
+// edges that close a cycle (found by depth-first search in node order) and
+// dropping self-loops.
+func acyclicAdjacency(n int, edges [][2]int) ([][]int, [][]int) {
+	out := make([][]int, n)
+	for _, e := range edges {
+		if e[0] != e[1] && e[0] >= 0 && e[0] < n && e[1] >= 0 && e[1] < n {
+			out[e[0]] = append(out[e[0]], e[1])
+		}
+	}
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make([]int, n)
+	preds := make([][]int, n)
+	succs := make([][]int, n)
+	var visit func(v int)
+	visit = func(v int) {
+		state[v] = onStack
+		for _, w := range out[v] {
+			if state[w] == onStack {
+				succs[w] = append(succs[w], v)
+				preds[v] = append(preds[v], w)
+				continue
+			}
+			succs[v] = append(succs[v], w)
+			preds[w] = append(preds[w], v)
+			if state[w] == unvisited {
+				visit(w)
+			}
+		}
+		state[v] = done
+	}
+	for v := 0; v < n; v++ {
+		if state[v] == unvisited {
+			visit(v)
+		}
+	}
+	return preds, succs
+}
+
+func longestPathRanks(n int, preds, succs [][]int) []int {
+	rank := make([]int, n)
+	indegree := make([]int, n)
+	for v := 0; v < n; v++ {
+		indegree[v] = len(preds[v])
+	}
+	queue := make([]int, 0, n)
+	for v := 0; v < n; v++ {
+		if indegree[v] == 0 {
+			queue = append(queue, v)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range succs[v] {
+			rank[w] = max(rank[w], rank[v]+1)
+			indegree[w]--
+			if indegree[w] == 0 {
+				queue = append(queue, w)
+			}
+		}
+	}
+	return rank
+}
+
+// orderRanks runs a few barycenter sweeps down and up the ranks so nodes sit
+// near the nodes they connect to, which keeps edge crossings down.
+func orderRanks(ranks [][]int, preds, succs [][]int) {
+	index := map[int]int{}
+	reindex := func(nodes []int) {
+		for i, v := range nodes {
+			index[v] = i
+		}
+	}
+	for _, nodes := range ranks {
+		reindex(nodes)
+	}
+	sweep := func(nodes []int, neighbors [][]int) {
+		key := make(map[int]float64, len(nodes))
+		for _, v := range nodes {
+			key[v] = float64(index[v])
+			if len(neighbors[v]) > 0 {
+				sum := 0
+				for _, u := range neighbors[v] {
+					sum += index[u]
+				}
+				key[v] = float64(sum) / float64(len(neighbors[v]))
+			}
+		}
+		sort.SliceStable(nodes, func(a, b int) bool { return key[nodes[a]] < key[nodes[b]] })
+		reindex(nodes)
+	}
+	for iter := 0; iter < 4; iter++ {
+		for r := 1; r < len(ranks); r++ {
+			sweep(ranks[r], preds)
+		}
+		for r := len(ranks) - 2; r >= 0; r-- {
+			sweep(ranks[r], succs)
+		}
+	}
+}
+
+// Arrange lays out the boxes at the given indices, or every box when there
+// are none, in ranks along dir by the lines between them. The group keeps
+// its top-left corner, and every line attached to one of its boxes is
+// rerouted. It reports whether any box moved.
+func (c *Canvas) Arrange(boxes []int, dir LayoutDirection) bool {
+	indices := sortedIndices(boxes, len(c.boxes))
+	if len(boxes) == 0 {
+		for i := range c.boxes {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) == 0 {
+		return false
+	}
+
+	member := make(map[int]int, len(indices))
+	sizes := make([]Point, len(indices))
+	originX, originY := c.boxes[indices[0]].X, c.boxes[indices[0]].Y
+	for k, i := range indices {
+		box := c.boxes[i]
+		member[box.ID] = k
+		sizes[k] = Point{X: box.Width, Y: box.Height}
+		originX, originY = min(originX, box.X), min(originY, box.Y)
+	}
+	var edges [][2]int
+	for _, conn := range c.connections {
+		from, okFrom := member[conn.FromID]
+		to, okTo := member[conn.ToID]
+		if okFrom && okTo {
+			edges = append(edges, [2]int{from, to})
+		}
+	}
+
+	positions := layeredLayout(sizes, edges, dir)
+	for k := range positions {
+		positions[k].X += originX
+		positions[k].Y += originY
+	}
+	return c.placeArranged(indices, positions, dir)
+}
+
+// placeArranged moves the boxes at indices, with the highlights painted on
+// them, to positions and, if any of them moved, reroutes the lines attached
+// to them. It reports whether any did.
+func (c *Canvas) placeArranged(indices []int, positions []Point, dir LayoutDirection) bool {
+	deltas := make(map[int]Point, len(indices))
+	moved := false
+	for k, i := range indices {
+		oldX, oldY := c.boxes[i].X, c.boxes[i].Y
+		c.SetBoxPosition(i, positions[k].X, positions[k].Y)
+		delta := Point{X: c.boxes[i].X - oldX, Y: c.boxes[i].Y - oldY}
+		deltas[c.boxes[i].ID] = delta
+		moved = moved || delta != Point{}
+	}
+	if !moved {
+		return false
+	}
+	c.rerouteArranged(deltas, dir)
+	return true
+}
+
+// rerouteArranged redraws every line attached to an arranged box; deltas
+// maps the IDs of those boxes to how far they moved. Lines between two of
+// them leave and enter along dir, like an imported chart's, unless the two
+// boxes are side by side in one rank. The old paths are dropped before any
+// is routed, so they don't count as crossings.
+func (c *Canvas) rerouteArranged(deltas map[int]Point, dir LayoutDirection) {
+	var updatedConnections []connectionPathInfo
+	var rerouted, between []int
+	for i := range c.connections {
+		conn := &c.connections[i]
+		fromDelta, fromArranged := deltas[conn.FromID]
+		toDelta, toArranged := deltas[conn.ToID]
+		if !fromArranged && !toArranged {
+			continue
+		}
+		updatedConnections = append(updatedConnections, connectionPathInfo{connIdx: i, points: conn.drawnPath()})
+		if fromArranged && toArranged {
+			from, to := c.BoxIndex(conn.FromID), c.BoxIndex(conn.ToID)
+			horizontal := dir.Horizontal()
+			if sameRank(c.boxes[from], c.boxes[to], dir) {
+				horizontal = !horizontal
+			}
+			conn.FromX, conn.FromY, conn.ToX, conn.ToY = c.calculateConnectionPointsPreservingOrientation(from, to, horizontal)
+			between = append(between, i)
+		} else if fromArranged {
+			conn.FromX, conn.FromY = conn.FromX+fromDelta.X, conn.FromY+fromDelta.Y
+		} else {
+			conn.ToX, conn.ToY = conn.ToX+toDelta.X, conn.ToY+toDelta.Y
+		}
+		conn.Waypoints = nil
+		rerouted = append(rerouted, i)
+	}
+	c.spreadPorts(between)
+
+	for _, i := range rerouted {
+		c.rerouteConnection(i)
+	}
+
+	c.updateBranchConnections(updatedConnections)
+}
+
+// sameRank reports whether a and b overlap along dir, so that a line
+// between them can't run along it.
+func sameRank(a, b Box, dir LayoutDirection) bool {
+	if dir.Horizontal() {
+		return a.X < b.X+b.Width && b.X < a.X+a.Width
+	}
+	return a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+// spreadPorts moves apart the ends of the given lines that meet on the same
+// side of a box, in the order of where their other ends are, so they reach
+// the box side by side instead of merging into one. Lines into a diamond
+// all stay on its tip.
+func (c *Canvas) spreadPorts(conns []int) {
+	type port struct {
+		conn  int
+		from  bool
+		other int
+	}
+	type side struct {
+		box  int
+		edge string
+	}
+	sides := map[side][]port{}
+	var order []side
+	for _, i := range conns {
+		conn := c.connections[i]
+		for _, from := range []bool{true, false} {
+			id, x, y, otherX, otherY := conn.ToID, conn.ToX, conn.ToY, conn.FromX, conn.FromY
+			if from {
+				id, x, y, otherX, otherY = conn.FromID, conn.FromX, conn.FromY, conn.ToX, conn.ToY
+			}
+			b := c.BoxIndex(id)
+			if b < 0 || c.boxes[b].Shape == ShapeDiamond {
+				continue
+			}
+			s := side{box: b, edge: c.GetConnectionEdge(c.boxes[b], x, y)}
+			other := otherX
+			if s.edge == "left" || s.edge == "right" {
+				other = otherY
+			}
+			if _, ok := sides[s]; !ok {
+				order = append(order, s)
+			}
+			sides[s] = append(sides[s], port{conn: i, from: from, other: other})
+		}
+	}
+
+	for _, s := range order {
+		ports := sides[s]
+		if len(ports) < 2 {
+			continue
+		}
+		sort.SliceStable(ports, func(a, b int) bool { return ports[a].other < ports[b].other })
+		box := c.boxes[s.box]
+		vertical := s.edge == "left" || s.edge == "right"
+		span := box.Width - 2
+		if vertical {
+			span = box.Height - 2
+		}
+		for k, p := range ports {
+			offset := 1 + (2*k+1)*span/(2*len(ports))
+			conn := &c.connections[p.conn]
+			x, y := &conn.ToX, &conn.ToY
+			if p.from {
+				x, y = &conn.FromX, &conn.FromY
+			}
+			if vertical {
+				*y = box.Y + offset
+			} else {
+				*x = box.X + offset
+			}
+		}
+	}
+}
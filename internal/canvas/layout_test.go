@@ -0,0 +1,72 @@
+package canvas
+
+import "testing"
+
+// tangledChain is A -> B -> C with D -> C, scattered out of order.
+func tangledChain() *Canvas {
+	c := NewCanvas()
+	c.AddBox(30, 20, "A")
+	c.AddBox(4, 30, "B")
+	c.AddBox(50, 2, "C")
+	c.AddBox(10, 6, "D")
+	c.AddConnection(0, 1)
+	c.AddConnection(1, 2)
+	c.AddConnection(3, 2)
+	return c
+}
+
+func TestArrangeRanksBoxesAlongLines(t *testing.T) {
+	for _, dir := range []LayoutDirection{LayoutTopDown, LayoutLeftRight} {
+		c := tangledChain()
+		if !c.Arrange(nil, dir) {
+			t.Fatal("expected the boxes to move")
+		}
+		a, b, cc, d := c.boxes[0], c.boxes[1], c.boxes[2], c.boxes[3]
+		if minX, minY := min(a.X, b.X, cc.X, d.X), min(a.Y, b.Y, cc.Y, d.Y); minX != 4 || minY != 2 {
+			t.Errorf("dir %d: expected the chart to keep its corner at (4,2), got (%d,%d)", dir, minX, minY)
+		}
+		after := func(p, q Box) bool {
+			if dir.Horizontal() {
+				return q.X >= p.X+p.Width
+			}
+			return q.Y >= p.Y+p.Height
+		}
+		if !after(a, b) || !after(b, cc) || !after(d, cc) {
+			t.Errorf("dir %d: expected every line to run forwards, got A=%v B=%v C=%v D=%v", dir, a, b, cc, d)
+		}
+		for i, conn := range c.connections {
+			if c.crossesBoxes(conn) {
+				t.Errorf("dir %d: line %d crosses a box: %+v", dir, i, conn)
+			}
+			from, to := c.BoxIndex(conn.FromID), c.BoxIndex(conn.ToID)
+			if c.GetConnectionEdge(c.boxes[from], conn.FromX, conn.FromY) == "unknown" || c.GetConnectionEdge(c.boxes[to], conn.ToX, conn.ToY) == "unknown" {
+				t.Errorf("dir %d: line %d came loose from its boxes: %+v", dir, i, conn)
+			}
+		}
+		// B and D both lead into the top of C, which is wide enough for
+		// them to arrive side by side.
+		if !dir.Horizontal() && c.connections[1].ToX == c.connections[2].ToX {
+			t.Errorf("expected the lines into C apart, both end at x=%d", c.connections[1].ToX)
+		}
+		if c.Arrange(nil, dir) {
+			t.Errorf("dir %d: expected arranging twice to change nothing", dir)
+		}
+	}
+}
+
+func TestArrangeSelectionLeavesOtherBoxesAlone(t *testing.T) {
+	c := tangledChain()
+	c.Arrange([]int{0, 1}, LayoutTopDown)
+	if c.boxes[2].X != 50 || c.boxes[2].Y != 2 || c.boxes[3].X != 10 || c.boxes[3].Y != 6 {
+		t.Errorf("unselected boxes moved: C=%v D=%v", c.boxes[2], c.boxes[3])
+	}
+	a, b := c.boxes[0], c.boxes[1]
+	if a.X != 4 || a.Y != 20 || b.Y < a.Y+a.Height {
+		t.Errorf("expected A at the selection's corner with B below it, got A=%v B=%v", a, b)
+	}
+	// The line from B to C still starts on B.
+	conn := c.connections[1]
+	if c.GetConnectionEdge(b, conn.FromX, conn.FromY) == "unknown" {
+		t.Errorf("line from B didn't follow it: %+v", conn)
+	}
+}
@@ -0,0 +1,129 @@
+package canvas
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Diagnostic is a problem found while loading a chart. Line is 1-based, or 0
+// when the problem isn't tied to a single line.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// String formats the diagnostic as file:line: message, leaving out whichever
+// of the file and line is unknown.
+func (d Diagnostic) String() string {
+	switch {
+	case d.File != "" && d.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+	case d.File != "":
+		return d.File + ": " + d.Message
+	case d.Line > 0:
+		return fmt.Sprintf("line %d: %s", d.Line, d.Message)
+	}
+	return d.Message
+}
+
+// LoadError is returned when a chart can't be loaded. Diagnostics holds every
+// problem found, not just the first.
+type LoadError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *LoadError) Error() string {
+	msg := e.Diagnostics[0].String()
+	switch more := len(e.Diagnostics) - 1; {
+	case more == 1:
+		msg += " (and 1 more problem)"
+	case more > 1:
+		msg += fmt.Sprintf(" (and %d more problems)", more)
+	}
+	return msg
+}
+
+// LoadOptions controls how forgiving Load is.
+type LoadOptions struct {
+	// Salvage loads whatever can be read from a damaged chart: objects whose
+	// lines can't be parsed are dropped and reported as warnings instead of
+	// failing the load. A file that isn't a chart at all still fails.
+	Salvage bool
+}
+
+// LoadResult is a chart read by Load.
+type LoadResult struct {
+	Canvas *Canvas
+	// Pan is the pan offset saved with the chart, or nil when it has none.
+	Pan *Point
+	// JSON reports whether the chart was saved in the JSON format.
+	JSON bool
+	// Warnings lists the values that were coerced or dropped so the chart
+	// could load.
+	Warnings []Diagnostic
+	// Stamp identifies the version of the file that was loaded. It is zero
+	// for charts decoded from memory.
+	Stamp FileStamp
+}
+
+// Load reads a chart into a new Canvas. The format is detected from the
+// content: JSON saves start with '{', anything else is read as the .sav text
+// format. When the file can't be loaded the error is a *LoadError listing
+// file:line diagnostics, unless the file couldn't be read at all.
+func Load(filename string, opts LoadOptions) (*LoadResult, error) {
+	data, stamp, err := readStamped(filename)
+	if err != nil {
+		return nil, err
+	}
+	res, err := Decode(filename, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Stamp = stamp
+	return res, nil
+}
+
+// Decode is Load for a chart that is already in memory. name is only used in
+// diagnostics and may be empty.
+func Decode(name string, data []byte, opts LoadOptions) (*LoadResult, error) {
+	d := &diagnostics{file: name, salvage: opts.Salvage}
+	res := &LoadResult{Canvas: NewCanvas()}
+	if trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff"); len(trimmed) > 0 && trimmed[0] == '{' {
+		res.JSON = true
+		res.Pan = res.Canvas.readJSON(bytes.TrimPrefix(data, []byte("\ufeff")), d)
+	} else {
+		res.Pan = res.Canvas.readSAV(bytes.NewReader(data), d)
+	}
+	if len(d.errors) > 0 {
+		return nil, &LoadError{Diagnostics: d.errors}
+	}
+	res.Warnings = d.warnings
+	return res, nil
+}
+
+// diagnostics collects what a reader finds wrong with a chart. Errors fail
+// the load unless salvaging, in which case they are kept as warnings; fatal
+// errors always fail it.
+type diagnostics struct {
+	file     string
+	salvage  bool
+	errors   []Diagnostic
+	warnings []Diagnostic
+}
+
+func (d *diagnostics) warnf(line int, format string, args ...any) {
+	d.warnings = append(d.warnings, Diagnostic{File: d.file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *diagnostics) errorf(line int, format string, args ...any) {
+	if d.salvage {
+		d.warnf(line, format, args...)
+		return
+	}
+	d.fatalf(line, format, args...)
+}
+
+func (d *diagnostics) fatalf(line int, format string, args ...any) {
+	d.errors = append(d.errors, Diagnostic{File: d.file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,99 @@
+package canvas
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// damaged has a bad number on line 4 and a truncated waypoint list on line 7.
+const damaged = "FLOWCHART\n" +
+	"BOXES:3\n" +
+	"0,0,10,3,0,0,,A\n" +
+	"20,x,10,3,0,0,,B\n" +
+	"40,0,10,3,7,9,,C\n" +
+	"CONNECTIONS:3\n" +
+	"0,2,9,1,40,1,2,2|15:1\n" +
+	"0,1,9,1,20,1,0,2\n" +
+	"2,5,40,1,60,1,0,2\n" +
+	"TEXTS:0\n"
+
+func diagnosticLines(diags []Diagnostic) []int {
+	var lines []int
+	for _, d := range diags {
+		lines = append(lines, d.Line)
+	}
+	return lines
+}
+
+func TestLoadReportsEveryProblemWithItsLine(t *testing.T) {
+	_, err := Decode("chart.sav", []byte(damaged), LoadOptions{})
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) {
+		t.Fatalf("expected a *LoadError, got %v", err)
+	}
+	if got := diagnosticLines(loadErr.Diagnostics); !reflect.DeepEqual(got, []int{4, 7}) {
+		t.Errorf("expected errors on lines 4 and 7, got %v", loadErr.Diagnostics)
+	}
+	if got, want := loadErr.Diagnostics[0].String(), `chart.sav:4: invalid box Y "x": not a number`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := err.Error(), `chart.sav:4: invalid box Y "x": not a number (and 1 more problem)`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLoadSalvage(t *testing.T) {
+	res, err := Decode("chart.sav", []byte(damaged), LoadOptions{Salvage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := res.Canvas
+	if len(c.boxes) != 2 || c.boxes[1].GetText() != "C" {
+		t.Fatalf("expected boxes A and C, got %+v", c.boxes)
+	}
+	if c.boxes[1].ZLevel != 0 || c.boxes[1].BorderStyle != BorderStyleASCII {
+		t.Errorf("expected C's ZLevel and BorderStyle to be coerced, got %+v", c.boxes[1])
+	}
+	// The line to B loses that end; the line from C to box 5 loses its other.
+	if len(c.connections) != 2 {
+		t.Fatalf("expected 2 connections, got %+v", c.connections)
+	}
+	if conn := c.connections[0]; conn.FromID != c.boxes[0].ID || conn.ToID != -1 {
+		t.Errorf("expected A to a loose end, got %+v", conn)
+	}
+	if conn := c.connections[1]; conn.FromID != c.boxes[1].ID || conn.ToID != -1 {
+		t.Errorf("expected C to a loose end, got %+v", conn)
+	}
+	if got := diagnosticLines(res.Warnings); !reflect.DeepEqual(got, []int{4, 5, 5, 7, 8, 9}) {
+		t.Errorf("unexpected warnings %v", res.Warnings)
+	}
+}
+
+func TestLoadFailureKeepsCanvas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.sav")
+	if err := os.WriteFile(path, []byte(damaged), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCanvas()
+	c.AddBox(1, 1, "keep me")
+	if err := c.LoadFromFile(path); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(c.boxes) != 1 || c.boxes[0].GetText() != "keep me" {
+		t.Errorf("a failed load changed the canvas: %+v", c.boxes)
+	}
+}
+
+func TestLoadTruncatedSection(t *testing.T) {
+	_, err := Decode("", []byte("FLOWCHART\nBOXES:2\n0,0,10,3,0,0,,A\nCONNECTIONS:0\n"), LoadOptions{})
+	if err == nil || err.Error() != "line 2: BOXES declares 2 entries but has 1" {
+		t.Errorf("unexpected error %v", err)
+	}
+	_, err = Decode("", []byte("not a chart\n"), LoadOptions{Salvage: true})
+	if err == nil {
+		t.Error("expected salvaging a non-chart to fail")
+	}
+}
@@ -0,0 +1,120 @@
+package canvas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func (c *Canvas) ExportToMermaid(filename string) error {
+	return writeExportFile(filename, c.WriteMermaid)
+}
+
+func (c *Canvas) WriteMermaid(w io.Writer) error {
+	if len(c.boxes) == 0 {
+		return fmt.Errorf("nothing to export")
+	}
+	edges := c.graphEdges()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "flowchart %s\n", c.mermaidDirection(edges))
+	for i, box := range c.boxes {
+		open, close := mermaidShape(box)
+		fmt.Fprintf(bw, "    %s%s\"%s\"%s\n", graphNodeID(i), open, mermaidLabel(boxLabelLines(box)), close)
+	}
+	for _, e := range edges {
+		from, to, arrow := e.From, e.To, "---"
+		switch {
+		case e.ArrowFrom && e.ArrowTo:
+			arrow = "<-->"
+		case e.ArrowTo:
+			arrow = "-->"
+		case e.ArrowFrom:
+			from, to, arrow = e.To, e.From, "-->"
+		}
+		if e.Label != "" {
+			arrow += "|\"" + mermaidLabel([]string{e.Label}) + "\"|"
+		}
+		fmt.Fprintf(bw, "    %s %s %s\n", graphNodeID(from), arrow, graphNodeID(to))
+	}
+
+	usedColors := map[int]bool{}
+	for _, box := range c.boxes {
+		if box.Color >= 0 && box.Color < NumColors {
+			usedColors[box.Color] = true
+		}
+	}
+	colors := make([]int, 0, len(usedColors))
+	for color := range usedColors {
+		colors = append(colors, color)
+	}
+	sort.Ints(colors)
+	for _, color := range colors {
+		fmt.Fprintf(bw, "    classDef color%d stroke:%s,color:%s\n", color, hexColor(color), hexColor(color))
+	}
+	for _, color := range colors {
+		var ids []string
+		for i, box := range c.boxes {
+			if box.Color == color {
+				ids = append(ids, graphNodeID(i))
+			}
+		}
+		fmt.Fprintf(bw, "    class %s color%d\n", strings.Join(ids, ","), color)
+	}
+	for i, e := range edges {
+		if e.Color >= 0 && e.Color < NumColors {
+			fmt.Fprintf(bw, "    linkStyle %d stroke:%s\n", i, hexColor(e.Color))
+		}
+	}
+	return bw.Flush()
+}
+
+// mermaidDirection picks LR when the chart's edges mostly run sideways and TD
+// otherwise, so the rendered graph keeps roughly the same shape.
+func (c *Canvas) mermaidDirection(edges []graphEdge) string {
+	dx, dy := 0, 0
+	for _, e := range edges {
+		a, b := c.boxes[e.From], c.boxes[e.To]
+		dx += abs((a.X + a.Width/2) - (b.X + b.Width/2))
+		dy += abs((a.Y+a.Height/2)-(b.Y+b.Height/2)) * 2
+	}
+	if dx > dy {
+		return "LR"
+	}
+	return "TD"
+}
+
+// mermaidShape is the pair of brackets that draws box's outline: the node
+// shape matching a flowchart shape, or else one for the border style.
+func mermaidShape(box Box) (string, string) {
+	switch box.Shape {
+	case ShapeDiamond:
+		return "{", "}"
+	case ShapeTerminator:
+		return "([", "])"
+	case ShapeParallelogram:
+		return "[/", "/]"
+	case ShapeDatabase:
+		return "[(", ")]"
+	case ShapeDocument:
+		return ">", "]"
+	}
+	switch box.BorderStyle {
+	case BorderStyleRounded:
+		return "(", ")"
+	case BorderStyleDouble:
+		return "[[", "]]"
+	default:
+		return "[", "]"
+	}
+}
+
+func mermaidLabel(lines []string) string {
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = strings.ReplaceAll(line, "\"", "#quot;")
+	}
+	return strings.Join(escaped, "<br/>")
+}
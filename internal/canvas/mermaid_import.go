@@ -0,0 +1,390 @@
+package canvas
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseMermaid reads a Mermaid flowchart (graph or flowchart header) and lays
+// it out as boxes and connections. A Markdown file's first ```mermaid block is
+// used when present. Subgraphs are flattened and click/interaction lines are
+// ignored.
+func ParseMermaid(r io.Reader) (*Canvas, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	g, err := parseMermaidGraph(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return g.canvas(), nil
+}
+
+type mermaidParser struct {
+	g                *importGraph
+	line             int
+	classes          map[string]int
+	nodeClass        map[int]string
+	linkColors       map[int]int
+	defaultLinkColor int
+}
+
+func parseMermaidGraph(src string) (*importGraph, error) {
+	p := &mermaidParser{
+		g:                newImportGraph(),
+		classes:          map[string]int{},
+		nodeClass:        map[int]string{},
+		linkColors:       map[int]int{},
+		defaultLinkColor: -1,
+	}
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	start, end := 0, len(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```mermaid") {
+			start, end = i+1, len(lines)
+			for j := i + 1; j < len(lines); j++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[j]), "```") {
+					end = j
+					break
+				}
+			}
+			break
+		}
+	}
+
+	header := false
+	for i := start; i < end; i++ {
+		p.line = i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		for _, stmt := range splitMermaidStatements(line) {
+			if !header {
+				fields := strings.Fields(stmt)
+				if fields[0] != "graph" && fields[0] != "flowchart" {
+					return nil, p.errorf("expected a graph or flowchart header, got %q", stmt)
+				}
+				if len(fields) > 1 {
+					dir, ok := mermaidDirections[fields[1]]
+					if !ok {
+						return nil, p.errorf("unknown direction %q", fields[1])
+					}
+					p.g.dir = dir
+				}
+				header = true
+				continue
+			}
+			if err := p.statement(stmt); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !header {
+		return nil, fmt.Errorf("no graph or flowchart header found")
+	}
+
+	for node, class := range p.nodeClass {
+		if color, ok := p.classes[class]; ok && p.g.nodes[node].color < 0 {
+			p.g.nodes[node].color = color
+		}
+	}
+	for i := range p.g.edges {
+		if color, ok := p.linkColors[i]; ok {
+			p.g.edges[i].color = color
+		} else if p.g.edges[i].color < 0 {
+			p.g.edges[i].color = p.defaultLinkColor
+		}
+	}
+	return p.g, nil
+}
+
+var mermaidDirections = map[string]LayoutDirection{
+	"TD": LayoutTopDown,
+	"TB": LayoutTopDown,
+	"BT": LayoutBottomUp,
+	"LR": LayoutLeftRight,
+	"RL": LayoutRightLeft,
+}
+
+func (p *mermaidParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// splitMermaidStatements splits a line on semicolons that are not inside a
+// quoted label.
+func splitMermaidStatements(line string) []string {
+	var stmts []string
+	inQuote := false
+	last := 0
+	add := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			stmts = append(stmts, s)
+		}
+	}
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == ';' && !inQuote:
+			add(line[last:i])
+			last = i + 1
+		}
+	}
+	add(line[last:])
+	return stmts
+}
+
+func (p *mermaidParser) statement(stmt string) error {
+	keyword, rest, _ := strings.Cut(stmt, " ")
+	rest = strings.TrimSpace(rest)
+	switch keyword {
+	case "subgraph", "end", "direction", "click", "accTitle:", "accDescr:", "accTitle", "accDescr":
+		return nil
+	case "classDef":
+		name, styles, _ := strings.Cut(rest, " ")
+		if color := mermaidStyleColor(styles); color >= 0 {
+			for _, n := range strings.Split(name, ",") {
+				p.classes[n] = color
+			}
+		}
+		return nil
+	case "class":
+		ids, class, _ := strings.Cut(rest, " ")
+		for _, id := range strings.Split(ids, ",") {
+			p.nodeClass[p.g.node(strings.TrimSpace(id))] = strings.TrimSpace(class)
+		}
+		return nil
+	case "style":
+		id, styles, _ := strings.Cut(rest, " ")
+		node := p.g.node(id)
+		if color := mermaidStyleColor(styles); color >= 0 {
+			p.g.nodes[node].color = color
+		}
+		return nil
+	case "linkStyle":
+		which, styles, _ := strings.Cut(rest, " ")
+		color := mermaidStyleColor(styles)
+		if which == "default" {
+			p.defaultLinkColor = color
+			return nil
+		}
+		for _, s := range strings.Split(which, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return p.errorf("bad linkStyle index %q", s)
+			}
+			p.linkColors[i] = color
+		}
+		return nil
+	}
+	return p.chain(stmt)
+}
+
+// mermaidStyleColor picks the stroke color from a style list, falling back to
+// the text color and then the fill.
+func mermaidStyleColor(styles string) int {
+	props := map[string]string{}
+	for _, prop := range strings.Split(styles, ",") {
+		key, value, ok := strings.Cut(prop, ":")
+		if ok {
+			props[strings.TrimSpace(key)] = strings.TrimSpace(strings.TrimSuffix(value, ";"))
+		}
+	}
+	for _, key := range []string{"stroke", "color", "fill"} {
+		if value, ok := props[key]; ok {
+			if color := paletteColor(value); color >= 0 {
+				return color
+			}
+		}
+	}
+	return -1
+}
+
+var (
+	mermaidLinkRe     = regexp.MustCompile(`^(<|x|o)?(-{2,}|={2,}|-\.+-|~~~)(>|x|o)?\s*(?:\|([^|]*)\|)?`)
+	mermaidTextLinkRe = regexp.MustCompile(`^(<|x|o)?(--|==|-\.)\s+([^-=.>|][^>]*?)\s+(-{2,}|={2,}|\.+-)(>|x|o)?`)
+)
+
+// chain parses "A --> B & C -- text --> D" style statements: groups of nodes
+// joined by '&' and separated by links.
+func (p *mermaidParser) chain(stmt string) error {
+	s := stmt
+	prev, err := p.nodeGroup(&s)
+	if err != nil {
+		return err
+	}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return nil
+		}
+		m := mermaidTextLinkRe.FindStringSubmatch(s)
+		label, arrowTo := "", false
+		if m != nil {
+			label, arrowTo = m[3], m[5] != ""
+		} else {
+			m = mermaidLinkRe.FindStringSubmatch(s)
+			if m == nil || m[0] == "" {
+				return p.errorf("cannot parse %q", s)
+			}
+			label, arrowTo = m[4], m[3] != ""
+		}
+		s = s[len(m[0]):]
+		invisible := m[2] == "~~~"
+		arrowFrom := m[1] != ""
+		label = strings.Trim(strings.TrimSpace(label), "\"")
+		label = strings.ReplaceAll(mermaidLabelText(label), "\n", " ")
+
+		s = strings.TrimLeft(s, " \t")
+		next, err := p.nodeGroup(&s)
+		if err != nil {
+			return err
+		}
+		if !invisible {
+			for _, from := range prev {
+				for _, to := range next {
+					p.g.edges = append(p.g.edges, importEdge{from: from, to: to, arrowFrom: arrowFrom, arrowTo: arrowTo, color: -1, label: label})
+				}
+			}
+		}
+		prev = next
+	}
+}
+
+func (p *mermaidParser) nodeGroup(s *string) ([]int, error) {
+	var nodes []int
+	for {
+		node, err := p.nodeRef(s)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		rest := strings.TrimLeft(*s, " \t")
+		if !strings.HasPrefix(rest, "&") {
+			return nodes, nil
+		}
+		*s = strings.TrimLeft(rest[1:], " \t")
+	}
+}
+
+type mermaidShapeDef struct {
+	open    string
+	closers []string
+	style   BorderStyle
+	shape   Shape
+}
+
+// mermaidShapes lists the node shape delimiters, longest first so "([" wins
+// over "(". Shapes without a matching border style or flowchart shape become
+// plain boxes.
+var mermaidShapes = []mermaidShapeDef{
+	{"(((", []string{")))"}, BorderStyleDouble, ShapeRectangle},
+	{"([", []string{"])"}, BorderStyleRounded, ShapeTerminator},
+	{"[[", []string{"]]"}, BorderStyleDouble, ShapeRectangle},
+	{"[(", []string{")]"}, BorderStyleRounded, ShapeDatabase},
+	{"((", []string{"))"}, BorderStyleRounded, ShapeRectangle},
+	{"{{", []string{"}}"}, BorderStyleASCII, ShapeRectangle},
+	{"[/", []string{"/]", "\\]"}, BorderStyleASCII, ShapeParallelogram},
+	{"[\\", []string{"\\]", "/]"}, BorderStyleASCII, ShapeParallelogram},
+	{"[", []string{"]"}, BorderStyleASCII, ShapeRectangle},
+	{"(", []string{")"}, BorderStyleRounded, ShapeRectangle},
+	{"{", []string{"}"}, BorderStyleASCII, ShapeDiamond},
+	{">", []string{"]"}, BorderStyleASCII, ShapeDocument},
+}
+
+func (p *mermaidParser) nodeRef(s *string) (int, error) {
+	src := *s
+	end := 0
+	for end < len(src) {
+		r := rune(src[end])
+		if r >= 0x80 {
+			r = []rune(src[end:])[0]
+		}
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			break
+		}
+		end += len(string(r))
+	}
+	if end == 0 {
+		return 0, p.errorf("expected a node id at %q", src)
+	}
+	node := p.g.node(src[:end])
+	src = src[end:]
+
+	for _, shape := range mermaidShapes {
+		if !strings.HasPrefix(src, shape.open) {
+			continue
+		}
+		body := src[len(shape.open):]
+		label, rest, err := p.shapeLabel(body, shape.closers)
+		if err != nil {
+			return 0, err
+		}
+		p.g.nodes[node].label = label
+		p.g.nodes[node].borderStyle = shape.style
+		p.g.nodes[node].shape = shape.shape
+		src = rest
+		break
+	}
+
+	if strings.HasPrefix(src, ":::") {
+		src = src[3:]
+		n := strings.IndexFunc(src, func(r rune) bool {
+			return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+		})
+		if n < 0 {
+			n = len(src)
+		}
+		p.nodeClass[node] = src[:n]
+		src = src[n:]
+	}
+	*s = src
+	return node, nil
+}
+
+func (p *mermaidParser) shapeLabel(body string, closers []string) (string, string, error) {
+	trimmed := strings.TrimLeft(body, " ")
+	if strings.HasPrefix(trimmed, "\"") {
+		q := strings.Index(trimmed[1:], "\"")
+		if q < 0 {
+			return "", "", p.errorf("unterminated quoted label")
+		}
+		label := trimmed[1 : q+1]
+		after := strings.TrimLeft(trimmed[q+2:], " ")
+		for _, closer := range closers {
+			if strings.HasPrefix(after, closer) {
+				return mermaidLabelText(label), after[len(closer):], nil
+			}
+		}
+		return "", "", p.errorf("expected %q after label", closers[0])
+	}
+	best := -1
+	closeLen := 0
+	for _, closer := range closers {
+		if i := strings.Index(body, closer); i >= 0 && (best < 0 || i < best) {
+			best, closeLen = i, len(closer)
+		}
+	}
+	if best < 0 {
+		return "", "", p.errorf("missing %q to close node shape", closers[0])
+	}
+	return mermaidLabelText(strings.TrimSpace(body[:best])), body[best+closeLen:], nil
+}
+
+var mermaidBreakRe = regexp.MustCompile(`(?i)<br\s*/?>`)
+
+func mermaidLabelText(label string) string {
+	label = mermaidBreakRe.ReplaceAllString(label, "\n")
+	label = strings.ReplaceAll(label, "#quot;", "\"")
+	lines := strings.Split(label, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
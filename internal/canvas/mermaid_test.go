@@ -0,0 +1,52 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMermaidExport(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Start")        // 0
+	c.AddBox(2, 12, "Check")       // 1
+	c.AddBox(16, 12, "Say \"hi\"") // 2
+	c.boxes[0].Title = "Entry"
+	c.boxes[0].UpdateSize()
+	c.SetBorderStyle(0, BorderStyleRounded)
+	c.SetBorderStyle(1, BorderStyleDouble)
+	c.SetBoxColor(2, 1)
+	c.AddConnection(0, 1)
+	c.connections[0].ArrowTo = true
+	c.SetLineColor(0, 4)
+
+	// A branch that leaves the 0->1 line and ends on box 2 joins 0 to 2.
+	host := c.connections[0]
+	midY := (host.FromY + host.ToY) / 2
+	c.AddConnectionWithWaypoints(-1, 2, host.FromX, midY, c.boxes[2].X, c.boxes[2].Y+1, nil)
+	// Arrow only at the start reverses the edge.
+	c.AddConnection(2, 1)
+	c.connections[2].ArrowFrom = true
+
+	var buf bytes.Buffer
+	if err := c.WriteMermaid(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"flowchart TD\n",
+		`n0("Entry<br/>Start")`,
+		`n1[["Check"]]`,
+		`n2["Say #quot;hi#quot;"]`,
+		"n0 --> n1\n",
+		"n0 --> n2\n",
+		"n1 --> n2\n",
+		"classDef color1 stroke:#cd0000,color:#cd0000",
+		"class n2 color1",
+		"linkStyle 0 stroke:#0000dc",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected Mermaid output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
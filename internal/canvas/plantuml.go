@@ -0,0 +1,91 @@
+package canvas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (c *Canvas) ExportToPlantUML(filename string) error {
+	return writeExportFile(filename, c.WritePlantUML)
+}
+
+// WritePlantUML writes the chart as a PlantUML description diagram, which
+// can express any graph and has an element keyword for most flowchart
+// shapes (see plantUMLShapes). Each box is declared first, its title in bold
+// above the text and its color set inline, then the lines join them.
+func (c *Canvas) WritePlantUML(w io.Writer) error {
+	if len(c.boxes) == 0 {
+		return fmt.Errorf("nothing to export")
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "@startuml\n")
+	for i, box := range c.boxes {
+		keyword, ok := plantUMLShapes[box.Shape]
+		if !ok {
+			keyword = "rectangle"
+		}
+		fmt.Fprintf(bw, "%s \"%s\" as %s", keyword, plantUMLLabel(box), graphNodeID(i))
+		if box.Shape == ShapeParallelogram {
+			fmt.Fprintf(bw, " <<input/output>>")
+		}
+		if box.Color >= 0 && box.Color < NumColors {
+			color := strings.TrimPrefix(hexColor(box.Color), "#")
+			fmt.Fprintf(bw, " #line:%s;text:%s", color, color)
+		}
+		fmt.Fprintf(bw, "\n")
+	}
+	for _, e := range c.graphEdges() {
+		from, to := e.From, e.To
+		if e.ArrowFrom && !e.ArrowTo {
+			from, to = e.To, e.From
+		}
+		style := ""
+		if e.Color >= 0 && e.Color < NumColors {
+			style = "[" + hexColor(e.Color) + "]"
+		}
+		var arrow string
+		switch {
+		case e.ArrowFrom && e.ArrowTo:
+			arrow = "<-" + style + "->"
+		case e.ArrowFrom || e.ArrowTo:
+			arrow = "-" + style + "->"
+		default:
+			arrow = "-" + style + "-"
+		}
+		fmt.Fprintf(bw, "%s %s %s", graphNodeID(from), arrow, graphNodeID(to))
+		if e.Label != "" {
+			fmt.Fprintf(bw, " : %s", e.Label)
+		}
+		fmt.Fprintf(bw, "\n")
+	}
+	fmt.Fprintf(bw, "@enduml\n")
+	return bw.Flush()
+}
+
+// plantUMLShapes is the element keyword for each shape that isn't a plain
+// rectangle. Decisions are hexagons, the way PlantUML draws its own
+// conditions. PlantUML has no parallelogram, so input and output stays a
+// rectangle, marked with an <<input/output>> stereotype instead.
+var plantUMLShapes = map[Shape]string{
+	ShapeDiamond:    "hexagon",
+	ShapeTerminator: "usecase",
+	ShapeDatabase:   "database",
+	ShapeDocument:   "file",
+}
+
+func plantUMLLabel(box Box) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `''`)
+	var lines []string
+	if box.Title != "" {
+		for _, line := range trimmedLines(box.Title) {
+			lines = append(lines, "<b>"+escape.Replace(line)+"</b>")
+		}
+	}
+	for _, line := range trimmedLines(box.GetText()) {
+		lines = append(lines, escape.Replace(line))
+	}
+	return strings.Join(lines, `\n`)
+}
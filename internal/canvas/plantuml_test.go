@@ -0,0 +1,57 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlantUMLExport(t *testing.T) {
+	c := dotChart()
+	c.AddBox(60, 20, `Say "hi"`) // 3, not connected
+
+	var buf bytes.Buffer
+	if err := c.WritePlantUML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"@startuml\n",
+		`rectangle "Start" as n0` + "\n",
+		`rectangle "<b>Check</b>\na|b {c}" as n1 #line:cd0000;text:cd0000` + "\n",
+		`rectangle "Done\nhere" as n2` + "\n",
+		`rectangle "Say ''hi''" as n3` + "\n",
+		"n0 -[#0000dc]-> n1\n",
+		"n1 <--> n2\n",
+		"n2 -- n0\n",
+		"@enduml\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected PlantUML output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPlantUMLShapes(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Begin")
+	c.AddBox(2, 12, "Read")
+	c.AddBox(2, 22, "Report")
+	c.SetBoxShape(0, ShapeTerminator)
+	c.SetBoxShape(1, ShapeParallelogram)
+	c.SetBoxShape(2, ShapeDocument)
+
+	var buf bytes.Buffer
+	if err := c.WritePlantUML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`usecase "Begin" as n0` + "\n",
+		`rectangle "Read" as n1 <<input/output>>` + "\n",
+		`file "Report" as n2` + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected PlantUML output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
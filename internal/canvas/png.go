@@ -3,7 +3,9 @@ package canvas
 import (
 	"fmt"
 	"image/color"
+	"io"
 	"math"
+	"strings"
 
 	"github.com/fogleman/gg"
 	"github.com/golang/freetype/truetype"
@@ -11,128 +13,142 @@ import (
 	"golang.org/x/image/font/gofont/gomono"
 )
 
-func (c *Canvas) ExportToPNG(filename string, renderWidth, renderHeight int, panX, panY int) error {
-	if len(c.boxes) == 0 && len(c.connections) == 0 && len(c.texts) == 0 {
-		return fmt.Errorf("nothing to export")
-	}
-
-	charWidth := 8.0
-	charHeight := 16.0
-	minX, minY := 0, 0
-	maxX, maxY := 0, 0
-	hasElements := false
-	for _, box := range c.boxes {
-		if !hasElements {
-			minX, minY = box.X, box.Y
-			maxX, maxY = box.X+box.Width, box.Y+box.Height
-			hasElements = true
-		} else {
-			if box.X < minX {
-				minX = box.X
-			}
-			if box.Y < minY {
-				minY = box.Y
-			}
-			if box.X+box.Width > maxX {
-				maxX = box.X + box.Width
-			}
-			if box.Y+box.Height > maxY {
-				maxY = box.Y + box.Height
-			}
-		}
+type PNGTheme int
+
+const (
+	PNGThemeLight PNGTheme = iota
+	PNGThemeDark
+)
+
+func ParsePNGTheme(name string) (PNGTheme, error) {
+	switch strings.ToLower(name) {
+	case "light":
+		return PNGThemeLight, nil
+	case "dark":
+		return PNGThemeDark, nil
 	}
+	return PNGThemeLight, fmt.Errorf("unknown theme %q (want light or dark)", name)
+}
 
-	for _, conn := range c.connections {
-		points := []Point{{conn.FromX, conn.FromY}}
-		points = append(points, conn.Waypoints...)
-		points = append(points, Point{conn.ToX, conn.ToY})
+type PNGOptions struct {
+	Scale       float64
+	FontSize    float64
+	Theme       PNGTheme
+	Transparent bool
+}
 
-		for _, pt := range points {
-			if !hasElements {
-				minX, minY = pt.X, pt.Y
-				maxX, maxY = pt.X, pt.Y
-				hasElements = true
-			} else {
-				if pt.X < minX {
-					minX = pt.X
-				}
-				if pt.Y < minY {
-					minY = pt.Y
-				}
-				if pt.X > maxX {
-					maxX = pt.X
-				}
-				if pt.Y > maxY {
-					maxY = pt.Y
-				}
-			}
-		}
+func DefaultPNGOptions() PNGOptions {
+	return PNGOptions{
+		Scale:    1,
+		FontSize: 14,
+		Theme:    PNGThemeLight,
 	}
+}
 
-	for _, text := range c.texts {
-		if !hasElements {
-			minX, minY = text.X, text.Y
-			maxX, maxY = text.X, text.Y
-			hasElements = true
-		} else {
-			if text.X < minX {
-				minX = text.X
-			}
-			if text.Y < minY {
-				minY = text.Y
-			}
-			maxTextX := text.X
-			for _, line := range text.Lines {
-				if text.X+len(line) > maxTextX {
-					maxTextX = text.X + len(line)
-				}
-			}
-			if maxTextX > maxX {
-				maxX = maxTextX
-			}
-			if text.Y+len(text.Lines) > maxY {
-				maxY = text.Y + len(text.Lines)
-			}
-		}
+func (o PNGOptions) background() color.Color {
+	if o.Theme == PNGThemeDark {
+		return color.RGBA{30, 30, 30, 255}
+	}
+	return color.White
+}
+
+func (o PNGOptions) foreground() color.Color {
+	if o.Theme == PNGThemeDark {
+		return color.RGBA{230, 230, 230, 255}
 	}
+	return color.Black
+}
 
-	if !hasElements {
-		return fmt.Errorf("nothing to export")
+func (o PNGOptions) color(index int) color.Color {
+	if index < 0 || index >= NumColors {
+		return o.foreground()
 	}
+	return pngColor(index)
+}
+
+func (c *Canvas) ExportToPNG(filename string, opts PNGOptions) error {
+	return writeExportFile(filename, func(w io.Writer) error {
+		return c.WritePNGWithOptions(w, opts)
+	})
+}
 
-	padding := 2
-	minX -= padding
-	minY -= padding
-	maxX += padding
-	maxY += padding
-	imageWidth := int(float64(maxX-minX) * charWidth)
-	imageHeight := int(float64(maxY-minY) * charHeight)
-	dc := gg.NewContext(imageWidth, imageHeight)
-	dc.SetColor(color.White)
-	dc.Clear()
-	dc.SetColor(color.Black)
-	fontData := gomono.TTF
-	ttfFont, err := truetype.Parse(fontData)
+func (c *Canvas) WritePNG(w io.Writer) error {
+	return c.WritePNGWithOptions(w, DefaultPNGOptions())
+}
+
+// WritePNGWithOptions rasterises the same cell grid the terminal shows, so
+// z-order, shadows, titles, border styles and highlights all match the editor.
+func (c *Canvas) WritePNGWithOptions(w io.Writer, opts PNGOptions) error {
+	if opts.Scale <= 0 {
+		return fmt.Errorf("scale must be positive, got %g", opts.Scale)
+	}
+	if opts.FontSize <= 0 {
+		return fmt.Errorf("font size must be positive, got %g", opts.FontSize)
+	}
+	rr, _, _, err := c.RenderExport(1)
+	if err != nil {
+		return err
+	}
+
+	size := opts.FontSize * opts.Scale
+	cellW := math.Round(math.Round(opts.FontSize*0.6) * opts.Scale)
+	cellH := 2 * cellW
+	if cellW < 1 {
+		return fmt.Errorf("font size %g at scale %g is too small to render", opts.FontSize, opts.Scale)
+	}
+
+	ttfFont, err := truetype.Parse(gomono.TTF)
 	if err != nil {
 		return fmt.Errorf("failed to parse font: %v", err)
 	}
 	face := truetype.NewFace(ttfFont, &truetype.Options{
-		Size:    12.0,
+		Size:    size,
 		DPI:     72,
 		Hinting: font.HintingFull,
 	})
-	dc.SetFontFace(face)
-	for _, conn := range c.connections {
-		c.drawConnectionPNG(dc, conn, minX, minY, charWidth, charHeight)
-	}
-	for _, text := range c.texts {
-		c.drawTextPNG(dc, text, minX, minY, charWidth, charHeight)
+	defer face.Close()
+
+	dc := gg.NewContext(int(float64(rr.Width)*cellW), int(float64(rr.Height)*cellH))
+	if !opts.Transparent {
+		dc.SetColor(opts.background())
+		dc.Clear()
 	}
-	for _, box := range c.boxes {
-		c.drawBoxPNG(dc, box, minX, minY, charWidth, charHeight)
+	dc.SetFontFace(face)
+	metrics := face.Metrics()
+	ascent := float64(metrics.Ascent) / 64
+	descent := float64(metrics.Descent) / 64
+	baseline := (cellH-(ascent+descent))/2 + ascent
+
+	cell := pngCell{dc: dc, w: cellW, h: cellH, line: math.Max(1, math.Round(cellW/8))}
+	for y, row := range rr.Canvas {
+		row = append([]rune(nil), row...)
+		repairCells(row)
+		for x, ch := range row {
+			colorIndex := -1
+			if y < len(rr.ColorMap) && x < len(rr.ColorMap[y]) {
+				colorIndex = rr.ColorMap[y][x]
+			}
+			cell.x, cell.y = float64(x)*cellW, float64(y)*cellH
+			if ch == WideCont {
+				continue
+			}
+			if ch == ' ' {
+				if colorIndex >= 0 {
+					dc.SetColor(opts.color(colorIndex))
+					dc.DrawRectangle(cell.x, cell.y, cellW, cellH)
+					dc.Fill()
+				}
+				continue
+			}
+			cell.fg = opts.color(colorIndex)
+			dc.SetColor(cell.fg)
+			if !cell.drawShape(ch) {
+				dc.DrawString(cellText(ch), cell.x, cell.y+baseline)
+			}
+		}
 	}
 
-	return dc.SavePNG(filename)
+	return dc.EncodePNG(w)
 }
 
 func pngColor(index int) color.Color {
@@ -158,92 +174,206 @@ func pngColor(index int) color.Color {
 	}
 }
 
-func (c *Canvas) drawConnectionPNG(dc *gg.Context, conn Connection, minX, minY int, charWidth, charHeight float64) {
-	points := []Point{{conn.FromX, conn.FromY}}
-	points = append(points, conn.Waypoints...)
-	points = append(points, Point{conn.ToX, conn.ToY})
-	if len(points) < 2 {
-		return
+// lineArms describes which edges of a cell a box-drawing rune reaches:
+// 0 for none, 1 for a single stroke and 2 for a double stroke.
+type lineArms struct {
+	left, right, up, down int
+}
+
+var boxDrawingArms = map[rune]lineArms{
+	'─': {1, 1, 0, 0},
+	'│': {0, 0, 1, 1},
+	'┌': {0, 1, 0, 1},
+	'┐': {1, 0, 0, 1},
+	'└': {0, 1, 1, 0},
+	'┘': {1, 0, 1, 0},
+	'├': {0, 1, 1, 1},
+	'┤': {1, 0, 1, 1},
+	'┬': {1, 1, 0, 1},
+	'┴': {1, 1, 1, 0},
+	'┼': {1, 1, 1, 1},
+	'═': {2, 2, 0, 0},
+	'║': {0, 0, 2, 2},
+	'╔': {0, 2, 0, 2},
+	'╗': {2, 0, 0, 2},
+	'╚': {0, 2, 2, 0},
+	'╝': {2, 0, 2, 0},
+}
+
+var shadeAlpha = map[rune]float64{
+	'░': 0.25,
+	'▒': 0.5,
+	'▓': 0.75,
+	'█': 1,
+}
+
+// pngCell draws box-drawing, shade and arrow runes as shapes so they join
+// seamlessly between cells and do not depend on font coverage.
+type pngCell struct {
+	dc         *gg.Context
+	x, y, w, h float64
+	line       float64
+	fg         color.Color
+}
+
+func (p pngCell) drawShape(ch rune) bool {
+	if arms, ok := boxDrawingArms[ch]; ok {
+		if arms.left == 2 || arms.right == 2 || arms.up == 2 || arms.down == 2 {
+			p.drawDouble(arms)
+		} else {
+			p.drawSingle(arms)
+		}
+		return true
 	}
-	dc.SetLineWidth(1.0)
-	if conn.Color >= 0 {
-		dc.SetColor(pngColor(conn.Color))
-	} else {
-		dc.SetColor(color.Black)
-	}
-	for i := 0; i < len(points)-1; i++ {
-		x1 := float64(points[i].X-minX) * charWidth
-		y1 := float64(points[i].Y-minY) * charHeight
-		x2 := float64(points[i+1].X-minX) * charWidth
-		y2 := float64(points[i+1].Y-minY) * charHeight
-		dc.DrawLine(x1, y1, x2, y2)
-		dc.Stroke()
-	}
-	if conn.ArrowFrom && len(points) > 1 {
-		c.drawArrowPNG(dc, points[1].X, points[1].Y, points[0].X, points[0].Y, minX, minY, charWidth, charHeight)
-	}
-	if conn.ArrowTo && len(points) > 1 {
-		c.drawArrowPNG(dc, points[len(points)-2].X, points[len(points)-2].Y, points[len(points)-1].X, points[len(points)-1].Y, minX, minY, charWidth, charHeight)
-	}
-}
-
-func (c *Canvas) drawArrowPNG(dc *gg.Context, fromX, fromY, toX, toY, minX, minY int, charWidth, charHeight float64) {
-	fx := float64(fromX-minX) * charWidth
-	fy := float64(fromY-minY) * charHeight
-	tx := float64(toX-minX) * charWidth
-	ty := float64(toY-minY) * charHeight
-	dx := tx - fx
-	dy := ty - fy
-	length := math.Sqrt(dx*dx + dy*dy)
-	if length < 0.1 {
-		return
-	}
-	dx /= length
-	dy /= length
-	arrowSize := 6.0
-	arrowAngle := 0.5
-	tipX, tipY := tx, ty
-	baseX1 := tx - arrowSize*dx + arrowSize*dy*arrowAngle
-	baseY1 := ty - arrowSize*dy - arrowSize*dx*arrowAngle
-	baseX2 := tx - arrowSize*dx - arrowSize*dy*arrowAngle
-	baseY2 := ty - arrowSize*dy + arrowSize*dx*arrowAngle
-	dc.MoveTo(tipX, tipY)
-	dc.LineTo(baseX1, baseY1)
-	dc.LineTo(baseX2, baseY2)
-	dc.ClosePath()
-	dc.Fill()
-}
-
-func (c *Canvas) drawBoxPNG(dc *gg.Context, box Box, minX, minY int, charWidth, charHeight float64) {
-	x := float64(box.X-minX) * charWidth
-	y := float64(box.Y-minY) * charHeight
-	width := float64(box.Width) * charWidth
-	height := float64(box.Height) * charHeight
-	dc.SetLineWidth(1.0)
-	if box.Color >= 0 {
-		dc.SetColor(pngColor(box.Color))
-	} else {
-		dc.SetColor(color.Black)
+	if alpha, ok := shadeAlpha[ch]; ok {
+		r, g, b, _ := p.fg.RGBA()
+		p.dc.SetRGBA(float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff, alpha)
+		p.dc.DrawRectangle(p.x, p.y, p.w, p.h)
+		p.dc.Fill()
+		return true
+	}
+	switch ch {
+	case '╭', '╮', '╰', '╯':
+		p.drawRounded(ch)
+		return true
+	case '╱', '╲':
+		p.drawDiagonal(ch)
+		return true
+	case '▶', '◀', '▼', '▲':
+		p.drawArrow(ch)
+		return true
+	}
+	return false
+}
+
+// center returns the pixel-aligned middle of the cell so one-pixel strokes
+// stay crisp instead of being smeared across two rows.
+func (p pngCell) center() (float64, float64) {
+	return p.x + math.Floor(p.w/2), p.y + math.Floor(p.h/2)
+}
+
+func (p pngCell) hline(x0, x1, y float64) {
+	p.dc.DrawRectangle(x0, y-math.Floor(p.line/2), x1-x0, p.line)
+	p.dc.Fill()
+}
+
+func (p pngCell) vline(y0, y1, x float64) {
+	p.dc.DrawRectangle(x-math.Floor(p.line/2), y0, p.line, y1-y0)
+	p.dc.Fill()
+}
+
+func (p pngCell) drawSingle(arms lineArms) {
+	cx, cy := p.center()
+	if arms.left > 0 {
+		p.hline(p.x, cx+p.line/2, cy)
+	}
+	if arms.right > 0 {
+		p.hline(cx-p.line/2, p.x+p.w, cy)
 	}
-	dc.DrawRectangle(x, y, width, height)
-	dc.Stroke()
+	if arms.up > 0 {
+		p.vline(p.y, cy+p.line/2, cx)
+	}
+	if arms.down > 0 {
+		p.vline(cy-p.line/2, p.y+p.h, cx)
+	}
+}
 
-	dc.SetColor(color.Black)
-	textY := y + charHeight
-	for i, line := range box.Lines {
-		dc.DrawString(line, x+charWidth, textY+float64(i)*charHeight)
+func (p pngCell) drawDouble(arms lineArms) {
+	cx, cy := p.center()
+	gap := math.Round(math.Max(p.line*1.5, p.w/5))
+	horizontal := arms.left > 0 || arms.right > 0
+	vertical := arms.up > 0 || arms.down > 0
+	switch {
+	case horizontal && !vertical:
+		p.hline(p.x, p.x+p.w, cy-gap)
+		p.hline(p.x, p.x+p.w, cy+gap)
+	case vertical && !horizontal:
+		p.vline(p.y, p.y+p.h, cx-gap)
+		p.vline(p.y, p.y+p.h, cx+gap)
+	default:
+		sx, sy := 1.0, 1.0
+		if arms.left > 0 {
+			sx = -1
+		}
+		if arms.up > 0 {
+			sy = -1
+		}
+		edgeX, edgeY := p.x+p.w, p.y+p.h
+		if sx < 0 {
+			edgeX = p.x
+		}
+		if sy < 0 {
+			edgeY = p.y
+		}
+		for _, offset := range []float64{-gap, gap} {
+			ox, oy := cx+sx*offset, cy+sy*offset
+			p.hline(math.Min(ox-p.line/2, edgeX), math.Max(ox+p.line/2, edgeX), oy)
+			p.vline(math.Min(oy-p.line/2, edgeY), math.Max(oy+p.line/2, edgeY), ox)
+		}
 	}
 }
 
-func (c *Canvas) drawTextPNG(dc *gg.Context, text Text, minX, minY int, charWidth, charHeight float64) {
-	x := float64(text.X-minX) * charWidth
-	y := float64(text.Y-minY) * charHeight
-	if text.Color >= 0 {
-		dc.SetColor(pngColor(text.Color))
+func (p pngCell) drawRounded(ch rune) {
+	cx, cy := p.center()
+	r := p.w / 2
+	p.dc.SetLineWidth(p.line)
+	switch ch {
+	case '╭':
+		p.dc.DrawArc(cx+r, cy+r, r, math.Pi, 1.5*math.Pi)
+		p.dc.Stroke()
+		p.vline(cy+r, p.y+p.h, cx)
+	case '╮':
+		p.dc.DrawArc(cx-r, cy+r, r, 1.5*math.Pi, 2*math.Pi)
+		p.dc.Stroke()
+		p.vline(cy+r, p.y+p.h, cx)
+	case '╰':
+		p.dc.DrawArc(cx+r, cy-r, r, 0.5*math.Pi, math.Pi)
+		p.dc.Stroke()
+		p.vline(p.y, cy-r, cx)
+	case '╯':
+		p.dc.DrawArc(cx-r, cy-r, r, 0, 0.5*math.Pi)
+		p.dc.Stroke()
+		p.vline(p.y, cy-r, cx)
+	}
+}
+
+// drawDiagonal runs corner to corner, so the slanted sides of a shape join
+// up between cells.
+func (p pngCell) drawDiagonal(ch rune) {
+	p.dc.SetLineWidth(p.line)
+	if ch == '╱' {
+		p.dc.DrawLine(p.x, p.y+p.h, p.x+p.w, p.y)
 	} else {
-		dc.SetColor(color.Black)
+		p.dc.DrawLine(p.x, p.y, p.x+p.w, p.y+p.h)
 	}
-	for i, line := range text.Lines {
-		dc.DrawString(line, x, y+float64(i)*charHeight)
+	p.dc.Stroke()
+}
+
+func (p pngCell) drawArrow(ch rune) {
+	inset := p.w / 10
+	left, right := p.x+inset, p.x+p.w-inset
+	cy := p.y + p.h/2
+	half := (p.w - 2*inset) / 2
+	top, bottom := cy-half, cy+half
+	cx := p.x + p.w/2
+	switch ch {
+	case '▶':
+		p.dc.MoveTo(left, top)
+		p.dc.LineTo(right, cy)
+		p.dc.LineTo(left, bottom)
+	case '◀':
+		p.dc.MoveTo(right, top)
+		p.dc.LineTo(left, cy)
+		p.dc.LineTo(right, bottom)
+	case '▼':
+		p.dc.MoveTo(left, top)
+		p.dc.LineTo(right, top)
+		p.dc.LineTo(cx, bottom)
+	case '▲':
+		p.dc.MoveTo(left, bottom)
+		p.dc.LineTo(right, bottom)
+		p.dc.LineTo(cx, top)
 	}
+	p.dc.ClosePath()
+	p.dc.Fill()
 }
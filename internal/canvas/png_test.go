@@ -0,0 +1,59 @@
+package canvas
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func decodePNG(t *testing.T, c *Canvas, opts PNGOptions) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c.WritePNGWithOptions(&buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestPNGFollowsRenderGrid(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Hi")
+	c.SetBorderStyle(0, BorderStyleSingle)
+	c.SetBoxColor(0, 1)
+	c.SetHighlight(7, 3, 4)
+
+	opts := DefaultPNGOptions()
+	img := decodePNG(t, c, opts)
+	rr, minX, minY, err := c.RenderExport(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cellW := img.Bounds().Dx() / rr.Width
+	cellH := img.Bounds().Dy() / rr.Height
+	if cellW == 0 || img.Bounds().Dx() != cellW*rr.Width || img.Bounds().Dy() != cellH*rr.Height {
+		t.Fatalf("image %v is not a whole number of %dx%d cells", img.Bounds(), rr.Width, rr.Height)
+	}
+
+	at := func(x, y int) (uint32, uint32, uint32, uint32) {
+		return img.At((x-minX)*cellW+cellW/2, (y-minY)*cellH+cellH/2).RGBA()
+	}
+	// The top border runs through the middle of the cell in the box color.
+	if r, g, b, _ := at(4, 2); r>>8 != 205 || g>>8 != 0 || b>>8 != 0 {
+		t.Fatalf("expected red border at (4,2), got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+	// A highlighted empty cell is filled with the highlight color.
+	if r, g, b, _ := at(7, 3); r>>8 != 0 || g>>8 != 0 || b>>8 != 220 {
+		t.Fatalf("expected blue highlight at (7,3), got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+
+	opts.Transparent = true
+	img = decodePNG(t, c, opts)
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Fatalf("expected transparent background, got alpha %d", a)
+	}
+}
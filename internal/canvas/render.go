@@ -12,37 +12,64 @@ type RenderResult struct {
 	Height   int
 }
 
+// ColorRun is a stretch of a rendered row that shares one color. Background
+// is set when the run starts on a blank cell, which is how highlights on
+// empty space are shown.
+type ColorRun struct {
+	Text       string
+	Color      int
+	Background bool
+}
+
+func (r *RenderResult) Runs(row int) []ColorRun {
+	if r.Width == 0 {
+		return nil
+	}
+	line := make([]rune, r.Width)
+	copy(line, r.Canvas[row])
+	for j := len(r.Canvas[row]); j < r.Width; j++ {
+		line[j] = ' '
+	}
+	repairCells(line)
+	var runs []ColorRun
+	start := 0
+	for j := 0; j <= len(line); j++ {
+		if j < len(line) && (j == start || r.cellColor(row, j) == r.cellColor(row, start)) {
+			continue
+		}
+		color := r.cellColor(row, start)
+		runs = append(runs, ColorRun{
+			Text:       cellString(line[start:j]),
+			Color:      color,
+			Background: color != -1 && line[start] == ' ',
+		})
+		start = j
+	}
+	return runs
+}
+
+func (r *RenderResult) cellColor(row, col int) int {
+	if row < len(r.ColorMap) && col < len(r.ColorMap[row]) {
+		return r.ColorMap[row][col]
+	}
+	return -1
+}
+
 func (r *RenderResult) ApplyColors() []string {
 	result := make([]string, r.Height)
-	for i, row := range r.Canvas {
-		line := make([]rune, r.Width)
-		copy(line, row)
-		for j := len(row); j < r.Width; j++ {
-			line[j] = ' '
-		}
+	for i := range r.Canvas {
 		var coloredLine strings.Builder
-		currentColor := -1
-		for j, char := range line {
-			cellColor := -1
-			if i < len(r.ColorMap) && j < len(r.ColorMap[i]) {
-				cellColor = r.ColorMap[i][j]
+		for _, run := range r.Runs(i) {
+			if run.Color == -1 {
+				coloredLine.WriteString(run.Text)
+				continue
 			}
-			if cellColor != currentColor {
-				if currentColor != -1 {
-					coloredLine.WriteString(colorReset)
-				}
-				if cellColor != -1 {
-					if char != ' ' {
-						coloredLine.WriteString(getTextColorCode(cellColor))
-					} else {
-						coloredLine.WriteString(getColorCode(cellColor))
-					}
-				}
-				currentColor = cellColor
+			if run.Background {
+				coloredLine.WriteString(getColorCode(run.Color))
+			} else {
+				coloredLine.WriteString(getTextColorCode(run.Color))
 			}
-			coloredLine.WriteRune(char)
-		}
-		if currentColor != -1 {
+			coloredLine.WriteString(run.Text)
 			coloredLine.WriteString(colorReset)
 		}
 		result[i] = coloredLine.String()
@@ -87,6 +114,9 @@ func (c *Canvas) RenderRaw(width, height int, selectedBox int, previewFromX, pre
 		}
 		c.drawConnectionWithPan(canvas, previewConnection, panX, panY)
 	}
+	for _, connection := range c.connections {
+		c.drawConnectionLabelWithPan(canvas, connection, panX, panY)
+	}
 	for _, text := range c.texts {
 		c.drawTextWithPan(canvas, text, panX, panY)
 	}
@@ -314,6 +344,7 @@ func (c *Canvas) RenderRaw(width, height int, selectedBox int, previewFromX, pre
 	}
 	for i := range c.connections {
 		paintCells(c.GetConnectionCells(i), c.connections[i].Color)
+		paintCells(c.GetConnectionLabelCells(i), c.connections[i].Color)
 	}
 	for i := range c.texts {
 		paintCells(c.GetTextCells(i), c.texts[i].Color)
@@ -327,7 +358,7 @@ func (c *Canvas) RenderRaw(width, height int, selectedBox int, previewFromX, pre
 		paintCells(c.GetBoxTitleBarCells(i), box.Color)
 	}
 
-	for key, colorIndex := range c.highlights {
+	for key, h := range c.highlights {
 		var x, y int
 		fmt.Sscanf(key, "%d,%d", &x, &y)
 
@@ -335,7 +366,7 @@ func (c *Canvas) RenderRaw(width, height int, selectedBox int, previewFromX, pre
 		screenY := y - panY
 		if screenY >= 0 && screenY < height && screenX >= 0 && screenX < width {
 			if screenY < len(colorMap) && screenX < len(colorMap[screenY]) {
-				colorMap[screenY][screenX] = colorIndex
+				colorMap[screenY][screenX] = h.color
 			}
 		}
 	}
@@ -388,23 +419,9 @@ func (c *Canvas) drawBoxWithPan(canvas [][]rune, box Box, isSelected bool, panX,
 }
 
 func (c *Canvas) drawBoxAt(canvas [][]rune, box Box, isSelected bool, boxX, boxY int) {
-	var topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical rune
-
+	horizontal := borderStyleRunes(box.BorderStyle).horizontal
 	if isSelected {
-		topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical = '#', '#', '#', '#', '#', '#'
-	} else {
-		switch box.BorderStyle {
-		case BorderStyleASCII:
-			topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical = '+', '+', '+', '+', '-', '|'
-		case BorderStyleSingle:
-			topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical = '┌', '┐', '└', '┘', '─', '│'
-		case BorderStyleDouble:
-			topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical = '╔', '╗', '╚', '╝', '═', '║'
-		case BorderStyleRounded:
-			topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical = '╭', '╮', '╰', '╯', '─', '│'
-		default:
-			topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical = '+', '+', '+', '+', '-', '|'
-		}
+		horizontal = '#'
 	}
 
 	height := len(canvas)
@@ -433,30 +450,12 @@ func (c *Canvas) drawBoxAt(canvas [][]rune, box Box, isSelected bool, boxX, boxY
 
 	for y := startY; y < endY; y++ {
 		for x := startX; x < endX; x++ {
-
-			isTopRow := (y == boxY)
-			isBottomRow := (y == boxY+box.Height-1)
-			isLeftCol := (x == boxX)
-			isRightCol := (x == boxX+box.Width-1)
-
-			if isTopRow {
-				if isLeftCol {
-					canvas[y][x] = topLeft
-				} else if isRightCol {
-					canvas[y][x] = topRight
-				} else {
-					canvas[y][x] = horizontal
-				}
-			} else if isBottomRow {
-				if isLeftCol {
-					canvas[y][x] = bottomLeft
-				} else if isRightCol {
-					canvas[y][x] = bottomRight
-				} else {
-					canvas[y][x] = horizontal
-				}
-			} else if isLeftCol || isRightCol {
-				canvas[y][x] = vertical
+			switch r := shapeRune(box, x-boxX, y-boxY); {
+			case r == 0:
+			case isSelected:
+				canvas[y][x] = '#'
+			default:
+				canvas[y][x] = r
 			}
 		}
 	}
@@ -473,18 +472,7 @@ func (c *Canvas) drawBoxAt(canvas [][]rune, box Box, isSelected bool, boxX, boxY
 		for lineIdx, titleLine := range titleLines {
 			titleY := boxY + 1 + lineIdx
 			if titleY >= 0 && titleY < len(canvas) {
-				titleText := titleLine
-				if len(titleText) > maxWidth {
-					titleText = titleText[:maxWidth]
-				}
-
-				titleX := boxX + 1
-
-				for i, char := range titleText {
-					if titleX+i >= 0 && titleX+i < len(canvas[titleY]) && titleX+i < boxX+box.Width-1 {
-						canvas[titleY][titleX+i] = char
-					}
-				}
+				putText(canvas[titleY], boxX+1, boxX+1+maxWidth, titleLine)
 			}
 		}
 
@@ -512,21 +500,7 @@ func (c *Canvas) drawBoxAt(canvas [][]rune, box Box, isSelected bool, boxX, boxY
 		textY := boxY + contentStartLine + lineIdx
 		textX := boxX + 1
 		if textY >= 0 && textY < len(canvas) && textY < boxY+box.Height-1 {
-			maxWidth := box.Width - 2
-			if maxWidth < 0 {
-				maxWidth = 0
-			}
-			displayText := line
-			if len(displayText) > maxWidth {
-				displayText = displayText[:maxWidth]
-			}
-
-			for i, char := range displayText {
-				charX := textX + i
-				if charX >= 0 && charX < len(canvas[textY]) && charX < boxX+box.Width-1 {
-					canvas[textY][charX] = char
-				}
-			}
+			putText(canvas[textY], textX, boxX+box.Width-1, line)
 		}
 	}
 }
@@ -538,31 +512,26 @@ func (c *Canvas) drawTextWithPan(canvas [][]rune, text Text, panX, panY int) {
 func (c *Canvas) drawTextAt(canvas [][]rune, text Text, textX, textY int) {
 	for lineIdx, line := range text.Lines {
 		lineY := textY + lineIdx
-		lineX := textX
 		if lineY >= 0 && lineY < len(canvas) {
-
-			for i, char := range line {
-				charX := lineX + i
-				if charX >= 0 && charX < len(canvas[lineY]) {
-					canvas[lineY][charX] = char
-				}
-			}
+			putText(canvas[lineY], textX, len(canvas[lineY]), line)
 		}
 	}
 }
 
+// cursorScreenPos finds where the edit cursor, cursorPos characters into
+// content, is drawn. Characters before it on its line may be double width.
 func cursorScreenPos(originX, originY, cursorPos int, content string, panX, panY int) (int, int) {
 	lines := strings.Split(content, "\n")
 	currentPos := 0
 	for lineIdx, line := range lines {
-		lineLength := len([]rune(line))
-		if cursorPos <= currentPos+lineLength {
-			return originX + (cursorPos - currentPos) - panX, originY + lineIdx - panY
+		runes := []rune(line)
+		if cursorPos <= currentPos+len(runes) {
+			return originX + StringWidth(string(runes[:max(cursorPos-currentPos, 0)])) - panX, originY + lineIdx - panY
 		}
-		currentPos += lineLength + 1
+		currentPos += len(runes) + 1
 	}
 	if len(lines) > 0 {
-		return originX + len([]rune(lines[len(lines)-1])) - panX, originY + len(lines) - 1 - panY
+		return originX + StringWidth(lines[len(lines)-1]) - panX, originY + len(lines) - 1 - panY
 	}
 	return originX - panX, originY - panY
 }
@@ -691,10 +660,11 @@ func cornerChar(prev, cur, next Point) rune {
 }
 
 func (c *Canvas) drawConnEndArrow(canvas [][]rune, boxID, ax, ay, panX, panY int) {
-	if boxID < 0 || boxID >= len(c.boxes) {
+	index := c.BoxIndex(boxID)
+	if index < 0 {
 		return
 	}
-	box := c.boxes[boxID]
+	box := c.boxes[index]
 	dl := abs(ax - box.X)
 	dr := abs(ax - (box.X + box.Width - 1))
 	dt := abs(ay - box.Y)
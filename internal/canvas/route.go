@@ -0,0 +1,271 @@
+package canvas
+
+import "container/heap"
+
+// The router searches a grid covering the chart for the cheapest orthogonal
+// path. Every step costs 1; bends, cells another line already uses, and
+// cells right next to a box cost extra, so it prefers few, straight
+// segments with some air around boxes.
+const (
+	routeBendCost  = 6
+	routeCrossCost = 4
+	routeHugCost   = 1
+	routeMargin    = 6
+	// Charts whose grid has more cells than this are left to the
+	// heuristics in createFlexibleWaypoints, which don't search.
+	maxRouteCells = 250000
+)
+
+// routeDirs are right, down, left and up; turning around is i^2.
+var routeDirs = [4]Point{{X: 1, Y: 0}, {X: 0, Y: 1}, {X: -1, Y: 0}, {X: 0, Y: -1}}
+
+type routeGrid struct {
+	minX, minY, w, h int
+	blocked          []bool
+	extra            []int
+}
+
+func (g *routeGrid) index(p Point) int {
+	x, y := p.X-g.minX, p.Y-g.minY
+	if x < 0 || y < 0 || x >= g.w || y >= g.h {
+		return -1
+	}
+	return y*g.w + x
+}
+
+func (g *routeGrid) point(i int) Point {
+	return Point{X: g.minX + i%g.w, Y: g.minY + i/g.w}
+}
+
+// RouteConnection reroutes the connection at index i around the boxes in
+// its way. It reports false and leaves the connection alone when the chart
+// is too big to search or no path exists.
+func (c *Canvas) RouteConnection(i int) bool {
+	if i < 0 || i >= len(c.connections) {
+		return false
+	}
+	conn := &c.connections[i]
+	waypoints, ok := c.route(conn)
+	if !ok {
+		return false
+	}
+	conn.Waypoints = waypoints
+	simplifyConnectionPath(conn)
+	return true
+}
+
+// rerouteConnection gives the connection at index i a new path between its
+// ends, searched for when the chart allows it and bent around its boxes
+// otherwise.
+func (c *Canvas) rerouteConnection(i int) {
+	conn := &c.connections[i]
+	from, to := c.BoxIndex(conn.FromID), c.BoxIndex(conn.ToID)
+	if waypoints, ok := c.route(conn); ok {
+		conn.Waypoints = waypoints
+	} else if from >= 0 && to >= 0 {
+		conn.Waypoints = c.createFlexibleWaypoints(conn, c.boxes[from], c.boxes[to])
+	} else if from >= 0 {
+		conn.Waypoints = c.createFlexibleWaypointsForLineConnection(conn, &c.boxes[from], nil)
+	} else {
+		conn.Waypoints = c.createFlexibleWaypointsForLineConnection(conn, nil, &c.boxes[to])
+	}
+	simplifyConnectionPath(conn)
+}
+
+// route finds waypoints taking conn from its start to its end without
+// passing through any box. An end on a box leaves it straight out of the
+// edge it is on.
+func (c *Canvas) route(conn *Connection) ([]Point, bool) {
+	start, startDir, ok := c.routePort(conn.FromID, conn.FromX, conn.FromY)
+	if !ok {
+		return nil, false
+	}
+	end, endDir, ok := c.routePort(conn.ToID, conn.ToX, conn.ToY)
+	if !ok {
+		return nil, false
+	}
+	// The path arrives at the end port heading into the box.
+	if endDir >= 0 {
+		endDir ^= 2
+	}
+
+	g, ok := c.routeGrid(conn, start, end)
+	if !ok {
+		return nil, false
+	}
+	path, ok := g.search(start, startDir, end, endDir)
+	if !ok {
+		return nil, false
+	}
+	return path, true
+}
+
+// routePort is the cell a path leaves from or arrives at for an end at
+// (x, y): the cell just outside the box edge it is on, with the direction
+// pointing away from the box, or the point itself and -1 for an end that
+// isn't on a box.
+func (c *Canvas) routePort(boxID, x, y int) (Point, int, bool) {
+	if boxID < 0 {
+		return Point{X: x, Y: y}, -1, true
+	}
+	i := c.BoxIndex(boxID)
+	if i < 0 {
+		return Point{}, 0, false
+	}
+	dir := -1
+	switch c.GetConnectionEdge(c.boxes[i], x, y) {
+	case "right":
+		dir = 0
+	case "bottom":
+		dir = 1
+	case "left":
+		dir = 2
+	case "top":
+		dir = 3
+	default:
+		return Point{}, 0, false
+	}
+	return Point{X: x + routeDirs[dir].X, Y: y + routeDirs[dir].Y}, dir, true
+}
+
+// routeGrid covers every box and both ends with some room to go around,
+// marking box cells as blocked and pricing the cells next to boxes and on
+// other lines.
+func (c *Canvas) routeGrid(conn *Connection, start, end Point) (*routeGrid, bool) {
+	minX, minY := min(start.X, end.X), min(start.Y, end.Y)
+	maxX, maxY := max(start.X, end.X), max(start.Y, end.Y)
+	for _, box := range c.boxes {
+		minX, minY = min(minX, box.X), min(minY, box.Y)
+		maxX, maxY = max(maxX, box.X+box.Width-1), max(maxY, box.Y+box.Height-1)
+	}
+	minX, minY = minX-routeMargin, minY-routeMargin
+	maxX, maxY = maxX+routeMargin, maxY+routeMargin
+	w, h := maxX-minX+1, maxY-minY+1
+	if w*h > maxRouteCells {
+		return nil, false
+	}
+
+	g := &routeGrid{minX: minX, minY: minY, w: w, h: h, blocked: make([]bool, w*h), extra: make([]int, w*h)}
+	for _, box := range c.boxes {
+		for y := box.Y - 1; y <= box.Y+box.Height; y++ {
+			for x := box.X - 1; x <= box.X+box.Width; x++ {
+				i := g.index(Point{X: x, Y: y})
+				inside := x >= box.X && x < box.X+box.Width && y >= box.Y && y < box.Y+box.Height
+				switch {
+				case i < 0:
+				case inside:
+					g.blocked[i] = true
+				default:
+					g.extra[i] = max(g.extra[i], routeHugCost)
+				}
+			}
+		}
+	}
+	for i, other := range c.connections {
+		if other.ID == conn.ID {
+			continue
+		}
+		for _, p := range c.GetConnectionCells(i) {
+			if j := g.index(p); j >= 0 {
+				g.extra[j] += routeCrossCost
+			}
+		}
+	}
+	for _, p := range []Point{start, end} {
+		if i := g.index(p); i < 0 || g.blocked[i] {
+			return nil, false
+		}
+	}
+	return g, true
+}
+
+type routeItem struct {
+	state, f int
+}
+
+type routeQueue []routeItem
+
+func (q routeQueue) Len() int { return len(q) }
+func (q routeQueue) Less(i, j int) bool {
+	return q[i].f < q[j].f
+}
+func (q routeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *routeQueue) Push(x any)   { *q = append(*q, x.(routeItem)) }
+func (q *routeQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// search runs A* over (cell, heading) states from start to end. A heading
+// of -1 at either end means any direction will do.
+func (g *routeGrid) search(start Point, startDir int, end Point, endDir int) ([]Point, bool) {
+	goal := g.index(end)
+	cost := make([]int, len(g.blocked)*4)
+	parent := make([]int, len(g.blocked)*4)
+	for i := range cost {
+		cost[i] = -1
+	}
+	heuristic := func(cell int) int {
+		p := g.point(cell)
+		return abs(p.X-end.X) + abs(p.Y-end.Y)
+	}
+
+	q := &routeQueue{}
+	first := g.index(start)
+	for d := range routeDirs {
+		if startDir >= 0 && d != startDir {
+			continue
+		}
+		s := first*4 + d
+		cost[s], parent[s] = 0, -1
+		if first == goal && endDir >= 0 && d != endDir {
+			cost[s] = routeBendCost
+		}
+		heap.Push(q, routeItem{state: s, f: cost[s] + heuristic(first)})
+	}
+
+	for q.Len() > 0 {
+		item := heap.Pop(q).(routeItem)
+		s := item.state
+		cell, dir := s/4, s%4
+		if item.f != cost[s]+heuristic(cell) {
+			continue // a cheaper way here was found after this was queued
+		}
+		if cell == goal {
+			var path []Point
+			for ; s >= 0; s = parent[s] {
+				path = append(path, g.point(s/4))
+			}
+			for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+				path[i], path[j] = path[j], path[i]
+			}
+			return path, true
+		}
+		p := g.point(cell)
+		for d, step := range routeDirs {
+			if d == dir^2 {
+				continue
+			}
+			next := g.index(Point{X: p.X + step.X, Y: p.Y + step.Y})
+			if next < 0 || g.blocked[next] {
+				continue
+			}
+			c := cost[s] + 1 + g.extra[next]
+			if d != dir {
+				c += routeBendCost
+			}
+			if next == goal && endDir >= 0 && d != endDir {
+				c += routeBendCost
+			}
+			ns := next*4 + d
+			if cost[ns] >= 0 && cost[ns] <= c {
+				continue
+			}
+			cost[ns], parent[ns] = c, s
+			heap.Push(q, routeItem{state: ns, f: c + heuristic(next)})
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,64 @@
+package canvas
+
+import "testing"
+
+// rowOfBoxes puts A and B on one row with C between them.
+func rowOfBoxes() *Canvas {
+	c := NewCanvas()
+	c.AddBox(0, 5, "A")
+	c.AddBox(40, 5, "B")
+	c.AddBox(20, 3, "C\nin\nthe\nway")
+	c.AddConnection(0, 1)
+	return c
+}
+
+func TestRouteConnectionGoesAroundBoxes(t *testing.T) {
+	c := rowOfBoxes()
+	if !c.crossesBoxes(c.connections[0]) {
+		t.Fatal("expected the straight line to cross C to begin with")
+	}
+	if !c.RouteConnection(0) {
+		t.Fatal("expected a route")
+	}
+	conn := c.connections[0]
+	if c.crossesBoxes(conn) {
+		t.Errorf("routed line still crosses a box: %+v", conn)
+	}
+	if conn.FromX != 7 || conn.FromY != 6 || conn.ToX != 40 || conn.ToY != 6 {
+		t.Errorf("routing moved the ends: %+v", conn)
+	}
+	// Out of A's right edge and into B's left one, with the fewest bends.
+	if len(conn.Waypoints) != 4 {
+		t.Errorf("expected 4 bends around C, got %v", conn.Waypoints)
+	}
+	if w := conn.Waypoints; w[0].Y != 6 || w[0].X <= 7 || w[len(w)-1].Y != 6 || w[len(w)-1].X >= 40 {
+		t.Errorf("expected the line to leave and enter horizontally, got %v", w)
+	}
+}
+
+func TestRouteConnectionLeavesClearLinesStraight(t *testing.T) {
+	c := rowOfBoxes()
+	c.DeleteBox(2)
+	if !c.RouteConnection(0) || len(c.connections[0].Waypoints) != 0 {
+		t.Errorf("expected a straight line, got %v", c.connections[0].Waypoints)
+	}
+}
+
+func TestRouteConnectionGivesUpOnHugeCharts(t *testing.T) {
+	c := rowOfBoxes()
+	c.AddBox(5000, 5000, "far away")
+	if c.RouteConnection(0) {
+		t.Error("expected no route on a chart too big to search")
+	}
+}
+
+func TestMovingBoxReroutesAroundOthers(t *testing.T) {
+	c := rowOfBoxes()
+	c.MoveBox(2, 0, 20) // out of the way
+	c.MoveBox(1, 0, 1)
+	c.MoveBox(2, 0, -20) // back in the way
+	c.MoveBox(1, 0, -1)
+	if conn := c.connections[0]; c.crossesBoxes(conn) {
+		t.Errorf("expected the line rerouted around C, got %+v", conn)
+	}
+}
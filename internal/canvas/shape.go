@@ -0,0 +1,154 @@
+package canvas
+
+// borderRunes are the runes a border style draws a rectangle with.
+type borderRunes struct {
+	topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical rune
+}
+
+func borderStyleRunes(style BorderStyle) borderRunes {
+	switch style {
+	case BorderStyleSingle:
+		return borderRunes{'┌', '┐', '└', '┘', '─', '│'}
+	case BorderStyleDouble:
+		return borderRunes{'╔', '╗', '╚', '╝', '═', '║'}
+	case BorderStyleRounded:
+		return borderRunes{'╭', '╮', '╰', '╯', '─', '│'}
+	default:
+		return borderRunes{'+', '+', '+', '+', '-', '|'}
+	}
+}
+
+// shapeRune is what box draws at column dx, row dy of its outline, or 0
+// inside the box and at the corners its shape cuts off. Shapes other than
+// the rectangle and document keep the style's straight edges but draw their
+// slanted and curved parts in ASCII, or in Unicode for any other style.
+func shapeRune(box Box, dx, dy int) rune {
+	b := borderStyleRunes(box.BorderStyle)
+	ascii := box.BorderStyle == BorderStyleASCII
+	slash, backslash := '╱', '╲'
+	if ascii {
+		slash, backslash = '/', '\\'
+	}
+	top, bottom := dy == 0, dy == box.Height-1
+	left, right := dx == 0, dx == box.Width-1
+	if !top && !bottom && !left && !right {
+		return 0
+	}
+
+	switch box.Shape {
+	case ShapeDiamond:
+		mid := box.Height / 2
+		switch {
+		case (top || bottom) && (left || right):
+			return 0
+		case top && dx == 1, bottom && dx == box.Width-2:
+			return slash
+		case top && dx == box.Width-2, bottom && dx == 1:
+			return backslash
+		case top || bottom:
+			return b.horizontal
+		case dy == mid && left:
+			return '<'
+		case dy == mid:
+			return '>'
+		case (dy < mid) == left:
+			return slash
+		default:
+			return backslash
+		}
+
+	case ShapeTerminator:
+		switch {
+		case top && left:
+			return pick(ascii, '/', '╭')
+		case top && right:
+			return pick(ascii, '\\', '╮')
+		case bottom && left:
+			return pick(ascii, '\\', '╰')
+		case bottom && right:
+			return pick(ascii, '/', '╯')
+		case left:
+			return '('
+		case right:
+			return ')'
+		}
+		return b.horizontal
+
+	case ShapeParallelogram:
+		switch {
+		case top && left, bottom && right:
+			return 0
+		case top && dx == 1, bottom && dx == box.Width-2, left, right:
+			return slash
+		}
+		return b.horizontal
+
+	case ShapeDatabase:
+		switch {
+		case top && left:
+			return '('
+		case top && right:
+			return ')'
+		case bottom && left:
+			return pick(ascii, '\\', '╰')
+		case bottom && right:
+			return pick(ascii, '/', '╯')
+		case left || right:
+			return b.vertical
+		}
+		return b.horizontal
+	}
+
+	switch {
+	case top && left:
+		return b.topLeft
+	case top && right:
+		return b.topRight
+	case bottom && left:
+		return b.bottomLeft
+	case bottom && right:
+		return b.bottomRight
+	case left || right:
+		return b.vertical
+	case bottom && box.Shape == ShapeDocument:
+		return '~'
+	}
+	return b.horizontal
+}
+
+func pick(ascii bool, a, u rune) rune {
+	if ascii {
+		return a
+	}
+	return u
+}
+
+// shapeAnchor moves a line end at (x, y) on the edge of box to where its
+// shape lets lines attach to that edge: the tip of a diamond, and anywhere
+// but the corners of the other shapes that aren't rectangles.
+func (c *Canvas) shapeAnchor(box Box, x, y int) (int, int) {
+	if box.Shape == ShapeRectangle || box.Shape == ShapeDocument {
+		return x, y
+	}
+	edge := c.GetConnectionEdge(box, x, y)
+	if box.Shape == ShapeDiamond {
+		switch edge {
+		case "left":
+			return box.X, box.Y + box.Height/2
+		case "right":
+			return box.X + box.Width - 1, box.Y + box.Height/2
+		case "top":
+			return box.X + box.Width/2, box.Y
+		case "bottom":
+			return box.X + box.Width/2, box.Y + box.Height - 1
+		}
+		return x, y
+	}
+	switch edge {
+	case "left", "right":
+		y = min(max(y, box.Y+1), box.Y+box.Height-2)
+	case "top", "bottom":
+		x = min(max(x, box.X+1), box.X+box.Width-2)
+	}
+	return x, y
+}
@@ -0,0 +1,97 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestShapesDrawTheirOutline(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "Ok?")
+	c.SetBoxShape(0, ShapeDiamond)
+	c.AddBox(10, 0, "Report")
+	c.SetBoxShape(1, ShapeDocument)
+
+	var buf bytes.Buffer
+	if err := c.WriteVisualTXT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[0], " /----\\ ") || !strings.HasPrefix(lines[1], "<Ok?   >") || !strings.HasPrefix(lines[2], " \\----/ ") {
+		t.Errorf("expected a diamond with its tips on the middle row, got:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[2], "+~~~~~~+") {
+		t.Errorf("expected a wavy bottom on the document, got:\n%s", buf.String())
+	}
+}
+
+func TestDiamondLinesAttachAtTheTips(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(10, 10, "Decide")
+	c.boxes[0].Height = 5
+	c.AddBox(40, 10, "Next")
+	c.AddConnection(0, 1)
+	c.connections[0].FromY = 11
+
+	c.SetBoxShape(0, ShapeDiamond)
+	if conn := c.connections[0]; conn.FromX != 17 || conn.FromY != 12 {
+		t.Errorf("expected the line to move to the right tip at (17,12), got (%d,%d)", conn.FromX, conn.FromY)
+	}
+	if x, y := c.FindNearestEdgePoint(c.boxes[0], 12, 9); x != 14 || y != 10 {
+		t.Errorf("expected a line drawn near the top to start at the top tip (14,10), got (%d,%d)", x, y)
+	}
+
+	c.SetBoxShape(0, ShapeTerminator)
+	if x, y := c.FindNearestEdgePoint(c.boxes[0], 9, 9); x != 10 || y != 11 {
+		t.Errorf("expected a line near a rounded corner to start beside it at (10,11), got (%d,%d)", x, y)
+	}
+}
+
+func TestShapesCarryOverToGraphFormats(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Ok?")
+	c.AddBox(2, 12, "Orders")
+	c.SetBoxShape(0, ShapeDiamond)
+	c.SetBoxShape(1, ShapeDatabase)
+	c.AddConnection(0, 1)
+
+	var mermaid, dot, plantuml bytes.Buffer
+	if err := c.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WritePlantUML(&plantuml); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`n0{"Ok?"}`, `n1[("Orders")]`} {
+		if !strings.Contains(mermaid.String(), want) {
+			t.Errorf("expected Mermaid to contain %s, got:\n%s", want, mermaid.String())
+		}
+	}
+	for _, want := range []string{"shape=diamond", "shape=cylinder"} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("expected DOT to contain %s, got:\n%s", want, dot.String())
+		}
+	}
+	for _, want := range []string{`hexagon "Ok?" as n0`, `database "Orders" as n1`} {
+		if !strings.Contains(plantuml.String(), want) {
+			t.Errorf("expected PlantUML to contain %s, got:\n%s", want, plantuml.String())
+		}
+	}
+
+	for name, parse := range map[string]func() (*Canvas, error){
+		"Mermaid": func() (*Canvas, error) { return ParseMermaid(&mermaid) },
+		"DOT":     func() (*Canvas, error) { return ParseDOT(&dot) },
+	} {
+		got, err := parse()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.boxes[0].Shape != ShapeDiamond || got.boxes[1].Shape != ShapeDatabase {
+			t.Errorf("%s: expected the shapes back, got %v and %v", name, got.boxes[0].Shape, got.boxes[1].Shape)
+		}
+	}
+}
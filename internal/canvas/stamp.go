@@ -0,0 +1,67 @@
+package canvas
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"time"
+)
+
+// FileStamp identifies one version of a file on disk, so an editor can tell
+// whether the file was changed by something else since it was loaded or
+// saved.
+type FileStamp struct {
+	ModTime time.Time
+	Size    int64
+	Hash    [sha256.Size]byte
+}
+
+// IsZero reports whether the stamp is unset, as for a chart that was never
+// loaded from or saved to a file.
+func (s FileStamp) IsZero() bool {
+	return s.ModTime.IsZero() && s.Size == 0 && s.Hash == [sha256.Size]byte{}
+}
+
+// SameContent reports whether both stamps describe the same bytes, whatever
+// their modification times.
+func (s FileStamp) SameContent(other FileStamp) bool {
+	return s.Size == other.Size && s.Hash == other.Hash
+}
+
+func stampOf(info os.FileInfo, data []byte) FileStamp {
+	return FileStamp{ModTime: info.ModTime(), Size: info.Size(), Hash: sha256.Sum256(data)}
+}
+
+// readStamped reads a file along with its stamp, taking both from the same
+// open file.
+func readStamped(filename string) ([]byte, FileStamp, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, FileStamp{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, FileStamp{}, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, FileStamp{}, err
+	}
+	return data, stampOf(info, data), nil
+}
+
+// StampFile returns the stamp of a file as it is now. When the file's size
+// and modification time still match prev, prev is returned without reading
+// the file again.
+func StampFile(filename string, prev FileStamp) (FileStamp, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return FileStamp{}, err
+	}
+	if !prev.IsZero() && info.Size() == prev.Size && info.ModTime().Equal(prev.ModTime) {
+		return prev, nil
+	}
+	_, stamp, err := readStamped(filename)
+	return stamp, err
+}
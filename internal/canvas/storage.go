@@ -2,32 +2,69 @@ package canvas
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 	"strings"
 )
 
+// SaveOptions controls how Save writes a chart.
+type SaveOptions struct {
+	// Pan is the pan offset to save with the chart, or nil for none.
+	Pan *Point
+	// Backups is how many earlier versions of the file to keep as
+	// name~, name~2 and so on; zero keeps none.
+	Backups int
+}
+
 func (c *Canvas) SaveToFile(filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	return c.Save(filename, SaveOptions{})
+}
+
+func (c *Canvas) SaveToFileWithPan(filename string, panX, panY int) error {
+	return c.Save(filename, SaveOptions{Pan: &Point{X: panX, Y: panY}})
+}
+
+// Save writes the chart atomically, in the JSON format for names ending in
+// JSONExtension and the .sav text format otherwise. See WriteFileAtomic.
+func (c *Canvas) Save(filename string, opts SaveOptions) error {
+	return WriteFileAtomic(filename, opts.Backups, func(w io.Writer) error {
+		if IsJSONFile(filename) {
+			return c.WriteJSON(w, opts.Pan)
+		}
+		return c.writeSAV(w, opts.Pan)
+	})
+}
+
+// savWriter keeps the first error from writing a .sav so that every line is
+// checked without an if after each one.
+type savWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (s *savWriter) printf(format string, args ...any) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, args...)
 	}
-	defer file.Close()
+}
 
-	fmt.Fprintf(file, "FLOWCHART\n")
-	fmt.Fprintf(file, "BOXES:%d\n", len(c.boxes))
+func (c *Canvas) writeSAV(w io.Writer, pan *Point) error {
+	file := &savWriter{w: bufio.NewWriter(w)}
+	file.printf("FLOWCHART\n")
+	file.printf("BOXES:%d\n", len(c.boxes))
 	for _, box := range c.boxes {
 		encodedText := strings.ReplaceAll(box.GetText(), "\n", "\\n")
 
 		encodedTitle := strings.ReplaceAll(box.Title, "\n", "\\n")
 		encodedTitle = strings.ReplaceAll(encodedTitle, ",", "\\,")
 
-		fmt.Fprintf(file, "%d,%d,%d,%d,%d,%d,%s,%s\n",
+		file.printf("%d,%d,%d,%d,%d,%d,%s,%s\n",
 			box.X, box.Y, box.Width, box.Height, box.ZLevel, box.BorderStyle, encodedTitle, encodedText)
 	}
 
-	fmt.Fprintf(file, "CONNECTIONS:%d\n", len(c.connections))
+	file.printf("CONNECTIONS:%d\n", len(c.connections))
 	for _, connection := range c.connections {
 		waypointsStr := ""
 		if len(connection.Waypoints) > 0 {
@@ -44,24 +81,22 @@ func (c *Canvas) SaveToFile(filename string) error {
 		if connection.ArrowTo {
 			arrowFlags |= 2
 		}
-		fmt.Fprintf(file, "%d,%d,%d,%d,%d,%d,%d,%d%s\n",
-			connection.FromID, connection.ToID,
+		file.printf("%d,%d,%d,%d,%d,%d,%d,%d%s\n",
+			c.BoxIndex(connection.FromID), c.BoxIndex(connection.ToID),
 			connection.FromX, connection.FromY,
 			connection.ToX, connection.ToY,
 			len(connection.Waypoints), arrowFlags, waypointsStr)
 	}
 
-	fmt.Fprintf(file, "TEXTS:%d\n", len(c.texts))
+	file.printf("TEXTS:%d\n", len(c.texts))
 	for _, text := range c.texts {
 		encodedText := strings.ReplaceAll(text.GetText(), "\n", "\\n")
-		fmt.Fprintf(file, "%d,%d,%s\n", text.X, text.Y, encodedText)
+		file.printf("%d,%d,%s\n", text.X, text.Y, encodedText)
 	}
 
-	fmt.Fprintf(file, "HIGHLIGHTS:%d\n", len(c.highlights))
-	for key, colorIndex := range c.highlights {
-		var x, y int
-		fmt.Sscanf(key, "%d,%d", &x, &y)
-		fmt.Fprintf(file, "%d,%d,%d\n", x, y, colorIndex)
+	file.printf("HIGHLIGHTS:%d\n", len(c.highlights))
+	for _, cell := range c.sortedHighlights() {
+		file.printf("%d,%d,%d\n", cell.X, cell.Y, cell.Color)
 	}
 
 	writeColors := func(header string, colors []int) {
@@ -71,9 +106,9 @@ func (c *Canvas) SaveToFile(filename string) error {
 				lines = append(lines, fmt.Sprintf("%d,%d", i, col))
 			}
 		}
-		fmt.Fprintf(file, "%s:%d\n", header, len(lines))
+		file.printf("%s:%d\n", header, len(lines))
 		for _, line := range lines {
-			fmt.Fprintln(file, line)
+			file.printf("%s\n", line)
 		}
 	}
 	boxColors := make([]int, len(c.boxes))
@@ -92,22 +127,74 @@ func (c *Canvas) SaveToFile(filename string) error {
 	writeColors("LINECOLORS", lineColors)
 	writeColors("TEXTCOLORS", textColors)
 
-	return nil
-}
+	// Only boxes that aren't rectangles are listed, and a chart with none
+	// has no section, so it saves exactly as before shapes existed.
+	var shapes []string
+	for i, b := range c.boxes {
+		if b.Shape != ShapeRectangle {
+			shapes = append(shapes, fmt.Sprintf("%d,%d", i, b.Shape))
+		}
+	}
+	if len(shapes) > 0 {
+		file.printf("BOXSHAPES:%d\n", len(shapes))
+		for _, line := range shapes {
+			file.printf("%s\n", line)
+		}
+	}
 
-func (c *Canvas) SaveToFileWithPan(filename string, panX, panY int) error {
-	err := c.SaveToFile(filename)
-	if err != nil {
-		return err
+	// Likewise for labeled lines. The label is the rest of the line, so it
+	// may hold commas.
+	var labels []string
+	for i, cn := range c.connections {
+		if cn.Label != "" {
+			labels = append(labels, fmt.Sprintf("%d,%d,%s", i, cn.LabelSegment, strings.ReplaceAll(cn.Label, "\n", "\\n")))
+		}
 	}
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+	if len(labels) > 0 {
+		file.printf("LINELABELS:%d\n", len(labels))
+		for _, line := range labels {
+			file.printf("%s\n", line)
+		}
+	}
+
+	// Connections above refer to boxes by index. The IDs sections list only
+	// the objects whose ID differs from their index, so a chart that never
+	// had anything deleted saves exactly as before IDs existed.
+	writeIDs := func(header string, ids []int) {
+		var lines []string
+		for i, id := range ids {
+			if id != i {
+				lines = append(lines, fmt.Sprintf("%d,%d", i, id))
+			}
+		}
+		file.printf("%s:%d\n", header, len(lines))
+		for _, line := range lines {
+			file.printf("%s\n", line)
+		}
+	}
+	boxIDs := make([]int, len(c.boxes))
+	for i, b := range c.boxes {
+		boxIDs[i] = b.ID
+	}
+	lineIDs := make([]int, len(c.connections))
+	for i, cn := range c.connections {
+		lineIDs[i] = cn.ID
 	}
-	defer file.Close()
+	textIDs := make([]int, len(c.texts))
+	for i, t := range c.texts {
+		textIDs[i] = t.ID
+	}
+	writeIDs("BOXIDS", boxIDs)
+	writeIDs("LINEIDS", lineIDs)
+	writeIDs("TEXTIDS", textIDs)
 
-	fmt.Fprintf(file, "PAN:%d,%d\n", panX, panY)
-	return nil
+	if pan != nil {
+		file.printf("PAN:%d,%d\n", pan.X, pan.Y)
+	}
+	if file.err != nil {
+		return file.err
+	}
+	return file.w.Flush()
 }
 
 func splitBoxLine(line string) []string {
@@ -136,312 +223,548 @@ func splitBoxLine(line string) []string {
 }
 
 func (c *Canvas) LoadFromFile(filename string) error {
-	file, err := os.Open(filename)
+	_, _, err := c.LoadFromFileWithPan(filename)
+	return err
+}
+
+// LoadFromFileWithPan replaces the chart with a saved one and returns the pan
+// offset saved with it. The chart is left untouched when the file can't be
+// loaded; use Load to see warnings or to salvage a damaged file.
+func (c *Canvas) LoadFromFileWithPan(filename string) (int, int, error) {
+	res, err := Load(filename, LoadOptions{})
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
-	defer file.Close()
+	*c = *res.Canvas
+	if res.Pan == nil {
+		return 0, 0, nil
+	}
+	return res.Pan.X, res.Pan.Y, nil
+}
+
+// FormatChart re-encodes a saved chart in canonical form, keeping its format
+// and pan offset: every section in a fixed order, objects in order and
+// highlights sorted by position. Older .sav variants are upgraded to the
+// current layout. name is only used in diagnostics.
+func FormatChart(name string, data []byte) ([]byte, error) {
+	res, err := Decode(name, data, LoadOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if res.JSON {
+		err = res.Canvas.WriteJSON(&buf, res.Pan)
+	} else {
+		err = res.Canvas.writeSAV(&buf, res.Pan)
+	}
+	return buf.Bytes(), err
+}
+
+// maxSavLine bounds a single .sav line, which holds a whole box's text.
+const maxSavLine = 16 << 20
 
-	c.boxes = c.boxes[:0]
-	c.connections = c.connections[:0]
-	c.texts = c.texts[:0]
-	c.highlights = make(map[string]int)
+// savSections are the sections readSAV understands, other than PAN which
+// carries its value on the header line.
+var savSections = map[string]bool{
+	"BOXES": true, "CONNECTIONS": true, "TEXTS": true, "HIGHLIGHTS": true,
+	"BOXCOLORS": true, "LINECOLORS": true, "TEXTCOLORS": true,
+	"BOXSHAPES": true, "LINELABELS": true,
+	"BOXIDS": true, "LINEIDS": true, "TEXTIDS": true,
+}
+
+// savReader holds a .sav file as it is read. Objects refer to each other by
+// their position in the file, so every section keeps a slot per line, with
+// -1 for lines that were skipped while salvaging.
+type savReader struct {
+	d    *diagnostics
+	line int
+
+	section     string
+	headerLine  int
+	count       int
+	entries     int
+	seen        map[string]bool
+	boxes       []Box
+	boxSlots    []int
+	conns       []savConnection
+	texts       []Text
+	textSlots   []int
+	highlights  map[string]int
+	annotations []savAnnotation
+	pan         *Point
+}
+
+// savConnection is a CONNECTIONS line. From and To are box positions in the
+// file; legacy lines only name the boxes and get their points computed once
+// the boxes are known.
+type savConnection struct {
+	line     int
+	ok       bool
+	legacy   bool
+	from, to int
+	conn     Connection
+}
+
+// savAnnotation is a line of one of the color, shape, label or ID sections,
+// applied once every object has been read. A label line keeps its segment in
+// value and the label in text.
+type savAnnotation struct {
+	line    int
+	section string
+	index   int
+	value   int
+	text    string
+}
+
+// readSAV fills an empty canvas from the .sav text format, reporting problems
+// to d, and returns the saved pan offset or nil.
+func (c *Canvas) readSAV(file io.Reader, d *diagnostics) *Point {
+	r := &savReader{d: d, seen: map[string]bool{}, highlights: map[string]int{}, count: -1}
 
 	scanner := bufio.NewScanner(file)
-	if !scanner.Scan() || scanner.Text() != "FLOWCHART" {
-		return fmt.Errorf("invalid file format")
+	scanner.Buffer(nil, maxSavLine)
+	if !scanner.Scan() || strings.TrimSuffix(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r") != "FLOWCHART" {
+		if err := scanner.Err(); err != nil {
+			d.fatalf(1, "%v", err)
+		} else {
+			d.fatalf(1, "not a flerm chart: missing FLOWCHART header")
+		}
+		return nil
 	}
-	if !scanner.Scan() {
-		return fmt.Errorf("missing boxes header")
+	r.line = 1
+	for scanner.Scan() {
+		r.line++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if header, value, ok := savHeader(line); ok {
+			r.startSection(header, value)
+			continue
+		}
+		switch r.section {
+		case "BOXES":
+			r.readBox(line)
+		case "CONNECTIONS":
+			r.readConnection(line)
+		case "TEXTS":
+			r.readText(line)
+		case "HIGHLIGHTS":
+			r.readHighlight(line)
+		case "BOXCOLORS", "LINECOLORS", "TEXTCOLORS", "BOXSHAPES", "BOXIDS", "LINEIDS", "TEXTIDS":
+			r.readAnnotation(line)
+		case "LINELABELS":
+			r.readLabel(line)
+		case "":
+			r.errorf("unexpected line outside any section")
+			continue
+		default:
+			// Body of an unknown section, already warned about.
+			continue
+		}
+		r.entries++
 	}
-	boxCountStr := strings.TrimPrefix(scanner.Text(), "BOXES:")
-	boxCount, err := strconv.Atoi(boxCountStr)
-	if err != nil {
-		return fmt.Errorf("invalid box count: %v", err)
+	if err := scanner.Err(); err != nil {
+		d.fatalf(r.line+1, "%v", err)
+		return nil
 	}
-
-	for i := 0; i < boxCount; i++ {
-		if !scanner.Scan() {
-			return fmt.Errorf("missing box data")
+	r.startSection("", "")
+	for _, required := range []string{"BOXES", "CONNECTIONS"} {
+		if !r.seen[required] {
+			d.errorf(0, "missing %s section", required)
 		}
-		line := scanner.Text()
-
-		var x, y, width, height, zLevel int
-		var borderStyle BorderStyle
-		var title, text string
+	}
 
-		fields := splitBoxLine(line)
+	r.build(c)
+	return r.pan
+}
 
-		if len(fields) < 3 {
-			return fmt.Errorf("invalid box format")
+// savHeader splits a section header such as "BOXES:3". Object lines always
+// start with a number, so an upper-case word before the colon is enough to
+// tell them apart.
+func savHeader(line string) (string, string, bool) {
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return "", "", false
+	}
+	for _, ch := range line[:colon] {
+		if ch < 'A' || ch > 'Z' {
+			return "", "", false
 		}
+	}
+	return line[:colon], line[colon+1:], true
+}
 
-		x, _ = strconv.Atoi(fields[0])
-		y, _ = strconv.Atoi(fields[1])
-
-		if len(fields) >= 8 {
+func (r *savReader) errorf(format string, args ...any) {
+	r.d.errorf(r.line, format, args...)
+}
 
-			width, _ = strconv.Atoi(fields[2])
-			height, _ = strconv.Atoi(fields[3])
-			zLevel, _ = strconv.Atoi(fields[4])
-			if zLevel < 0 || zLevel > 3 {
-				zLevel = 0
-			}
-			borderStyleInt, _ := strconv.Atoi(fields[5])
-			borderStyle = BorderStyle(borderStyleInt)
-
-			title = strings.ReplaceAll(fields[6], "\\,", ",")
-			title = strings.ReplaceAll(title, "\\n", "\n")
-			text = strings.ReplaceAll(strings.Join(fields[7:], ","), "\\n", "\n")
-		} else if len(fields) >= 6 {
-
-			width, _ = strconv.Atoi(fields[2])
-			height, _ = strconv.Atoi(fields[3])
-			zLevel, _ = strconv.Atoi(fields[4])
-			if zLevel < 0 || zLevel > 3 {
-				zLevel = 0
-			}
-			borderStyle = BorderStyleASCII
-			title = ""
-			text = strings.ReplaceAll(strings.Join(fields[5:], ","), "\\n", "\n")
-		} else if len(fields) >= 5 {
-
-			width, _ = strconv.Atoi(fields[2])
-			height, _ = strconv.Atoi(fields[3])
-			zLevel = 0
-			borderStyle = BorderStyleASCII
-			title = ""
-			text = strings.ReplaceAll(strings.Join(fields[4:], ","), "\\n", "\n")
-		} else {
+func (r *savReader) warnf(format string, args ...any) {
+	r.d.warnf(r.line, format, args...)
+}
 
-			text = strings.ReplaceAll(strings.Join(fields[2:], ","), "\\n", "\n")
-			box := Box{
-				X:           x,
-				Y:           y,
-				ID:          i,
-				ZLevel:      0,
-				BorderStyle: BorderStyleASCII,
-				Title:       "",
-				Color:       -1,
+// startSection checks that the section being left had as many lines as its
+// header promised and begins the next one. An empty header ends the file.
+func (r *savReader) startSection(header, value string) {
+	if r.count >= 0 && r.entries != r.count {
+		r.d.errorf(r.headerLine, "%s declares %d entries but has %d", r.section, r.count, r.entries)
+	}
+	r.section, r.headerLine, r.count, r.entries = "", r.line, -1, 0
+	switch {
+	case header == "":
+		return
+	case header == "PAN":
+		parts := strings.Split(value, ",")
+		if len(parts) == 2 {
+			x, err1 := strconv.Atoi(parts[0])
+			y, err2 := strconv.Atoi(parts[1])
+			if err1 == nil && err2 == nil {
+				r.pan = &Point{X: x, Y: y}
+				return
 			}
-			box.SetText(text)
-			c.boxes = append(c.boxes, box)
-			continue
 		}
+		r.errorf("invalid PAN %q: want X,Y", value)
+		return
+	case !savSections[header]:
+		r.warnf("unknown section %s skipped", header)
+		r.section = "?"
+		return
+	case r.seen[header]:
+		r.errorf("duplicate %s section skipped", header)
+		r.section = "?"
+		return
+	}
+	r.seen[header] = true
+	r.section = header
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		r.errorf("invalid %s count %q", header, value)
+		return
+	}
+	r.count = count
+}
 
-		box := Box{
-			X:           x,
-			Y:           y,
-			ID:          i,
-			ZLevel:      zLevel,
-			BorderStyle: borderStyle,
-			Title:       title,
-			Color:       -1,
+// atoi parses the numeric fields of a line, reporting the first one that
+// isn't a number by name.
+func (r *savReader) atoi(kind string, names []string, fields []string) ([]int, bool) {
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		v, err := strconv.Atoi(field)
+		if err != nil {
+			r.errorf("invalid %s %s %q: not a number", kind, names[i], field)
+			return nil, false
 		}
-		box.SetText(text)
-		box.Width = width
-		box.Height = height
-		c.boxes = append(c.boxes, box)
+		values[i] = v
 	}
+	return values, true
+}
 
-	if !scanner.Scan() {
-		return fmt.Errorf("missing connections header")
+var savBoxFields = []string{"X", "Y", "Width", "Height", "ZLevel", "BorderStyle"}
+
+// readBox reads a box line. Older files left out the trailing numbers and the
+// title, so the field count decides which layout the line has.
+func (r *savReader) readBox(line string) {
+	r.boxSlots = append(r.boxSlots, -1)
+	fields := splitBoxLine(line)
+
+	var numeric int
+	switch {
+	case len(fields) >= 8:
+		numeric = 6
+	case len(fields) >= 6:
+		numeric = 5
+	case len(fields) >= 5:
+		numeric = 4
+	case len(fields) >= 3:
+		numeric = 2
+	default:
+		r.errorf("invalid box: want X,Y,Width,Height,ZLevel,BorderStyle,Title,Text")
+		return
 	}
-	connectionCountStr := strings.TrimPrefix(scanner.Text(), "CONNECTIONS:")
-	connectionCount, err := strconv.Atoi(connectionCountStr)
-	if err != nil {
-		return fmt.Errorf("invalid connection count: %v", err)
+	values, ok := r.atoi("box", savBoxFields, fields[:numeric])
+	if !ok {
+		return
 	}
 
-	for i := 0; i < connectionCount; i++ {
-		if !scanner.Scan() {
-			return fmt.Errorf("missing connection data")
+	box := Box{X: values[0], Y: values[1], ID: len(r.boxSlots) - 1, Color: -1}
+	rest := fields[numeric:]
+	if numeric == 6 {
+		box.Title = strings.ReplaceAll(rest[0], "\\,", ",")
+		box.Title = strings.ReplaceAll(box.Title, "\\n", "\n")
+		rest = rest[1:]
+	}
+	if numeric >= 5 {
+		box.ZLevel = values[4]
+		if box.ZLevel < 0 || box.ZLevel > 3 {
+			r.warnf("ZLevel %d out of range 0-3; using 0", box.ZLevel)
+			box.ZLevel = 0
+		}
+	}
+	if numeric == 6 {
+		box.BorderStyle = BorderStyle(values[5])
+		if values[5] < 0 || values[5] >= len(borderStyleNames) {
+			r.warnf("unknown BorderStyle %d; using ASCII", values[5])
+			box.BorderStyle = BorderStyleASCII
 		}
-		line := scanner.Text()
+	}
+	box.SetText(strings.ReplaceAll(strings.Join(rest, ","), "\\n", "\n"))
+	if numeric >= 4 {
+		box.Width = values[2]
+		box.Height = values[3]
+	}
 
-		parts := strings.Split(line, "|")
-		mainParts := strings.Split(parts[0], ",")
+	r.boxSlots[len(r.boxSlots)-1] = len(r.boxes)
+	r.boxes = append(r.boxes, box)
+}
 
-		if len(mainParts) == 2 {
-			fromID, _ := strconv.Atoi(mainParts[0])
-			toID, _ := strconv.Atoi(mainParts[1])
-			if fromID >= 0 && fromID < len(c.boxes) && toID >= 0 && toID < len(c.boxes) {
-				c.AddConnection(fromID, toID)
-			}
-		} else if len(mainParts) >= 7 {
-			fromID, _ := strconv.Atoi(mainParts[0])
-			toID, _ := strconv.Atoi(mainParts[1])
-			fromX, _ := strconv.Atoi(mainParts[2])
-			fromY, _ := strconv.Atoi(mainParts[3])
-			toX, _ := strconv.Atoi(mainParts[4])
-			toY, _ := strconv.Atoi(mainParts[5])
-			waypointCount, _ := strconv.Atoi(mainParts[6])
-
-			arrowFlags := 2
-			if len(mainParts) >= 8 {
-				arrowFlags, _ = strconv.Atoi(mainParts[7])
-			}
+var savConnectionFields = []string{"FromID", "ToID", "FromX", "FromY", "ToX", "ToY", "WaypointCount", "arrow flags"}
 
-			var waypoints []Point
-			if len(parts) > 1 && waypointCount > 0 {
-				waypointParts := strings.Split(parts[1], ",")
-				for j := 0; j < waypointCount && j < len(waypointParts); j++ {
-					wpParts := strings.Split(waypointParts[j], ":")
-					if len(wpParts) == 2 {
-						wpX, _ := strconv.Atoi(wpParts[0])
-						wpY, _ := strconv.Atoi(wpParts[1])
-						waypoints = append(waypoints, Point{wpX, wpY})
-					}
-				}
-			}
+func (r *savReader) readConnection(line string) {
+	sc := savConnection{line: r.line}
+	defer func() { r.conns = append(r.conns, sc) }()
 
-			connection := Connection{
-				FromID:    fromID,
-				ToID:      toID,
-				FromX:     fromX,
-				FromY:     fromY,
-				ToX:       toX,
-				ToY:       toY,
-				Waypoints: waypoints,
-				ArrowFrom: (arrowFlags & 1) != 0,
-				ArrowTo:   (arrowFlags & 2) != 0,
-				Color:     -1,
-			}
-			c.connections = append(c.connections, connection)
-		} else {
-			return fmt.Errorf("invalid connection format")
-		}
-	}
-
-	if scanner.Scan() {
-		textCountStr := strings.TrimPrefix(scanner.Text(), "TEXTS:")
-		textCount, err := strconv.Atoi(textCountStr)
-		if err == nil {
-			for i := 0; i < textCount; i++ {
-				if !scanner.Scan() {
-					break
-				}
-				line := scanner.Text()
-
-				firstComma := strings.Index(line, ",")
-				if firstComma == -1 {
-					continue
-				}
-				secondComma := strings.Index(line[firstComma+1:], ",")
-				if secondComma == -1 {
-					continue
-				}
-				secondComma += firstComma + 1
-
-				x, err1 := strconv.Atoi(line[:firstComma])
-				y, err2 := strconv.Atoi(line[firstComma+1 : secondComma])
-				if err1 != nil || err2 != nil {
-					continue
-				}
-				text := strings.ReplaceAll(line[secondComma+1:], "\\n", "\n")
-				c.AddText(x, y, text)
-			}
+	parts := strings.SplitN(line, "|", 2)
+	fields := strings.Split(parts[0], ",")
+	if len(fields) == 2 {
+		values, ok := r.atoi("connection", savConnectionFields, fields)
+		if !ok {
+			return
 		}
+		sc.ok, sc.legacy, sc.from, sc.to = true, true, values[0], values[1]
+		return
+	}
+	if len(fields) < 7 || len(fields) > 8 {
+		r.errorf("invalid connection: want FromID,ToID,FromX,FromY,ToX,ToY,WaypointCount,Arrows|X:Y,...")
+		return
+	}
+	values, ok := r.atoi("connection", savConnectionFields, fields)
+	if !ok {
+		return
+	}
+
+	// Files from before arrows were saved always had one at the To end.
+	arrowFlags := 2
+	if len(values) == 8 {
+		arrowFlags = values[7]
 	}
 
-	if scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "HIGHLIGHTS:") {
-			highlightCountStr := strings.TrimPrefix(line, "HIGHLIGHTS:")
-			highlightCount, err := strconv.Atoi(highlightCountStr)
-			if err == nil {
-				for i := 0; i < highlightCount; i++ {
-					if !scanner.Scan() {
-						break
-					}
-					parts := strings.Split(scanner.Text(), ",")
-					if len(parts) >= 3 {
-						x, _ := strconv.Atoi(parts[0])
-						y, _ := strconv.Atoi(parts[1])
-						colorIndex, _ := strconv.Atoi(parts[2])
-						if colorIndex >= 0 && colorIndex < NumColors {
-							c.SetHighlight(x, y, colorIndex)
-						}
-					}
-				}
+	var waypoints []Point
+	if count := values[6]; count > 0 {
+		var listed []string
+		if len(parts) > 1 && parts[1] != "" {
+			listed = strings.Split(parts[1], ",")
+		}
+		if len(listed) != count {
+			r.errorf("connection declares %d waypoints but lists %d", count, len(listed))
+			return
+		}
+		for _, wp := range listed {
+			x, y, found := strings.Cut(wp, ":")
+			wpX, err1 := strconv.Atoi(x)
+			wpY, err2 := strconv.Atoi(y)
+			if !found || err1 != nil || err2 != nil {
+				r.errorf("invalid waypoint %q: want X:Y", wp)
+				return
 			}
+			waypoints = append(waypoints, Point{X: wpX, Y: wpY})
 		}
+	} else if count < 0 {
+		r.errorf("invalid connection WaypointCount %d", count)
+		return
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		var header string
+	sc.ok, sc.from, sc.to = true, values[0], values[1]
+	sc.conn = Connection{
+		FromX:     values[2],
+		FromY:     values[3],
+		ToX:       values[4],
+		ToY:       values[5],
+		Waypoints: waypoints,
+		ArrowFrom: (arrowFlags & 1) != 0,
+		ArrowTo:   (arrowFlags & 2) != 0,
+		Color:     -1,
+	}
+}
+
+func (r *savReader) readText(line string) {
+	r.textSlots = append(r.textSlots, -1)
+	fields := strings.SplitN(line, ",", 3)
+	if len(fields) < 3 {
+		r.errorf("invalid text: want X,Y,Text")
+		return
+	}
+	values, ok := r.atoi("text", savBoxFields, fields[:2])
+	if !ok {
+		return
+	}
+	text := Text{X: values[0], Y: values[1], ID: len(r.textSlots) - 1, Color: -1}
+	text.SetText(strings.ReplaceAll(fields[2], "\\n", "\n"))
+	r.textSlots[len(r.textSlots)-1] = len(r.texts)
+	r.texts = append(r.texts, text)
+}
+
+func (r *savReader) readHighlight(line string) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 3 {
+		r.errorf("invalid highlight: want X,Y,Color")
+		return
+	}
+	values, ok := r.atoi("highlight", []string{"X", "Y", "Color"}, fields)
+	if !ok {
+		return
+	}
+	if values[2] < 0 || values[2] >= NumColors {
+		r.warnf("highlight color %d out of range 0-%d; skipped", values[2], NumColors-1)
+		return
+	}
+	r.highlights[fmt.Sprintf("%d,%d", values[0], values[1])] = values[2]
+}
+
+func (r *savReader) readAnnotation(line string) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 2 {
+		r.errorf("invalid %s entry: want index,value", r.section)
+		return
+	}
+	values, ok := r.atoi(r.section, []string{"index", "value"}, fields)
+	if !ok {
+		return
+	}
+	r.annotations = append(r.annotations, savAnnotation{line: r.line, section: r.section, index: values[0], value: values[1]})
+}
+
+func (r *savReader) readLabel(line string) {
+	fields := strings.SplitN(line, ",", 3)
+	if len(fields) != 3 {
+		r.errorf("invalid %s entry: want index,segment,label", r.section)
+		return
+	}
+	values, ok := r.atoi(r.section, []string{"index", "segment"}, fields[:2])
+	if !ok {
+		return
+	}
+	label := strings.ReplaceAll(fields[2], "\\n", "\n")
+	r.annotations = append(r.annotations, savAnnotation{line: r.line, section: r.section, index: values[0], value: values[1], text: label})
+}
+
+// build puts what was read into c, resolving connection ends and the color
+// and ID sections from file positions to the objects that were kept.
+func (r *savReader) build(c *Canvas) {
+	c.boxes = r.boxes
+	c.texts = r.texts
+	r.applyAnnotations(c, "BOX", r.boxSlots)
+
+	// boxIndex maps a box position in the file to its index in c, warning
+	// when there is no such box.
+	boxIndex := func(line, pos int) (int, bool) {
 		switch {
-		case strings.HasPrefix(line, "BOXCOLORS:"):
-			header = "BOXCOLORS"
-		case strings.HasPrefix(line, "LINECOLORS:"):
-			header = "LINECOLORS"
-		case strings.HasPrefix(line, "TEXTCOLORS:"):
-			header = "TEXTCOLORS"
+		case pos == -1:
+			return -1, true
+		case pos < -1 || pos >= len(r.boxSlots):
+			r.d.warnf(line, "connection refers to box %d, which does not exist", pos)
+		case r.boxSlots[pos] == -1:
+			r.d.warnf(line, "connection refers to box %d, which could not be loaded", pos)
 		default:
-			continue
+			return r.boxSlots[pos], true
 		}
-		count, err := strconv.Atoi(strings.TrimPrefix(line, header+":"))
-		if err != nil {
+		return -1, false
+	}
+
+	// Connection ends hold box indices until assignIDs has made the box IDs
+	// unique.
+	connSlots := make([]int, len(r.conns))
+	for i, sc := range r.conns {
+		connSlots[i] = -1
+		if !sc.ok {
 			continue
 		}
-		for i := 0; i < count; i++ {
-			if !scanner.Scan() {
-				break
-			}
-			parts := strings.Split(scanner.Text(), ",")
-			if len(parts) < 2 {
+		from, okFrom := boxIndex(sc.line, sc.from)
+		to, okTo := boxIndex(sc.line, sc.to)
+		conn := sc.conn
+		if sc.legacy {
+			if !okFrom || !okTo || from < 0 || to < 0 {
+				r.d.warnf(sc.line, "connection without both boxes dropped")
 				continue
 			}
-			idx, err1 := strconv.Atoi(parts[0])
-			col, err2 := strconv.Atoi(parts[1])
-			if err1 != nil || err2 != nil || col < 0 || col >= NumColors {
-				continue
-			}
-			switch header {
-			case "BOXCOLORS":
-				if idx >= 0 && idx < len(c.boxes) {
-					c.boxes[idx].Color = col
-				}
-			case "LINECOLORS":
-				if idx >= 0 && idx < len(c.connections) {
-					c.connections[idx].Color = col
-				}
-			case "TEXTCOLORS":
-				if idx >= 0 && idx < len(c.texts) {
-					c.texts[idx].Color = col
-				}
-			}
+			conn = Connection{Color: -1}
+			conn.FromX, conn.FromY, conn.ToX, conn.ToY = c.CalculateConnectionPoints(from, to)
 		}
+		conn.ID, conn.FromID, conn.ToID = i, from, to
+		connSlots[i] = len(c.connections)
+		c.connections = append(c.connections, conn)
 	}
+	r.applyAnnotations(c, "LINE", connSlots)
+	r.applyAnnotations(c, "TEXT", r.textSlots)
 
-	return scanner.Err()
-}
+	c.assignIDs()
+	for i := range c.connections {
+		c.connections[i].FromID = c.BoxID(c.connections[i].FromID)
+		c.connections[i].ToID = c.BoxID(c.connections[i].ToID)
+	}
 
-func (c *Canvas) LoadFromFileWithPan(filename string) (int, int, error) {
-	err := c.LoadFromFile(filename)
-	if err != nil {
-		return 0, 0, err
+	// Highlights are set once the boxes have their IDs, so each cell knows
+	// the box it was painted on.
+	for key, color := range r.highlights {
+		var x, y int
+		fmt.Sscanf(key, "%d,%d", &x, &y)
+		c.SetHighlight(x, y, color)
 	}
+}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		return 0, 0, err
+// applyAnnotations applies the COLORS, SHAPES, LABELS and IDS sections for
+// one kind of object (BOX, LINE or TEXT). slots maps positions in the file to
+// indices in c.
+func (r *savReader) applyAnnotations(c *Canvas, kind string, slots []int) {
+	var id, color func(i int) *int
+	switch kind {
+	case "BOX":
+		id = func(i int) *int { return &c.boxes[i].ID }
+		color = func(i int) *int { return &c.boxes[i].Color }
+	case "LINE":
+		id = func(i int) *int { return &c.connections[i].ID }
+		color = func(i int) *int { return &c.connections[i].Color }
+	case "TEXT":
+		id = func(i int) *int { return &c.texts[i].ID }
+		color = func(i int) *int { return &c.texts[i].Color }
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	panX, panY := 0, 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "PAN:") {
-			parts := strings.Split(strings.TrimPrefix(line, "PAN:"), ",")
-			if len(parts) >= 2 {
-				panX, _ = strconv.Atoi(parts[0])
-				panY, _ = strconv.Atoi(parts[1])
+
+	for _, a := range r.annotations {
+		if a.section != kind+"IDS" && a.section != kind+"COLORS" && a.section != kind+"SHAPES" && a.section != kind+"LABELS" {
+			continue
+		}
+		if a.index < 0 || a.index >= len(slots) {
+			r.d.warnf(a.line, "%s refers to entry %d, which does not exist", a.section, a.index)
+			continue
+		}
+		i := slots[a.index]
+		if i == -1 {
+			// Already reported when the object itself was skipped.
+			continue
+		}
+		if a.section == kind+"IDS" {
+			*id(i) = a.value
+			continue
+		}
+		if a.section == "BOXSHAPES" {
+			if a.value < 0 || a.value >= len(shapeNames) {
+				r.d.warnf(a.line, "unknown shape %d; using a rectangle", a.value)
+				continue
+			}
+			c.boxes[i].Shape = Shape(a.value)
+			continue
+		}
+		if a.section == "LINELABELS" {
+			if a.value < 0 {
+				r.d.warnf(a.line, "label segment %d; centering the label", a.value)
+				a.value = 0
 			}
-			break
+			c.connections[i].Label, c.connections[i].LabelSegment = a.text, a.value
+			continue
 		}
+		if a.value < 0 || a.value >= NumColors {
+			r.d.warnf(a.line, "color %d out of range 0-%d; ignored", a.value, NumColors-1)
+			continue
+		}
+		*color(i) = a.value
 	}
-
-	return panX, panY, scanner.Err()
 }
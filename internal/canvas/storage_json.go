@@ -0,0 +1,343 @@
+package canvas
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSONExtension marks charts saved in the JSON format; every other name is
+// saved in the legacy .sav text format.
+const JSONExtension = ".flerm.json"
+
+// jsonFormatVersion is bumped whenever a change to the JSON layout would be
+// misread by an older flerm. Version 2 added object IDs and made connection
+// ends refer to box IDs rather than box indices.
+const jsonFormatVersion = 2
+
+func IsJSONFile(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), JSONExtension)
+}
+
+type jsonChart struct {
+	Version     int              `json:"version"`
+	Pan         *jsonPoint       `json:"pan,omitempty"`
+	Boxes       []jsonBox        `json:"boxes"`
+	Connections []jsonConnection `json:"connections"`
+	Texts       []jsonText       `json:"texts"`
+	Highlights  []jsonHighlight  `json:"highlights"`
+}
+
+type jsonPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Colors are palette indices, with -1 for the default color.
+type jsonBox struct {
+	ID          int    `json:"id"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ZLevel      int    `json:"zLevel"`
+	BorderStyle string `json:"borderStyle"`
+	Shape       string `json:"shape,omitempty"`
+	Title       string `json:"title"`
+	Text        string `json:"text"`
+	Color       int    `json:"color"`
+}
+
+// From and To are box IDs, or -1 when that end sits on another line.
+type jsonConnection struct {
+	ID        int         `json:"id"`
+	From      int         `json:"from"`
+	To        int         `json:"to"`
+	FromPoint jsonPoint   `json:"fromPoint"`
+	ToPoint   jsonPoint   `json:"toPoint"`
+	Waypoints []jsonPoint `json:"waypoints"`
+	ArrowFrom bool        `json:"arrowFrom"`
+	ArrowTo   bool        `json:"arrowTo"`
+	Color     int         `json:"color"`
+	// Written only for labeled lines.
+	Label        string `json:"label,omitempty"`
+	LabelSegment int    `json:"labelSegment,omitempty"`
+}
+
+type jsonText struct {
+	ID    int    `json:"id"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Text  string `json:"text"`
+	Color int    `json:"color"`
+}
+
+type jsonHighlight struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Color int `json:"color"`
+}
+
+var borderStyleNames = []string{
+	BorderStyleASCII:   "ascii",
+	BorderStyleSingle:  "single",
+	BorderStyleDouble:  "double",
+	BorderStyleRounded: "rounded",
+}
+
+func (s BorderStyle) String() string {
+	if s >= 0 && int(s) < len(borderStyleNames) {
+		return borderStyleNames[s]
+	}
+	return fmt.Sprintf("BorderStyle(%d)", int(s))
+}
+
+func parseBorderStyle(name string) (BorderStyle, error) {
+	for i, n := range borderStyleNames {
+		if n == name {
+			return BorderStyle(i), nil
+		}
+	}
+	return BorderStyleASCII, fmt.Errorf("unknown border style %q", name)
+}
+
+var shapeNames = []string{
+	ShapeRectangle:     "rectangle",
+	ShapeDiamond:       "diamond",
+	ShapeTerminator:    "terminator",
+	ShapeParallelogram: "parallelogram",
+	ShapeDatabase:      "database",
+	ShapeDocument:      "document",
+}
+
+func (s Shape) String() string {
+	if s >= 0 && int(s) < len(shapeNames) {
+		return shapeNames[s]
+	}
+	return fmt.Sprintf("Shape(%d)", int(s))
+}
+
+// parseShape reads a shape name; the empty string, which rectangles are
+// saved as, is a rectangle.
+func parseShape(name string) (Shape, error) {
+	if name == "" {
+		return ShapeRectangle, nil
+	}
+	for i, n := range shapeNames {
+		if n == name {
+			return Shape(i), nil
+		}
+	}
+	return ShapeRectangle, fmt.Errorf("unknown shape %q", name)
+}
+
+// WriteJSON writes the chart in the versioned JSON format. Objects keep their
+// order and IDs and highlights are sorted by position, so
+// saving an unchanged chart produces the same bytes. pan may be nil.
+func (c *Canvas) WriteJSON(w io.Writer, pan *Point) error {
+	doc := jsonChart{
+		Version:     jsonFormatVersion,
+		Boxes:       make([]jsonBox, len(c.boxes)),
+		Connections: make([]jsonConnection, len(c.connections)),
+		Texts:       make([]jsonText, len(c.texts)),
+		Highlights:  make([]jsonHighlight, 0, len(c.highlights)),
+	}
+	if pan != nil {
+		doc.Pan = &jsonPoint{X: pan.X, Y: pan.Y}
+	}
+	for i, box := range c.boxes {
+		doc.Boxes[i] = jsonBox{
+			ID:          box.ID,
+			X:           box.X,
+			Y:           box.Y,
+			Width:       box.Width,
+			Height:      box.Height,
+			ZLevel:      box.ZLevel,
+			BorderStyle: box.BorderStyle.String(),
+			Title:       box.Title,
+			Text:        box.GetText(),
+			Color:       box.Color,
+		}
+		if box.Shape != ShapeRectangle {
+			doc.Boxes[i].Shape = box.Shape.String()
+		}
+	}
+	for i, conn := range c.connections {
+		waypoints := make([]jsonPoint, len(conn.Waypoints))
+		for j, wp := range conn.Waypoints {
+			waypoints[j] = jsonPoint{X: wp.X, Y: wp.Y}
+		}
+		doc.Connections[i] = jsonConnection{
+			ID:        conn.ID,
+			From:      conn.FromID,
+			To:        conn.ToID,
+			FromPoint: jsonPoint{X: conn.FromX, Y: conn.FromY},
+			ToPoint:   jsonPoint{X: conn.ToX, Y: conn.ToY},
+			Waypoints: waypoints,
+			ArrowFrom: conn.ArrowFrom,
+			ArrowTo:   conn.ArrowTo,
+			Color:     conn.Color,
+		}
+		if conn.Label != "" {
+			doc.Connections[i].Label = conn.Label
+			doc.Connections[i].LabelSegment = conn.LabelSegment
+		}
+	}
+	for i, text := range c.texts {
+		doc.Texts[i] = jsonText{ID: text.ID, X: text.X, Y: text.Y, Text: text.GetText(), Color: text.Color}
+	}
+	for _, cell := range c.sortedHighlights() {
+		doc.Highlights = append(doc.Highlights, jsonHighlight{X: cell.X, Y: cell.Y, Color: cell.Color})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+// readJSON fills an empty canvas from a JSON save, reporting problems to d,
+// and returns the saved pan offset or nil. JSON objects have no useful line
+// numbers once decoded, so only syntax errors are tied to a line; the rest
+// name the object instead.
+func (c *Canvas) readJSON(data []byte, d *diagnostics) *Point {
+	var doc jsonChart
+	if err := json.Unmarshal(data, &doc); err != nil {
+		var offset int64
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		}
+		line := 0
+		if offset > 0 {
+			line = bytes.Count(data[:min(offset, int64(len(data)))], []byte("\n")) + 1
+		}
+		d.fatalf(line, "invalid JSON chart: %v", err)
+		return nil
+	}
+	if doc.Version < 1 || doc.Version > jsonFormatVersion {
+		d.fatalf(0, "unsupported chart version %d (this flerm reads up to %d)", doc.Version, jsonFormatVersion)
+		return nil
+	}
+
+	// Version 1 had no IDs and joined boxes by index, which is the same as
+	// giving every object its index as ID.
+	if doc.Version == 1 {
+		for i := range doc.Boxes {
+			doc.Boxes[i].ID = i
+		}
+		for i := range doc.Connections {
+			doc.Connections[i].ID = i
+		}
+		for i := range doc.Texts {
+			doc.Texts[i].ID = i
+		}
+	}
+
+	color := func(what string, i, color int) int {
+		if color != validColor(color) {
+			d.warnf(0, "%s %d: color %d out of range 0-%d; using the default", what, i, color, NumColors-1)
+		}
+		return validColor(color)
+	}
+
+	boxIDs := map[int]bool{}
+	for i, jb := range doc.Boxes {
+		style, err := parseBorderStyle(jb.BorderStyle)
+		if err != nil {
+			d.warnf(0, "box %d: %v; using ascii", i, err)
+		}
+		shape, err := parseShape(jb.Shape)
+		if err != nil {
+			d.warnf(0, "box %d: %v; using a rectangle", i, err)
+		}
+		if jb.ID < 0 || boxIDs[jb.ID] {
+			d.errorf(0, "box %d: invalid or duplicate id %d", i, jb.ID)
+			jb.ID = -1
+		}
+		boxIDs[jb.ID] = true
+		if jb.ZLevel < 0 || jb.ZLevel > 3 {
+			d.warnf(0, "box %d: zLevel %d out of range 0-3; using 0", i, jb.ZLevel)
+			jb.ZLevel = 0
+		}
+		box := Box{
+			X:           jb.X,
+			Y:           jb.Y,
+			ID:          jb.ID,
+			ZLevel:      jb.ZLevel,
+			BorderStyle: style,
+			Shape:       shape,
+			Title:       jb.Title,
+			Color:       color("box", i, jb.Color),
+		}
+		box.SetText(jb.Text)
+		box.Width = max(jb.Width, minBoxWidth)
+		box.Height = max(jb.Height, minBoxHeight)
+		c.boxes = append(c.boxes, box)
+	}
+
+	for i, jc := range doc.Connections {
+		for _, end := range []*int{&jc.From, &jc.To} {
+			if *end != -1 && (*end < 0 || !boxIDs[*end]) {
+				d.warnf(0, "connection %d: no box with id %d; leaving that end unattached", i, *end)
+				*end = -1
+			}
+		}
+		if jc.LabelSegment < 0 {
+			d.warnf(0, "connection %d: label segment %d; centering the label", i, jc.LabelSegment)
+			jc.LabelSegment = 0
+		}
+		var waypoints []Point
+		for _, wp := range jc.Waypoints {
+			waypoints = append(waypoints, Point{X: wp.X, Y: wp.Y})
+		}
+		c.connections = append(c.connections, Connection{
+			ID:           jc.ID,
+			FromID:       jc.From,
+			ToID:         jc.To,
+			FromX:        jc.FromPoint.X,
+			FromY:        jc.FromPoint.Y,
+			ToX:          jc.ToPoint.X,
+			ToY:          jc.ToPoint.Y,
+			Waypoints:    waypoints,
+			ArrowFrom:    jc.ArrowFrom,
+			ArrowTo:      jc.ArrowTo,
+			Color:        color("connection", i, jc.Color),
+			Label:        jc.Label,
+			LabelSegment: jc.LabelSegment,
+		})
+	}
+
+	for i, jt := range doc.Texts {
+		text := Text{X: jt.X, Y: jt.Y, ID: jt.ID, Color: color("text", i, jt.Color)}
+		text.SetText(jt.Text)
+		c.texts = append(c.texts, text)
+	}
+	c.assignIDs()
+
+	for i, h := range doc.Highlights {
+		if h.Color < 0 || h.Color >= NumColors {
+			d.warnf(0, "highlight %d: color %d out of range 0-%d; skipped", i, h.Color, NumColors-1)
+			continue
+		}
+		c.SetHighlight(h.X, h.Y, h.Color)
+	}
+
+	if doc.Pan == nil {
+		return nil
+	}
+	return &Point{X: doc.Pan.X, Y: doc.Pan.Y}
+}
+
+func validColor(color int) int {
+	if color < 0 || color >= NumColors {
+		return -1
+	}
+	return color
+}
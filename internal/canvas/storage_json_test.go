@@ -0,0 +1,127 @@
+package canvas
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func jsonChartFixture() *Canvas {
+	c := NewCanvas()
+	c.AddBox(2, 2, "Start, with a comma\nand two lines")
+	c.AddBox(30, 10, "End")
+	c.boxes[1].Title = "Title, too"
+	c.boxes[1].UpdateSize()
+	c.SetBorderStyle(1, BorderStyleRounded)
+	c.SetBoxColor(1, 3)
+	c.SetBoxShape(1, ShapeDatabase)
+	c.CycleBoxZLevel(0)
+	c.AddConnection(0, 1)
+	c.connections[0].Waypoints = []Point{{X: 20, Y: 3}, {X: 20, Y: 11}}
+	c.connections[0].ArrowFrom = true
+	c.SetConnectionLabel(0, "yes, really")
+	c.SetConnectionLabelSegment(0, 2)
+	c.SetLineColor(0, 6)
+	c.AddText(4, 14, "note \"quoted\"")
+	c.SetTextColor(0, 5)
+	c.SetHighlight(9, 3, 2)
+	c.SetHighlight(3, 3, 1)
+	return c
+}
+
+func TestJSONSaveRoundTrip(t *testing.T) {
+	c := jsonChartFixture()
+	path := filepath.Join(t.TempDir(), "chart"+JSONExtension)
+	if err := c.SaveToFileWithPan(path, 4, -2); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"version": 2`, `"id": 1`, `"borderStyle": "rounded"`, `"shape": "database"`, `"label": "yes, really"`, `"title": "Title, too"`, `"pan": {`} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("expected JSON to contain %s, got:\n%s", want, data)
+		}
+	}
+	if bytes.Index(data, []byte(`"x": 3`)) > bytes.Index(data, []byte(`"x": 9`)) {
+		t.Errorf("expected highlights sorted by position, got:\n%s", data)
+	}
+
+	loaded := NewCanvas()
+	panX, panY, err := loaded.LoadFromFileWithPan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if panX != 4 || panY != -2 {
+		t.Errorf("expected pan (4,-2), got (%d,%d)", panX, panY)
+	}
+	if !reflect.DeepEqual(loaded.boxes, c.boxes) {
+		t.Errorf("boxes changed:\n got %+v\nwant %+v", loaded.boxes, c.boxes)
+	}
+	if !reflect.DeepEqual(loaded.connections, c.connections) {
+		t.Errorf("connections changed:\n got %+v\nwant %+v", loaded.connections, c.connections)
+	}
+	if !reflect.DeepEqual(loaded.texts, c.texts) {
+		t.Errorf("texts changed:\n got %+v\nwant %+v", loaded.texts, c.texts)
+	}
+	if !reflect.DeepEqual(loaded.highlights, c.highlights) {
+		t.Errorf("highlights changed:\n got %v\nwant %v", loaded.highlights, c.highlights)
+	}
+
+	// Saving again gives identical bytes.
+	again := filepath.Join(t.TempDir(), "again"+JSONExtension)
+	if err := loaded.SaveToFileWithPan(again, panX, panY); err != nil {
+		t.Fatal(err)
+	}
+	if data2, _ := os.ReadFile(again); !bytes.Equal(data, data2) {
+		t.Errorf("expected a stable encoding, got:\n%s\nthen:\n%s", data, data2)
+	}
+}
+
+func TestLoadDetectsFormatByContent(t *testing.T) {
+	dir := t.TempDir()
+	c := jsonChartFixture()
+	sav := filepath.Join(dir, "chart.sav")
+	if err := c.SaveToFile(sav); err != nil {
+		t.Fatal(err)
+	}
+
+	// Converting is a load followed by a save under the new name.
+	converted := NewCanvas()
+	if err := converted.LoadFromFile(sav); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "chart"+JSONExtension)
+	if err := converted.SaveToFile(jsonPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// A JSON file with a misleading name still loads as JSON.
+	misnamed := filepath.Join(dir, "misnamed.sav")
+	data, _ := os.ReadFile(jsonPath)
+	if err := os.WriteFile(misnamed, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewCanvas()
+	if err := loaded.LoadFromFile(misnamed); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.boxes) != 2 || loaded.boxes[1].Title != "Title, too" || loaded.boxes[1].Shape != ShapeDatabase || loaded.connections[0].Color != 6 || loaded.connections[0].Label != "yes, really" || loaded.connections[0].LabelSegment != 2 {
+		t.Errorf("JSON content not detected: %+v", loaded.boxes)
+	}
+}
+
+func TestLoadJSONRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future"+JSONExtension)
+	if err := os.WriteFile(path, []byte(`{"version": 99, "boxes": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := NewCanvas().LoadFromFile(path)
+	if err == nil || !strings.Contains(err.Error(), "unsupported chart version 99") {
+		t.Fatalf("expected a version error, got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package canvas
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	svgCellWidth  = 8.0
+	svgCellHeight = 16.0
+	svgFontSize   = 13.0
+	svgPadding    = 2
+)
+
+func (c *Canvas) ExportToSVG(filename string) error {
+	return writeExportFile(filename, c.WriteSVG)
+}
+
+func (c *Canvas) WriteSVG(w io.Writer) error {
+	minX, minY, maxX, maxY := c.GetFullBounds()
+	if minX > maxX || minY > maxY {
+		return fmt.Errorf("nothing to export")
+	}
+	minX -= svgPadding
+	minY -= svgPadding
+	cols := maxX - minX + svgPadding + 1
+	rows := maxY - minY + svgPadding + 1
+
+	s := &svgWriter{w: bufio.NewWriter(w), minX: minX, minY: minY}
+	s.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%g\" height=\"%g\" viewBox=\"0 0 %g %g\">\n",
+		float64(cols)*svgCellWidth, float64(rows)*svgCellHeight, float64(cols)*svgCellWidth, float64(rows)*svgCellHeight)
+	s.printf("<style>text{font-family:\"DejaVu Sans Mono\",Menlo,Consolas,monospace;font-size:%gpx;white-space:pre}</style>\n", svgFontSize)
+	s.writeMarkers(c.connections)
+	s.printf("<rect width=\"100%%\" height=\"100%%\" fill=\"#ffffff\"/>\n")
+
+	for _, conn := range c.connections {
+		s.connection(conn)
+	}
+	for _, conn := range c.connections {
+		s.connectionLabel(conn)
+	}
+	for _, text := range c.texts {
+		s.textLines(text.Lines, text.X, text.Y, hexColor(text.Color))
+	}
+	for _, i := range c.boxDrawOrder() {
+		s.box(c.boxes[i])
+	}
+	s.highlights(c.sortedHighlights())
+
+	s.printf("</svg>\n")
+	return s.w.Flush()
+}
+
+// boxDrawOrder returns box indices sorted by ZLevel so raised boxes are
+// painted over lower ones.
+func (c *Canvas) boxDrawOrder() []int {
+	order := make([]int, len(c.boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return c.boxes[order[a]].ZLevel < c.boxes[order[b]].ZLevel
+	})
+	return order
+}
+
+func hexColor(index int) string {
+	if index < 0 || index >= NumColors {
+		return "#000000"
+	}
+	r, g, b, _ := pngColor(index).RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+func svgEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+type svgWriter struct {
+	w          *bufio.Writer
+	minX, minY int
+}
+
+func (s *svgWriter) printf(format string, args ...any) {
+	fmt.Fprintf(s.w, format, args...)
+}
+
+// cx and cy return the centre of a cell, which is where lines and borders run.
+func (s *svgWriter) cx(x int) float64 {
+	return (float64(x-s.minX) + 0.5) * svgCellWidth
+}
+
+func (s *svgWriter) cy(y int) float64 {
+	return (float64(y-s.minY) + 0.5) * svgCellHeight
+}
+
+func markerID(color int) string {
+	if color < 0 || color >= NumColors {
+		return "arrow"
+	}
+	return fmt.Sprintf("arrow-%d", color)
+}
+
+func (s *svgWriter) writeMarkers(conns []Connection) {
+	seen := map[int]bool{}
+	var colors []int
+	for _, conn := range conns {
+		if !conn.ArrowFrom && !conn.ArrowTo {
+			continue
+		}
+		color := conn.Color
+		if color < 0 || color >= NumColors {
+			color = -1
+		}
+		if !seen[color] {
+			seen[color] = true
+			colors = append(colors, color)
+		}
+	}
+	if len(colors) == 0 {
+		return
+	}
+	sort.Ints(colors)
+	s.printf("<defs>\n")
+	for _, color := range colors {
+		s.printf("<marker id=\"%s\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"8\" markerHeight=\"8\" markerUnits=\"userSpaceOnUse\" orient=\"auto-start-reverse\"><path d=\"M0,0 L10,5 L0,10 z\" fill=\"%s\"/></marker>\n",
+			markerID(color), hexColor(color))
+	}
+	s.printf("</defs>\n")
+}
+
+func (s *svgWriter) connection(conn Connection) {
+	pts := []Point{{X: conn.FromX, Y: conn.FromY}}
+	pts = append(pts, conn.Waypoints...)
+	pts = append(pts, Point{X: conn.ToX, Y: conn.ToY})
+
+	var verts []Point
+	addV := func(p Point) {
+		if len(verts) == 0 || verts[len(verts)-1] != p {
+			verts = append(verts, p)
+		}
+	}
+	for i := 0; i < len(pts)-1; i++ {
+		from, to := pts[i], pts[i+1]
+		addV(from)
+		if from.X != to.X && from.Y != to.Y {
+			addV(Point{X: to.X, Y: from.Y})
+		}
+		addV(to)
+	}
+	if len(verts) < 2 {
+		return
+	}
+
+	coords := make([]string, len(verts))
+	for i, v := range verts {
+		coords[i] = fmt.Sprintf("%g,%g", s.cx(v.X), s.cy(v.Y))
+	}
+	markers := ""
+	if conn.ArrowFrom {
+		markers += fmt.Sprintf(" marker-start=\"url(#%s)\"", markerID(conn.Color))
+	}
+	if conn.ArrowTo {
+		markers += fmt.Sprintf(" marker-end=\"url(#%s)\"", markerID(conn.Color))
+	}
+	s.printf("<polyline points=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\"%s/>\n",
+		strings.Join(coords, " "), hexColor(conn.Color), markers)
+}
+
+// connectionLabel blanks out the line under a label, padding included, and
+// writes the label over it in the line's color.
+func (s *svgWriter) connectionLabel(conn Connection) {
+	if conn.Label == "" {
+		return
+	}
+	start := conn.labelStart()
+	s.printf("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" fill=\"#ffffff\"/>\n",
+		float64(start.X-s.minX)*svgCellWidth, float64(start.Y-s.minY)*svgCellHeight,
+		float64(StringWidth(conn.labelText()))*svgCellWidth, svgCellHeight)
+	s.textLines([]string{conn.Label}, start.X+1, start.Y, hexColor(conn.Color))
+}
+
+func (s *svgWriter) textLines(lines []string, x, y int, fill string) {
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		baseline := (float64(y-s.minY+i) + 0.75) * svgCellHeight
+		s.printf("<text x=\"%g\" y=\"%g\" fill=\"%s\" textLength=\"%g\" lengthAdjust=\"spacingAndGlyphs\">%s</text>\n",
+			float64(x-s.minX)*svgCellWidth, baseline, fill, float64(StringWidth(line))*svgCellWidth, svgEscape(line))
+	}
+}
+
+func (s *svgWriter) box(box Box) {
+	x, y := s.cx(box.X), s.cy(box.Y)
+	w := float64(box.Width-1) * svgCellWidth
+	h := float64(box.Height-1) * svgCellHeight
+	stroke := hexColor(box.Color)
+
+	if box.ZLevel > 0 {
+		opacity := []float64{0, 0.25, 0.45, 0.65}[min(box.ZLevel, 3)]
+		s.printf("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" fill=\"#000000\" fill-opacity=\"%g\"/>\n",
+			x+float64(box.ZLevel)*svgCellWidth, y+float64(box.ZLevel)*svgCellHeight, w, h, opacity)
+	}
+
+	s.outline(box, x, y, w, h, stroke)
+	if box.BorderStyle == BorderStyleDouble && box.Shape == ShapeRectangle {
+		s.printf("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" fill=\"none\" stroke=\"%s\" stroke-width=\"1\"/>\n",
+			x+3, y+3, w-6, h-6, stroke)
+	}
+
+	contentY := box.Y + 1
+	if box.Title != "" {
+		titleLines := strings.Split(box.Title, "\n")
+		s.textLines(clipLines(titleLines, box.Width-2), box.X+1, box.Y+1, stroke)
+		dividerY := s.cy(box.Y + 1 + len(titleLines))
+		s.printf("<line x1=\"%g\" y1=\"%g\" x2=\"%g\" y2=\"%g\" stroke=\"%s\" stroke-width=\"1\"/>\n",
+			x, dividerY, x+w, dividerY, stroke)
+		contentY = box.Y + 1 + len(titleLines) + 1
+	}
+	lines := box.Lines
+	if maxLines := box.Y + box.Height - 1 - contentY; len(lines) > maxLines {
+		lines = lines[:max(maxLines, 0)]
+	}
+	s.textLines(clipLines(lines, box.Width-2), box.X+1, contentY, "#000000")
+}
+
+// outline draws the border of box, whose corner cells are centered on the
+// w by h rectangle at (x, y), in the box's shape.
+func (s *svgWriter) outline(box Box, x, y, w, h float64, stroke string) {
+	const attrs = `fill="#ffffff" stroke="%s" stroke-width="1.5"`
+	point := func(px, py float64) string { return fmt.Sprintf("%g,%g", px, py) }
+	switch box.Shape {
+	case ShapeDiamond:
+		s.printf("<polygon points=\"%s %s %s %s\" "+attrs+"/>\n",
+			point(x+w/2, y), point(x+w, y+h/2), point(x+w/2, y+h), point(x, y+h/2), stroke)
+	case ShapeTerminator:
+		s.printf("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" rx=\"%g\" "+attrs+"/>\n",
+			x, y, w, h, h/2, stroke)
+	case ShapeParallelogram:
+		slant := svgCellWidth
+		s.printf("<polygon points=\"%s %s %s %s\" "+attrs+"/>\n",
+			point(x+slant, y), point(x+w, y), point(x+w-slant, y+h), point(x, y+h), stroke)
+	case ShapeDatabase:
+		ry := svgCellHeight / 4
+		s.printf("<path d=\"M%s A%g,%g 0 0 1 %s V%g A%g,%g 0 0 1 %s Z M%s A%g,%g 0 0 0 %s\" "+attrs+"/>\n",
+			point(x, y+ry), w/2, ry, point(x+w, y+ry), y+h-ry, w/2, ry, point(x, y+h-ry),
+			point(x, y+ry), w/2, ry, point(x+w, y+ry), stroke)
+	case ShapeDocument:
+		wave := svgCellHeight / 4
+		s.printf("<path d=\"M%s H%g V%g Q%s %s T%s Z\" "+attrs+"/>\n",
+			point(x, y), x+w, y+h-wave, point(x+3*w/4, y+h-2*wave), point(x+w/2, y+h-wave), point(x, y+h-wave), stroke)
+	default:
+		rx := 0.0
+		if box.BorderStyle == BorderStyleRounded {
+			rx = svgCellWidth
+		}
+		s.printf("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" rx=\"%g\" "+attrs+"/>\n",
+			x, y, w, h, rx, stroke)
+	}
+}
+
+func clipLines(lines []string, width int) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = TruncateWidth(line, width)
+	}
+	return out
+}
+
+func (s *svgWriter) highlights(cells []HighlightCell) {
+	for _, hc := range cells {
+		s.printf("<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" fill=\"%s\" fill-opacity=\"0.4\"/>\n",
+			float64(hc.X-s.minX)*svgCellWidth, float64(hc.Y-s.minY)*svgCellHeight, svgCellWidth, svgCellHeight, hexColor(hc.Color))
+	}
+}
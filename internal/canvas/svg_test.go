@@ -0,0 +1,55 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSVGExportDrawsModel(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(2, 2, "A & B")
+	c.AddBox(30, 2, "Next")
+	c.boxes[1].BorderStyle = BorderStyleRounded
+	c.boxes[1].ZLevel = 2
+	c.boxes[1].Title = "Step"
+	c.boxes[1].UpdateSize()
+	c.AddConnection(0, 1)
+	c.connections[0].ArrowTo = true
+	c.SetLineColor(0, 1)
+	c.AddText(2, 10, "note")
+	c.SetHighlight(3, 10, 4)
+
+	var buf bytes.Buffer
+	if err := c.WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<svg ",
+		">A &amp; B</text>",
+		">Step</text>",
+		">note</text>",
+		`<marker id="arrow-1"`,
+		`marker-end="url(#arrow-1)"`,
+		`rx="8"`,
+		`fill-opacity="0.45"`,
+		`fill="#0000dc" fill-opacity="0.4"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected SVG to contain %q", want)
+		}
+	}
+
+	var again bytes.Buffer
+	c.WriteSVG(&again)
+	if again.String() != out {
+		t.Fatal("SVG output differs between runs")
+	}
+}
+
+func TestSVGExportEmptyCanvas(t *testing.T) {
+	if err := NewCanvas().WriteSVG(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for an empty canvas")
+	}
+}
@@ -0,0 +1,182 @@
+package canvas
+
+import "sort"
+
+// ArrangeTree lays out the boxes reachable from the box at index root by
+// following lines from their start to their end as a tidy tree growing
+// along dir. Each box goes under the first parent that reaches it, closest
+// to the root, so a box with several parents is placed once and the other
+// lines run across to it. Children keep their current order, subtrees are
+// packed as close as their outlines allow, and every parent is centered over
+// its children. The root stays where it is unless the tree would then run
+// off the top or left of the chart. Lines attached to the tree are rerouted.
+// It reports whether any box moved.
+func (c *Canvas) ArrangeTree(root int, dir LayoutDirection) bool {
+	if root < 0 || root >= len(c.boxes) {
+		return false
+	}
+	nodes, children, depth := c.treeFrom(root, dir)
+	if len(nodes) < 2 {
+		return false
+	}
+
+	main := func(b Box) int {
+		if dir.Horizontal() {
+			return b.Width
+		}
+		return b.Height
+	}
+	cross := func(b Box) int {
+		if dir.Horizontal() {
+			return b.Height
+		}
+		return b.Width
+	}
+	rankGap, nodeGap := layoutRankGapRows, layoutNodeGapCols
+	if dir.Horizontal() {
+		rankGap, nodeGap = layoutRankGapCols, layoutNodeGapRows
+	}
+
+	// Each subtree is measured by its outline: for every depth below its
+	// root, the first and last cell it takes across, relative to the
+	// root's own start. offset is where a child starts relative to its
+	// parent.
+	offset := make([]int, len(nodes))
+	var measure func(v int) (lo, hi []int)
+	measure = func(v int) ([]int, []int) {
+		size := cross(c.boxes[nodes[v]])
+		if len(children[v]) == 0 {
+			return []int{0}, []int{size}
+		}
+		var accLo, accHi []int
+		for k, w := range children[v] {
+			lo, hi := measure(w)
+			shift := 0
+			if k > 0 {
+				shift = accHi[0] + nodeGap - lo[0]
+				for d := 1; d < min(len(lo), len(accLo)); d++ {
+					shift = max(shift, accHi[d]+nodeGap-lo[d])
+				}
+			}
+			offset[w] = shift
+			for d := range lo {
+				if d < len(accLo) {
+					accLo[d] = min(accLo[d], lo[d]+shift)
+					accHi[d] = max(accHi[d], hi[d]+shift)
+				} else {
+					accLo = append(accLo, lo[d]+shift)
+					accHi = append(accHi, hi[d]+shift)
+				}
+			}
+		}
+		first, last := children[v][0], children[v][len(children[v])-1]
+		center := (offset[first] + cross(c.boxes[nodes[first]])/2 + offset[last] + cross(c.boxes[nodes[last]])/2) / 2
+		start := center - size/2
+		lo, hi := []int{0}, []int{size}
+		for d := range accLo {
+			lo = append(lo, accLo[d]-start)
+			hi = append(hi, accHi[d]-start)
+		}
+		for _, w := range children[v] {
+			offset[w] -= start
+		}
+		return lo, hi
+	}
+	measure(0)
+
+	depthStart := []int{0}
+	for d := 0; ; d++ {
+		deepest := -1
+		for v, dv := range depth {
+			if dv == d {
+				deepest = max(deepest, main(c.boxes[nodes[v]]))
+			}
+		}
+		if deepest < 0 {
+			break
+		}
+		depthStart = append(depthStart, depthStart[d]+deepest+rankGap)
+	}
+
+	crossPos := make([]int, len(nodes))
+	for v := range nodes {
+		for _, w := range children[v] {
+			crossPos[w] = crossPos[v] + offset[w]
+		}
+	}
+	positions := make([]Point, len(nodes))
+	for v, i := range nodes {
+		m := depthStart[depth[v]]
+		if dir == LayoutBottomUp || dir == LayoutRightLeft {
+			m = -m - main(c.boxes[i]) + main(c.boxes[root])
+		}
+		if dir.Horizontal() {
+			positions[v] = Point{X: m, Y: crossPos[v]}
+		} else {
+			positions[v] = Point{X: crossPos[v], Y: m}
+		}
+	}
+
+	shiftX, shiftY := c.boxes[root].X-positions[0].X, c.boxes[root].Y-positions[0].Y
+	minX, minY := positions[0].X+shiftX, positions[0].Y+shiftY
+	for _, p := range positions {
+		minX, minY = min(minX, p.X+shiftX), min(minY, p.Y+shiftY)
+	}
+	shiftX -= min(minX, 0)
+	shiftY -= min(minY, 0)
+	for v := range positions {
+		positions[v].X += shiftX
+		positions[v].Y += shiftY
+	}
+	return c.placeArranged(nodes, positions, dir)
+}
+
+// TreeBoxes returns the indices of the boxes in the tree grown from the box
+// at index root, the ones ArrangeTree would move, root first.
+func (c *Canvas) TreeBoxes(root int, dir LayoutDirection) []int {
+	if root < 0 || root >= len(c.boxes) {
+		return nil
+	}
+	nodes, _, _ := c.treeFrom(root, dir)
+	return nodes
+}
+
+// treeFrom walks the lines out of the box at index root breadth first. It
+// returns the box indices it reaches, root first, and for each of them its
+// children and depth, all by position in that list. Children are in the
+// order they stand across dir.
+func (c *Canvas) treeFrom(root int, dir LayoutDirection) (nodes []int, children [][]int, depth []int) {
+	local := map[int]int{c.boxes[root].ID: 0}
+	nodes, children, depth = []int{root}, [][]int{nil}, []int{0}
+	for v := 0; v < len(nodes); v++ {
+		id := c.boxes[nodes[v]].ID
+		var next []int
+		for _, conn := range c.connections {
+			if conn.FromID != id {
+				continue
+			}
+			if _, seen := local[conn.ToID]; seen {
+				continue
+			}
+			if i := c.BoxIndex(conn.ToID); i >= 0 {
+				local[conn.ToID] = -1
+				next = append(next, i)
+			}
+		}
+		sort.SliceStable(next, func(a, b int) bool {
+			p, q := c.boxes[next[a]], c.boxes[next[b]]
+			if dir.Horizontal() {
+				return p.Y+p.Height/2 < q.Y+q.Height/2
+			}
+			return p.X+p.Width/2 < q.X+q.Width/2
+		})
+		for _, i := range next {
+			local[c.boxes[i].ID] = len(nodes)
+			children[v] = append(children[v], len(nodes))
+			nodes = append(nodes, i)
+			children = append(children, nil)
+			depth = append(depth, depth[v]+1)
+		}
+	}
+	return nodes, children, depth
+}
@@ -0,0 +1,109 @@
+package canvas
+
+import "testing"
+
+// decisionTree is Q -> {No, Yes}, Yes -> {Y1, Y2}, scattered, with No
+// standing to the left of Yes.
+func decisionTree() *Canvas {
+	c := NewCanvas()
+	c.AddBox(40, 2, "Question?")
+	c.AddBox(70, 30, "Yes")
+	c.AddBox(10, 25, "No")
+	c.AddBox(90, 10, "Y1")
+	c.AddBox(100, 12, "Y2")
+	c.AddConnection(0, 1)
+	c.AddConnection(0, 2)
+	c.AddConnection(1, 3)
+	c.AddConnection(1, 4)
+	return c
+}
+
+func TestArrangeTreeCentersParentsOverChildren(t *testing.T) {
+	for _, dir := range []LayoutDirection{LayoutTopDown, LayoutLeftRight} {
+		c := decisionTree()
+		if !c.ArrangeTree(0, dir) {
+			t.Fatal("expected the tree to move")
+		}
+		if q := c.boxes[0]; q.X != 40 || q.Y != 2 {
+			t.Errorf("dir %d: expected the root to stay at (40,2), got (%d,%d)", dir, q.X, q.Y)
+		}
+		across := func(b Box) (int, int) {
+			if dir.Horizontal() {
+				return b.Y, b.Height
+			}
+			return b.X, b.Width
+		}
+		along := func(b Box) int {
+			if dir.Horizontal() {
+				return b.X
+			}
+			return b.Y
+		}
+		center := func(b Box) int {
+			pos, size := across(b)
+			return pos + size/2
+		}
+		for _, family := range [][3]int{{0, 2, 1}, {1, 3, 4}} {
+			parent, first, last := c.boxes[family[0]], c.boxes[family[1]], c.boxes[family[2]]
+			if along(first) != along(last) || along(first) <= along(parent) {
+				t.Errorf("dir %d: expected %v and %v in one rank after %v", dir, first.Lines, last.Lines, parent.Lines)
+			}
+			if mid := (center(first) + center(last)) / 2; abs(center(parent)-mid) > 1 {
+				t.Errorf("dir %d: %v is centered at %d, its children at %d", dir, parent.Lines, center(parent), mid)
+			}
+			firstPos, firstSize := across(first)
+			if lastPos, _ := across(last); firstPos+firstSize > lastPos {
+				t.Errorf("dir %d: expected %v before %v, as they stood", dir, first.Lines, last.Lines)
+			}
+		}
+		for i, conn := range c.connections {
+			if c.crossesBoxes(conn) {
+				t.Errorf("dir %d: line %d crosses a box: %+v", dir, i, conn)
+			}
+		}
+		if c.ArrangeTree(0, dir) {
+			t.Errorf("dir %d: expected arranging twice to change nothing", dir)
+		}
+	}
+}
+
+func TestArrangeTreePlacesSharedChildOnce(t *testing.T) {
+	c := decisionTree()
+	c.AddBox(60, 40, "Shared")
+	c.AddConnection(4, 5) // deepest first, so order of lines doesn't decide
+	c.AddConnection(2, 5)
+	c.AddBox(0, 0, "Loose")
+	c.ArrangeTree(0, LayoutTopDown)
+
+	no, y1, shared := c.boxes[2], c.boxes[3], c.boxes[5]
+	if shared.Y != y1.Y {
+		t.Errorf("expected Shared under No, next to Y1 at y=%d, got y=%d", y1.Y, shared.Y)
+	}
+	if shared.Y < no.Y+no.Height {
+		t.Errorf("expected Shared below No, got %v and %v", no, shared)
+	}
+	if loose := c.boxes[6]; loose.X != 0 || loose.Y != 0 {
+		t.Errorf("a box outside the tree moved to (%d,%d)", loose.X, loose.Y)
+	}
+	for i, a := range c.boxes {
+		for _, b := range c.boxes[i+1:] {
+			if a.X < b.X+b.Width && b.X < a.X+a.Width && a.Y < b.Y+b.Height && b.Y < a.Y+a.Height {
+				t.Errorf("%v and %v overlap", a.Lines, b.Lines)
+			}
+		}
+	}
+}
+
+func TestArrangeTreeStaysOnTheChart(t *testing.T) {
+	c := decisionTree()
+	c.SetBoxPositionOnly(0, 0, 0)
+	c.ArrangeTree(0, LayoutTopDown)
+	for _, b := range c.boxes {
+		if b.X < 0 || b.Y < 0 {
+			t.Fatalf("%v ran off the chart at (%d,%d)", b.Lines, b.X, b.Y)
+		}
+	}
+	if c.boxes[2].X != 0 {
+		t.Errorf("expected the tree to shift just enough for No to start at x=0, got %d", c.boxes[2].X)
+	}
+}
@@ -0,0 +1,227 @@
+package canvas
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity says how serious an Issue is.
+type Severity int
+
+const (
+	// SeverityWarning marks something that renders but is probably not what
+	// was meant, such as overlapping boxes.
+	SeverityWarning Severity = iota
+	// SeverityError marks a broken chart, such as a line whose end has come
+	// away from its box.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// The rules Validate checks, as reported in Issue.Rule.
+const (
+	RuleMissingBox      = "missing-box"
+	RuleDetachedEnd     = "detached-end"
+	RuleLooseEnd        = "loose-end"
+	RuleDiagonal        = "diagonal"
+	RuleOverlap         = "overlap"
+	RuleOrphanHighlight = "orphan-highlight"
+	RuleColor           = "color"
+)
+
+// Issue is a problem found by Validate. X and Y are the canvas cell to look
+// at. Objects are named by index, as in box jump.
+type Issue struct {
+	Rule     string
+	Severity Severity
+	X, Y     int
+	Message  string
+}
+
+// Validate checks the chart for connections whose ends don't touch their
+// boxes, routed lines with diagonal segments, overlapping boxes, highlights
+// left on empty cells and colors outside the palette. Issues are ordered by
+// position.
+func (c *Canvas) Validate() []Issue {
+	var issues []Issue
+	add := func(rule string, severity Severity, x, y int, format string, args ...any) {
+		issues = append(issues, Issue{Rule: rule, Severity: severity, X: x, Y: y, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, box := range c.boxes {
+		if !validObjectColor(box.Color) {
+			add(RuleColor, SeverityError, box.X, box.Y, "box %d: color %d is not in the palette (0-%d)", i, box.Color, NumColors-1)
+		}
+	}
+	for i, text := range c.texts {
+		if !validObjectColor(text.Color) {
+			add(RuleColor, SeverityError, text.X, text.Y, "text %d: color %d is not in the palette (0-%d)", i, text.Color, NumColors-1)
+		}
+	}
+	for _, cell := range c.sortedHighlights() {
+		if cell.Color < 0 || cell.Color >= NumColors {
+			add(RuleColor, SeverityError, cell.X, cell.Y, "highlight color %d is not in the palette (0-%d)", cell.Color, NumColors-1)
+		}
+	}
+
+	for i, conn := range c.connections {
+		if !validObjectColor(conn.Color) {
+			add(RuleColor, SeverityError, conn.FromX, conn.FromY, "connection %d: color %d is not in the palette (0-%d)", i, conn.Color, NumColors-1)
+		}
+		ends := []struct {
+			name  string
+			boxID int
+			x, y  int
+			arrow bool
+		}{
+			{"from", conn.FromID, conn.FromX, conn.FromY, conn.ArrowFrom},
+			{"to", conn.ToID, conn.ToX, conn.ToY, conn.ArrowTo},
+		}
+		for _, end := range ends {
+			switch index := c.BoxIndex(end.boxID); {
+			case end.boxID >= 0 && index < 0:
+				add(RuleMissingBox, SeverityError, end.x, end.y, "connection %d: %s end refers to box id %d, which does not exist", i, end.name, end.boxID)
+			case index >= 0:
+				if !c.onBoxEdge(c.boxes[index], end.x, end.y) {
+					add(RuleDetachedEnd, SeverityError, end.x, end.y, "connection %d: %s end is not on the edge of box %d", i, end.name, index)
+				}
+			case !c.onOtherConnection(i, end.x, end.y):
+				if end.arrow {
+					add(RuleLooseEnd, SeverityWarning, end.x, end.y, "connection %d: arrow at the %s end points into empty space", i, end.name)
+				} else {
+					add(RuleLooseEnd, SeverityWarning, end.x, end.y, "connection %d: %s end is not on a box or another line", i, end.name)
+				}
+			}
+		}
+
+		// A line without waypoints is drawn as an elbow on purpose; once it has
+		// waypoints, every segment is meant to be straight.
+		if len(conn.Waypoints) == 0 {
+			continue
+		}
+		points := append([]Point{{X: conn.FromX, Y: conn.FromY}}, conn.Waypoints...)
+		points = append(points, Point{X: conn.ToX, Y: conn.ToY})
+		for j := 1; j < len(points); j++ {
+			a, b := points[j-1], points[j]
+			if a.X != b.X && a.Y != b.Y {
+				add(RuleDiagonal, SeverityWarning, a.X, a.Y, "connection %d: segment to (%d,%d) is not horizontal or vertical", i, b.X, b.Y)
+			}
+		}
+	}
+
+	for i, a := range c.boxes {
+		for j := i + 1; j < len(c.boxes); j++ {
+			b := c.boxes[j]
+			if a.X < b.X+b.Width && b.X < a.X+a.Width && a.Y < b.Y+b.Height && b.Y < a.Y+a.Height {
+				add(RuleOverlap, SeverityWarning, b.X, b.Y, "boxes %d and %d overlap", i, j)
+			}
+		}
+	}
+
+	for _, cells := range c.orphanHighlights() {
+		noun := "cells"
+		if len(cells) == 1 {
+			noun = "cell"
+		}
+		add(RuleOrphanHighlight, SeverityWarning, cells[0].X, cells[0].Y, "highlight on %d %s outside any box, text or line", len(cells), noun)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Y != issues[j].Y {
+			return issues[i].Y < issues[j].Y
+		}
+		return issues[i].X < issues[j].X
+	})
+	return issues
+}
+
+func validObjectColor(color int) bool {
+	return color >= -1 && color < NumColors
+}
+
+// onBoxEdge reports whether (x, y) is on the border of box, which is where a
+// connection attached to it has to end.
+func (c *Canvas) onBoxEdge(box Box, x, y int) bool {
+	if x < box.X || x > box.X+box.Width-1 || y < box.Y || y > box.Y+box.Height-1 {
+		return false
+	}
+	return c.GetConnectionEdge(box, x, y) != "unknown"
+}
+
+// onOtherConnection reports whether (x, y) lies on the path of any
+// connection other than the one at index skip.
+func (c *Canvas) onOtherConnection(skip, x, y int) bool {
+	for i := range c.connections {
+		if i == skip {
+			continue
+		}
+		for _, cell := range c.GetConnectionCells(i) {
+			if cell.X == x && cell.Y == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// orphanHighlights groups the highlighted cells that aren't on any object
+// into touching clusters, each sorted by position.
+func (c *Canvas) orphanHighlights() [][]Point {
+	covered := map[Point]bool{}
+	for i := range c.boxes {
+		for _, p := range c.GetBoxCells(i) {
+			covered[p] = true
+		}
+	}
+	for i := range c.texts {
+		for _, p := range c.GetTextCells(i) {
+			covered[p] = true
+		}
+	}
+	for i := range c.connections {
+		for _, p := range c.GetConnectionCells(i) {
+			covered[p] = true
+		}
+	}
+
+	orphans := map[Point]bool{}
+	var order []Point
+	for _, cell := range c.sortedHighlights() {
+		if p := (Point{X: cell.X, Y: cell.Y}); !covered[p] {
+			orphans[p] = true
+			order = append(order, p)
+		}
+	}
+
+	var clusters [][]Point
+	for _, start := range order {
+		if !orphans[start] {
+			continue
+		}
+		delete(orphans, start)
+		cluster := []Point{start}
+		for k := 0; k < len(cluster); k++ {
+			p := cluster[k]
+			for _, n := range []Point{{X: p.X + 1, Y: p.Y}, {X: p.X - 1, Y: p.Y}, {X: p.X, Y: p.Y + 1}, {X: p.X, Y: p.Y - 1}} {
+				if orphans[n] {
+					delete(orphans, n)
+					cluster = append(cluster, n)
+				}
+			}
+		}
+		sort.Slice(cluster, func(i, j int) bool {
+			if cluster[i].Y != cluster[j].Y {
+				return cluster[i].Y < cluster[j].Y
+			}
+			return cluster[i].X < cluster[j].X
+		})
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
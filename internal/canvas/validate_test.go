@@ -0,0 +1,58 @@
+package canvas
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateCleanChart(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "A")
+	c.AddBox(30, 10, "B")
+	c.AddBox(60, 0, "C")
+	c.AddConnection(0, 1)
+	c.AddConnection(1, 2)
+	c.AddConnectionWithWaypoints(-1, 2, 20, 1, 60, 1, nil)
+	c.MoveBox(1, 4, 6)
+	c.SetHighlight(1, 1, 2)
+	if issues := c.Validate(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestValidateFindsEachProblem(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "A")
+	c.AddBox(30, 10, "B")
+	c.AddBox(4, 1, "Over A")
+	c.AddConnection(0, 1)
+	c.connections[0].FromX += 3
+	c.AddConnectionWithWaypoints(-1, -1, 60, 20, 70, 20, nil)
+	c.connections[1].ArrowTo = true
+	c.AddConnectionWithWaypoints(1, -1, 30, 11, 50, 30, []Point{{X: 40, Y: 11}})
+	c.connections[2].ToID = 99
+	c.AddText(0, 40, "note")
+	c.texts[0].Color = 12
+	c.SetHighlight(80, 30, 1)
+	c.SetHighlight(81, 30, 1)
+	c.SetHighlight(90, 40, 2)
+
+	var rules []string
+	for _, issue := range c.Validate() {
+		rules = append(rules, issue.Rule)
+	}
+	want := []string{
+		RuleOverlap,         // box 2 at (4,1)
+		RuleDetachedEnd,     // (10,1)
+		RuleDiagonal,        // (40,11) to (50,30)
+		RuleLooseEnd,        // (60,20)
+		RuleLooseEnd,        // arrow at (70,20)
+		RuleMissingBox,      // (50,30)
+		RuleOrphanHighlight, // (80,30) and (81,30)
+		RuleColor,           // text at (0,40)
+		RuleOrphanHighlight, // (90,40)
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("got rules %v\nwant %v\n%+v", rules, want, c.Validate())
+	}
+}
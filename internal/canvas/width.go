@@ -0,0 +1,199 @@
+package canvas
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// WideCont fills the cell covered by the right half of a double-width
+// character in a rendered grid. It is never printed; the character to its
+// left already covers it.
+const WideCont rune = -1
+
+// cellWidths ignores the locale so that box-drawing characters, which are
+// ambiguous width, always take one cell.
+var cellWidths = &runewidth.Condition{}
+
+// RuneWidth is the number of cells r takes on screen: 2 for wide characters
+// such as CJK and most emoji, 0 for combining marks and 1 otherwise.
+// Control characters count as 1, as they always have.
+func RuneWidth(r rune) int {
+	if r >= 0 && r < 0x20 {
+		return 1
+	}
+	return cellWidths.RuneWidth(r)
+}
+
+// StringWidth is the number of cells s takes on screen.
+func StringWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += RuneWidth(r)
+	}
+	return width
+}
+
+// TruncateWidth returns the longest prefix of s that fits in width cells,
+// never cutting a character in half.
+func TruncateWidth(s string, width int) string {
+	used := 0
+	for i, r := range s {
+		w := RuneWidth(r)
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
+
+// byteAtColumn returns the byte offset in line of the character drawn over
+// column col, or -1 when col is past the end of the line.
+func byteAtColumn(line string, col int) int {
+	if col < 0 {
+		return -1
+	}
+	x := 0
+	for i, r := range line {
+		w := RuneWidth(r)
+		if w > 0 && col < x+w {
+			return i
+		}
+		x += w
+	}
+	return -1
+}
+
+// TextCells lays s out one cell per entry, as it would be drawn into a
+// rendered row.
+func TextCells(s string) []rune {
+	row := make([]rune, StringWidth(s))
+	putText(row, 0, len(row), s)
+	return row
+}
+
+// putText draws s into row from column x, stopping before column end. A
+// double-width character takes two cells, the second holding WideCont; one
+// that would straddle end is left out. Zero-width characters, such as
+// combining accents and the joiners in emoji, have no cell of their own and
+// join the character before them.
+func putText(row []rune, x, end int, s string) {
+	end = min(end, len(row))
+	last := -1
+	for _, r := range s {
+		w := RuneWidth(r)
+		if w == 0 {
+			if last >= 0 {
+				row[last] = JoinCell(row[last], r)
+			}
+			continue
+		}
+		if x+w > end {
+			return
+		}
+		last = -1
+		if x >= 0 {
+			row[x] = r
+			last = x
+		}
+		if w == 2 && x+1 >= 0 {
+			row[x+1] = WideCont
+		}
+		x += w
+	}
+}
+
+// A character followed by zero-width ones still takes a single cell, so a
+// rendered grid stores the whole cluster in that cell as one rune from the
+// last private use plane, standing for the cluster's entry in clusters.
+const (
+	clusterFirst rune = 0x100000
+	clusterLast  rune = 0x10FFFD
+)
+
+var clusters struct {
+	sync.Mutex
+	ids   map[string]rune
+	texts []string
+}
+
+// JoinCell returns the rendered cell that shows cell followed by the
+// zero-width character r. Once the private use plane is used up, r is
+// dropped.
+func JoinCell(cell, r rune) rune {
+	text := cellText(cell) + string(r)
+	clusters.Lock()
+	defer clusters.Unlock()
+	if id, ok := clusters.ids[text]; ok {
+		return id
+	}
+	id := clusterFirst + rune(len(clusters.texts))
+	if id > clusterLast {
+		return cell
+	}
+	if clusters.ids == nil {
+		clusters.ids = make(map[string]rune)
+	}
+	clusters.ids[text] = id
+	clusters.texts = append(clusters.texts, text)
+	return id
+}
+
+// cellText is what a rendered cell shows.
+func cellText(cell rune) string {
+	if cell >= clusterFirst && cell <= clusterLast {
+		clusters.Lock()
+		defer clusters.Unlock()
+		if i := int(cell - clusterFirst); i < len(clusters.texts) {
+			return clusters.texts[i]
+		}
+	}
+	return string(cell)
+}
+
+// cellWidth is how many columns a rendered cell's character takes.
+func cellWidth(cell rune) int {
+	if cell >= clusterFirst && cell <= clusterLast {
+		for _, r := range cellText(cell) {
+			return RuneWidth(r)
+		}
+	}
+	return RuneWidth(cell)
+}
+
+// repairCells blanks the halves of double-width characters that something
+// else was drawn over, so that every cell shows as exactly one column.
+func repairCells(row []rune) {
+	for x, r := range row {
+		switch {
+		case r == WideCont:
+			if x == 0 || cellWidth(row[x-1]) != 2 {
+				row[x] = ' '
+			}
+		case cellWidth(r) == 2:
+			if x+1 >= len(row) || row[x+1] != WideCont {
+				row[x] = ' '
+			}
+		}
+	}
+}
+
+// cellString is the text shown for a run of repaired cells.
+func cellString(cells []rune) string {
+	var out strings.Builder
+	for _, r := range cells {
+		if r != WideCont {
+			out.WriteString(cellText(r))
+		}
+	}
+	return out.String()
+}
+
+// RowText is the text that displays a rendered row.
+func RowText(row []rune) string {
+	cells := append([]rune(nil), row...)
+	repairCells(cells)
+	return cellString(cells)
+}
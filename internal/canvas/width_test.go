@@ -0,0 +1,112 @@
+package canvas
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWideTextSizesBoxesByColumns(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "日本語のラベル")
+	c.AddBox(0, 5, "Ångström")
+	if w := c.boxes[0].Width; w != 16 {
+		t.Errorf("expected a 7-character CJK label to need a 16-wide box, got %d", w)
+	}
+	if w := c.boxes[1].Width; w != 10 {
+		t.Errorf("expected accented letters to take one column each, got width %d", w)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteVisualTXT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("unexpected export:\n%s", buf.String())
+	}
+	// The border and the label row end in the same column.
+	if top, label := StringWidth(lines[0]), StringWidth(lines[1]); top != label {
+		t.Errorf("border is %d columns but the label row is %d:\n%s", top, label, buf.String())
+	}
+	if !strings.Contains(lines[1], "|日本語のラベル|") {
+		t.Errorf("expected the label inside the borders, got %q", lines[1])
+	}
+}
+
+func TestShrinkingWideBoxTruncatesWholeCharacters(t *testing.T) {
+	c := NewCanvas()
+	c.AddBox(0, 0, "漢字漢字漢字漢字")
+	c.ResizeBox(0, -9, 0)
+	line := c.boxes[0].Lines[0]
+	if !utf8.ValidString(line) || !strings.HasSuffix(line, "...") {
+		t.Fatalf("expected a valid truncated line, got %q", line)
+	}
+	if w := StringWidth(line); w > c.boxes[0].Width-2 {
+		t.Errorf("truncated line is %d columns, box only has %d", w, c.boxes[0].Width-2)
+	}
+}
+
+func TestWideTextHitTestingAndBounds(t *testing.T) {
+	c := NewCanvas()
+	c.AddText(10, 2, "表")
+	if c.GetTextAt(11, 2) != 0 {
+		t.Error("expected the right half of a wide character to hit the text")
+	}
+	if c.GetTextAt(12, 2) != -1 {
+		t.Error("expected the text to end after two columns")
+	}
+	if _, _, maxX, _ := c.GetFullBounds(); maxX != 12 {
+		t.Errorf("expected the bounds to end at 12, got %d", maxX)
+	}
+	if cells := c.GetTextCells(0); len(cells) != 2 {
+		t.Errorf("expected two cells under the text, got %v", cells)
+	}
+}
+
+func TestRowTextBlanksOverwrittenWideCharacters(t *testing.T) {
+	row := []rune("          ")
+	putText(row, 0, len(row), "ab表cd")
+	if got := RowText(row); got != "ab表cd    " {
+		t.Fatalf("got %q", got)
+	}
+	row[3] = '|' // a line drawn over the right half
+	if got := RowText(row); got != "ab |cd    " {
+		t.Errorf("got %q", got)
+	}
+	row = []rune("    ")
+	putText(row, 0, 3, "a表表")
+	if got := RowText(row); got != "a表 " {
+		t.Errorf("expected the character that doesn't fit left out, got %q", got)
+	}
+}
+
+func TestZeroWidthCharactersStayWithTheirLetter(t *testing.T) {
+	for _, label := range []string{
+		"Renée",  // decomposed é
+		"ภาษาไทย", // Thai with vowel marks
+		"नमस्ते",  // Devanagari with vowel signs and a virama
+		"👨‍👩‍👧",   // family emoji joined with ZWJ
+	} {
+		c := NewCanvas()
+		c.AddBox(0, 0, label)
+		var buf bytes.Buffer
+		if err := c.WriteVisualTXT(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "|"+label) {
+			t.Errorf("expected %q drawn whole, got:\n%s", label, buf.String())
+		}
+	}
+
+	row := []rune("     ")
+	putText(row, 0, len(row), "éé")
+	if got := RowText(row); got != "éé   " {
+		t.Fatalf("got %q", got)
+	}
+	row[1] = '|' // a line drawn over the second letter takes its accent too
+	if got := RowText(row); got != "é|   " {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cv "flerm/internal/canvas"
+	"flerm/internal/tui"
+)
+
+var Version = "dev"
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]*command{}
+
+var openTUI = tui.Run
+
+func register(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+func Main(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "--help", "help":
+			if len(args) > 1 {
+				if cmd, ok := commands[args[1]]; ok {
+					fmt.Fprintf(stdout, "usage: flerm %s\n\n%s\n", cmd.usage, cmd.summary)
+					return exitOK
+				}
+				fmt.Fprintf(stderr, "flerm: unknown command %q\n", args[1])
+				return exitUsage
+			}
+			printUsage(stdout)
+			return exitOK
+		case "-v", "--version":
+			return runVersion(nil, stdout, stderr)
+		case "--":
+			args = args[1:]
+		default:
+			if cmd, ok := commands[args[0]]; ok {
+				return cmd.run(args[1:], stdout, stderr)
+			}
+			if strings.HasPrefix(args[0], "-") {
+				fmt.Fprintf(stderr, "flerm: unknown flag %s\n", args[0])
+				printUsage(stderr)
+				return exitUsage
+			}
+		}
+	}
+	if err := openTUI(args...); err != nil {
+		printLoadError(stderr, "flerm", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// printLoadError reports err under prefix, one line per problem when it is a
+// *canvas.LoadError.
+func printLoadError(w io.Writer, prefix string, err error) {
+	var loadErr *cv.LoadError
+	if !errors.As(err, &loadErr) {
+		fmt.Fprintf(w, "%s: %s\n", prefix, err)
+		return
+	}
+	for _, d := range loadErr.Diagnostics {
+		fmt.Fprintf(w, "%s: %s\n", prefix, d)
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: flerm [files...]")
+	fmt.Fprintln(w, "       flerm <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'flerm help <command>' for details.")
+}
+
+func init() {
+	register(&command{
+		name:    "version",
+		usage:   "version",
+		summary: "Print the flerm version",
+		run:     runVersion,
+	})
+}
+
+func runVersion(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(stderr, "usage: flerm version")
+		return exitUsage
+	}
+	fmt.Fprintf(stdout, "flerm %s\n", Version)
+	return exitOK
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func stubTUI(t *testing.T, err error) *[]string {
+	t.Helper()
+	var opened []string
+	prev := openTUI
+	openTUI = func(files ...string) error {
+		opened = append(opened, files...)
+		return err
+	}
+	t.Cleanup(func() { openTUI = prev })
+	return &opened
+}
+
+func TestFilesOpenInTUI(t *testing.T) {
+	opened := stubTUI(t, nil)
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"a.sav", "b.sav"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+	}
+	if len(*opened) != 2 || (*opened)[0] != "a.sav" || (*opened)[1] != "b.sav" {
+		t.Fatalf("expected both files passed to the TUI, got %v", *opened)
+	}
+}
+
+func TestDoubleDashOpensFileNamedLikeCommand(t *testing.T) {
+	opened := stubTUI(t, nil)
+	var stdout, stderr bytes.Buffer
+	Main([]string{"--", "version"}, &stdout, &stderr)
+	if len(*opened) != 1 || (*opened)[0] != "version" {
+		t.Fatalf("expected 'version' opened as a file, got %v", *opened)
+	}
+}
+
+func TestTUIErrorExitsNonZero(t *testing.T) {
+	stubTUI(t, errors.New("boom"))
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"bad.sav"}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected exit %d, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "boom") {
+		t.Fatalf("expected error on stderr, got %q", stderr.String())
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	stubTUI(t, nil)
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"version"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	if stdout.String() != "flerm "+Version+"\n" {
+		t.Fatalf("unexpected version output %q", stdout.String())
+	}
+}
+
+func TestUnknownFlagIsUsageError(t *testing.T) {
+	opened := stubTUI(t, nil)
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"--bogus"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("expected exit %d, got %d", exitUsage, code)
+	}
+	if len(*opened) != 0 {
+		t.Fatal("TUI should not start on a usage error")
+	}
+}
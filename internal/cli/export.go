@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cv "flerm/internal/canvas"
+)
+
+func init() {
+	register(&command{
+		name:    "export",
+		usage:   "export [-f format] [-o output] [png options] files...",
+		summary: "Render charts to " + formatNames() + " without starting the editor",
+		run:     runExport,
+	})
+}
+
+func formatNames() string {
+	var names []string
+	for _, f := range cv.ExportFormats() {
+		names = append(names, f.Name)
+	}
+	return strings.Join(names, "/")
+}
+
+func runExport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	formatName := fs.String("f", "", "output format: "+formatNames()+" (default: from -o, else txt)")
+	output := fs.String("o", "", "output file, directory for several inputs, or - for stdout")
+	pngDefaults := cv.DefaultPNGOptions()
+	scale := fs.Float64("scale", pngDefaults.Scale, "png: scale factor")
+	fontSize := fs.Float64("font-size", pngDefaults.FontSize, "png: font size in points")
+	theme := fs.String("theme", "light", "png: color theme, light or dark")
+	transparent := fs.Bool("transparent", false, "png: leave the background transparent")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: flerm export [-f format] [-o output] [png options] files...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	inputs, err := expandInputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "flerm export: %s\n", err)
+		return exitUsage
+	}
+	if len(inputs) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	format, _ := cv.LookupExportFormat("txt")
+	switch {
+	case *formatName != "":
+		f, ok := cv.LookupExportFormat(*formatName)
+		if !ok {
+			fmt.Fprintf(stderr, "flerm export: unknown format %q (want %s)\n", *formatName, formatNames())
+			return exitUsage
+		}
+		format = f
+	case *output != "" && *output != "-":
+		if f, found := cv.ExportFormatForFile(*output); found {
+			format = f
+		}
+	}
+
+	pngFlags := map[string]bool{"scale": true, "font-size": true, "theme": true, "transparent": true}
+	var misused []string
+	fs.Visit(func(f *flag.Flag) {
+		if pngFlags[f.Name] && format.Name != "png" {
+			misused = append(misused, "-"+f.Name)
+		}
+	})
+	if len(misused) > 0 {
+		fmt.Fprintf(stderr, "flerm export: %s only applies to png exports\n", strings.Join(misused, ", "))
+		return exitUsage
+	}
+	write := format.Write
+	if format.Name == "png" {
+		pngTheme, err := cv.ParsePNGTheme(*theme)
+		if err != nil {
+			fmt.Fprintf(stderr, "flerm export: %s\n", err)
+			return exitUsage
+		}
+		if *scale <= 0 || *fontSize <= 0 {
+			fmt.Fprintln(stderr, "flerm export: -scale and -font-size must be positive")
+			return exitUsage
+		}
+		opts := cv.PNGOptions{Scale: *scale, FontSize: *fontSize, Theme: pngTheme, Transparent: *transparent}
+		write = func(c *cv.Canvas, w io.Writer) error {
+			return c.WritePNGWithOptions(w, opts)
+		}
+	}
+
+	outputDir := ""
+	if *output != "" && *output != "-" {
+		if info, err := os.Stat(*output); err == nil && info.IsDir() {
+			outputDir = *output
+		}
+	}
+	if len(inputs) > 1 && outputDir == "" && *output != "" {
+		if *output == "-" {
+			fmt.Fprintln(stderr, "flerm export: -o - needs a single input")
+		} else {
+			fmt.Fprintf(stderr, "flerm export: -o %s must be an existing directory when exporting several files\n", *output)
+		}
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, input := range inputs {
+		res, err := cv.Load(input, cv.LoadOptions{})
+		if err != nil {
+			printLoadError(stderr, "flerm export", err)
+			code = exitError
+			continue
+		}
+		for _, warning := range res.Warnings {
+			fmt.Fprintf(stderr, "flerm export: warning: %s\n", warning)
+		}
+		canvas := res.Canvas
+		var buf bytes.Buffer
+		if err := write(canvas, &buf); err != nil {
+			fmt.Fprintf(stderr, "flerm export: %s: %s\n", input, err)
+			code = exitError
+			continue
+		}
+
+		target := *output
+		if target == "" || outputDir != "" {
+			dir := outputDir
+			if dir == "" {
+				dir = filepath.Dir(input)
+			}
+			target = filepath.Join(dir, exportName(input, format))
+		}
+		if target == "-" {
+			_, err = stdout.Write(buf.Bytes())
+		} else {
+			err = os.WriteFile(target, buf.Bytes(), 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "flerm export: %s\n", err)
+			code = exitError
+		}
+	}
+	return code
+}
+
+func exportName(input string, format cv.ExportFormat) string {
+	base := filepath.Base(input)
+	if cv.IsJSONFile(base) {
+		base = base[:len(base)-len(cv.JSONExtension)]
+	} else if ext := filepath.Ext(base); strings.EqualFold(ext, ".sav") {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return base + format.Extension
+}
+
+func expandInputs(patterns []string) ([]string, error) {
+	var inputs []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", pattern)
+			}
+			sort.Strings(matches)
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				inputs = append(inputs, match)
+			}
+		}
+	}
+	return inputs, nil
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cv "flerm/internal/canvas"
+)
+
+func writeChart(t *testing.T, dir, name, title string) string {
+	t.Helper()
+	c := cv.NewCanvas()
+	c.AddBox(2, 1, title)
+	c.AddBox(30, 8, "Next")
+	c.AddConnection(0, 1)
+	c.SetHighlight(3, 2, 1)
+	c.SetHighlight(4, 2, 2)
+	path := filepath.Join(dir, name)
+	if err := c.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExportTXTToStdoutIsDeterministic(t *testing.T) {
+	path := writeChart(t, t.TempDir(), "chart.sav", "Start")
+	var first string
+	for i := 0; i < 3; i++ {
+		var stdout, stderr bytes.Buffer
+		if code := Main([]string{"export", "-f", "txt", "-o", "-", path}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "Start") {
+			t.Fatalf("expected box text in output, got:\n%s", stdout.String())
+		}
+		if i == 0 {
+			first = stdout.String()
+		} else if stdout.String() != first {
+			t.Fatal("export output differs between runs")
+		}
+	}
+}
+
+func TestExportGlobWritesNextToInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeChart(t, dir, "a.sav", "A")
+	writeChart(t, dir, "b.sav", "B")
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"export", "-f", "png", filepath.Join(dir, "*.sav")}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+	}
+	for _, name := range []string{"a.png", "b.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected %s to be written: %v", name, err)
+		}
+		if !bytes.HasPrefix(data, []byte("\x89PNG")) {
+			t.Fatalf("%s is not a PNG", name)
+		}
+	}
+}
+
+func TestExportFormatFromOutputExtension(t *testing.T) {
+	dir := t.TempDir()
+	path := writeChart(t, dir, "chart.sav", "Start")
+	out := filepath.Join(dir, "out.txt")
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"export", "-o", out, path}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || !strings.Contains(string(data), "Start") {
+		t.Fatalf("expected text export at %s, err=%v", out, err)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.sav")
+	if err := cv.NewCanvas().SaveToFile(empty); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"missing file", []string{"export", filepath.Join(dir, "nope.sav")}, exitError},
+		{"empty chart", []string{"export", "-o", "-", empty}, exitError},
+		{"unknown format", []string{"export", "-f", "bmp", empty}, exitUsage},
+		{"no inputs", []string{"export"}, exitUsage},
+		{"glob without matches", []string{"export", filepath.Join(dir, "*.none")}, exitUsage},
+		{"stdout with several inputs", []string{"export", "-o", "-", empty, empty + "x"}, exitUsage},
+	}
+	for _, tc := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := Main(tc.args, &stdout, &stderr); code != tc.code {
+			t.Errorf("%s: expected exit %d, got %d (%s)", tc.name, tc.code, code, stderr.String())
+		}
+	}
+}
+
+func TestExportReportsLoadDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.sav")
+	if err := os.WriteFile(broken, []byte("FLOWCHART\nBOXES:1\n1,x,8,3,0,0,,A\nCONNECTIONS:0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	coerced := filepath.Join(dir, "coerced.sav")
+	if err := os.WriteFile(coerced, []byte("FLOWCHART\nBOXES:1\n1,1,8,3,9,0,,A\nCONNECTIONS:0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"export", "-o", "-", broken}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected exit 1, got %d", code)
+	}
+	if want := "flerm export: " + broken + ":3: invalid box Y \"x\": not a number\n"; stderr.String() != want {
+		t.Errorf("got %q, want %q", stderr.String(), want)
+	}
+
+	stderr.Reset()
+	if code := Main([]string{"export", "-o", "-", coerced}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "warning: "+coerced+":3: ZLevel 9 out of range") {
+		t.Errorf("expected a ZLevel warning, got %q", stderr.String())
+	}
+}
+
+func TestExportPNGOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := writeChart(t, dir, "chart.sav", "Start")
+	size := func(args ...string) (int, int) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"export", "-f", "png", "-o", "-"}, append(args, path)...)
+		if code := Main(args, &stdout, &stderr); code != exitOK {
+			t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+		}
+		cfg, err := png.DecodeConfig(&stdout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg.Width, cfg.Height
+	}
+	w1, h1 := size()
+	w2, h2 := size("-scale", "2", "-theme", "dark", "-transparent")
+	if w2 != 2*w1 || h2 != 2*h1 {
+		t.Fatalf("expected -scale 2 to double %dx%d, got %dx%d", w1, h1, w2, h2)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"export", "-f", "txt", "-scale", "2", path}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("expected png flags with txt to be a usage error, got %d", code)
+	}
+	if code := Main([]string{"export", "-f", "png", "-theme", "sepia", path}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("expected unknown theme to be a usage error, got %d", code)
+	}
+}
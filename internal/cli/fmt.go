@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	cv "flerm/internal/canvas"
+)
+
+func init() {
+	register(&command{
+		name:    "fmt",
+		usage:   "fmt [-check] files...",
+		summary: "Rewrite saved charts in canonical form so unchanged charts diff cleanly",
+		run:     runFmt,
+	})
+}
+
+func runFmt(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	check := fs.Bool("check", false, "list files that are not canonical instead of rewriting them; exit 1 if any")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: flerm fmt [-check] files...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	inputs, err := expandInputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "flerm fmt: %s\n", err)
+		return exitUsage
+	}
+	if len(inputs) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, input := range inputs {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			fmt.Fprintf(stderr, "flerm fmt: %s\n", err)
+			code = exitError
+			continue
+		}
+		formatted, err := cv.FormatChart(input, data)
+		if err != nil {
+			printLoadError(stderr, "flerm fmt", err)
+			code = exitError
+			continue
+		}
+		if bytes.Equal(data, formatted) {
+			continue
+		}
+		if *check {
+			fmt.Fprintln(stdout, input)
+			code = exitError
+			continue
+		}
+		err = cv.WriteFileAtomic(input, 0, func(w io.Writer) error {
+			_, err := w.Write(formatted)
+			return err
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "flerm fmt: %s\n", err)
+			code = exitError
+		}
+	}
+	return code
+}
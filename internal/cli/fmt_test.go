@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cv "flerm/internal/canvas"
+)
+
+func TestSaveIsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	path := writeChart(t, dir, "chart.sav", "Start")
+	first, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		c := cv.NewCanvas()
+		if err := c.LoadFromFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.SaveToFile(path); err != nil {
+			t.Fatal(err)
+		}
+		again, _ := os.ReadFile(path)
+		if !bytes.Equal(first, again) {
+			t.Fatalf("save %d changed an unchanged chart:\n%s\nthen:\n%s", i, first, again)
+		}
+	}
+	if !strings.Contains(string(first), "HIGHLIGHTS:2\n3,2,1\n4,2,2\n") {
+		t.Fatalf("expected highlights sorted by y,x, got:\n%s", first)
+	}
+}
+
+func TestFmtRewritesInPlaceAndChecks(t *testing.T) {
+	dir := t.TempDir()
+	canonical := writeChart(t, dir, "ok.sav", "Fine")
+	messy := filepath.Join(dir, "messy.sav")
+	old := "FLOWCHART\nBOXES:1\n5,5,Box\nCONNECTIONS:0\nTEXTS:0\nHIGHLIGHTS:2\n9,9,1\n1,1,2\nPAN:3,4\n"
+	if err := os.WriteFile(messy, []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"fmt", "--check", filepath.Join(dir, "*.sav")}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected exit 1 from --check, got %d (%s)", code, stderr.String())
+	}
+	if got := strings.TrimSpace(stdout.String()); got != messy {
+		t.Fatalf("expected only %s listed, got %q", messy, got)
+	}
+	if data, _ := os.ReadFile(messy); string(data) != old {
+		t.Fatal("--check must not modify files")
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := Main([]string{"fmt", messy, canonical}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, stderr.String())
+	}
+	data, _ := os.ReadFile(messy)
+	if !strings.Contains(string(data), "HIGHLIGHTS:2\n1,1,2\n9,9,1\n") || !strings.HasSuffix(string(data), "PAN:3,4\n") {
+		t.Fatalf("expected sorted highlights and the pan kept, got:\n%s", data)
+	}
+	if info, _ := os.Stat(messy); info.Mode().Perm() != 0o600 {
+		t.Errorf("expected file mode kept, got %v", info.Mode().Perm())
+	}
+
+	stdout.Reset()
+	if code := Main([]string{"fmt", "-check", messy, canonical}, &stdout, &stderr); code != exitOK || stdout.Len() != 0 {
+		t.Fatalf("expected formatted files to pass -check, got %d: %s", code, stdout.String())
+	}
+}
+
+func TestFmtReportsUnreadableCharts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.sav")
+	if err := os.WriteFile(path, []byte("not a chart\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"fmt", path}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected exit 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "bad.sav") {
+		t.Fatalf("expected the file named in the error, got %q", stderr.String())
+	}
+}
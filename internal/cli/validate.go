@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	cv "flerm/internal/canvas"
+)
+
+func init() {
+	register(&command{
+		name:    "validate",
+		usage:   "validate [-json] files...",
+		summary: "Check charts for detached lines, overlapping boxes, stray highlights and bad colors",
+		run:     runValidate,
+	})
+}
+
+// validateIssue is one problem in a chart, as printed by flerm validate.
+// Problems found while loading have a Line; the rest have a Position on the
+// canvas.
+type validateIssue struct {
+	File     string         `json:"file"`
+	Line     int            `json:"line,omitempty"`
+	Position *validatePoint `json:"position,omitempty"`
+	Severity string         `json:"severity"`
+	Rule     string         `json:"rule"`
+	Message  string         `json:"message"`
+}
+
+type validatePoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// String formats the issue as file:line or file:x,y, then the severity,
+// message and rule, which stays easy to grep and to split on ": ".
+func (i validateIssue) String() string {
+	where := i.File
+	if i.Line > 0 {
+		where = fmt.Sprintf("%s:%d", i.File, i.Line)
+	} else if i.Position != nil {
+		where = fmt.Sprintf("%s:%d,%d", i.File, i.Position.X, i.Position.Y)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", where, i.Severity, i.Message, i.Rule)
+}
+
+// ruleLoad marks problems reported by the loader rather than by Validate.
+const ruleLoad = "load"
+
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print the problems as a JSON array")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: flerm validate [-json] files...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	inputs, err := expandInputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "flerm validate: %s\n", err)
+		return exitUsage
+	}
+	if len(inputs) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	issues := []validateIssue{}
+	for _, input := range inputs {
+		issues = append(issues, validateFile(input)...)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(issues); err != nil {
+			fmt.Fprintf(stderr, "flerm validate: %s\n", err)
+			return exitError
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintln(stdout, issue)
+		}
+	}
+	if len(issues) > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// validateFile loads a chart and checks it, turning load diagnostics into
+// issues so a damaged file is reported the same way as a broken chart.
+func validateFile(input string) []validateIssue {
+	fromDiagnostic := func(d cv.Diagnostic, severity cv.Severity) validateIssue {
+		return validateIssue{File: input, Line: d.Line, Severity: severity.String(), Rule: ruleLoad, Message: d.Message}
+	}
+
+	res, err := cv.Load(input, cv.LoadOptions{})
+	if err != nil {
+		var loadErr *cv.LoadError
+		if !errors.As(err, &loadErr) {
+			return []validateIssue{{File: input, Severity: cv.SeverityError.String(), Rule: ruleLoad, Message: err.Error()}}
+		}
+		var issues []validateIssue
+		for _, d := range loadErr.Diagnostics {
+			issues = append(issues, fromDiagnostic(d, cv.SeverityError))
+		}
+		return issues
+	}
+
+	var issues []validateIssue
+	for _, d := range res.Warnings {
+		issues = append(issues, fromDiagnostic(d, cv.SeverityWarning))
+	}
+	for _, issue := range res.Canvas.Validate() {
+		issues = append(issues, validateIssue{
+			File:     input,
+			Position: &validatePoint{X: issue.X, Y: issue.Y},
+			Severity: issue.Severity.String(),
+			Rule:     issue.Rule,
+			Message:  issue.Message,
+		})
+	}
+	return issues
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cv "flerm/internal/canvas"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	clean := writeChart(t, dir, "clean.sav", "Start")
+
+	c := cv.NewCanvas()
+	c.AddBox(0, 0, "A")
+	c.AddBox(4, 1, "B")
+	overlap := filepath.Join(dir, "overlap.sav")
+	if err := c.SaveToFile(overlap); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.sav")
+	if err := os.WriteFile(broken, []byte("FLOWCHART\nBOXES:1\n1,x,8,3,0,0,,A\nCONNECTIONS:0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := Main([]string{"validate", clean}, &stdout, &stderr); code != exitOK || stdout.Len() != 0 {
+		t.Fatalf("expected a clean chart to pass, got %d: %s%s", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	if code := Main([]string{"validate", overlap, broken}, &stdout, &stderr); code != exitError {
+		t.Fatalf("expected exit 1, got %d", code)
+	}
+	want := overlap + ":4,1: warning: boxes 0 and 1 overlap [overlap]\n" +
+		broken + ":3: error: invalid box Y \"x\": not a number [load]\n"
+	if stdout.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	Main([]string{"validate", "-json", overlap}, &stdout, &stderr)
+	var issues []validateIssue
+	if err := json.Unmarshal(stdout.Bytes(), &issues); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	if len(issues) != 1 || issues[0].Rule != cv.RuleOverlap || issues[0].Position == nil || *issues[0].Position != (validatePoint{X: 4, Y: 1}) {
+		t.Errorf("unexpected issues %+v", issues)
+	}
+}
@@ -4,13 +4,30 @@ import (
 	"bufio"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// DefaultBackups is how many earlier versions of a chart a save keeps when
+// .flermrc doesn't say otherwise.
+const DefaultBackups = 1
+
 type Config struct {
 	SaveDirectory string
 	StartMenu     bool
 	Confirmations bool
+	// Backups is how many earlier versions of a chart to keep when saving
+	// over it, as chart.sav~, chart.sav~2 and so on. Zero keeps none.
+	Backups int
+	// Autosave is how many seconds pass between autosaves of changed
+	// charts. Zero turns autosave off.
+	Autosave int
+	// RecoveryDirectory is where autosaved charts are kept until they are
+	// saved for real.
+	RecoveryDirectory string
+	// TreeRelayout lays a tree arranged with y or Y out again every time a
+	// line is drawn or pasted into the chart.
+	TreeRelayout bool
 }
 
 func Load() *Config {
@@ -18,6 +35,11 @@ func Load() *Config {
 		SaveDirectory: "",
 		StartMenu:     true,
 		Confirmations: true,
+		Backups:       DefaultBackups,
+		Autosave:      30,
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		config.RecoveryDirectory = filepath.Join(cacheDir, "flerm", "recovery")
 	}
 
 	homeDir, err := os.UserHomeDir()
@@ -49,25 +71,43 @@ func Load() *Config {
 
 		switch strings.ToLower(key) {
 		case "savedirectory", "save_directory", "savedir":
-			if strings.HasPrefix(value, "~") {
-				value = filepath.Join(homeDir, strings.TrimPrefix(value, "~"))
-			}
-			if !filepath.IsAbs(value) {
-				if absPath, err := filepath.Abs(value); err == nil {
-					value = absPath
-				}
-			}
-			config.SaveDirectory = value
+			config.SaveDirectory = expandPath(homeDir, value)
 		case "startmenu", "start_menu":
 			config.StartMenu = strings.ToLower(value) == "true"
 		case "confirmations", "confirm":
 			config.Confirmations = strings.ToLower(value) == "true"
+		case "autosave":
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				config.Autosave = n
+			}
+		case "recoverydirectory", "recovery_directory", "recoverydir":
+			config.RecoveryDirectory = expandPath(homeDir, value)
+		case "treerelayout", "tree_relayout":
+			config.TreeRelayout = strings.ToLower(value) == "true"
+		case "backups":
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				config.Backups = n
+			}
 		}
 	}
 
 	return config
 }
 
+// expandPath turns a directory from .flermrc into an absolute path, reading a
+// leading ~ as the home directory.
+func expandPath(homeDir, value string) string {
+	if strings.HasPrefix(value, "~") {
+		value = filepath.Join(homeDir, strings.TrimPrefix(value, "~"))
+	}
+	if !filepath.IsAbs(value) {
+		if absPath, err := filepath.Abs(value); err == nil {
+			value = absPath
+		}
+	}
+	return value
+}
+
 func (c *Config) GetSavePath(filename string) string {
 	if c.SaveDirectory == "" {
 		return filename
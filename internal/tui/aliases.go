@@ -6,15 +6,20 @@ import (
 )
 
 type (
-	Canvas        = cv.Canvas
-	Box           = cv.Box
-	Text          = cv.Text
-	Connection    = cv.Connection
-	RenderResult  = cv.RenderResult
-	HighlightCell = cv.HighlightCell
-	BorderStyle   = cv.BorderStyle
-	point         = cv.Point
-	Config        = config.Config
+	Canvas          = cv.Canvas
+	Box             = cv.Box
+	Text            = cv.Text
+	Connection      = cv.Connection
+	RenderResult    = cv.RenderResult
+	HighlightCell   = cv.HighlightCell
+	Issue           = cv.Issue
+	FileStamp       = cv.FileStamp
+	Group           = cv.Group
+	BorderStyle     = cv.BorderStyle
+	Shape           = cv.Shape
+	LayoutDirection = cv.LayoutDirection
+	point           = cv.Point
+	Config          = config.Config
 )
 
 const (
@@ -22,9 +27,17 @@ const (
 	colorMouseSelect = cv.ColorMouseSelect
 	colorMenuSelect  = cv.ColorMenuSelect
 	colorMenuBorder  = cv.ColorMenuBorder
+	wideCont         = cv.WideCont
 
 	BorderStyleASCII   = cv.BorderStyleASCII
 	BorderStyleSingle  = cv.BorderStyleSingle
 	BorderStyleDouble  = cv.BorderStyleDouble
 	BorderStyleRounded = cv.BorderStyleRounded
+
+	ShapeRectangle     = cv.ShapeRectangle
+	ShapeDiamond       = cv.ShapeDiamond
+	ShapeTerminator    = cv.ShapeTerminator
+	ShapeParallelogram = cv.ShapeParallelogram
+	ShapeDatabase      = cv.ShapeDatabase
+	ShapeDocument      = cv.ShapeDocument
 )
@@ -11,9 +11,13 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func Run() error {
+func Run(files ...string) error {
+	m := initialModel()
+	if err := m.openFiles(files); err != nil {
+		return err
+	}
 	p := tea.NewProgram(
-		initialModel(),
+		m,
 		tea.WithAltScreen(),
 
 		tea.WithMouseAllMotion(),
@@ -31,8 +35,9 @@ func abs(x int) int {
 
 func initialModel() model {
 	cfg := config.Load()
+	recovered := scanRecovery(cfg.RecoveryDirectory)
 	initialMode := ModeStartup
-	if !cfg.StartMenu {
+	if !cfg.StartMenu && len(recovered) == 0 {
 		initialMode = ModeNormal
 	}
 	buffer := Buffer{
@@ -53,6 +58,7 @@ func initialModel() model {
 		connectionFrom:         -1,
 		connectionFromLine:     -1,
 		config:                 cfg,
+		recovered:              recovered,
 		highlightMode:          false,
 		selectedColor:          0,
 		selectionStartX:        -1,
@@ -74,6 +80,59 @@ func initialModel() model {
 	}
 }
 
+func (m *model) openFiles(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	var buffers []Buffer
+	for _, path := range files {
+		canvas := cv.NewCanvas()
+		panX, panY := 0, 0
+		filename := path
+		var stamp cv.FileStamp
+		if format, ok := cv.ImportFormatForFile(path); ok {
+			imported, err := cv.ImportFile(path, format)
+			if err != nil {
+				return err
+			}
+			canvas, filename = imported, importedFilename(path)
+		} else if _, err := os.Stat(path); err == nil {
+			res, err := cv.Load(path, cv.LoadOptions{})
+			if err != nil {
+				return err
+			}
+			canvas, stamp = res.Canvas, res.Stamp
+			if res.Pan != nil {
+				panX, panY = res.Pan.X, res.Pan.Y
+			}
+			if m.successMessage == "" {
+				m.successMessage = loadWarningMessage(res.Warnings)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		buffers = append(buffers, Buffer{
+			canvas:    canvas,
+			undoStack: []Action{},
+			redoStack: []Action{},
+			filename:  filename,
+			panX:      panX,
+			panY:      panY,
+			diskStamp: stamp,
+		})
+	}
+	m.buffers = buffers
+	m.currentBufferIndex = 0
+	m.mode = ModeNormal
+	if len(m.recovered) > 0 {
+		// The start menu isn't shown, so ask about the charts left by an
+		// earlier session before they are forgotten.
+		m.mode = ModeConfirm
+		m.confirmAction = ConfirmRestoreRecovered
+	}
+	return nil
+}
+
 func (m *model) ensureCursorInBounds() {
 	if m.cursorX < 0 {
 		m.cursorX = 0
@@ -112,7 +171,10 @@ func (m *model) scanTxtFiles() {
 		return
 	}
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".sav") {
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := cv.ImportFormatForFile(entry.Name()); ok || isChartFile(entry.Name()) {
 			m.fileList = append(m.fileList, entry.Name())
 		}
 	}
@@ -0,0 +1,127 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+)
+
+// arrangeBoxes lays out the boxes at the given indices, or the whole chart
+// when there are none, as one undoable step.
+func (m *model) arrangeBoxes(boxes []int, dir LayoutDirection) {
+	c := m.getCanvas()
+	if len(c.Boxes()) == 0 {
+		return
+	}
+	before := snapshotArrangement(c, boxes)
+	if !c.Arrange(boxes, dir) {
+		m.successMessage = "Already arranged"
+		m.errorMessage = ""
+		return
+	}
+	m.recordAction(ActionArrange, snapshotArrangement(c, boxes), before)
+	m.successMessage = "Arranged " + arrangedWhat(len(boxes))
+	m.errorMessage = ""
+}
+
+func arrangedWhat(n int) string {
+	switch n {
+	case 0:
+		return "the chart"
+	case 1:
+		return "1 box"
+	}
+	return fmt.Sprintf("%d boxes", n)
+}
+
+// snapshotArrangement records where the boxes at the given indices, or all
+// boxes when there are none, are and how the lines that moving them can
+// change run: the ones attached to them and the ones that branch off a line.
+func snapshotArrangement(c *Canvas, boxes []int) ArrangeData {
+	var data ArrangeData
+	ids := map[int]bool{}
+	for i, box := range c.Boxes() {
+		if len(boxes) > 0 && !slices.Contains(boxes, i) {
+			continue
+		}
+		ids[box.ID] = true
+		data.Boxes = append(data.Boxes, BoxPosition{ID: box.ID, X: box.X, Y: box.Y})
+	}
+	for _, conn := range c.Connections() {
+		if ids[conn.FromID] || ids[conn.ToID] || conn.FromID < 0 || conn.ToID < 0 {
+			data.Connections = append(data.Connections, conn.Clone())
+		}
+	}
+	return data
+}
+
+// applyArrangement puts boxes and lines back as snapshotArrangement found
+// them.
+func applyArrangement(c *Canvas, data ArrangeData) {
+	for _, pos := range data.Boxes {
+		c.SetBoxPosition(c.BoxIndex(pos.ID), pos.X, pos.Y)
+	}
+	for _, conn := range data.Connections {
+		if i := c.ConnectionIndex(conn.ID); i >= 0 {
+			c.Connections()[i] = conn.Clone()
+		}
+	}
+}
+
+// arrangeTree lays out the tree grown from the box under the cursor as one
+// undoable step, and remembers it so that, with the treerelayout setting,
+// adding lines can lay it out again.
+func (m *model) arrangeTree(dir LayoutDirection) {
+	panX, panY := m.getPanOffset()
+	c := m.getCanvas()
+	root := c.GetBoxAt(m.cursorX+panX, m.cursorY+panY)
+	if root == -1 {
+		m.errorMessage = "Put the cursor on the box to grow the tree from"
+		m.successMessage = ""
+		return
+	}
+	if buf := m.getCurrentBuffer(); buf != nil {
+		buf.hasTree, buf.treeRoot, buf.treeDir = true, c.BoxID(root), dir
+	}
+	if !m.recordTreeLayout(root, dir) {
+		m.successMessage = "Already arranged"
+		m.errorMessage = ""
+		return
+	}
+	m.successMessage = "Arranged the tree"
+	m.errorMessage = ""
+}
+
+// relayoutTree lays out the remembered tree again, if the treerelayout
+// setting is on and its root is still there. The layout joins the action
+// just recorded, so one undo takes back both.
+func (m *model) relayoutTree() {
+	buf := m.getCurrentBuffer()
+	if buf == nil || !buf.hasTree || m.config == nil || !m.config.TreeRelayout || len(buf.undoStack) == 0 {
+		return
+	}
+	c := m.getCanvas()
+	root := c.BoxIndex(buf.treeRoot)
+	if root < 0 {
+		buf.hasTree = false
+		return
+	}
+	tree := c.TreeBoxes(root, buf.treeDir)
+	before := snapshotArrangement(c, tree)
+	if !c.ArrangeTree(root, buf.treeDir) {
+		return
+	}
+	buf.undoStack[len(buf.undoStack)-1].Relayout = &Relayout{Before: before, After: snapshotArrangement(c, tree)}
+}
+
+// recordTreeLayout lays out the tree from the box at index root and records
+// it as an arrangement, reporting whether anything moved.
+func (m *model) recordTreeLayout(root int, dir LayoutDirection) bool {
+	c := m.getCanvas()
+	tree := c.TreeBoxes(root, dir)
+	before := snapshotArrangement(c, tree)
+	if !c.ArrangeTree(root, dir) {
+		return false
+	}
+	m.recordAction(ActionArrange, snapshotArrangement(c, tree), before)
+	return true
+}
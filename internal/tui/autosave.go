@@ -0,0 +1,419 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	cv "flerm/internal/canvas"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// autosaveMsg is sent by the autosave tick.
+type autosaveMsg struct{}
+
+// recoveryFile is what autosave writes for each changed buffer: the chart in
+// the JSON format along with where it came from.
+type recoveryFile struct {
+	Filename string `json:"filename,omitempty"`
+	// Undo and Redo are the buffer's undo history and SavedUndo its saved
+	// point (see Buffer.savedUndo), so a restored chart can be undone past
+	// the crash and knows whether it matches the file on disk.
+	Undo      []savedAction `json:"undo,omitempty"`
+	Redo      []savedAction `json:"redo,omitempty"`
+	SavedUndo int           `json:"savedUndo"`
+	// PID and Host are the session writing the file. Other sessions leave
+	// it alone for as long as that process is running.
+	PID   int             `json:"pid,omitempty"`
+	Host  string          `json:"host,omitempty"`
+	Saved time.Time       `json:"saved"`
+	Chart json.RawMessage `json:"chart"`
+}
+
+// ownedElsewhere reports whether the file belongs to another session that is
+// still running. A file from another machine sharing the directory can't be
+// checked, so it counts as left behind.
+func (rec recoveryFile) ownedElsewhere() bool {
+	if rec.PID == 0 || rec.PID == os.Getpid() {
+		return false
+	}
+	if host, _ := os.Hostname(); rec.Host != host {
+		return false
+	}
+	return processRunning(rec.PID)
+}
+
+// processRunning reports whether the process with the given ID exists. On
+// Windows FindProcess itself fails once a process has exited.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// savedAction is an undo record as a recovery file holds it. Data and
+// Inverse are decoded into the types actionPayloads gives for Type.
+type savedAction struct {
+	Type     ActionType      `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	Inverse  json.RawMessage `json:"inverse"`
+	Relayout *Relayout       `json:"relayout,omitempty"`
+}
+
+// actionPayloads makes the Data and Inverse values for each action type,
+// as pointers to decode into.
+var actionPayloads = map[ActionType]func() (data, inverse interface{}){
+	ActionAddBox:            func() (interface{}, interface{}) { return &AddBoxData{}, &DeleteBoxData{} },
+	ActionDeleteBox:         func() (interface{}, interface{}) { return &DeleteBoxData{}, &AddBoxData{} },
+	ActionEditBox:           func() (interface{}, interface{}) { return &EditBoxData{}, &EditBoxData{} },
+	ActionEditText:          func() (interface{}, interface{}) { return &EditTextData{}, &EditTextData{} },
+	ActionDeleteText:        func() (interface{}, interface{}) { return &DeleteTextData{}, &AddTextData{} },
+	ActionResizeBox:         func() (interface{}, interface{}) { return &ResizeBoxData{}, &OriginalBoxState{} },
+	ActionMoveBox:           func() (interface{}, interface{}) { return &MoveBoxData{}, &OriginalBoxState{} },
+	ActionMoveText:          func() (interface{}, interface{}) { return &MoveTextData{}, &OriginalTextState{} },
+	ActionAddConnection:     func() (interface{}, interface{}) { return &AddConnectionData{}, &AddConnectionData{} },
+	ActionDeleteConnection:  func() (interface{}, interface{}) { return &AddConnectionData{}, &AddConnectionData{} },
+	ActionCycleArrow:        func() (interface{}, interface{}) { return &CycleArrowData{}, &CycleArrowData{} },
+	ActionHighlight:         func() (interface{}, interface{}) { return &HighlightData{}, &HighlightData{} },
+	ActionChangeBorderStyle: func() (interface{}, interface{}) { return &BorderStyleData{}, &BorderStyleData{} },
+	ActionEditTitle:         func() (interface{}, interface{}) { return &EditTitleData{}, &EditTitleData{} },
+	ActionSetColor:          func() (interface{}, interface{}) { return &ColorData{}, &ColorData{} },
+	ActionPaste:             func() (interface{}, interface{}) { return &Group{}, &Group{} },
+	ActionCut:               func() (interface{}, interface{}) { return &Group{}, &Group{} },
+	ActionArrange:           func() (interface{}, interface{}) { return &ArrangeData{}, &ArrangeData{} },
+	ActionChangeShape:       func() (interface{}, interface{}) { return &ShapeData{}, &ShapeData{} },
+	ActionEditLabel:         func() (interface{}, interface{}) { return &LabelData{}, &LabelData{} },
+}
+
+func encodeActions(actions []Action) ([]savedAction, error) {
+	saved := make([]savedAction, 0, len(actions))
+	for _, a := range actions {
+		data, err := json.Marshal(a.Data)
+		if err != nil {
+			return nil, err
+		}
+		inverse, err := json.Marshal(a.Inverse)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, savedAction{Type: a.Type, Data: data, Inverse: inverse, Relayout: a.Relayout})
+	}
+	return saved, nil
+}
+
+func decodeActions(saved []savedAction) ([]Action, error) {
+	actions := make([]Action, 0, len(saved))
+	for _, sa := range saved {
+		payloads, ok := actionPayloads[sa.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown action type %d", sa.Type)
+		}
+		data, inverse := payloads()
+		if err := json.Unmarshal(sa.Data, data); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(sa.Inverse, inverse); err != nil {
+			return nil, err
+		}
+		actions = append(actions, Action{
+			Type:     sa.Type,
+			Data:     reflect.ValueOf(data).Elem().Interface(),
+			Inverse:  reflect.ValueOf(inverse).Elem().Interface(),
+			Relayout: sa.Relayout,
+		})
+	}
+	return actions, nil
+}
+
+// recoveredChart is a recovery file left behind by an earlier session. When
+// its undo history can't be read, noHistory is set and savedUndo is -1.
+type recoveredChart struct {
+	path      string
+	filename  string
+	undo      []Action
+	redo      []Action
+	savedUndo int
+	noHistory bool
+	saved     time.Time
+	canvas    *Canvas
+	pan       *point
+}
+
+func (m model) recoveryDir() string {
+	if m.config == nil {
+		return ""
+	}
+	return m.config.RecoveryDirectory
+}
+
+// autosaveTick schedules the next autosave, or returns nil when autosave is
+// turned off.
+func (m model) autosaveTick() tea.Cmd {
+	if m.config == nil || m.config.Autosave <= 0 || m.recoveryDir() == "" {
+		return nil
+	}
+	return tea.Tick(time.Duration(m.config.Autosave)*time.Second, func(time.Time) tea.Msg {
+		return autosaveMsg{}
+	})
+}
+
+// autosave writes every modified buffer that changed since it was last
+// autosaved to the recovery directory, and drops the recovery files of
+// buffers that are back to their saved state.
+func (m *model) autosave() {
+	dir := m.recoveryDir()
+	if dir == "" {
+		return
+	}
+	for i := range m.buffers {
+		buf := &m.buffers[i]
+		if !buf.modified() {
+			discardRecovery(buf)
+			continue
+		}
+		if buf.revision == buf.autosavedRevision {
+			continue
+		}
+		if buf.recoveryPath == "" {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				m.errorMessage = fmt.Sprintf("Autosave failed: %s", err)
+				return
+			}
+			// Reserve a name no other session can be using.
+			f, err := os.CreateTemp(dir, "chart-*.json")
+			if err != nil {
+				m.errorMessage = fmt.Sprintf("Autosave failed: %s", err)
+				return
+			}
+			f.Close()
+			buf.recoveryPath = f.Name()
+		}
+		if err := writeRecoveryFile(buf); err != nil {
+			m.errorMessage = fmt.Sprintf("Autosave failed: %s", err)
+			continue
+		}
+		buf.autosavedRevision = buf.revision
+	}
+}
+
+func writeRecoveryFile(buf *Buffer) error {
+	var chart bytes.Buffer
+	if err := buf.canvas.WriteJSON(&chart, &point{X: buf.panX, Y: buf.panY}); err != nil {
+		return err
+	}
+	undo, err := encodeActions(buf.undoStack)
+	if err != nil {
+		return err
+	}
+	redo, err := encodeActions(buf.redoStack)
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	rec := recoveryFile{
+		Filename:  buf.filename,
+		Undo:      undo,
+		Redo:      redo,
+		SavedUndo: buf.savedUndo,
+		PID:       os.Getpid(),
+		Host:      host,
+		Saved:     time.Now(),
+		Chart:     chart.Bytes(),
+	}
+	return cv.WriteFileAtomic(buf.recoveryPath, 0, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(rec)
+	})
+}
+
+// discardRecovery removes the buffer's recovery file once its changes are
+// safely saved.
+func discardRecovery(buf *Buffer) {
+	if buf.recoveryPath != "" {
+		os.Remove(buf.recoveryPath)
+		buf.recoveryPath = ""
+	}
+	buf.autosavedRevision = buf.revision
+}
+
+func readRecoveryFile(path string) (recoveryFile, error) {
+	var rec recoveryFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rec, err
+	}
+	err = json.Unmarshal(data, &rec)
+	return rec, err
+}
+
+// scanRecovery lists the recovery files in dir, oldest first. Files that
+// can't be read are skipped rather than offered, and so are the files of
+// sessions that are still running.
+func scanRecovery(dir string) []recoveredChart {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var charts []recoveredChart
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		rec, err := readRecoveryFile(path)
+		if err != nil || rec.ownedElsewhere() {
+			continue
+		}
+		res, err := cv.Decode(path, rec.Chart, cv.LoadOptions{Salvage: true})
+		if err != nil {
+			continue
+		}
+		rc := recoveredChart{
+			path:      path,
+			filename:  rec.Filename,
+			savedUndo: rec.SavedUndo,
+			saved:     rec.Saved,
+			canvas:    res.Canvas,
+			pan:       res.Pan,
+		}
+		undo, errUndo := decodeActions(rec.Undo)
+		redo, errRedo := decodeActions(rec.Redo)
+		if errUndo != nil || errRedo != nil {
+			rc.noHistory, rc.savedUndo = true, -1
+		} else {
+			rc.undo, rc.redo = undo, redo
+		}
+		charts = append(charts, rc)
+	}
+	sort.SliceStable(charts, func(i, j int) bool { return charts[i].saved.Before(charts[j].saved) })
+	return charts
+}
+
+// restoreRecovered opens every recovered chart in its own buffer, with its
+// undo history, in place of the empty chart on the start menu or after the
+// charts named on the command line. Each keeps its recovery file until it is
+// saved, claimed at once so no other session offers it meanwhile.
+func (m *model) restoreRecovered() {
+	first := len(m.buffers)
+	if m.mode == ModeStartup {
+		m.buffers, first = nil, 0
+	}
+	lost := 0
+	for _, rc := range m.recovered {
+		buf := Buffer{
+			canvas:       rc.canvas,
+			undoStack:    rc.undo,
+			redoStack:    rc.redo,
+			filename:     rc.filename,
+			savedUndo:    rc.savedUndo,
+			recoveryPath: rc.path,
+		}
+		if buf.undoStack == nil {
+			buf.undoStack = []Action{}
+		}
+		if buf.redoStack == nil {
+			buf.redoStack = []Action{}
+		}
+		if rc.pan != nil {
+			buf.panX, buf.panY = rc.pan.X, rc.pan.Y
+		}
+		if rc.noHistory {
+			lost++
+		}
+		m.buffers = append(m.buffers, buf)
+	}
+	n := len(m.recovered)
+	m.currentBufferIndex = first
+	m.recovered = nil
+	m.mode = ModeNormal
+	m.cursorX, m.cursorY = 0, 0
+	m.errorMessage = ""
+	if n == 1 {
+		m.successMessage = "Restored 1 unsaved chart"
+	} else {
+		m.successMessage = fmt.Sprintf("Restored %d unsaved charts", n)
+	}
+	if lost > 0 {
+		m.successMessage += fmt.Sprintf("; the undo history of %d couldn't be read", lost)
+	}
+	for i := first; i < len(m.buffers); i++ {
+		if err := writeRecoveryFile(&m.buffers[i]); err != nil {
+			m.errorMessage = fmt.Sprintf("Autosave failed: %s", err)
+		}
+	}
+}
+
+// discardRecovered deletes the recovery files left by an earlier session,
+// except any another session has restored since they were listed.
+func (m *model) discardRecovered() {
+	for _, rc := range m.recovered {
+		if rec, err := readRecoveryFile(rc.path); err == nil && rec.ownedElsewhere() {
+			continue
+		}
+		os.Remove(rc.path)
+	}
+	m.recovered = nil
+	m.successMessage = ""
+}
+
+// restorePrompt asks about the recovered charts when charts named on the
+// command line skip the start menu.
+func (m model) restorePrompt() string {
+	what := "1 unsaved chart"
+	if len(m.recovered) != 1 {
+		what = fmt.Sprintf("%d unsaved charts", len(m.recovered))
+	}
+	return fmt.Sprintf("Restore %s from an earlier session? y=restore, d=discard, n=ask again next time", what)
+}
+
+// handleRestoreKey answers restorePrompt. Keeping the charts for later
+// leaves their recovery files for the next session to offer.
+func (m model) handleRestoreKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y", "r", "R":
+		m.restoreRecovered()
+	case "d", "D":
+		m.discardRecovered()
+		m.mode = ModeNormal
+	case "n", "N", "esc", "escape":
+		m.recovered = nil
+		m.mode = ModeNormal
+	}
+	return m, nil
+}
+
+// recoveredLabel describes the recovered charts for the start menu.
+func (m model) recoveredLabel() string {
+	if len(m.recovered) == 1 {
+		name := m.recovered[0].filename
+		if name == "" {
+			name = "untitled chart"
+		}
+		label := fmt.Sprintf("  r: Restore %s", filepath.Base(name))
+		if n := len(m.recovered[0].undo); n > 0 {
+			label += fmt.Sprintf(" (%d changes)", n)
+		}
+		return label
+	}
+	return fmt.Sprintf("  r: Restore %d unsaved charts", len(m.recovered))
+}
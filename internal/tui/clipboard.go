@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	cv "flerm/internal/canvas"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The system clipboard is reached through these so tests don't touch the
+// real one.
+var (
+	readSystemClipboard  = readClipboardText
+	writeSystemClipboard = writeClipboardText
+)
+
+// clipboardReadMsg carries the system clipboard, read in the background.
+// mode is the mode it was read for: a paste at (x, y) in normal mode, or
+// text for the label being edited.
+type clipboardReadMsg struct {
+	text string
+	err  error
+	mode Mode
+	x, y int
+}
+
+// readClipboard reads the system clipboard in the background, since the
+// clipboard tools can be slow or hang, for a paste in the current mode.
+func (m *model) readClipboard(x, y int) tea.Cmd {
+	mode := m.mode
+	return func() tea.Msg {
+		text, err := readSystemClipboard()
+		return clipboardReadMsg{text: text, err: err, mode: mode, x: x, y: y}
+	}
+}
+
+// receiveClipboard uses the clipboard read by readClipboard, unless the user
+// has left the mode it was read for in the meantime.
+func (m *model) receiveClipboard(msg clipboardReadMsg) {
+	if msg.mode != m.mode {
+		return
+	}
+	switch m.mode {
+	case ModeNormal:
+		m.pasteSystemClipboard(msg)
+	case ModeLabelEdit:
+		if msg.err == nil && msg.text != "" {
+			m.insertLabelText(msg.text)
+		}
+	}
+}
+
+// overSSH reports whether flerm runs on a remote host, where the system
+// clipboard isn't the user's.
+func overSSH() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// writeClipboardText puts text on the system clipboard. Over SSH, or when no
+// clipboard tool is available, it asks the terminal to do it with OSC 52.
+func writeClipboardText(text string) error {
+	if !overSSH() {
+		if err := clipboard.WriteAll(text); err == nil {
+			return nil
+		}
+	}
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	_, err := seq.WriteTo(os.Stdout)
+	return err
+}
+
+// exportClip writes clip to the system clipboard as a fragment, so it can be
+// pasted into other programs as text and into any flerm session as objects.
+// It runs in the background since the clipboard tools can be slow.
+func exportClip(clip *Canvas) tea.Cmd {
+	text, err := clip.EncodeFragment()
+	if err != nil {
+		return nil
+	}
+	return func() tea.Msg {
+		writeSystemClipboard(text)
+		return nil
+	}
+}
+
+// objectAtCursor finds the box, text or line under the cursor, in that
+// order, and returns it as selection indices for Extract and Cut.
+func (m *model) objectAtCursor() (boxes, texts, conns []int) {
+	panX, panY := m.getPanOffset()
+	worldX, worldY := m.cursorX+panX, m.cursorY+panY
+	c := m.getCanvas()
+	if i := c.GetBoxAt(worldX, worldY); i != -1 {
+		return []int{i}, nil, nil
+	}
+	if i := c.GetTextAt(worldX, worldY); i != -1 {
+		return nil, []int{i}, nil
+	}
+	if i, _, _ := c.FindNearestPointOnConnection(worldX, worldY); i != -1 {
+		return nil, nil, []int{i}
+	}
+	return nil, nil, nil
+}
+
+// takeSelection returns the indices of what the multi-select picked, with
+// highlight cells where they are now, and ends the move so any dragging done
+// so far is recorded first.
+func (m *model) takeSelection() (boxes, texts, conns []int, cells []point) {
+	c := m.getCanvas()
+	for _, id := range m.selectedBoxes {
+		if i := c.BoxIndex(id); i >= 0 {
+			boxes = append(boxes, i)
+		}
+	}
+	for _, id := range m.selectedTexts {
+		if i := c.TextIndex(id); i >= 0 {
+			texts = append(texts, i)
+		}
+	}
+	for _, id := range m.selectedConnections {
+		if i := c.ConnectionIndex(id); i >= 0 {
+			conns = append(conns, i)
+		}
+	}
+	for p := range m.originalHighlights {
+		cells = append(cells, point{X: p.X + m.highlightMoveDelta.X, Y: p.Y + m.highlightMoveDelta.Y})
+	}
+	m.commitMove()
+	m.originalHighlights = make(map[point]int)
+	return boxes, texts, conns, cells
+}
+
+// copyObjects puts a copy of the given objects on the clipboard.
+func (m *model) copyObjects(boxes, texts, conns []int, cells []point) tea.Cmd {
+	clip := m.getCanvas().Extract(boxes, texts, conns, cells)
+	if clip.Empty() {
+		return nil
+	}
+	m.clipboard = clip
+	m.successMessage = fmt.Sprintf("Copied %s", describeClip(m.clipboard))
+	m.errorMessage = ""
+	return exportClip(clip)
+}
+
+// cutObjects copies the given objects to the clipboard and removes them as
+// one undoable step.
+func (m *model) cutObjects(boxes, texts, conns []int, cells []point) tea.Cmd {
+	c := m.getCanvas()
+	clip := c.Extract(boxes, texts, conns, cells)
+	group := c.Cut(boxes, texts, conns, cells)
+	if group.Empty() {
+		return nil
+	}
+	m.recordAction(ActionCut, group, group)
+	m.selBox, m.selText, m.selConn = -1, -1, -1
+	m.successMessage = fmt.Sprintf("Cut %s", describeClip(clip))
+	m.errorMessage = ""
+	m.ensureCursorInBounds()
+	if clip.Empty() {
+		return nil
+	}
+	m.clipboard = clip
+	return exportClip(clip)
+}
+
+// pasteClipboard pastes at the cursor. The system clipboard is read in the
+// background and the paste happens when its clipboardReadMsg arrives. Over
+// SSH the system clipboard belongs to the remote host, so the editor's own
+// clipboard is pasted right away; a fragment from the user's own clipboard
+// comes in as a terminal paste.
+func (m *model) pasteClipboard() tea.Cmd {
+	panX, panY := m.getPanOffset()
+	x, y := m.cursorX+panX, m.cursorY+panY
+	if overSSH() {
+		m.pasteAt(m.clipboard, x, y)
+		return nil
+	}
+	return m.readClipboard(x, y)
+}
+
+// pasteSystemClipboard pastes what was read from the system clipboard. A
+// fragment there, copied in this session or another, wins over the
+// editor's own clipboard, which is used when the system one can't be read
+// or holds something else.
+func (m *model) pasteSystemClipboard(msg clipboardReadMsg) {
+	clip := m.clipboard
+	if msg.err == nil {
+		switch fragment, err := cv.DecodeFragment(msg.text); {
+		case err == nil:
+			clip = fragment
+		case !errors.Is(err, cv.ErrNoFragment):
+			m.errorMessage = fmt.Sprintf("Can't paste the clipboard: %s", err)
+			return
+		}
+	}
+	m.pasteAt(clip, msg.x, msg.y)
+}
+
+// pasteText inserts a fragment pasted into the terminal, from a chat or
+// another session's copy, at the cursor.
+func (m *model) pasteText(text string) {
+	fragment, err := cv.DecodeFragment(text)
+	switch {
+	case errors.Is(err, cv.ErrNoFragment):
+		m.errorMessage = "Nothing to paste: that isn't a flerm fragment"
+		return
+	case err != nil:
+		m.errorMessage = fmt.Sprintf("Can't paste: %s", err)
+		return
+	}
+	panX, panY := m.getPanOffset()
+	m.pasteAt(fragment, m.cursorX+panX, m.cursorY+panY)
+}
+
+// pasteAt inserts clip with its top-left corner at (x, y) as one undoable
+// step.
+func (m *model) pasteAt(clip *Canvas, x, y int) {
+	if clip == nil {
+		return
+	}
+	group := m.getCanvas().Paste(clip, x, y)
+	if group.Empty() {
+		return
+	}
+	m.recordAction(ActionPaste, group, group)
+	if len(group.Connections) > 0 {
+		m.relayoutTree()
+	}
+	m.errorMessage = ""
+	m.ensureCursorInBounds()
+}
+
+// describeClip counts what is on the clipboard for the status line.
+func describeClip(clip *Canvas) string {
+	n := len(clip.Boxes()) + len(clip.Texts()) + len(clip.Connections())
+	switch {
+	case n == 0:
+		return "highlights"
+	case n == 1:
+		return "1 object"
+	}
+	return fmt.Sprintf("%d objects", n)
+}
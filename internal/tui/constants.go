@@ -15,6 +15,8 @@ const (
 	ModeBoxJump
 	ModeTitleEdit
 	ModeContextMenu
+	ModeLint
+	ModeLabelEdit
 )
 
 type MenuAction int
@@ -30,6 +32,9 @@ const (
 	MenuDeleteLine
 	MenuEditTitle
 	MenuSetBorderStyle
+	MenuSetShape
+	MenuEditLabel
+	MenuSetLabelSegment
 	MenuSetColor
 	MenuSubmenu
 )
@@ -38,8 +43,7 @@ type FileOperation int
 
 const (
 	FileOpSave FileOperation = iota
-	FileOpSavePNG
-	FileOpSaveVisualTXT
+	FileOpExport
 	FileOpOpen
 )
 
@@ -55,6 +59,10 @@ const (
 	ConfirmCloseBuffer
 	ConfirmOverwriteFile
 	ConfirmChooseExportType
+	ConfirmSalvageLoad
+	ConfirmOpenOverCurrent
+	ConfirmExternalChange
+	ConfirmRestoreRecovered
 )
 
 type ActionType int
@@ -75,4 +83,9 @@ const (
 	ActionChangeBorderStyle
 	ActionEditTitle
 	ActionSetColor
+	ActionPaste
+	ActionCut
+	ActionArrange
+	ActionChangeShape
+	ActionEditLabel
 )
@@ -2,8 +2,40 @@ package tui
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
+// Edit cursors count characters rather than bytes, so that they never land
+// inside a multi-byte character. These helpers translate.
+
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// byteOffset is where character pos starts in s, or len(s) past the end.
+func byteOffset(s string, pos int) int {
+	if pos <= 0 {
+		return 0
+	}
+	n := 0
+	for i := range s {
+		if n == pos {
+			return i
+		}
+		n++
+	}
+	return len(s)
+}
+
+func insertAt(s string, pos int, insert string) string {
+	i := byteOffset(s, pos)
+	return s[:i] + insert + s[i:]
+}
+
+func deleteRange(s string, start, end int) string {
+	return s[:byteOffset(s, start)] + s[byteOffset(s, end):]
+}
+
 func (m *model) linearToCursorPos(pos int, text string) (row, col int) {
 	lines := strings.Split(text, "\n")
 	currentPos := 0
@@ -76,31 +108,21 @@ func (m *model) deleteEditSelection() bool {
 		return false
 	}
 	start, end := m.getEditSelectionBounds()
-	m.editText = m.editText[:start] + m.editText[end:]
+	m.editText = deleteRange(m.editText, start, end)
 	m.editCursorPos = start
 	m.clearEditSelection()
 	return true
 }
 
 func (m *model) getLineStartPos() int {
-	if m.editCursorPos == 0 {
-		return 0
-	}
-
-	for i := m.editCursorPos - 1; i >= 0; i-- {
-		if m.editText[i] == '\n' {
-			return i + 1
-		}
-	}
-	return 0
+	before := m.editText[:byteOffset(m.editText, m.editCursorPos)]
+	return m.editCursorPos - runeLen(before[strings.LastIndex(before, "\n")+1:])
 }
 
 func (m *model) getLineEndPos() int {
-
-	for i := m.editCursorPos; i < len(m.editText); i++ {
-		if m.editText[i] == '\n' {
-			return i
-		}
+	after := m.editText[byteOffset(m.editText, m.editCursorPos):]
+	if i := strings.Index(after, "\n"); i >= 0 {
+		after = after[:i]
 	}
-	return len(m.editText)
+	return m.editCursorPos + runeLen(after)
 }
@@ -2,46 +2,90 @@ package tui
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
 	"strings"
+
+	cv "flerm/internal/canvas"
 )
 
-func (m *model) exportVisualTXT(filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+type exportChoice struct {
+	key    string
+	format string
+	label  string
+}
 
-	canvas := m.getCanvas()
-	if canvas == nil {
-		return fmt.Errorf("no canvas available")
-	}
+var exportChoices = []exportChoice{
+	{key: "p", format: "png", label: "PNG"},
+	{key: "s", format: "svg", label: "SVG"},
+	{key: "t", format: "txt", label: "Visual TXT"},
+	{key: "a", format: "ansi", label: "ANSI TXT"},
+	{key: "h", format: "html", label: "HTML"},
+	{key: "m", format: "mermaid", label: "Mermaid"},
+	{key: "d", format: "dot", label: "DOT"},
+	{key: "u", format: "plantuml", label: "PlantUML"},
+}
 
-	minX, minY, maxX, maxY := canvas.GetFullBounds()
-	if minX > maxX || minY > maxY {
-		return fmt.Errorf("nothing to export")
+func lookupExportChoice(key string) (exportChoice, bool) {
+	key = strings.ToLower(key)
+	for _, choice := range exportChoices {
+		if choice.key == key {
+			return choice, true
+		}
 	}
+	return exportChoice{}, false
+}
 
-	padding := 1
-	minX -= padding
-	minY -= padding
-	if minX < 0 {
-		minX = 0
+func exportPrompt() string {
+	parts := make([]string, len(exportChoices))
+	for i, choice := range exportChoices {
+		parts[i] = fmt.Sprintf("%s (%s)", choice.label, choice.key)
 	}
-	if minY < 0 {
-		minY = 0
+	last := len(parts) - 1
+	if last > 0 {
+		return fmt.Sprintf("Export as %s or %s? Press Esc to cancel", strings.Join(parts[:last], ", "), parts[last])
 	}
+	return fmt.Sprintf("Export as %s? Press Esc to cancel", parts[0])
+}
 
-	width := maxX - minX + padding + 1
-	height := maxY - minY + padding + 1
+func (m *model) beginExport(choice exportChoice) {
+	m.mode = ModeFileInput
+	m.fileOp = FileOpExport
+	m.exportChoice = choice
 
-	renderResult := canvas.RenderRaw(width, height, -1, -1, -1, nil, -1, -1, minX, minY, -1, -1, false, -1, -1, 0, "", -1, -1, -1, -1, -1, -1, false, -1, -1)
+	buf := m.getCurrentBuffer()
+	if buf != nil && buf.filename != "" {
+		baseName := filepath.Base(buf.filename)
+		if strings.HasSuffix(strings.ToLower(baseName), ".sav") {
+			baseName = baseName[:len(baseName)-4]
+		}
+		m.filename = baseName
+	} else {
+		m.filename = "flowchart"
+	}
+	m.errorMessage = ""
+	m.successMessage = ""
+	m.fromStartup = false
+}
 
-	for _, row := range renderResult.Canvas {
-		line := strings.TrimRight(string(row), " ")
-		fmt.Fprintln(file, line)
+func (m *model) finishExport(filename string) error {
+	format, ok := cv.LookupExportFormat(m.exportChoice.format)
+	if !ok {
+		return fmt.Errorf("unknown export format %q", m.exportChoice.format)
 	}
 
+	baseFilename := filepath.Base(filename)
+	if !strings.HasSuffix(strings.ToLower(baseFilename), format.Extension) {
+		baseFilename += format.Extension
+	}
+	savePath := baseFilename
+	if m.config != nil {
+		savePath = m.config.GetSavePath(baseFilename)
+	}
+	if err := m.getCanvas().Export(savePath, format); err != nil {
+		return err
+	}
+	absPath, _ := filepath.Abs(savePath)
+	m.successMessage = fmt.Sprintf("Exported to %s", absPath)
+	m.errorMessage = ""
 	return nil
 }
@@ -16,6 +16,9 @@ var helpText = []string{
 	"  Left drag        Drag a box to move it; connected lines re-route automatically",
 	"  Right click      Open a context menu (New Box, New Text, Edit/Delete,",
 	"                   and New Line when clicking a box)",
+	"  Border > Shape   Make a box a decision, start/end, input/output,",
+	"                   database or document (Process for a plain box)",
+	"  Label            Edit a line's label, or move it to the clicked segment",
 	"  New Line         After choosing it from a box menu, the line follows the",
 	"                   mouse; left-click a box or line to connect, empty space to add a bend",
 	"  Scroll wheel     Pan the canvas",
@@ -29,12 +32,17 @@ var helpText = []string{
 	"  r                Resize box under cursor",
 	"  m                Move box under cursor",
 	"  d                Delete box under cursor",
-	"  c                Copy box under cursor",
-	"  p                Paste copied box at cursor position",
+	"  c                Copy box, text or line under cursor",
+	"  X (Shift+x)      Cut box, text or line under cursor",
+	"  p                Paste copied objects at cursor (also from other sessions)",
+	"                   - A fragment pasted into the terminal is inserted too",
 	"  Z (Shift+z)      Cycle box z-level (0-3) for drop shadow effect",
 	"  Tab              Cycle border style (ASCII, Single, Double, Rounded)",
 	"  B (Shift+b)      Box jump - quickly jump to any box by number",
 	"  M (Shift+m)      Enter multi-select mode to select multiple boxes",
+	"  g / G            Arrange the whole chart top-to-bottom / left-to-right",
+	"  y / Y            Arrange the tree grown from the box under cursor,",
+	"                   top-to-bottom / left-to-right",
 	"",
 	"Text Operations:",
 	"----------------",
@@ -54,6 +62,9 @@ var helpText = []string{
 	"----------",
 	"  h/←/j/↓/k/↑/l/→  Move object around the screen",
 	"  Shift+h/j/k/l    Move object 2x faster",
+	"  c                Copy the multi-selection, with its lines and highlights",
+	"  x                Cut the multi-selection",
+	"  g / G            Arrange the selected boxes top-to-bottom / left-to-right",
 	"  Enter            Finish moving and return to normal mode",
 	"  Escape           Cancel move and return to normal mode",
 	"",
@@ -71,6 +82,7 @@ var helpText = []string{
 	"  A                Toggle arrow state on connection line under cursor",
 	"                   - Cycles through: no arrows → to arrow → from arrow → both arrows",
 	"                   Note: Sometimes the arrows flip around. Redrawing the line fixes it.",
+	"  e                Edit the label of the line under cursor (Enter=save, Esc=cancel)",
 	"",
 	"Highlight Mode:",
 	"---------------",
@@ -87,7 +99,7 @@ var helpText = []string{
 	"File Operations:",
 	"----------------",
 	"  s                Save flowchart",
-	"  S                Export as PNG image (experimental and janky)",
+	"  S                Export as PNG, SVG, TXT, ANSI, HTML, Mermaid, DOT or PlantUML",
 	"  o                Load a saved flowchart in current buffer",
 	"  O                Load a saved flowchart in new buffer",
 	"",
@@ -104,6 +116,7 @@ var helpText = []string{
 	"  u                Undo last action",
 	"  U                Redo last undone action",
 	"  z                Toggle pan mode (scroll canvas instead of moving cursor)",
+	"  v                Check the chart for problems; j/k step through them",
 	"  Esc           	Clear selection/cancel current operation",
 	"  ?                Toggle this help screen",
 	"  q/Ctrl+C         Quit Flerm",
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cv "flerm/internal/canvas"
+)
+
+// importedFilename is where an imported chart gets saved: next to the source
+// with a .sav extension, so saving never overwrites the Mermaid or DOT file.
+func importedFilename(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".sav"
+}
+
+// importFile parses a Mermaid or DOT file and opens the laid-out chart in a
+// new buffer. From the startup menu it replaces the empty initial buffer.
+func (m *model) importFile(filename string, format cv.ImportFormat) error {
+	loadPath := filename
+	if m.config != nil && m.config.SaveDirectory != "" {
+		saveDirPath := m.config.GetSavePath(filename)
+		if _, err := os.Stat(saveDirPath); err == nil {
+			loadPath = saveDirPath
+		}
+	}
+	if _, err := os.Stat(loadPath); os.IsNotExist(err) {
+		return fmt.Errorf("file not found: %s", filename)
+	}
+	canvas, err := cv.ImportFile(loadPath, format)
+	if err != nil {
+		return err
+	}
+
+	if m.fromStartup && len(m.buffers) > 0 {
+		m.buffers[0] = Buffer{
+			canvas:    canvas,
+			undoStack: []Action{},
+			redoStack: []Action{},
+			filename:  importedFilename(loadPath),
+		}
+		m.currentBufferIndex = 0
+		m.fromStartup = false
+	} else {
+		m.addNewBuffer(canvas, importedFilename(loadPath))
+		m.openInNewBuffer = false
+	}
+	absPath, _ := filepath.Abs(loadPath)
+	m.successMessage = fmt.Sprintf("Imported %s", absPath)
+	m.errorMessage = ""
+	return nil
+}
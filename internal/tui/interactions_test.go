@@ -1,7 +1,13 @@
 package tui
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	cv "flerm/internal/canvas"
 
@@ -174,6 +180,33 @@ func TestMultiSelectDragSelectsBoxes(t *testing.T) {
 	}
 }
 
+func TestMultiSelectKeepsIDsAfterDelete(t *testing.T) {
+	m := newTestModel()
+	c := m.getCanvas()
+	c.DeleteBox(0) // Beta is now at index 0 but keeps ID 1
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("M")})
+	m = out.(model)
+	out, _ = m.Update(press(tea.MouseButtonLeft, 38, 18))
+	m = out.(model)
+	out, _ = m.Update(release(60, 30))
+	m = out.(model)
+	if len(m.selectedBoxes) != 1 || m.selectedBoxes[0] != 1 {
+		t.Fatalf("expected Beta selected by its ID 1, got %v", m.selectedBoxes)
+	}
+
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = out.(model)
+	if x := c.Boxes()[0].X; x != 41 {
+		t.Fatalf("expected Beta moved to x=41, got %d", x)
+	}
+	m.undo()
+	if x := c.Boxes()[0].X; x != 40 {
+		t.Fatalf("expected undo to put Beta back at x=40, got %d", x)
+	}
+}
+
 func TestGroupDragMovesAndHighlights(t *testing.T) {
 	m := newTestModel() // box0 @ (5,3), box1 @ (40,20)
 	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("M")})
@@ -295,3 +328,900 @@ func TestHighlightPaintDrag(t *testing.T) {
 		t.Fatal("expected undo to clear the painted stroke")
 	}
 }
+
+func TestOpenFilesGivesEachItsOwnBuffer(t *testing.T) {
+	dir := t.TempDir()
+	c := cv.NewCanvas()
+	c.AddBox(3, 4, "Saved")
+	saved := filepath.Join(dir, "saved.sav")
+	if err := c.SaveToFileWithPan(saved, 7, 2); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "new.sav")
+
+	m := initialModel()
+	m.mode = ModeStartup
+	if err := m.openFiles([]string{saved, missing}); err != nil {
+		t.Fatalf("openFiles: %v", err)
+	}
+	if m.mode != ModeNormal {
+		t.Fatalf("expected startup menu skipped, got mode %v", m.mode)
+	}
+	if len(m.buffers) != 2 || m.currentBufferIndex != 0 {
+		t.Fatalf("expected 2 buffers with the first active, got %d (current %d)", len(m.buffers), m.currentBufferIndex)
+	}
+	first := m.buffers[0]
+	if first.filename != saved || first.panX != 7 || first.panY != 2 || len(first.canvas.Boxes()) != 1 {
+		t.Fatalf("first buffer not loaded: file=%q pan=(%d,%d) boxes=%d", first.filename, first.panX, first.panY, len(first.canvas.Boxes()))
+	}
+	if m.buffers[1].filename != missing || len(m.buffers[1].canvas.Boxes()) != 0 {
+		t.Fatal("expected a missing file to open as an empty buffer with that name")
+	}
+}
+
+func TestExportPromptWritesChosenFormat(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestModel()
+	m.config = &Config{SaveDirectory: dir}
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("S")})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = out.(model)
+	if m.mode != ModeFileInput || m.fileOp != FileOpExport || m.exportChoice.format != "svg" {
+		t.Fatalf("expected SVG filename prompt, got mode %v op %v format %q", m.mode, m.fileOp, m.exportChoice.format)
+	}
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = out.(model)
+	if m.errorMessage != "" {
+		t.Fatalf("export failed: %s", m.errorMessage)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "flowchart.svg"))
+	if err != nil {
+		t.Fatalf("expected flowchart.svg to be written: %v", err)
+	}
+	if !strings.Contains(string(data), "<svg") || !strings.Contains(string(data), ">Alpha</text>") {
+		t.Fatalf("unexpected SVG output:\n%s", data)
+	}
+}
+
+func TestOpenImportsMermaidIntoNewBuffer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "flow.mmd"), []byte("graph TD\n  a[Start] --> b[End]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel()
+	m.config = &Config{SaveDirectory: dir}
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("O")})
+	m = out.(model)
+	if len(m.fileList) != 1 || m.fileList[0] != "flow.mmd" {
+		t.Fatalf("expected the Mermaid file in the open list, got %v", m.fileList)
+	}
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = out.(model)
+	if m.errorMessage != "" {
+		t.Fatalf("import failed: %s", m.errorMessage)
+	}
+	if len(m.buffers) != 2 || m.currentBufferIndex != 1 {
+		t.Fatalf("expected the import in a new buffer, got %d buffers (current %d)", len(m.buffers), m.currentBufferIndex)
+	}
+	buf := m.buffers[1]
+	if buf.filename != filepath.Join(dir, "flow.sav") {
+		t.Errorf("expected the buffer to save as flow.sav, got %q", buf.filename)
+	}
+	if len(buf.canvas.Boxes()) != 2 || len(buf.canvas.Connections()) != 1 {
+		t.Errorf("expected 2 boxes and 1 connection, got %d and %d", len(buf.canvas.Boxes()), len(buf.canvas.Connections()))
+	}
+}
+
+func TestOpenDamagedChartOffersSalvage(t *testing.T) {
+	dir := t.TempDir()
+	damaged := "FLOWCHART\nBOXES:2\n0,0,10,3,0,0,,Kept\n0,oops,10,3,0,0,,Lost\nCONNECTIONS:0\n"
+	if err := os.WriteFile(filepath.Join(dir, "damaged.sav"), []byte(damaged), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel()
+	m.config = &Config{SaveDirectory: dir}
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = out.(model)
+	if m.mode != ModeConfirm || m.confirmAction != ConfirmSalvageLoad {
+		t.Fatalf("expected the salvage prompt, got mode %v (error %q)", m.mode, m.errorMessage)
+	}
+	if !strings.Contains(m.salvageError, "damaged.sav:4:") {
+		t.Errorf("expected a file:line diagnostic, got %q", m.salvageError)
+	}
+	if got := m.getCanvas().Boxes(); len(got) != 2 || got[0].GetText() != "Alpha" {
+		t.Fatalf("the failed open changed the buffer: %+v", got)
+	}
+
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = out.(model)
+	if got := m.getCanvas().Boxes(); len(got) != 1 || got[0].GetText() != "Kept" {
+		t.Fatalf("expected the salvaged box, got %+v", got)
+	}
+	if !strings.Contains(m.successMessage, "warning") {
+		t.Errorf("expected the skipped line to be reported, got %q", m.successMessage)
+	}
+}
+
+func TestLintPanelStepsThroughProblems(t *testing.T) {
+	m := newTestModel()
+	m.getCanvas().AddBox(42, 21, "Over Beta")
+	m.getCanvas().SetHighlight(200, 100, 1)
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	m = out.(model)
+	if m.mode != ModeLint || len(m.lintIssues) != 2 {
+		t.Fatalf("expected the lint panel with 2 problems, got mode %v and %+v", m.mode, m.lintIssues)
+	}
+	if m.cursorX != 42 || m.cursorY != 21 {
+		t.Errorf("expected the cursor on the overlap at (42,21), got (%d,%d)", m.cursorX, m.cursorY)
+	}
+	if view := m.View(); !strings.Contains(view, "boxes 1 and 2 overlap") {
+		t.Errorf("expected the panel to list the overlap:\n%s", view)
+	}
+
+	// The stray highlight is off screen, so jumping to it pans.
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	m = out.(model)
+	panX, panY := m.getPanOffset()
+	if m.cursorX+panX != 200 || m.cursorY+panY != 100 {
+		t.Errorf("expected the cursor on (200,100), got (%d,%d) panned by (%d,%d)", m.cursorX, m.cursorY, panX, panY)
+	}
+
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	m = out.(model)
+	if m.mode != ModeNormal {
+		t.Errorf("expected Esc to close the panel, got mode %v", m.mode)
+	}
+}
+
+func TestUndoFollowsIDsAcrossDeletes(t *testing.T) {
+	m := newTestModel()
+	m.getCanvas().AddBox(70, 2, "Gamma")
+
+	out, _ := m.Update(press(tea.MouseButtonLeft, 71, 3))
+	m = out.(model)
+	out, _ = m.Update(dragMotion(71, 10))
+	m = out.(model)
+	out, _ = m.Update(release(71, 10))
+	m = out.(model)
+	gamma := m.getCanvas().Boxes()[2].ID
+
+	// Deleting Alpha shifts Gamma down to index 1; the selection and the
+	// move record still name Gamma.
+	m.deleteBoxByID(0)
+	c := m.getCanvas()
+	if m.selBox != gamma || c.BoxIndex(m.selBox) != 1 {
+		t.Fatalf("expected Gamma to stay selected, got selBox=%d", m.selBox)
+	}
+
+	m.undo()
+	m.undo()
+	c = m.getCanvas()
+	if len(c.Boxes()) != 3 || c.Boxes()[0].GetText() != "Alpha" {
+		t.Fatalf("expected Alpha restored first, got %+v", c.Boxes())
+	}
+	if c.Boxes()[1].Y != 20 || c.Boxes()[2].Y != 2 {
+		t.Fatalf("expected only Gamma moved back, got Beta.Y=%d Gamma.Y=%d", c.Boxes()[1].Y, c.Boxes()[2].Y)
+	}
+
+	m.redo()
+	m.redo()
+	c = m.getCanvas()
+	if len(c.Boxes()) != 2 || c.Boxes()[1].ID != gamma || c.Boxes()[1].Y != 9 {
+		t.Fatalf("expected redo to move Gamma and delete Alpha, got %+v", c.Boxes())
+	}
+}
+
+func TestAutosaveRecoversUnsavedChart(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestModel()
+	m.config = &Config{RecoveryDirectory: dir, Autosave: 30}
+
+	out, _ := m.Update(autosaveMsg{})
+	m = out.(model)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("autosaved an unchanged chart: %v", entries)
+	}
+
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	m = out.(model)
+	out, _ = m.Update(autosaveMsg{})
+	m = out.(model)
+	recovered := scanRecovery(dir)
+	if len(recovered) != 1 || len(recovered[0].canvas.Boxes()) != 3 || len(recovered[0].undo) != 1 {
+		t.Fatalf("expected one recovered chart with three boxes, got %+v", recovered)
+	}
+
+	restored := newTestModel()
+	restored.config = m.config
+	restored.recovered = recovered
+	restored.mode = ModeStartup
+	out, _ = restored.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	restored = out.(model)
+	if restored.mode != ModeNormal || len(restored.buffers) != 1 || len(restored.getCanvas().Boxes()) != 3 {
+		t.Fatalf("expected the recovered chart to open, got mode %v and %d buffers", restored.mode, len(restored.buffers))
+	}
+
+	// The undo history and the saved point come back with it.
+	buf := restored.getCurrentBuffer()
+	if !buf.modified() {
+		t.Fatal("expected the restored chart to differ from its saved state")
+	}
+	restored.undo()
+	if len(restored.getCanvas().Boxes()) != 2 || buf.modified() {
+		t.Fatalf("expected undo to reach the saved state, got %d boxes, modified %v", len(restored.getCanvas().Boxes()), buf.modified())
+	}
+	restored.redo()
+
+	if err := restored.saveChart(filepath.Join(t.TempDir(), "chart.sav")); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanRecovery(dir); len(got) != 0 {
+		t.Errorf("saving left the recovery file behind: %+v", got)
+	}
+}
+
+func TestOpenFilesOffersRecoveredCharts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", home)
+
+	crashed := initialModel()
+	crashed.mode = ModeNormal
+	out, _ := crashed.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	crashed = out.(model)
+	crashed.autosave()
+
+	c := cv.NewCanvas()
+	c.AddBox(3, 4, "Saved")
+	file := filepath.Join(t.TempDir(), "saved.sav")
+	if err := c.SaveToFile(file); err != nil {
+		t.Fatal(err)
+	}
+	open := func() model {
+		m := initialModel()
+		if err := m.openFiles([]string{file}); err != nil {
+			t.Fatalf("openFiles: %v", err)
+		}
+		if m.mode != ModeConfirm || m.confirmAction != ConfirmRestoreRecovered {
+			t.Fatalf("expected to be asked about the recovered chart, got mode %v", m.mode)
+		}
+		return m
+	}
+	key := func(m model, k string) model {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		return out.(model)
+	}
+
+	// n keeps the recovery file for the next session.
+	m := key(open(), "n")
+	if m.mode != ModeNormal || len(m.buffers) != 1 {
+		t.Fatalf("expected only the file open, got mode %v and %d buffers", m.mode, len(m.buffers))
+	}
+
+	m = key(open(), "y")
+	if len(m.buffers) != 2 || m.currentBufferIndex != 1 || m.buffers[0].filename != file {
+		t.Fatalf("expected the recovered chart opened after the file, got %d buffers, current %d", len(m.buffers), m.currentBufferIndex)
+	}
+	if got := m.getCurrentBuffer(); len(got.canvas.Boxes()) != 1 || len(got.undoStack) != 1 {
+		t.Errorf("expected the recovered box and its undo step, got %d boxes and %d steps", len(got.canvas.Boxes()), len(got.undoStack))
+	}
+
+	m = key(open(), "d")
+	if m.mode != ModeNormal || len(scanRecovery(m.recoveryDir())) != 0 {
+		t.Errorf("expected d to discard the recovered chart, got mode %v", m.mode)
+	}
+}
+
+func TestRecoveryLeavesRunningSessionsAlone(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestModel()
+	m.config = &Config{RecoveryDirectory: dir, Autosave: 30}
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	m = out.(model)
+	m.autosave()
+	path := m.getCurrentBuffer().recoveryPath
+
+	// Hand the file to a process that is certainly running: our parent.
+	rec, err := readRecoveryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.PID = os.Getppid()
+	data, _ := json.Marshal(rec)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanRecovery(dir); len(got) != 0 {
+		t.Fatalf("offered a running session's chart: %+v", got)
+	}
+
+	other := newTestModel()
+	other.recovered = []recoveredChart{{path: path}}
+	other.discardRecovered()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("discarding deleted a running session's chart: %v", err)
+	}
+}
+
+func TestQuitWarnsOnlyAboutModifiedBuffers(t *testing.T) {
+	m := newTestModel()
+	m.config = &Config{Confirmations: true}
+	key := func(k string) tea.Cmd {
+		out, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = out.(model)
+		return cmd
+	}
+
+	if cmd := key("q"); cmd == nil || m.mode != ModeNormal {
+		t.Fatalf("expected a pristine chart to quit without asking, got mode %v", m.mode)
+	}
+
+	key("b")
+	if !m.getCurrentBuffer().modified() {
+		t.Fatal("adding a box didn't mark the buffer modified")
+	}
+	if cmd := key("q"); cmd != nil || m.mode != ModeConfirm || m.confirmAction != ConfirmQuit {
+		t.Fatalf("expected a quit prompt, got mode %v", m.mode)
+	}
+	if got := m.unsavedWarning(); !strings.Contains(got, "Buffer 1") {
+		t.Errorf("expected the prompt to name the buffer, got %q", got)
+	}
+	key("n")
+
+	key("u")
+	if m.getCurrentBuffer().modified() {
+		t.Error("undoing back to the opened state still counts as modified")
+	}
+
+	key("b")
+	if err := m.saveChart(filepath.Join(t.TempDir(), "chart.sav")); err != nil {
+		t.Fatal(err)
+	}
+	if m.getCurrentBuffer().modified() {
+		t.Error("saving didn't clear the modified flag")
+	}
+	key("u")
+	key("b")
+	if !m.getCurrentBuffer().modified() {
+		t.Error("replacing the saved state with a new edit should count as modified")
+	}
+	key("u")
+	if !m.getCurrentBuffer().modified() {
+		t.Error("the saved state is gone from the redo stack, so undoing can't reach it")
+	}
+}
+
+func TestSaveOverChartChangedOnDisk(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "chart.sav")
+	upstream := cv.NewCanvas()
+	upstream.AddBox(0, 0, "Upstream")
+	if err := upstream.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestModel()
+	m.config = &Config{SaveDirectory: dir}
+	res, err := cv.Load(path, cv.LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.openLoadedChart(res, path)
+
+	// Touching the file without changing it isn't a change.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	stamp, err := cv.StampFile(path, res.Stamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.applyFileChecks([]fileCheck{{filename: path, prev: res.Stamp, stamp: stamp}})
+	if m.getCurrentBuffer().diskChanged {
+		t.Fatal("a touched file was reported as changed")
+	}
+
+	upstream.AddBox(20, 0, "Pulled")
+	if err := upstream.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	save := func() {
+		for _, msg := range []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune("s")}, {Type: tea.KeyEnter}} {
+			out, _ := m.Update(msg)
+			m = out.(model)
+		}
+	}
+	key := func(k string) {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = out.(model)
+	}
+
+	save()
+	if m.mode != ModeConfirm || m.confirmAction != ConfirmExternalChange {
+		t.Fatalf("expected a prompt before saving over the pulled file, got mode %v", m.mode)
+	}
+	key("c")
+	if len(m.buffers) != 2 || len(m.getCanvas().Boxes()) != 2 {
+		t.Fatalf("expected the disk version in a new buffer, got %d buffers", len(m.buffers))
+	}
+
+	key("{")
+	save()
+	if m.confirmAction != ConfirmExternalChange {
+		t.Fatal("the original buffer stopped warning after comparing")
+	}
+	key("o")
+	if m.mode != ModeNormal || m.getCurrentBuffer().diskChanged {
+		t.Fatalf("expected the overwrite to go through, got mode %v (error %q)", m.mode, m.errorMessage)
+	}
+	saved, err := cv.Load(path, cv.LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := saved.Canvas.Boxes(); len(got) != 1 || got[0].GetText() != "Upstream" {
+		t.Errorf("expected the buffer's own chart on disk, got %+v", got)
+	}
+}
+
+// fakeSystemClipboard stands in for the system clipboard until the test
+// ends and returns what is on it.
+func fakeSystemClipboard(t *testing.T) *string {
+	t.Helper()
+	var text string
+	read, write := readSystemClipboard, writeSystemClipboard
+	readSystemClipboard = func() (string, error) { return text, nil }
+	writeSystemClipboard = func(s string) error {
+		text = s
+		return nil
+	}
+	t.Cleanup(func() { readSystemClipboard, writeSystemClipboard = read, write })
+	return &text
+}
+
+// paste presses p and delivers the clipboard it reads in the background.
+func paste(m model) model {
+	out, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	m = out.(model)
+	if cmd != nil {
+		out, _ = m.Update(cmd())
+		m = out.(model)
+	}
+	return m
+}
+
+func TestCopyPasteAndCutSelection(t *testing.T) {
+	fakeSystemClipboard(t)
+	m := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	c.AddText(5, 10, "note")
+	c.SetHighlight(6, 12, 3)
+
+	selectAll := func() {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("M")})
+		m = out.(model)
+		for _, msg := range []tea.Msg{press(tea.MouseButtonLeft, 2, 1), dragMotion(60, 30), release(60, 30)} {
+			out, _ = m.Update(msg)
+			m = out.(model)
+		}
+	}
+	key := func(k string) {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = out.(model)
+	}
+
+	selectAll()
+	key("c")
+	if m.mode != ModeNormal || m.clipboard == nil || len(m.clipboard.Boxes()) != 2 || len(m.clipboard.Connections()) != 1 || len(m.clipboard.Texts()) != 1 {
+		t.Fatalf("expected both boxes, the line and the text on the clipboard, got mode %v", m.mode)
+	}
+
+	m.cursorX, m.cursorY = 70, 5
+	undoDepth := len(m.getCurrentBuffer().undoStack)
+	m = paste(m)
+	c = m.getCanvas()
+	if len(c.Boxes()) != 4 || len(c.Connections()) != 2 || len(c.Texts()) != 2 {
+		t.Fatalf("expected the selection pasted, got %d boxes, %d lines, %d texts", len(c.Boxes()), len(c.Connections()), len(c.Texts()))
+	}
+	if len(m.getCurrentBuffer().undoStack) != undoDepth+1 {
+		t.Fatalf("expected the paste to be one undo step")
+	}
+	pasted := c.Connections()[1]
+	if c.BoxIndex(pasted.FromID) != 2 || c.BoxIndex(pasted.ToID) != 3 {
+		t.Errorf("expected the pasted line to join the pasted boxes, got %+v", pasted)
+	}
+	if c.Boxes()[2].X != 70 || c.GetHighlight(71, 14) != 3 {
+		t.Errorf("expected the paste at the cursor, got box at %d,%d", c.Boxes()[2].X, c.Boxes()[2].Y)
+	}
+
+	key("u")
+	if len(c.Boxes()) != 2 || len(c.Connections()) != 1 || len(c.Texts()) != 1 || c.GetHighlight(71, 14) != -1 {
+		t.Fatal("undo didn't remove the pasted objects")
+	}
+	key("U")
+	if len(c.Boxes()) != 4 || c.Boxes()[3].GetText() != "Beta" {
+		t.Fatal("redo didn't paste again")
+	}
+	key("u")
+
+	selectAll()
+	key("x")
+	if len(c.Boxes()) != 0 || len(c.Texts()) != 0 || len(c.Connections()) != 0 || c.GetHighlight(6, 12) != -1 {
+		t.Fatalf("expected the cut to empty the chart, got %d boxes", len(c.Boxes()))
+	}
+	key("u")
+	if len(c.Boxes()) != 2 || len(c.Connections()) != 1 || c.GetHighlight(6, 12) != 3 {
+		t.Fatal("undo didn't bring back the cut objects")
+	}
+}
+
+func TestPasteFragmentFromAnotherSession(t *testing.T) {
+	system := fakeSystemClipboard(t)
+	src := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	src.getCanvas().AddConnection(0, 1)
+	src.cursorX, src.cursorY = 6, 4
+	out, cmd := src.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	src = out.(model)
+	if cmd == nil {
+		t.Fatal("expected copying to write the system clipboard")
+	}
+	cmd()
+	if !strings.Contains(*system, "Alpha") || !strings.Contains(*system, "BEGIN FLERM FRAGMENT") {
+		t.Fatalf("expected the drawing and a fragment on the system clipboard, got %q", *system)
+	}
+
+	dst := newTestModel()
+	dst.cursorX, dst.cursorY = 20, 10
+	dst = paste(dst)
+	c := dst.getCanvas()
+	if len(c.Boxes()) != 3 || c.Boxes()[2].GetText() != "Alpha" || c.Boxes()[2].X != 20 {
+		t.Fatalf("expected the copied box pasted at the cursor, got %d boxes", len(c.Boxes()))
+	}
+
+	// Plain text from another program falls back to the editor's own clipboard.
+	*system = "just words"
+	dst = paste(dst)
+	if len(dst.getCanvas().Boxes()) != 3 || dst.errorMessage != "" {
+		t.Fatalf("expected plain text to paste nothing, got %d boxes, error %q", len(dst.getCanvas().Boxes()), dst.errorMessage)
+	}
+}
+
+// terminalPaste sends text the way bubbletea reports a bracketed paste.
+func terminalPaste(m model, text string) model {
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text), Paste: true})
+	return out.(model)
+}
+
+func TestPasteFragmentIntoTerminal(t *testing.T) {
+	src := newTestModel()
+	src.getCanvas().AddConnection(0, 1)
+	fragment, err := src.getCanvas().Extract([]int{0, 1}, nil, []int{0}, nil).EncodeFragment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Over SSH the remote clipboard is never read; the user's terminal
+	// pastes the fragment instead.
+	t.Setenv("SSH_TTY", "/dev/pts/0")
+	read := readSystemClipboard
+	readSystemClipboard = func() (string, error) {
+		t.Fatal("read the remote host's clipboard")
+		return "", nil
+	}
+	t.Cleanup(func() { readSystemClipboard = read })
+
+	m := newTestModel()
+	m = paste(m)
+	if len(m.getCanvas().Boxes()) != 2 {
+		t.Fatalf("expected nothing pasted from an empty clipboard, got %d boxes", len(m.getCanvas().Boxes()))
+	}
+	m.cursorX, m.cursorY = 60, 5
+	m = terminalPaste(m, fragment)
+	c := m.getCanvas()
+	if len(c.Boxes()) != 4 || len(c.Connections()) != 1 || c.Boxes()[2].X != 60 {
+		t.Fatalf("expected both boxes and their line pasted at the cursor, got %d boxes, %d lines", len(c.Boxes()), len(c.Connections()))
+	}
+
+	m = terminalPaste(m, "b hello")
+	if len(m.getCanvas().Boxes()) != 4 || m.errorMessage == "" {
+		t.Fatalf("expected pasted plain text to be refused, got %d boxes", len(m.getCanvas().Boxes()))
+	}
+}
+
+func TestEditingTextWithMultiByteCharacters(t *testing.T) {
+	m := newTestModel() // box0 Alpha @ (5,3)
+	m.getCanvas().SetBoxText(0, "日本")
+	key := func(msg tea.KeyMsg) {
+		out, _ := m.Update(msg)
+		m = out.(model)
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	m.cursorX, m.cursorY = 6, 4
+	key(runes("e"))
+	if m.mode != ModeEditing || m.editCursorPos != 2 {
+		t.Fatalf("expected to edit with the cursor after both characters, got mode %v, cursor %d", m.mode, m.editCursorPos)
+	}
+	key(tea.KeyMsg{Type: tea.KeyLeft})
+	key(runes("x"))
+	if m.editText != "日x本" {
+		t.Fatalf("expected x between the characters, got %q", m.editText)
+	}
+	key(tea.KeyMsg{Type: tea.KeyBackspace})
+	key(tea.KeyMsg{Type: tea.KeyHome})
+	key(runes("é"))
+	key(tea.KeyMsg{Type: tea.KeyEnd})
+	key(tea.KeyMsg{Type: tea.KeyBackspace})
+	if m.editText != "é日" || m.editCursorPos != 2 {
+		t.Fatalf("expected %q with the cursor at 2, got %q at %d", "é日", m.editText, m.editCursorPos)
+	}
+	key(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if box := m.getCanvas().Boxes()[0]; box.GetText() != "é日" || box.Width != 8 {
+		t.Errorf("expected the edit saved in an 8-wide box, got %q, width %d", box.GetText(), box.Width)
+	}
+}
+
+func TestNewLineIsRoutedAroundBoxes(t *testing.T) {
+	m := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	c := m.getCanvas()
+	c.AddBox(36, 8, "Wall\n\n\n\n")
+	wall := c.Boxes()[2]
+	key := func(k string) {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = out.(model)
+	}
+
+	m.cursorX, m.cursorY = 10, 4
+	key("a")
+	m.cursorX, m.cursorY = 41, 21
+	key("a")
+	if len(c.Connections()) != 1 {
+		t.Fatalf("expected a line, got %d", len(c.Connections()))
+	}
+	for _, p := range c.GetConnectionCells(0) {
+		if p.X >= wall.X && p.X < wall.X+wall.Width && p.Y >= wall.Y && p.Y < wall.Y+wall.Height {
+			t.Fatalf("line runs through the wall at %v: %+v", p, c.Connections()[0])
+		}
+	}
+
+	key("u")
+	key("U")
+	if len(c.Connections()) != 1 || len(c.Connections()[0].Waypoints) == 0 {
+		t.Errorf("expected redo to bring back the routed line, got %+v", c.Connections())
+	}
+}
+
+func TestArrangeIsOneUndoableStep(t *testing.T) {
+	m := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	c.RouteConnection(0)
+	c.SetHighlight(41, 21, 2) // on Beta
+	before := c.SnapshotConnections()
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	m = out.(model)
+	c = m.getCanvas()
+	alpha, beta := c.Boxes()[0], c.Boxes()[1]
+	if alpha.X != 5 || alpha.Y != 3 || beta.Y < alpha.Y+alpha.Height || beta.Y > alpha.Y+alpha.Height+5 {
+		t.Fatalf("expected Beta just below Alpha, got Alpha=(%d,%d) Beta=(%d,%d)", alpha.X, alpha.Y, beta.X, beta.Y)
+	}
+	if c.GetHighlight(beta.X+1, beta.Y+1) != 2 || c.GetHighlight(41, 21) != -1 {
+		t.Errorf("expected Beta's highlight to move with it")
+	}
+	arranged := c.Connections()[0].Clone()
+
+	m.undo()
+	c = m.getCanvas()
+	if b := c.Boxes()[1]; b.X != 40 || b.Y != 20 || c.GetHighlight(41, 21) != 2 {
+		t.Fatalf("expected one undo to put Beta and its highlight back, got (%d,%d)", b.X, b.Y)
+	}
+	if !reflect.DeepEqual(c.Connections()[0], before[0]) {
+		t.Errorf("expected the line restored, got %+v want %+v", c.Connections()[0], before[0])
+	}
+	m.redo()
+	c = m.getCanvas()
+	if b := c.Boxes()[1]; b.X != beta.X || b.Y != beta.Y {
+		t.Errorf("expected redo to arrange again, got (%d,%d)", b.X, b.Y)
+	}
+	if !reflect.DeepEqual(c.Connections()[0], arranged) {
+		t.Errorf("expected redo to reroute the line again, got %+v", c.Connections()[0])
+	}
+}
+
+func TestTreeIsLaidOutAgainWhenALineIsAdded(t *testing.T) {
+	m := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	m.config.TreeRelayout = true
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	c.AddBox(80, 30, "Gamma")
+	key := func(k string) {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = out.(model)
+	}
+
+	m.cursorX, m.cursorY = 6, 4
+	key("y")
+	alpha, beta := c.Boxes()[0], c.Boxes()[1]
+	if beta.Y <= alpha.Y || beta.X+beta.Width/2 != alpha.X+alpha.Width/2 {
+		t.Fatalf("expected Beta centered under Alpha, got Alpha=(%d,%d) Beta=(%d,%d)", alpha.X, alpha.Y, beta.X, beta.Y)
+	}
+
+	// Connecting Gamma to Alpha makes it Beta's sibling.
+	m.cursorX, m.cursorY = alpha.X+1, alpha.Y+1
+	key("a")
+	m.cursorX, m.cursorY = 81, 31
+	key("a")
+	beta, gamma := c.Boxes()[1], c.Boxes()[2]
+	if gamma.Y != beta.Y {
+		t.Fatalf("expected Gamma laid out next to Beta, got Beta=(%d,%d) Gamma=(%d,%d)", beta.X, beta.Y, gamma.X, gamma.Y)
+	}
+
+	// The line and the re-layout it caused are one step.
+	key("u")
+	if g := c.Boxes()[2]; g.X != 80 || g.Y != 30 || len(c.Connections()) != 1 {
+		t.Errorf("expected one undo to put Gamma back and remove the line, got (%d,%d) with %d lines", g.X, g.Y, len(c.Connections()))
+	}
+	key("U")
+	if b, g := c.Boxes()[1], c.Boxes()[2]; g.Y != b.Y || len(c.Connections()) != 2 {
+		t.Errorf("expected redo to bring back the line and the layout, got Beta=(%d,%d) Gamma=(%d,%d) with %d lines", b.X, b.Y, g.X, g.Y, len(c.Connections()))
+	}
+}
+
+func TestTreeRelayoutRecordsOnlyTheTree(t *testing.T) {
+	m := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	m.config.TreeRelayout = true
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	c.AddBox(80, 30, "Gamma")
+	c.AddBox(0, 50, "Delta")
+	c.AddBox(30, 50, "Epsilon")
+	c.AddConnection(3, 4)
+	key := func(k string) {
+		out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = out.(model)
+	}
+	m.cursorX, m.cursorY = 6, 4
+	key("y")
+	alpha := c.Boxes()[0]
+	m.cursorX, m.cursorY = alpha.X+1, alpha.Y+1
+	key("a")
+	m.cursorX, m.cursorY = 81, 31
+	key("a")
+	relayout := m.getCurrentBuffer().undoStack[len(m.getCurrentBuffer().undoStack)-1].Relayout
+	if relayout == nil {
+		t.Fatal("expected the new line to lay the tree out again")
+	}
+	if len(relayout.Before.Boxes) != 3 || len(relayout.Before.Connections) != 2 || len(relayout.After.Connections) != 2 {
+		t.Errorf("expected the re-layout to record Alpha, Beta, Gamma and their 2 lines, got %d boxes and %d lines",
+			len(relayout.Before.Boxes), len(relayout.Before.Connections))
+	}
+}
+
+func TestTreeIsLaidOutAgainWhenLinesArePasted(t *testing.T) {
+	m := newTestModel() // box0 Alpha @ (5,3), box1 Beta @ (40,20)
+	m.config.TreeRelayout = true
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	m.cursorX, m.cursorY = 6, 4
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = out.(model)
+	arranged := c.Boxes()[1]
+	c.MoveBox(1, 7, 0)
+
+	clip := cv.NewCanvas()
+	clip.AddBox(0, 0, "One")
+	clip.AddBox(20, 0, "Two")
+	clip.AddConnection(0, 1)
+	m.pasteAt(clip, 60, 40)
+	if b := c.Boxes()[1]; b.X != arranged.X || b.Y != arranged.Y {
+		t.Errorf("expected pasting lines to lay the tree out again, got Beta at (%d,%d), want (%d,%d)", b.X, b.Y, arranged.X, arranged.Y)
+	}
+
+	m.undo()
+	if b := c.Boxes()[1]; len(c.Boxes()) != 2 || b.X != arranged.X+7 {
+		t.Errorf("expected one undo to remove the paste and the layout, got %d boxes with Beta at x=%d", len(c.Boxes()), b.X)
+	}
+}
+
+func TestEditLabelOnLine(t *testing.T) {
+	m := newTestModel()
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	conn := &c.Connections()[0]
+	conn.FromX, conn.FromY, conn.ToX, conn.ToY = 12, 4, 40, 21
+	conn.Waypoints = []point{{X: 30, Y: 4}, {X: 30, Y: 21}}
+	m.cursorX, m.cursorY = 30, 10
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = out.(model)
+	if m.mode != ModeLabelEdit {
+		t.Fatalf("expected e on a line to edit its label, got mode %v", m.mode)
+	}
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("yes")})
+	m = out.(model)
+	if got := c.Connections()[0].Label; got != "yes" {
+		t.Fatalf("expected the label to update as it is typed, got %q", got)
+	}
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = out.(model)
+	if m.mode != ModeNormal || c.Connections()[0].Label != "yes" {
+		t.Fatalf("expected Enter to keep the label, got %q in mode %v", c.Connections()[0].Label, m.mode)
+	}
+
+	m.undo()
+	if got := c.Connections()[0].Label; got != "" {
+		t.Fatalf("expected undo to remove the label, got %q", got)
+	}
+	m.redo()
+	if got := c.Connections()[0].Label; got != "yes" {
+		t.Fatalf("expected redo to bring the label back, got %q", got)
+	}
+
+	// Esc puts back what was there before.
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	m = out.(model)
+	if got := c.Connections()[0].Label; got != "yes" {
+		t.Fatalf("expected Esc to restore the label, got %q", got)
+	}
+}
+
+func TestLabelEditFollowsItsLine(t *testing.T) {
+	m := newTestModel()
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	c.AddConnection(1, 0)
+	m.startLabelEdit(1)
+	m.deleteConnByIdx(0)
+
+	out, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("back")})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = out.(model)
+	if got := c.Connections()[0].Label; got != "back" {
+		t.Fatalf("expected the label on the line being edited, got %q", got)
+	}
+}
+
+func TestPasteIntoLabelReadsClipboardInBackground(t *testing.T) {
+	clip := fakeSystemClipboard(t)
+	*clip = "maybe\nlater"
+	m := newTestModel()
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	m.startLabelEdit(0)
+
+	out, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlV})
+	m = out.(model)
+	if cmd == nil || c.Connections()[0].Label != "" {
+		t.Fatalf("expected Ctrl+V to read the clipboard in the background, got label %q", c.Connections()[0].Label)
+	}
+	out, _ = m.Update(cmd())
+	m = out.(model)
+	if got := c.Connections()[0].Label; got != "maybe later" {
+		t.Errorf("expected the clipboard typed into the label, got %q", got)
+	}
+
+	// A read that arrives after the edit ended is dropped.
+	out, cmd = m.Update(tea.KeyMsg{Type: tea.KeyCtrlV})
+	m = out.(model)
+	out, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	m = out.(model)
+	out, _ = m.Update(cmd())
+	m = out.(model)
+	if got := c.Connections()[0].Label; got != "" || len(c.Boxes()) != 2 {
+		t.Errorf("expected the late clipboard ignored, got label %q and %d boxes", got, len(c.Boxes()))
+	}
+}
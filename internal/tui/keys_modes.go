@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -46,6 +47,14 @@ func (m model) handleStartupKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.openInNewBuffer = false
 		m.scanTxtFiles()
 		return m, nil
+	case "r":
+		if len(m.recovered) > 0 {
+			m.restoreRecovered()
+		}
+		return m, nil
+	case "d":
+		m.discardRecovered()
+		return m, nil
 	case "q", "ctrl+c":
 		return m, tea.Quit
 	default:
@@ -97,23 +106,23 @@ func (m model) handleResizeKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case "h", "left", "H", "shift+left", "l", "right", "L", "shift+right",
 		"k", "up", "K", "shift+up", "j", "down", "J", "shift+down":
-		if m.selectedBox != -1 {
+		if i := m.selectedBoxIndex(); i != -1 {
 			d := arrowDeltas[msg.String()]
-			m.getCanvas().ResizeBox(m.selectedBox, d[0], d[1])
+			m.getCanvas().ResizeBox(i, d[0], d[1])
 			m.ensureCursorInBounds()
 		}
 		return m, nil
 	case "enter":
 
-		if m.selectedBox != -1 && m.selectedBox < len(m.getCanvas().Boxes()) {
-			currentBox := m.getCanvas().Boxes()[m.selectedBox]
+		if i := m.selectedBoxIndex(); i != -1 {
+			currentBox := m.getCanvas().Boxes()[i]
 
 			deltaWidth := currentBox.Width - m.originalWidth
 			deltaHeight := currentBox.Height - m.originalHeight
 
 			if deltaWidth != 0 || deltaHeight != 0 {
-				resizeData := ResizeBoxData{ID: m.selectedBox, DeltaWidth: deltaWidth, DeltaHeight: deltaHeight}
-				originalState := OriginalBoxState{ID: m.selectedBox, X: currentBox.X, Y: currentBox.Y, Width: m.originalWidth, Height: m.originalHeight}
+				resizeData := ResizeBoxData{ID: currentBox.ID, DeltaWidth: deltaWidth, DeltaHeight: deltaHeight}
+				originalState := OriginalBoxState{ID: currentBox.ID, X: currentBox.X, Y: currentBox.Y, Width: m.originalWidth, Height: m.originalHeight}
 				m.recordAction(ActionResizeBox, resizeData, originalState)
 			}
 		}
@@ -176,6 +185,26 @@ func (m model) handleMoveKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter":
 		m.commitMove()
 		return m, nil
+	case "c":
+		if m.selectedBox == -1 && m.selectedText == -1 {
+			return m, m.copyObjects(m.takeSelection())
+		}
+		return m, nil
+	case "x":
+		if m.selectedBox == -1 && m.selectedText == -1 {
+			return m, m.cutObjects(m.takeSelection())
+		}
+		return m, nil
+	case "g", "G":
+		if m.selectedBox == -1 && m.selectedText == -1 && len(m.selectedBoxes) > 0 {
+			boxes, _, _, _ := m.takeSelection()
+			dir := cv.LayoutTopDown
+			if msg.String() == "G" {
+				dir = cv.LayoutLeftRight
+			}
+			m.arrangeBoxes(boxes, dir)
+		}
+		return m, nil
 	}
 	return m, nil
 }
@@ -365,6 +394,17 @@ func (m model) handleFileInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				filename = selectedFile
 			}
 		}
+		if m.fileOp == FileOpOpen {
+			if format, ok := cv.ImportFormatForFile(filename); ok {
+				if err := m.importFile(filename, format); err != nil {
+					m.errorMessage = fmt.Sprintf("Error importing file: %s", err.Error())
+					return m, nil
+				}
+				m.mode = ModeNormal
+				m.filename = ""
+				return m, nil
+			}
+		}
 		switch m.fileOp {
 		case FileOpSave, FileOpOpen:
 			if m.fileOp == FileOpSave {
@@ -374,7 +414,7 @@ func (m model) handleFileInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 			}
-			if !strings.HasSuffix(strings.ToLower(filename), ".sav") {
+			if !isChartFile(filename) {
 				filename += ".sav"
 			}
 			if m.fileOp == FileOpSave {
@@ -384,6 +424,13 @@ func (m model) handleFileInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					savePath = m.config.GetSavePath(filename)
 				}
 
+				if m.changedOnDisk(savePath) {
+					m.mode = ModeConfirm
+					m.confirmAction = ConfirmExternalChange
+					m.filename = savePath
+					return m, nil
+				}
+
 				if _, err := os.Stat(savePath); err == nil {
 					if m.config != nil && m.config.Confirmations {
 
@@ -396,12 +443,7 @@ func (m model) handleFileInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 				}
 
-				buf := m.getCurrentBuffer()
-				panX, panY := 0, 0
-				if buf != nil {
-					panX, panY = buf.panX, buf.panY
-				}
-				err := m.getCanvas().SaveToFileWithPan(savePath, panX, panY)
+				err := m.saveChart(savePath)
 				if err != nil {
 					m.errorMessage = fmt.Sprintf("Error saving file: %s", err.Error())
 					return m, nil
@@ -429,104 +471,24 @@ func (m model) handleFileInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					m.errorMessage = fmt.Sprintf("File not found: %s", filename)
 					return m, nil
 				}
-				newCanvas := cv.NewCanvas()
-				panX, panY, err := newCanvas.LoadFromFileWithPan(loadPath)
-				if err != nil {
+				res, err := cv.Load(loadPath, cv.LoadOptions{})
+				var loadErr *cv.LoadError
+				if errors.As(err, &loadErr) {
+					m.mode = ModeConfirm
+					m.confirmAction = ConfirmSalvageLoad
+					m.salvagePath = loadPath
+					m.salvageError = err.Error()
+					return m, nil
+				} else if err != nil {
 					m.errorMessage = fmt.Sprintf("Error opening file: %s", err.Error())
 					return m, nil
-				} else {
-
-					if m.fromStartup {
-
-						m.buffers[0] = Buffer{
-							canvas:    newCanvas,
-							undoStack: []Action{},
-							redoStack: []Action{},
-							filename:  loadPath,
-							panX:      panX,
-							panY:      panY,
-						}
-						m.currentBufferIndex = 0
-						m.fromStartup = false
-					} else if m.openInNewBuffer {
-
-						m.addNewBufferWithPan(newCanvas, loadPath, panX, panY)
-						m.openInNewBuffer = false
-					} else {
-
-						buf := m.getCurrentBuffer()
-						if buf != nil {
-							buf.canvas = newCanvas
-							buf.filename = loadPath
-							buf.panX = panX
-							buf.panY = panY
-							buf.undoStack = []Action{}
-							buf.redoStack = []Action{}
-						}
-					}
-					m.errorMessage = ""
 				}
+				m.openLoadedChart(res, loadPath)
 			}
-		case FileOpSavePNG:
-
-			baseFilename := filepath.Base(filename)
-			if !strings.HasSuffix(strings.ToLower(baseFilename), ".png") {
-				baseFilename += ".png"
-			}
-
-			savePath := baseFilename
-			if m.config != nil {
-				savePath = m.config.GetSavePath(baseFilename)
-			}
-
-			buf := m.getCurrentBuffer()
-			panX, panY := 0, 0
-			if buf != nil {
-				panX, panY = buf.panX, buf.panY
-			}
-
-			showBufferBar := m.mode != ModeStartup && len(m.buffers) > 1
-			renderWidth := m.width
-			if renderWidth < 1 {
-				renderWidth = 80
-			}
-			var renderHeight int
-			if showBufferBar {
-				renderHeight = m.height - 2
-			} else {
-				renderHeight = m.height - 1
-			}
-			if renderHeight < 1 {
-				renderHeight = 24
-			}
-			err := m.getCanvas().ExportToPNG(savePath, renderWidth, renderHeight, panX, panY)
-			if err != nil {
-				m.errorMessage = fmt.Sprintf("Error exporting PNG: %s", err.Error())
-				return m, nil
-			} else {
-				absPath, _ := filepath.Abs(savePath)
-				m.successMessage = fmt.Sprintf("Exported to %s", absPath)
-				m.errorMessage = ""
-			}
-		case FileOpSaveVisualTXT:
-
-			baseFilename := filepath.Base(filename)
-			if !strings.HasSuffix(strings.ToLower(baseFilename), ".txt") {
-				baseFilename += ".txt"
-			}
-
-			savePath := baseFilename
-			if m.config != nil {
-				savePath = m.config.GetSavePath(baseFilename)
-			}
-			err := m.exportVisualTXT(savePath)
-			if err != nil {
-				m.errorMessage = fmt.Sprintf("Error exporting Visual TXT: %s", err.Error())
+		case FileOpExport:
+			if err := m.finishExport(filename); err != nil {
+				m.errorMessage = fmt.Sprintf("Error exporting %s: %s", m.exportChoice.label, err.Error())
 				return m, nil
-			} else {
-				absPath, _ := filepath.Abs(savePath)
-				m.successMessage = fmt.Sprintf("Exported to %s", absPath)
-				m.errorMessage = ""
 			}
 		}
 		m.mode = ModeNormal
@@ -570,46 +532,24 @@ func (m model) handleFileInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.confirmAction == ConfirmExternalChange {
+		return m.handleExternalChangeKey(msg)
+	}
+	if m.confirmAction == ConfirmRestoreRecovered {
+		return m.handleRestoreKey(msg)
+	}
 	switch msg.String() {
 	case "y", "Y":
 
 		switch m.confirmAction {
 		case ConfirmDeleteBox:
 
-			if m.confirmBoxID >= 0 && m.confirmBoxID < len(m.getCanvas().Boxes()) {
-				box := m.getCanvas().Boxes()[m.confirmBoxID]
-
-				connectedConnections := make([]Connection, 0)
-				for _, connection := range m.getCanvas().Connections() {
-					if connection.FromID == m.confirmBoxID || connection.ToID == m.confirmBoxID {
-						connectedConnections = append(connectedConnections, connection)
-					}
-				}
-				highlights := m.getCanvas().GetHighlightsForBox(m.confirmBoxID)
-				deleteData := DeleteBoxData{Box: box, ID: m.confirmBoxID, Connections: connectedConnections, Highlights: highlights}
-				addData := AddBoxData{X: box.X, Y: box.Y, Text: box.GetText(), ID: box.ID}
-				m.recordAction(ActionDeleteBox, deleteData, addData)
-			}
-			m.getCanvas().DeleteBox(m.confirmBoxID)
-			m.ensureCursorInBounds()
+			m.deleteBoxByID(m.getCanvas().BoxIndex(m.confirmBoxID))
 		case ConfirmDeleteText:
-			if m.confirmTextID >= 0 && m.confirmTextID < len(m.getCanvas().Texts()) {
-				text := m.getCanvas().Texts()[m.confirmTextID]
-				highlights := m.getCanvas().GetHighlightsForText(m.confirmTextID)
-				deleteData := DeleteTextData{Text: text, ID: m.confirmTextID, Highlights: highlights}
-				addData := AddTextData{X: text.X, Y: text.Y, Text: text.GetText(), ID: text.ID}
-				m.recordAction(ActionDeleteText, deleteData, addData)
-			}
-			m.getCanvas().DeleteText(m.confirmTextID)
-			m.ensureCursorInBounds()
+			m.deleteTextByID(m.getCanvas().TextIndex(m.confirmTextID))
 		case ConfirmDeleteConnection:
-			if m.confirmConnIdx >= 0 && m.confirmConnIdx < len(m.getCanvas().Connections()) {
-				conn := m.getCanvas().Connections()[m.confirmConnIdx]
-				deleteData := AddConnectionData{FromID: conn.FromID, ToID: conn.ToID, Connection: conn}
-				m.getCanvas().RemoveSpecificConnection(conn)
-				m.recordAction(ActionDeleteConnection, deleteData, deleteData)
-				m.successMessage = ""
-			}
+			m.deleteConnByIdx(m.getCanvas().ConnectionIndex(m.confirmConnID))
+			m.successMessage = ""
 			m.mode = ModeNormal
 			return m, nil
 		case ConfirmDeleteHighlight:
@@ -650,14 +590,7 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 				m.addNewBuffer(cv.NewCanvas(), "")
 			} else {
-
-				buf := m.getCurrentBuffer()
-				if buf != nil {
-					buf.canvas = cv.NewCanvas()
-					buf.filename = ""
-					buf.undoStack = []Action{}
-					buf.redoStack = []Action{}
-				}
+				m.resetCurrentBuffer()
 			}
 			m.cursorX = 0
 			m.cursorY = 0
@@ -665,42 +598,13 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.successMessage = ""
 			m.createNewBuffer = false
 		case ConfirmCloseBuffer:
-
-			if len(m.buffers) > 1 {
-
-				newIndex := m.currentBufferIndex - 1
-				if newIndex < 0 {
-					newIndex = 0
-				}
-
-				m.buffers = append(m.buffers[:m.currentBufferIndex], m.buffers[m.currentBufferIndex+1:]...)
-
-				m.currentBufferIndex = newIndex
-			} else {
-
-				canvas := cv.NewCanvas()
-				m.buffers = []Buffer{
-					{
-						canvas:    canvas,
-						undoStack: []Action{},
-						redoStack: []Action{},
-						filename:  "",
-						panX:      0,
-						panY:      0,
-					},
-				}
-				m.currentBufferIndex = 0
-				m.mode = ModeStartup
-				m.cursorX = 0
-				m.cursorY = 0
-				m.errorMessage = ""
-				m.successMessage = ""
+			m.closeCurrentBuffer()
+			if m.mode == ModeStartup {
 				return m, nil
 			}
-			m.cursorX = 0
-			m.cursorY = 0
-			m.errorMessage = ""
-			m.successMessage = ""
+		case ConfirmOpenOverCurrent:
+			m.beginOpen()
+			return m, nil
 		case ConfirmOverwriteFile:
 
 			filename := m.filename
@@ -710,12 +614,7 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				baseName := filepath.Base(filename)
 				filename = m.config.GetSavePath(baseName)
 			}
-			buf := m.getCurrentBuffer()
-			panX, panY := 0, 0
-			if buf != nil {
-				panX, panY = buf.panX, buf.panY
-			}
-			err := m.getCanvas().SaveToFileWithPan(filename, panX, panY)
+			err := m.saveChart(filename)
 			if err != nil {
 				m.errorMessage = fmt.Sprintf("Error saving file: %s", err.Error())
 				m.mode = ModeFileInput
@@ -733,58 +632,18 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case ConfirmChooseExportType:
 
 			return m, nil
+		case ConfirmSalvageLoad:
+			res, err := cv.Load(m.salvagePath, cv.LoadOptions{Salvage: true})
+			if err != nil {
+				m.errorMessage = fmt.Sprintf("Error opening file: %s", err.Error())
+				m.mode = ModeFileInput
+				return m, nil
+			}
+			m.openLoadedChart(res, m.salvagePath)
 		}
 		m.mode = ModeNormal
 		m.filename = ""
 		return m, nil
-	case "p", "P":
-
-		if m.confirmAction == ConfirmChooseExportType {
-			m.mode = ModeFileInput
-			m.fileOp = FileOpSavePNG
-
-			buf := m.getCurrentBuffer()
-			if buf != nil && buf.filename != "" {
-
-				baseName := filepath.Base(buf.filename)
-
-				if strings.HasSuffix(strings.ToLower(baseName), ".sav") {
-					baseName = baseName[:len(baseName)-4]
-				}
-				m.filename = baseName
-			} else {
-				m.filename = "flowchart"
-			}
-			m.errorMessage = ""
-			m.successMessage = ""
-			m.fromStartup = false
-			return m, nil
-		}
-
-	case "t", "T":
-
-		if m.confirmAction == ConfirmChooseExportType {
-			m.mode = ModeFileInput
-			m.fileOp = FileOpSaveVisualTXT
-
-			buf := m.getCurrentBuffer()
-			if buf != nil && buf.filename != "" {
-
-				baseName := filepath.Base(buf.filename)
-
-				if strings.HasSuffix(strings.ToLower(baseName), ".sav") {
-					baseName = baseName[:len(baseName)-4]
-				}
-				m.filename = baseName
-			} else {
-				m.filename = "flowchart"
-			}
-			m.errorMessage = ""
-			m.successMessage = ""
-			m.fromStartup = false
-			return m, nil
-		}
-
 	case "n", "N", "esc", "escape":
 
 		if m.confirmAction == ConfirmOverwriteFile {
@@ -794,13 +653,21 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		} else if m.confirmAction == ConfirmChooseExportType {
 
 			m.mode = ModeNormal
+		} else if m.confirmAction == ConfirmSalvageLoad {
+
+			m.mode = ModeFileInput
+			m.fileOp = FileOpOpen
+			m.errorMessage = fmt.Sprintf("Error opening file: %s", m.salvageError)
 		} else {
 			m.mode = ModeNormal
 		}
 		return m, nil
 	default:
-
+		if m.confirmAction == ConfirmChooseExportType {
+			if choice, ok := lookupExportChoice(msg.String()); ok {
+				m.beginExport(choice)
+			}
+		}
 		return m, nil
 	}
-	return m, nil
 }
@@ -29,28 +29,18 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	switch msg.String() {
 	case "ctrl+c", "q":
-		if m.config != nil && m.config.Confirmations {
-			m.mode = ModeConfirm
-			m.confirmAction = ConfirmQuit
+		if !m.confirmDataLoss(ConfirmQuit) {
 			return m, nil
 		}
 
 		return m, tea.Quit
 	case "n":
-		if m.config != nil && m.config.Confirmations {
-			m.mode = ModeConfirm
-			m.confirmAction = ConfirmNewChart
-			m.createNewBuffer = false
+		m.createNewBuffer = false
+		if !m.confirmDataLoss(ConfirmNewChart, m.currentBufferIndex) {
 			return m, nil
 		}
 
-		buf := m.getCurrentBuffer()
-		if buf != nil {
-			buf.canvas = cv.NewCanvas()
-			buf.filename = ""
-			buf.undoStack = []Action{}
-			buf.redoStack = []Action{}
-		}
+		m.resetCurrentBuffer()
 		m.cursorX = 0
 		m.cursorY = 0
 		m.errorMessage = ""
@@ -99,16 +89,19 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case "b":
 		m.zPanMode = false
-		boxID := len(m.getCanvas().Boxes())
 		panX, panY := m.getPanOffset()
 		worldX, worldY := m.cursorX+panX, m.cursorY+panY
 		m.getCanvas().AddBox(worldX, worldY, "Box")
+		boxID := m.getCanvas().BoxID(len(m.getCanvas().Boxes()) - 1)
 		addData := AddBoxData{X: worldX, Y: worldY, Text: "Box", ID: boxID}
 		deleteData := DeleteBoxData{ID: boxID, Connections: nil, Highlights: nil}
 		m.recordAction(ActionAddBox, addData, deleteData)
 		m.successMessage = ""
 		m.ensureCursorInBounds()
 		return m, nil
+	case "v":
+		m.openLintPanel()
+		return m, nil
 	case "B":
 
 		m.mode = ModeBoxJump
@@ -122,10 +115,10 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		boxID := m.getCanvas().GetBoxAt(worldX, worldY)
 		if boxID != -1 && boxID < len(m.getCanvas().Boxes()) {
 			m.mode = ModeTitleEdit
-			m.titleEditBoxID = boxID
+			m.titleEditBoxID = m.getCanvas().BoxID(boxID)
 			m.titleEditText = m.getCanvas().Boxes()[boxID].Title
 			m.originalTitleText = m.titleEditText
-			m.titleEditCursorPos = len(m.titleEditText)
+			m.titleEditCursorPos = runeLen(m.titleEditText)
 		}
 		return m, nil
 	case "t":
@@ -142,7 +135,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		worldX, worldY := m.cursorX+panX, m.cursorY+panY
 		boxID := m.getCanvas().GetBoxAt(worldX, worldY)
 		if boxID != -1 {
-			m.selectedBox = boxID
+			m.selectedBox = m.getCanvas().BoxID(boxID)
 			if boxID < len(m.getCanvas().Boxes()) {
 				m.originalWidth = m.getCanvas().Boxes()[boxID].Width
 				m.originalHeight = m.getCanvas().Boxes()[boxID].Height
@@ -157,7 +150,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		boxID := m.getCanvas().GetBoxAt(worldX, worldY)
 		textID := m.getCanvas().GetTextAt(worldX, worldY)
 		if boxID != -1 {
-			m.selectedBox = boxID
+			m.selectedBox = m.getCanvas().BoxID(boxID)
 			m.selectedText = -1
 			m.selectedBoxes = []int{}
 			m.selectedTexts = []int{}
@@ -172,7 +165,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				box := m.getCanvas().Boxes()[boxID]
 				m.originalMoveX, m.originalMoveY = box.X, box.Y
 
-				m.originalBoxConnections[boxID] = m.getCanvas().GetConnectionsForBox(boxID)
+				m.originalBoxConnections[box.ID] = m.getCanvas().GetConnectionsForBox(boxID)
 				for y := box.Y; y < box.Y+box.Height; y++ {
 					for x := box.X; x < box.X+box.Width; x++ {
 						if color := m.getCanvas().GetHighlight(x, y); color != -1 {
@@ -183,7 +176,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			m.mode = ModeMove
 		} else if textID != -1 {
-			m.selectedText = textID
+			m.selectedText = m.getCanvas().TextID(textID)
 			m.selectedBox = -1
 			m.selectedBoxes = []int{}
 			m.selectedTexts = []int{}
@@ -198,8 +191,8 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.originalTextMoveX, m.originalTextMoveY = text.X, text.Y
 				maxWidth := 0
 				for _, line := range text.Lines {
-					if len(line) > maxWidth {
-						maxWidth = len(line)
+					if cv.StringWidth(line) > maxWidth {
+						maxWidth = cv.StringWidth(line)
 					}
 				}
 				for y := text.Y; y < text.Y+len(text.Lines); y++ {
@@ -241,25 +234,27 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		boxID := m.getCanvas().GetBoxAt(worldX, worldY)
 		textID := m.getCanvas().GetTextAt(worldX, worldY)
 		if boxID != -1 {
-			m.selectedBox = boxID
+			m.selectedBox = m.getCanvas().BoxID(boxID)
 			m.selectedText = -1
 			m.mode = ModeEditing
 			m.editText = m.getCanvas().GetBoxText(boxID)
 			m.originalEditText = m.editText
-			m.editCursorPos = len(m.editText)
+			m.editCursorPos = runeLen(m.editText)
 			m.editSelectionStart = -1
 			m.editSelectionEnd = -1
 			m.syncCursorPositions()
 		} else if textID != -1 {
-			m.selectedText = textID
+			m.selectedText = m.getCanvas().TextID(textID)
 			m.selectedBox = -1
 			m.mode = ModeEditing
 			m.editText = m.getCanvas().GetTextText(textID)
 			m.originalEditText = m.editText
-			m.editCursorPos = len(m.editText)
+			m.editCursorPos = runeLen(m.editText)
 			m.editSelectionStart = -1
 			m.editSelectionEnd = -1
 			m.syncCursorPositions()
+		} else if connIdx, _, _ := m.getCanvas().FindNearestPointOnConnection(worldX, worldY); connIdx != -1 {
+			m.startLabelEdit(connIdx)
 		}
 		return m, nil
 	case "A":
@@ -267,10 +262,10 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		worldX, worldY := m.cursorX+panX, m.cursorY+panY
 		lineConnIdx, _, _ := m.getCanvas().FindNearestPointOnConnection(worldX, worldY)
 		if lineConnIdx != -1 {
-			oldConn := m.getCanvas().Connections()[lineConnIdx]
+			oldConn := m.getCanvas().Connections()[lineConnIdx].Clone()
 			m.getCanvas().CycleConnectionArrowState(lineConnIdx)
-			newConn := m.getCanvas().Connections()[lineConnIdx]
-			cycleData := CycleArrowData{lineConnIdx, oldConn, newConn}
+			newConn := m.getCanvas().Connections()[lineConnIdx].Clone()
+			cycleData := CycleArrowData{oldConn.ID, oldConn, newConn}
 			m.recordAction(ActionCycleArrow, cycleData, cycleData)
 			m.successMessage = ""
 		}
@@ -299,21 +294,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				toBox := m.getCanvas().Boxes()[boxID]
 				toX, toY := m.getCanvas().FindNearestEdgePoint(toBox, worldX, worldY)
 
-				connection := Connection{
-					FromID:    m.connectionFrom,
-					ToID:      boxID,
-					FromX:     m.connectionFromX,
-					FromY:     m.connectionFromY,
-					ToX:       toX,
-					ToY:       toY,
-					Waypoints: m.connectionWaypoints,
-					Color:     -1,
-				}
-
-				m.getCanvas().AddConnectionWithWaypoints(m.connectionFrom, boxID, m.connectionFromX, m.connectionFromY, toX, toY, m.connectionWaypoints)
-				addConnectionData := AddConnectionData{FromID: m.connectionFrom, ToID: boxID, Connection: connection}
-				inverseConnectionData := AddConnectionData{FromID: m.connectionFrom, ToID: boxID, Connection: connection}
-				m.recordAction(ActionAddConnection, addConnectionData, inverseConnectionData)
+				m.addDrawnConnection(boxID, toX, toY)
 				m.successMessage = ""
 				m.connectionFrom = -1
 				m.connectionFromLine = -1
@@ -323,21 +304,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			} else if lineConnIdx != -1 {
 				toX, toY := lineX, lineY
 
-				connection := Connection{
-					FromID:    m.connectionFrom,
-					ToID:      -1,
-					FromX:     m.connectionFromX,
-					FromY:     m.connectionFromY,
-					ToX:       toX,
-					ToY:       toY,
-					Waypoints: m.connectionWaypoints,
-					Color:     -1,
-				}
-
-				m.getCanvas().AddConnectionWithWaypoints(m.connectionFrom, -1, m.connectionFromX, m.connectionFromY, toX, toY, m.connectionWaypoints)
-				addConnectionData := AddConnectionData{FromID: m.connectionFrom, ToID: -1, Connection: connection}
-				inverseConnectionData := AddConnectionData{FromID: m.connectionFrom, ToID: -1, Connection: connection}
-				m.recordAction(ActionAddConnection, addConnectionData, inverseConnectionData)
+				m.addDrawnConnection(-1, toX, toY)
 				m.successMessage = ""
 				m.connectionFrom = -1
 				m.connectionFromLine = -1
@@ -365,17 +332,12 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if m.config != nil && m.config.Confirmations {
 				m.mode = ModeConfirm
 				m.confirmAction = ConfirmDeleteConnection
-				m.confirmConnIdx = lineConnIdx
+				m.confirmConnID = m.getCanvas().ConnectionID(lineConnIdx)
 				return m, nil
 			}
 
-			if lineConnIdx >= 0 && lineConnIdx < len(m.getCanvas().Connections()) {
-				conn := m.getCanvas().Connections()[lineConnIdx]
-				deleteData := AddConnectionData{FromID: conn.FromID, ToID: conn.ToID, Connection: conn}
-				m.getCanvas().RemoveSpecificConnection(conn)
-				m.recordAction(ActionDeleteConnection, deleteData, deleteData)
-				m.successMessage = ""
-			}
+			m.deleteConnByIdx(lineConnIdx)
+			m.successMessage = ""
 		} else {
 			boxID := m.getCanvas().GetBoxAt(worldX, worldY)
 			textID := m.getCanvas().GetTextAt(worldX, worldY)
@@ -384,42 +346,20 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				if m.config != nil && m.config.Confirmations {
 					m.mode = ModeConfirm
 					m.confirmAction = ConfirmDeleteBox
-					m.confirmBoxID = boxID
+					m.confirmBoxID = m.getCanvas().BoxID(boxID)
 					return m, nil
 				}
 
-				if boxID >= 0 && boxID < len(m.getCanvas().Boxes()) {
-					box := m.getCanvas().Boxes()[boxID]
-					connectedConnections := make([]Connection, 0)
-					for _, connection := range m.getCanvas().Connections() {
-						if connection.FromID == boxID || connection.ToID == boxID {
-							connectedConnections = append(connectedConnections, connection)
-						}
-					}
-					highlights := m.getCanvas().GetHighlightsForBox(boxID)
-					deleteData := DeleteBoxData{Box: box, ID: boxID, Connections: connectedConnections, Highlights: highlights}
-					addData := AddBoxData{X: box.X, Y: box.Y, Text: box.GetText(), ID: box.ID}
-					m.recordAction(ActionDeleteBox, deleteData, addData)
-					m.getCanvas().DeleteBox(boxID)
-					m.ensureCursorInBounds()
-				}
+				m.deleteBoxByID(boxID)
 			} else if textID != -1 {
 				if m.config != nil && m.config.Confirmations {
 					m.mode = ModeConfirm
 					m.confirmAction = ConfirmDeleteText
-					m.confirmTextID = textID
+					m.confirmTextID = m.getCanvas().TextID(textID)
 					return m, nil
 				}
 
-				if textID >= 0 && textID < len(m.getCanvas().Texts()) {
-					text := m.getCanvas().Texts()[textID]
-					highlights := m.getCanvas().GetHighlightsForText(textID)
-					deleteData := DeleteTextData{Text: text, ID: textID, Highlights: highlights}
-					addData := AddTextData{X: text.X, Y: text.Y, Text: text.GetText(), ID: text.ID}
-					m.recordAction(ActionDeleteText, deleteData, addData)
-				}
-				m.getCanvas().DeleteText(textID)
-				m.ensureCursorInBounds()
+				m.deleteTextByID(textID)
 			}
 		}
 		return m, nil
@@ -484,14 +424,10 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.fromStartup = false
 		return m, nil
 	case "o":
-		m.mode = ModeFileInput
-		m.fileOp = FileOpOpen
-		m.filename = ""
-		m.errorMessage = ""
-		m.successMessage = ""
-		m.fromStartup = false
-		m.openInNewBuffer = false
-		m.scanTxtFiles()
+		if !m.confirmDataLoss(ConfirmOpenOverCurrent, m.currentBufferIndex) {
+			return m, nil
+		}
+		m.beginOpen()
 		return m, nil
 	case "O":
 		m.mode = ModeFileInput
@@ -513,39 +449,10 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "x":
 
 		if len(m.buffers) > 0 {
-			if m.config != nil && m.config.Confirmations {
-				m.mode = ModeConfirm
-				m.confirmAction = ConfirmCloseBuffer
+			if !m.confirmDataLoss(ConfirmCloseBuffer, m.currentBufferIndex) {
 				return m, nil
 			}
-
-			if len(m.buffers) > 1 {
-				newIndex := m.currentBufferIndex - 1
-				if newIndex < 0 {
-					newIndex = 0
-				}
-				m.buffers = append(m.buffers[:m.currentBufferIndex], m.buffers[m.currentBufferIndex+1:]...)
-				m.currentBufferIndex = newIndex
-			} else {
-
-				canvas := cv.NewCanvas()
-				m.buffers = []Buffer{
-					{
-						canvas:    canvas,
-						undoStack: []Action{},
-						redoStack: []Action{},
-						filename:  "",
-						panX:      0,
-						panY:      0,
-					},
-				}
-				m.currentBufferIndex = 0
-				m.mode = ModeStartup
-			}
-			m.cursorX = 0
-			m.cursorY = 0
-			m.errorMessage = ""
-			m.successMessage = ""
+			m.closeCurrentBuffer()
 		}
 		return m, nil
 	case "u":
@@ -557,46 +464,24 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.successMessage = ""
 		return m, nil
 	case "c":
-		panX, panY := m.getPanOffset()
-		worldX, worldY := m.cursorX+panX, m.cursorY+panY
-		boxID := m.getCanvas().GetBoxAt(worldX, worldY)
-		if boxID != -1 && boxID < len(m.getCanvas().Boxes()) {
-			box := m.getCanvas().Boxes()[boxID]
-			copiedBox := Box{
-				X:           box.X,
-				Y:           box.Y,
-				Width:       box.Width,
-				Height:      box.Height,
-				ID:          box.ID,
-				Lines:       make([]string, len(box.Lines)),
-				Title:       box.Title,
-				BorderStyle: box.BorderStyle,
-				Color:       box.Color,
-			}
-			copy(copiedBox.Lines, box.Lines)
-			m.clipboard = &copiedBox
-		}
-		return m, nil
+		boxes, texts, conns := m.objectAtCursor()
+		return m, m.copyObjects(boxes, texts, conns, nil)
+	case "X":
+		boxes, texts, conns := m.objectAtCursor()
+		return m, m.cutObjects(boxes, texts, conns, nil)
 	case "p":
-		if m.clipboard != nil {
-			boxID := len(m.getCanvas().Boxes())
-			text := m.clipboard.GetText()
-			panX, panY := m.getPanOffset()
-			worldX, worldY := m.cursorX+panX, m.cursorY+panY
-			m.getCanvas().AddBox(worldX, worldY, text)
-			if boxID < len(m.getCanvas().Boxes()) {
-				m.getCanvas().SetBoxSize(boxID, m.clipboard.Width, m.clipboard.Height)
-
-				m.getCanvas().Boxes()[boxID].Title = m.clipboard.Title
-				m.getCanvas().Boxes()[boxID].BorderStyle = m.clipboard.BorderStyle
-				m.getCanvas().Boxes()[boxID].Color = m.clipboard.Color
-				m.getCanvas().Boxes()[boxID].UpdateSize()
-			}
-			addData := AddBoxData{X: worldX, Y: worldY, Text: text, ID: boxID}
-			deleteData := DeleteBoxData{ID: boxID, Connections: nil, Highlights: nil}
-			m.recordAction(ActionAddBox, addData, deleteData)
-			m.ensureCursorInBounds()
-		}
+		return m, m.pasteClipboard()
+	case "g":
+		m.arrangeBoxes(nil, cv.LayoutTopDown)
+		return m, nil
+	case "G":
+		m.arrangeBoxes(nil, cv.LayoutLeftRight)
+		return m, nil
+	case "y":
+		m.arrangeTree(cv.LayoutTopDown)
+		return m, nil
+	case "Y":
+		m.arrangeTree(cv.LayoutLeftRight)
 		return m, nil
 	case "esc", "escape":
 		m.zPanMode = false
@@ -620,7 +505,7 @@ func (m model) handleNormalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 				oldStyle := m.getCanvas().CycleBorderStyle(boxID)
 				newStyle := m.getCanvas().Boxes()[boxID].BorderStyle
-				borderData := BorderStyleData{BoxID: boxID, OldStyle: oldStyle, NewStyle: newStyle}
+				borderData := BorderStyleData{BoxID: m.getCanvas().BoxID(boxID), OldStyle: oldStyle, NewStyle: newStyle}
 				m.recordAction(ActionChangeBorderStyle, borderData, borderData)
 			}
 		}
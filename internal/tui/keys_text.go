@@ -12,9 +12,9 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case msg.Type == tea.KeyEscape:
 
 		if m.selectedBox != -1 {
-			m.getCanvas().SetBoxText(m.selectedBox, m.originalEditText)
+			m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.originalEditText)
 		} else if m.selectedText != -1 {
-			m.getCanvas().SetTextText(m.selectedText, m.originalEditText)
+			m.getCanvas().SetTextText(m.selectedTextIndex(), m.originalEditText)
 		}
 		m.mode = ModeNormal
 		m.editText = ""
@@ -29,13 +29,15 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case msg.Type == tea.KeyCtrlS:
 		if m.selectedBox != -1 {
 
-			editData := EditBoxData{ID: m.selectedBox, NewText: m.editText, OldText: m.originalEditText}
-			inverseData := EditBoxData{ID: m.selectedBox, NewText: m.originalEditText, OldText: m.editText}
+			id := m.selectedBox
+			editData := EditBoxData{ID: id, NewText: m.editText, OldText: m.originalEditText}
+			inverseData := EditBoxData{ID: id, NewText: m.originalEditText, OldText: m.editText}
 			m.recordAction(ActionEditBox, editData, inverseData)
 		} else if m.selectedText != -1 {
 
-			editData := EditTextData{ID: m.selectedText, NewText: m.editText, OldText: m.originalEditText}
-			inverseData := EditTextData{ID: m.selectedText, NewText: m.originalEditText, OldText: m.editText}
+			id := m.selectedText
+			editData := EditTextData{ID: id, NewText: m.editText, OldText: m.originalEditText}
+			inverseData := EditTextData{ID: id, NewText: m.originalEditText, OldText: m.editText}
 			m.recordAction(ActionEditText, editData, inverseData)
 		}
 		m.mode = ModeNormal
@@ -57,13 +59,13 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		clipText, err := readClipboardText()
 		if err == nil && clipText != "" {
 
-			m.editText = m.editText[:m.editCursorPos] + clipText + m.editText[m.editCursorPos:]
+			m.editText = insertAt(m.editText, m.editCursorPos, clipText)
 			m.editCursorPos += len([]rune(clipText))
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
 		}
 		return m, nil
@@ -76,13 +78,13 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		clipText, err := readClipboardText()
 		if err == nil && clipText != "" {
 
-			m.editText = m.editText[:m.editCursorPos] + clipText + m.editText[m.editCursorPos:]
+			m.editText = insertAt(m.editText, m.editCursorPos, clipText)
 			m.editCursorPos += len([]rune(clipText))
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
 		}
 		return m, nil
@@ -116,7 +118,7 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.editSelectionStart = m.editCursorPos
 			m.editSelectionEnd = m.editCursorPos
 		}
-		if m.editCursorPos < len(m.editText) {
+		if m.editCursorPos < runeLen(m.editText) {
 			m.editCursorPos++
 			m.editSelectionEnd = m.editCursorPos
 		}
@@ -158,7 +160,7 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case msg.String() == "right":
 		m.clearEditSelection()
-		if m.editCursorPos < len(m.editText) {
+		if m.editCursorPos < runeLen(m.editText) {
 			m.editCursorPos++
 		}
 		m.syncCursorPositions()
@@ -187,13 +189,13 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.hasEditSelection() {
 			m.deleteEditSelection()
 		}
-		m.editText = m.editText[:m.editCursorPos] + "\n" + m.editText[m.editCursorPos:]
+		m.editText = insertAt(m.editText, m.editCursorPos, "\n")
 		m.editCursorPos++
 
 		if m.selectedBox != -1 {
-			m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+			m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 		} else if m.selectedText != -1 {
-			m.getCanvas().SetTextText(m.selectedText, m.editText)
+			m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 		}
 		return m, nil
 	case msg.Type == tea.KeyBackspace:
@@ -202,18 +204,18 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.deleteEditSelection()
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
 		} else if m.editCursorPos > 0 {
-			m.editText = m.editText[:m.editCursorPos-1] + m.editText[m.editCursorPos:]
+			m.editText = deleteRange(m.editText, m.editCursorPos-1, m.editCursorPos)
 			m.editCursorPos--
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
 		}
 		return m, nil
@@ -223,17 +225,17 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.deleteEditSelection()
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
-		} else if m.editCursorPos < len(m.editText) {
-			m.editText = m.editText[:m.editCursorPos] + m.editText[m.editCursorPos+1:]
+		} else if m.editCursorPos < runeLen(m.editText) {
+			m.editText = deleteRange(m.editText, m.editCursorPos, m.editCursorPos+1)
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
 		}
 		return m, nil
@@ -243,13 +245,13 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.deleteEditSelection()
 		}
 
-		m.editText = m.editText[:m.editCursorPos] + " " + m.editText[m.editCursorPos:]
+		m.editText = insertAt(m.editText, m.editCursorPos, " ")
 		m.editCursorPos++
 
 		if m.selectedBox != -1 {
-			m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+			m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 		} else if m.selectedText != -1 {
-			m.getCanvas().SetTextText(m.selectedText, m.editText)
+			m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 		}
 		return m, nil
 	default:
@@ -260,13 +262,13 @@ func (m model) handleEditingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.deleteEditSelection()
 			}
 			runeStr := string(msg.Runes)
-			m.editText = m.editText[:m.editCursorPos] + runeStr + m.editText[m.editCursorPos:]
+			m.editText = insertAt(m.editText, m.editCursorPos, runeStr)
 			m.editCursorPos += len(msg.Runes)
 
 			if m.selectedBox != -1 {
-				m.getCanvas().SetBoxText(m.selectedBox, m.editText)
+				m.getCanvas().SetBoxText(m.selectedBoxIndex(), m.editText)
 			} else if m.selectedText != -1 {
-				m.getCanvas().SetTextText(m.selectedText, m.editText)
+				m.getCanvas().SetTextText(m.selectedTextIndex(), m.editText)
 			}
 		}
 		return m, nil
@@ -293,7 +295,7 @@ func (m model) handleTextInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		clipText, err := readClipboardText()
 		if err == nil && clipText != "" {
 
-			m.textInputText = m.textInputText[:m.textInputCursorPos] + clipText + m.textInputText[m.textInputCursorPos:]
+			m.textInputText = insertAt(m.textInputText, m.textInputCursorPos, clipText)
 			m.textInputCursorPos += len([]rune(clipText))
 		}
 		return m, nil
@@ -302,7 +304,7 @@ func (m model) handleTextInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		clipText, err := readClipboardText()
 		if err == nil && clipText != "" {
 
-			m.textInputText = m.textInputText[:m.textInputCursorPos] + clipText + m.textInputText[m.textInputCursorPos:]
+			m.textInputText = insertAt(m.textInputText, m.textInputCursorPos, clipText)
 			m.textInputCursorPos += len([]rune(clipText))
 		}
 		return m, nil
@@ -312,35 +314,35 @@ func (m model) handleTextInputKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case msg.String() == "right":
-		if m.textInputCursorPos < len(m.textInputText) {
+		if m.textInputCursorPos < runeLen(m.textInputText) {
 			m.textInputCursorPos++
 		}
 		return m, nil
 	case msg.Type == tea.KeyEnter:
-		m.textInputText = m.textInputText[:m.textInputCursorPos] + "\n" + m.textInputText[m.textInputCursorPos:]
+		m.textInputText = insertAt(m.textInputText, m.textInputCursorPos, "\n")
 		m.textInputCursorPos++
 		return m, nil
 	case msg.Type == tea.KeyBackspace:
 		if m.textInputCursorPos > 0 {
-			m.textInputText = m.textInputText[:m.textInputCursorPos-1] + m.textInputText[m.textInputCursorPos:]
+			m.textInputText = deleteRange(m.textInputText, m.textInputCursorPos-1, m.textInputCursorPos)
 			m.textInputCursorPos--
 		}
 		return m, nil
 	case msg.Type == tea.KeyDelete:
-		if m.textInputCursorPos < len(m.textInputText) {
-			m.textInputText = m.textInputText[:m.textInputCursorPos] + m.textInputText[m.textInputCursorPos+1:]
+		if m.textInputCursorPos < runeLen(m.textInputText) {
+			m.textInputText = deleteRange(m.textInputText, m.textInputCursorPos, m.textInputCursorPos+1)
 		}
 		return m, nil
 	case msg.Type == tea.KeySpace:
 
-		m.textInputText = m.textInputText[:m.textInputCursorPos] + " " + m.textInputText[m.textInputCursorPos:]
+		m.textInputText = insertAt(m.textInputText, m.textInputCursorPos, " ")
 		m.textInputCursorPos++
 		return m, nil
 	default:
 
 		if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
 			runeStr := string(msg.Runes)
-			m.textInputText = m.textInputText[:m.textInputCursorPos] + runeStr + m.textInputText[m.textInputCursorPos:]
+			m.textInputText = insertAt(m.textInputText, m.textInputCursorPos, runeStr)
 			m.textInputCursorPos += len(msg.Runes)
 		}
 		return m, nil
@@ -391,9 +393,9 @@ func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case msg.Type == tea.KeyEscape:
 
-		if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-			m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.originalTitleText
-			m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+		if i := m.titleEditBoxIndex(); i != -1 {
+			m.getCanvas().Boxes()[i].Title = m.originalTitleText
+			m.getCanvas().Boxes()[i].UpdateSize()
 		}
 		m.mode = ModeNormal
 		m.titleEditText = ""
@@ -404,15 +406,16 @@ func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case msg.Type == tea.KeyCtrlS:
 
-		if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
+		if i := m.titleEditBoxIndex(); i != -1 {
 			oldTitle := m.originalTitleText
 			newTitle := m.titleEditText
 
-			m.getCanvas().Boxes()[m.titleEditBoxID].Title = newTitle
-			m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+			m.getCanvas().Boxes()[i].Title = newTitle
+			m.getCanvas().Boxes()[i].UpdateSize()
 
-			editData := EditTitleData{BoxID: m.titleEditBoxID, NewTitle: newTitle, OldTitle: oldTitle}
-			inverseData := EditTitleData{BoxID: m.titleEditBoxID, NewTitle: oldTitle, OldTitle: newTitle}
+			id := m.titleEditBoxID
+			editData := EditTitleData{BoxID: id, NewTitle: newTitle, OldTitle: oldTitle}
+			inverseData := EditTitleData{BoxID: id, NewTitle: oldTitle, OldTitle: newTitle}
 			m.recordAction(ActionEditTitle, editData, inverseData)
 		}
 		m.mode = ModeNormal
@@ -426,12 +429,12 @@ func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 		clipText, err := readClipboardText()
 		if err == nil && clipText != "" {
-			m.titleEditText = m.titleEditText[:m.titleEditCursorPos] + clipText + m.titleEditText[m.titleEditCursorPos:]
+			m.titleEditText = insertAt(m.titleEditText, m.titleEditCursorPos, clipText)
 			m.titleEditCursorPos += len([]rune(clipText))
 
-			if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-				m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-				m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+			if i := m.titleEditBoxIndex(); i != -1 {
+				m.getCanvas().Boxes()[i].Title = m.titleEditText
+				m.getCanvas().Boxes()[i].UpdateSize()
 			}
 		}
 		return m, nil
@@ -439,12 +442,12 @@ func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 		clipText, err := readClipboardText()
 		if err == nil && clipText != "" {
-			m.titleEditText = m.titleEditText[:m.titleEditCursorPos] + clipText + m.titleEditText[m.titleEditCursorPos:]
+			m.titleEditText = insertAt(m.titleEditText, m.titleEditCursorPos, clipText)
 			m.titleEditCursorPos += len([]rune(clipText))
 
-			if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-				m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-				m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+			if i := m.titleEditBoxIndex(); i != -1 {
+				m.getCanvas().Boxes()[i].Title = m.titleEditText
+				m.getCanvas().Boxes()[i].UpdateSize()
 			}
 		}
 		return m, nil
@@ -455,7 +458,7 @@ func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.titleEditCursorRow, m.titleEditCursorCol = m.linearToCursorPos(m.titleEditCursorPos, m.titleEditText)
 		return m, nil
 	case msg.String() == "right":
-		if m.titleEditCursorPos < len(m.titleEditText) {
+		if m.titleEditCursorPos < runeLen(m.titleEditText) {
 			m.titleEditCursorPos++
 		}
 		m.titleEditCursorRow, m.titleEditCursorCol = m.linearToCursorPos(m.titleEditCursorPos, m.titleEditText)
@@ -478,55 +481,55 @@ func (m model) handleTitleEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case msg.Type == tea.KeyEnter:
-		m.titleEditText = m.titleEditText[:m.titleEditCursorPos] + "\n" + m.titleEditText[m.titleEditCursorPos:]
+		m.titleEditText = insertAt(m.titleEditText, m.titleEditCursorPos, "\n")
 		m.titleEditCursorPos++
 
-		if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-			m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-			m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+		if i := m.titleEditBoxIndex(); i != -1 {
+			m.getCanvas().Boxes()[i].Title = m.titleEditText
+			m.getCanvas().Boxes()[i].UpdateSize()
 		}
 		return m, nil
 	case msg.Type == tea.KeyBackspace:
 		if m.titleEditCursorPos > 0 {
-			m.titleEditText = m.titleEditText[:m.titleEditCursorPos-1] + m.titleEditText[m.titleEditCursorPos:]
+			m.titleEditText = deleteRange(m.titleEditText, m.titleEditCursorPos-1, m.titleEditCursorPos)
 			m.titleEditCursorPos--
 
-			if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-				m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-				m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+			if i := m.titleEditBoxIndex(); i != -1 {
+				m.getCanvas().Boxes()[i].Title = m.titleEditText
+				m.getCanvas().Boxes()[i].UpdateSize()
 			}
 		}
 		return m, nil
 	case msg.Type == tea.KeyDelete:
-		if m.titleEditCursorPos < len(m.titleEditText) {
-			m.titleEditText = m.titleEditText[:m.titleEditCursorPos] + m.titleEditText[m.titleEditCursorPos+1:]
+		if m.titleEditCursorPos < runeLen(m.titleEditText) {
+			m.titleEditText = deleteRange(m.titleEditText, m.titleEditCursorPos, m.titleEditCursorPos+1)
 
-			if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-				m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-				m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+			if i := m.titleEditBoxIndex(); i != -1 {
+				m.getCanvas().Boxes()[i].Title = m.titleEditText
+				m.getCanvas().Boxes()[i].UpdateSize()
 			}
 		}
 		return m, nil
 	case msg.Type == tea.KeySpace:
 
-		m.titleEditText = m.titleEditText[:m.titleEditCursorPos] + " " + m.titleEditText[m.titleEditCursorPos:]
+		m.titleEditText = insertAt(m.titleEditText, m.titleEditCursorPos, " ")
 		m.titleEditCursorPos++
 
-		if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-			m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-			m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+		if i := m.titleEditBoxIndex(); i != -1 {
+			m.getCanvas().Boxes()[i].Title = m.titleEditText
+			m.getCanvas().Boxes()[i].UpdateSize()
 		}
 		return m, nil
 	default:
 
 		if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
 			runeStr := string(msg.Runes)
-			m.titleEditText = m.titleEditText[:m.titleEditCursorPos] + runeStr + m.titleEditText[m.titleEditCursorPos:]
+			m.titleEditText = insertAt(m.titleEditText, m.titleEditCursorPos, runeStr)
 			m.titleEditCursorPos += len([]rune(runeStr))
 
-			if m.titleEditBoxID != -1 && m.titleEditBoxID < len(m.getCanvas().Boxes()) {
-				m.getCanvas().Boxes()[m.titleEditBoxID].Title = m.titleEditText
-				m.getCanvas().Boxes()[m.titleEditBoxID].UpdateSize()
+			if i := m.titleEditBoxIndex(); i != -1 {
+				m.getCanvas().Boxes()[i].Title = m.titleEditText
+				m.getCanvas().Boxes()[i].UpdateSize()
 			}
 		}
 		return m, nil
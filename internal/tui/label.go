@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startLabelEdit edits the label of the line at index connIdx in place; the
+// line shows each keystroke until Enter keeps it or Esc puts it back. The
+// line is kept by ID, so the edit follows it if other lines go meanwhile.
+func (m *model) startLabelEdit(connIdx int) {
+	m.mode = ModeLabelEdit
+	m.labelEditConn = m.getCanvas().ConnectionID(connIdx)
+	m.labelEditText = m.getCanvas().Connections()[connIdx].Label
+	m.originalLabelText = m.labelEditText
+	m.labelEditCursorPos = runeLen(m.labelEditText)
+}
+
+// setLabelSegment moves the label of the line at index connIdx to segment,
+// or to the middle of the line for 0.
+func (m *model) setLabelSegment(connIdx, segment int) {
+	conn := m.getCanvas().Connections()[connIdx]
+	if conn.LabelSegment == segment {
+		return
+	}
+	m.getCanvas().SetConnectionLabelSegment(connIdx, segment)
+	data := LabelData{
+		ConnID:     m.getCanvas().ConnectionID(connIdx),
+		OldLabel:   conn.Label,
+		NewLabel:   conn.Label,
+		OldSegment: conn.LabelSegment,
+		NewSegment: segment,
+	}
+	m.recordAction(ActionEditLabel, data, data)
+}
+
+func (m *model) endLabelEdit() {
+	m.mode = ModeNormal
+	m.labelEditConn = -1
+	m.labelEditText = ""
+	m.labelEditCursorPos = 0
+	m.originalLabelText = ""
+}
+
+// insertLabelText types s at the cursor. A label is one line, so newlines
+// from a paste become spaces.
+func (m *model) insertLabelText(s string) {
+	s = strings.ReplaceAll(s, "\n", " ")
+	m.labelEditText = insertAt(m.labelEditText, m.labelEditCursorPos, s)
+	m.labelEditCursorPos += runeLen(s)
+	m.getCanvas().SetConnectionLabel(m.getCanvas().ConnectionIndex(m.labelEditConn), m.labelEditText)
+}
+
+func (m model) handleLabelEditKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	i := m.getCanvas().ConnectionIndex(m.labelEditConn)
+	if i < 0 {
+		m.endLabelEdit()
+		return m, nil
+	}
+	switch {
+	case msg.Type == tea.KeyEscape:
+		m.getCanvas().SetConnectionLabel(i, m.originalLabelText)
+		m.endLabelEdit()
+	case msg.Type == tea.KeyEnter:
+		if m.labelEditText != m.originalLabelText {
+			segment := m.getCanvas().Connections()[i].LabelSegment
+			data := LabelData{
+				ConnID:     m.labelEditConn,
+				OldLabel:   m.originalLabelText,
+				NewLabel:   m.labelEditText,
+				OldSegment: segment,
+				NewSegment: segment,
+			}
+			m.recordAction(ActionEditLabel, data, data)
+		}
+		m.endLabelEdit()
+	case msg.Type == tea.KeyCtrlV || msg.String() == "ctrl+v":
+		return m, m.readClipboard(0, 0)
+	case msg.Type == tea.KeyLeft:
+		if m.labelEditCursorPos > 0 {
+			m.labelEditCursorPos--
+		}
+	case msg.Type == tea.KeyRight:
+		if m.labelEditCursorPos < runeLen(m.labelEditText) {
+			m.labelEditCursorPos++
+		}
+	case msg.Type == tea.KeyHome:
+		m.labelEditCursorPos = 0
+	case msg.Type == tea.KeyEnd:
+		m.labelEditCursorPos = runeLen(m.labelEditText)
+	case msg.Type == tea.KeyBackspace:
+		if m.labelEditCursorPos > 0 {
+			m.labelEditText = deleteRange(m.labelEditText, m.labelEditCursorPos-1, m.labelEditCursorPos)
+			m.labelEditCursorPos--
+			m.getCanvas().SetConnectionLabel(i, m.labelEditText)
+		}
+	case msg.Type == tea.KeyDelete:
+		if m.labelEditCursorPos < runeLen(m.labelEditText) {
+			m.labelEditText = deleteRange(m.labelEditText, m.labelEditCursorPos, m.labelEditCursorPos+1)
+			m.getCanvas().SetConnectionLabel(i, m.labelEditText)
+		}
+	case msg.Type == tea.KeySpace:
+		m.insertLabelText(" ")
+	case msg.Type == tea.KeyRunes && len(msg.Runes) > 0:
+		m.insertLabelText(string(msg.Runes))
+	}
+	return m, nil
+}
+
+func (m model) labelStatus() string {
+	runes := []rune(m.labelEditText)
+	pos := min(m.labelEditCursorPos, len(runes))
+	cursorDisplay := string(runes[:pos]) + "█"
+	if pos < len(runes) {
+		cursorDisplay += string(runes[pos+1:])
+	}
+	return fmt.Sprintf("Mode: LABEL EDIT | Label: %s | ←/→=move cursor, Enter=save, Esc=cancel", cursorDisplay)
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"fmt"
+
+	cv "flerm/internal/canvas"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lintPanelRows is how many problems the lint panel lists at once.
+const lintPanelRows = 5
+
+// openLintPanel validates the chart and, if anything is wrong, lists the
+// problems and jumps to the first.
+func (m *model) openLintPanel() {
+	m.lintIssues = m.getCanvas().Validate()
+	m.lintIndex = 0
+	if len(m.lintIssues) == 0 {
+		m.mode = ModeNormal
+		m.successMessage = "No problems found"
+		m.errorMessage = ""
+		return
+	}
+	m.mode = ModeLint
+	m.successMessage = ""
+	m.jumpToLintIssue()
+}
+
+// jumpToLintIssue moves the cursor to the selected problem, panning when it
+// is off screen.
+func (m *model) jumpToLintIssue() {
+	buf := m.getCurrentBuffer()
+	if buf == nil || m.lintIndex < 0 || m.lintIndex >= len(m.lintIssues) {
+		return
+	}
+	issue := m.lintIssues[m.lintIndex]
+	screenX, screenY := issue.X-buf.panX, issue.Y-buf.panY
+	if screenX < 0 || screenX >= m.width || screenY < 0 || screenY >= m.height-2 {
+		buf.panX = issue.X - m.width/2
+		buf.panY = issue.Y - m.height/2
+	}
+	m.cursorX = issue.X - buf.panX
+	m.cursorY = issue.Y - buf.panY
+	m.ensureCursorInBounds()
+}
+
+func (m model) handleLintKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "j", "down", "n", "tab":
+		m.lintIndex = (m.lintIndex + 1) % len(m.lintIssues)
+		m.jumpToLintIssue()
+	case "k", "up", "N", "shift+tab":
+		m.lintIndex = (m.lintIndex + len(m.lintIssues) - 1) % len(m.lintIssues)
+		m.jumpToLintIssue()
+	case "r":
+		m.openLintPanel()
+	case "enter", "esc", "escape", "v", "q":
+		m.mode = ModeNormal
+		m.lintIssues = nil
+	}
+	return m, nil
+}
+
+// overlayLintPanel lists the problems around the selected one in a box along
+// the bottom of the canvas, or along the top when the cursor is down there.
+func (m model) overlayLintPanel(r *RenderResult) {
+	if len(m.lintIssues) == 0 || len(r.Canvas) == 0 {
+		return
+	}
+	rows := min(lintPanelRows, len(m.lintIssues))
+	w := len(r.Canvas[0])
+	h := rows + 2
+	y := len(r.Canvas) - h
+	if m.cursorY >= y {
+		y = 0
+	}
+	first := min(max(m.lintIndex-rows/2, 0), len(m.lintIssues)-rows)
+
+	setCell := func(px, py int, ch rune, colorIdx int) {
+		if py < 0 || py >= len(r.Canvas) || px < 0 || px >= len(r.Canvas[py]) {
+			return
+		}
+		r.Canvas[py][px] = ch
+		if py < len(r.ColorMap) && px < len(r.ColorMap[py]) {
+			r.ColorMap[py][px] = colorIdx
+		}
+	}
+	border := func(py int, left, right rune, label string) {
+		setCell(0, py, left, colorMenuBorder)
+		title := cv.TextCells(label)
+		for x := 1; x < w-1; x++ {
+			ch := '─'
+			if x-2 >= 0 && x-2 < len(title) {
+				ch = title[x-2]
+			}
+			setCell(x, py, ch, colorMenuBorder)
+		}
+		setCell(w-1, py, right, colorMenuBorder)
+	}
+
+	border(y, '┌', '┐', fmt.Sprintf(" Problems %d/%d ", m.lintIndex+1, len(m.lintIssues)))
+	for row := 0; row < rows; row++ {
+		i := first + row
+		issue := m.lintIssues[i]
+		py := y + 1 + row
+		rowColor := -1
+		if i == m.lintIndex {
+			rowColor = colorMenuSelect
+		}
+		label := cv.TextCells(fmt.Sprintf(" %-7s %-16s %s", issue.Severity, issue.Rule, issue.Message))
+		setCell(0, py, '│', colorMenuBorder)
+		for x := 1; x < w-1; x++ {
+			ch := ' '
+			if x-1 < len(label) {
+				ch = label[x-1]
+			}
+			setCell(x, py, ch, rowColor)
+		}
+		setCell(w-1, py, '│', colorMenuBorder)
+	}
+	border(y+h-1, '└', '┘', "")
+}
+
+// lintStatus describes the selected problem for the status line.
+func (m model) lintStatus() string {
+	issue := m.lintIssues[m.lintIndex]
+	severity := "Warning"
+	if issue.Severity == cv.SeverityError {
+		severity = "Error"
+	}
+	return fmt.Sprintf("Mode: LINT | %s at (%d,%d): %s | j/k=next/prev, r=recheck, Enter/Esc=close", severity, issue.X, issue.Y, issue.Message)
+}
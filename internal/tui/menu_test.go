@@ -76,3 +76,98 @@ func TestMenuEditTitleEntersMode(t *testing.T) {
 		t.Fatalf("expected titleEditBoxID 0, got %d", m.titleEditBoxID)
 	}
 }
+
+func TestMenuKeepsTargetWhenAnEarlierBoxGoes(t *testing.T) {
+	m := newTestModel()
+	out, _ := m.Update(press(tea.MouseButtonRight, 41, 21))
+	m = out.(model)
+	m.deleteBoxByID(0)
+
+	m.activateMenuItem(MenuEditTitle, 0)
+	if m.mode != ModeTitleEdit || m.titleEditBoxIndex() != 0 {
+		t.Fatalf("expected to edit Beta's title, now box 0, got mode %v box %d", m.mode, m.titleEditBoxIndex())
+	}
+	m.mode = ModeNormal
+	m.activateMenuItem(MenuDeleteBox, 0)
+	if boxes := m.getCanvas().Boxes(); len(boxes) != 0 {
+		t.Fatalf("expected the menu to delete Beta, left %+v", boxes)
+	}
+}
+
+func TestMenuShapeMovesLineEndsAndUndoes(t *testing.T) {
+	m := newTestModel()
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	c.Connections()[0].FromX, c.Connections()[0].FromY = 6, 5 // bottom edge, off center
+	out, _ := m.Update(press(tea.MouseButtonRight, 6, 4))
+	m = out.(model)
+
+	var items []MenuItem
+	for _, label := range []string{"Border", "Shape", "Decision"} {
+		items = m.focusedItems()
+		found := false
+		for i, it := range items {
+			if it.Label == label {
+				m.setFocusedIndex(i)
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no %s item in menu", label)
+		}
+		if label != "Decision" {
+			m.menuDescend()
+		}
+	}
+	item := items[m.focusedIndex()]
+	m.activateMenuItem(item.Action, item.Arg)
+
+	conn := m.getCanvas().Connections()[0]
+	if m.getCanvas().Boxes()[0].Shape != ShapeDiamond || conn.FromX != 9 || conn.FromY != 5 {
+		t.Fatalf("expected a diamond with the line on its bottom tip (9,5), got shape %v at (%d,%d)",
+			m.getCanvas().Boxes()[0].Shape, conn.FromX, conn.FromY)
+	}
+	m.undo()
+	conn = m.getCanvas().Connections()[0]
+	if m.getCanvas().Boxes()[0].Shape != ShapeRectangle || conn.FromX != 6 {
+		t.Fatalf("expected undo to restore the rectangle and the line end, got shape %v at x=%d", m.getCanvas().Boxes()[0].Shape, conn.FromX)
+	}
+	m.redo()
+	if m.getCanvas().Boxes()[0].Shape != ShapeDiamond || m.getCanvas().Connections()[0].FromX != 9 {
+		t.Fatal("expected redo to make the box a diamond again")
+	}
+}
+
+func TestMenuSameShapeRecordsNothing(t *testing.T) {
+	m := newTestModel()
+	out, _ := m.Update(press(tea.MouseButtonRight, 6, 4))
+	m = out.(model)
+	m.activateMenuItem(MenuSetShape, int(ShapeRectangle))
+	if buf := m.getCurrentBuffer(); len(buf.undoStack) != 0 || buf.modified() {
+		t.Fatalf("expected picking the box's own shape to change nothing, got %d undo steps", len(buf.undoStack))
+	}
+}
+
+func TestMenuMovesLabelToSegment(t *testing.T) {
+	m := newTestModel()
+	c := m.getCanvas()
+	c.AddConnection(0, 1)
+	conn := &c.Connections()[0]
+	conn.FromX, conn.FromY, conn.ToX, conn.ToY = 12, 4, 40, 21
+	conn.Waypoints = []point{{X: 30, Y: 4}, {X: 30, Y: 21}}
+	c.SetConnectionLabel(0, "no")
+	out, _ := m.Update(press(tea.MouseButtonRight, 35, 21))
+	m = out.(model)
+	if m.menuTargetConn != 0 {
+		t.Fatalf("expected the menu to target the line, got %d", m.menuTargetConn)
+	}
+
+	m.activateMenuItem(MenuSetLabelSegment, -1)
+	if got := c.Connections()[0].LabelSegment; got != 3 {
+		t.Fatalf("expected the label on the last segment, got %d", got)
+	}
+	m.undo()
+	if got := c.Connections()[0].LabelSegment; got != 0 {
+		t.Fatalf("expected undo to center the label again, got segment %d", got)
+	}
+}
@@ -1,6 +1,8 @@
 package tui
 
 import (
+	cv "flerm/internal/canvas"
+
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -222,14 +224,14 @@ func (m *model) beginBoxDrag(boxID, worldX, worldY int) {
 	}
 	box := canvas.Boxes()[boxID]
 	m.draggingBox = true
-	m.dragBoxID = boxID
+	m.dragBoxID = box.ID
 	m.dragGrabOffsetX = box.X - worldX
 	m.dragGrabOffsetY = box.Y - worldY
 	m.originalMoveX = box.X
 	m.originalMoveY = box.Y
 
 	m.originalBoxConnections = make(map[int][]Connection)
-	m.originalBoxConnections[boxID] = canvas.GetConnectionsForBox(boxID)
+	m.originalBoxConnections[box.ID] = canvas.GetConnectionsForBox(boxID)
 
 	m.dragConnSnapshot = canvas.SnapshotConnections()
 
@@ -243,14 +245,19 @@ func (m *model) beginBoxDrag(boxID, worldX, worldY int) {
 	}
 	m.highlightMoveDelta = point{X: 0, Y: 0}
 
-	m.selBox = boxID
+	m.selBox = box.ID
 	m.selText = -1
 	m.selConn = -1
 }
 
 func (m *model) dragMoveTo(canvasX, canvasY int) {
 	canvas := m.getCanvas()
-	if canvas == nil || m.dragBoxID < 0 || m.dragBoxID >= len(canvas.Boxes()) {
+	if canvas == nil {
+		m.draggingBox = false
+		return
+	}
+	i := canvas.BoxIndex(m.dragBoxID)
+	if i < 0 {
 		m.draggingBox = false
 		return
 	}
@@ -263,12 +270,12 @@ func (m *model) dragMoveTo(canvasX, canvasY int) {
 	if m.dragConnSnapshot != nil {
 		canvas.RestoreConnectionsSnapshot(m.dragConnSnapshot)
 	}
-	canvas.SetBoxPositionOnly(m.dragBoxID, m.originalMoveX, m.originalMoveY)
-	canvas.MoveBox(m.dragBoxID, desiredX-m.originalMoveX, desiredY-m.originalMoveY)
+	canvas.SetBoxPositionOnly(i, m.originalMoveX, m.originalMoveY)
+	canvas.MoveBox(i, desiredX-m.originalMoveX, desiredY-m.originalMoveY)
 
 	if len(m.originalHighlights) > 0 {
-		cumX := canvas.Boxes()[m.dragBoxID].X - m.originalMoveX
-		cumY := canvas.Boxes()[m.dragBoxID].Y - m.originalMoveY
+		cumX := canvas.Boxes()[i].X - m.originalMoveX
+		cumY := canvas.Boxes()[i].Y - m.originalMoveY
 		m.highlightMoveDelta = m.moveHighlightsOnSelectedObjects(cumX, cumY)
 	}
 
@@ -279,23 +286,27 @@ func (m *model) dragMoveTo(canvasX, canvasY int) {
 
 func (m *model) finishBoxDrag() {
 	canvas := m.getCanvas()
-	if canvas != nil && m.dragBoxID >= 0 && m.dragBoxID < len(canvas.Boxes()) {
-		cur := canvas.Boxes()[m.dragBoxID]
+	i := -1
+	if canvas != nil {
+		i = canvas.BoxIndex(m.dragBoxID)
+	}
+	if i >= 0 {
+		cur := canvas.Boxes()[i]
 		deltaX := cur.X - m.originalMoveX
 		deltaY := cur.Y - m.originalMoveY
 		if deltaX != 0 || deltaY != 0 {
-			moveData := MoveBoxData{ID: m.dragBoxID, DeltaX: deltaX, DeltaY: deltaY}
+			moveData := MoveBoxData{ID: cur.ID, DeltaX: deltaX, DeltaY: deltaY}
 			var highlightCells []HighlightCell
 			for origPos, color := range m.originalHighlights {
 				highlightCells = append(highlightCells, HighlightCell{X: origPos.X, Y: origPos.Y, Color: color})
 			}
 			originalState := OriginalBoxState{
-				ID:          m.dragBoxID,
+				ID:          cur.ID,
 				X:           m.originalMoveX,
 				Y:           m.originalMoveY,
 				Width:       cur.Width,
 				Height:      cur.Height,
-				Connections: m.originalBoxConnections[m.dragBoxID],
+				Connections: m.originalBoxConnections[cur.ID],
 				Highlights:  highlightCells,
 			}
 			m.recordAction(ActionMoveBox, moveData, originalState)
@@ -315,19 +326,24 @@ func (m *model) beginTextDrag(textID, worldX, worldY int) {
 	}
 	text := canvas.Texts()[textID]
 	m.draggingText = true
-	m.dragTextID = textID
+	m.dragTextID = text.ID
 	m.dragGrabOffsetX = text.X - worldX
 	m.dragGrabOffsetY = text.Y - worldY
 	m.originalTextMoveX = text.X
 	m.originalTextMoveY = text.Y
-	m.selText = textID
+	m.selText = text.ID
 	m.selBox = -1
 	m.selConn = -1
 }
 
 func (m *model) dragTextMoveTo(canvasX, canvasY int) {
 	canvas := m.getCanvas()
-	if canvas == nil || m.dragTextID < 0 || m.dragTextID >= len(canvas.Texts()) {
+	if canvas == nil {
+		m.draggingText = false
+		return
+	}
+	i := canvas.TextIndex(m.dragTextID)
+	if i < 0 {
 		m.draggingText = false
 		return
 	}
@@ -335,8 +351,8 @@ func (m *model) dragTextMoveTo(canvasX, canvasY int) {
 	worldX, worldY := canvasX+panX, canvasY+panY
 	desiredX := worldX + m.dragGrabOffsetX
 	desiredY := worldY + m.dragGrabOffsetY
-	cur := canvas.Texts()[m.dragTextID]
-	canvas.MoveText(m.dragTextID, desiredX-cur.X, desiredY-cur.Y)
+	cur := canvas.Texts()[i]
+	canvas.MoveText(i, desiredX-cur.X, desiredY-cur.Y)
 	m.cursorX = canvasX
 	m.cursorY = canvasY
 	m.ensureCursorInBounds()
@@ -344,13 +360,17 @@ func (m *model) dragTextMoveTo(canvasX, canvasY int) {
 
 func (m *model) finishTextDrag() {
 	canvas := m.getCanvas()
-	if canvas != nil && m.dragTextID >= 0 && m.dragTextID < len(canvas.Texts()) {
-		cur := canvas.Texts()[m.dragTextID]
+	i := -1
+	if canvas != nil {
+		i = canvas.TextIndex(m.dragTextID)
+	}
+	if i >= 0 {
+		cur := canvas.Texts()[i]
 		deltaX := cur.X - m.originalTextMoveX
 		deltaY := cur.Y - m.originalTextMoveY
 		if deltaX != 0 || deltaY != 0 {
-			moveData := MoveTextData{ID: m.dragTextID, DeltaX: deltaX, DeltaY: deltaY}
-			originalState := OriginalTextState{ID: m.dragTextID, X: m.originalTextMoveX, Y: m.originalTextMoveY}
+			moveData := MoveTextData{ID: cur.ID, DeltaX: deltaX, DeltaY: deltaY}
+			originalState := OriginalTextState{ID: cur.ID, X: m.originalTextMoveX, Y: m.originalTextMoveY}
 			m.recordAction(ActionMoveText, moveData, originalState)
 		}
 	}
@@ -433,18 +453,37 @@ func (m *model) selectAtMouse(worldX, worldY int) {
 		return
 	}
 	if boxID := canvas.GetBoxAt(worldX, worldY); boxID != -1 {
-		m.selBox = boxID
+		m.selBox = canvas.BoxID(boxID)
 		return
 	}
 	if textID := canvas.GetTextAt(worldX, worldY); textID != -1 {
-		m.selText = textID
+		m.selText = canvas.TextID(textID)
 		return
 	}
 	if connIdx, _, _ := canvas.FindNearestPointOnConnection(worldX, worldY); connIdx != -1 {
-		m.selConn = connIdx
+		m.selConn = canvas.ConnectionID(connIdx)
 	}
 }
 
+// addDrawnConnection adds the line being drawn from m.connectionFrom, ending
+// at (toX, toY) on the box at index to or, when to is -1, on another line.
+func (m *model) addDrawnConnection(to, toX, toY int) {
+	canvas := m.getCanvas()
+	connIdx := canvas.AddConnectionWithWaypoints(m.connectionFrom, to, m.connectionFromX, m.connectionFromY, toX, toY, m.connectionWaypoints)
+	if connIdx < 0 {
+		return
+	}
+	// A line drawn without bends is routed around the boxes in its way;
+	// bends the user placed are kept as drawn.
+	if len(m.connectionWaypoints) == 0 {
+		canvas.RouteConnection(connIdx)
+	}
+	connection := canvas.Connections()[connIdx].Clone()
+	connData := AddConnectionData{FromID: connection.FromID, ToID: connection.ToID, Connection: connection}
+	m.recordAction(ActionAddConnection, connData, connData)
+	m.relayoutTree()
+}
+
 func (m *model) cancelMouseLine() {
 	m.mouseLineDrawing = false
 	m.connectionFrom = -1
@@ -468,35 +507,11 @@ func (m *model) completeMouseLine() {
 	if boxID != -1 {
 		toBox := canvas.Boxes()[boxID]
 		toX, toY := canvas.FindNearestEdgePoint(toBox, worldX, worldY)
-		connection := Connection{
-			FromID:    m.connectionFrom,
-			ToID:      boxID,
-			FromX:     m.connectionFromX,
-			FromY:     m.connectionFromY,
-			ToX:       toX,
-			ToY:       toY,
-			Waypoints: m.connectionWaypoints,
-			Color:     -1,
-		}
-		canvas.AddConnectionWithWaypoints(m.connectionFrom, boxID, m.connectionFromX, m.connectionFromY, toX, toY, m.connectionWaypoints)
-		connData := AddConnectionData{FromID: m.connectionFrom, ToID: boxID, Connection: connection}
-		m.recordAction(ActionAddConnection, connData, connData)
+		m.addDrawnConnection(boxID, toX, toY)
 		m.cancelMouseLine()
 		m.successMessage = ""
 	} else if lineConnIdx != -1 {
-		connection := Connection{
-			FromID:    m.connectionFrom,
-			ToID:      -1,
-			FromX:     m.connectionFromX,
-			FromY:     m.connectionFromY,
-			ToX:       lineX,
-			ToY:       lineY,
-			Waypoints: m.connectionWaypoints,
-			Color:     -1,
-		}
-		canvas.AddConnectionWithWaypoints(m.connectionFrom, -1, m.connectionFromX, m.connectionFromY, lineX, lineY, m.connectionWaypoints)
-		connData := AddConnectionData{FromID: m.connectionFrom, ToID: -1, Connection: connection}
-		m.recordAction(ActionAddConnection, connData, connData)
+		m.addDrawnConnection(-1, lineX, lineY)
 		m.cancelMouseLine()
 		m.successMessage = ""
 	} else {
@@ -514,17 +529,17 @@ func (m *model) openContextMenu(canvasX, canvasY int) {
 	worldX, worldY := canvasX+panX, canvasY+panY
 
 	m.menuWorldX, m.menuWorldY = worldX, worldY
-	m.menuTargetBox, m.menuTargetText, m.menuTargetConn = -1, -1, -1
 
-	m.menuTargetBox = canvas.GetBoxAt(worldX, worldY)
-	if m.menuTargetBox == -1 {
-		m.menuTargetText = canvas.GetTextAt(worldX, worldY)
-		if m.menuTargetText == -1 {
-			m.menuTargetConn, _, _ = canvas.FindNearestPointOnConnection(worldX, worldY)
+	box, text, conn := canvas.GetBoxAt(worldX, worldY), -1, -1
+	if box == -1 {
+		text = canvas.GetTextAt(worldX, worldY)
+		if text == -1 {
+			conn, _, _ = canvas.FindNearestPointOnConnection(worldX, worldY)
 		}
 	}
+	m.menuTargetBox, m.menuTargetText, m.menuTargetConn = canvas.BoxID(box), canvas.TextID(text), canvas.ConnectionID(conn)
 
-	m.menuItems = buildMenuItems(m.menuTargetBox, m.menuTargetText, m.menuTargetConn)
+	m.menuItems = buildMenuItems(box, text, conn)
 	m.menuIndex = firstSelectableMenuIndex(m.menuItems)
 	m.menuStack = nil
 	m.menuX = canvasX
@@ -550,6 +565,27 @@ func borderStyleSubmenu() []MenuItem {
 	}
 }
 
+func shapeSubmenu() []MenuItem {
+	return []MenuItem{
+		{Label: "Process", Action: MenuSetShape, Arg: int(ShapeRectangle)},
+		{Label: "Decision", Action: MenuSetShape, Arg: int(ShapeDiamond)},
+		{Label: "Start/End", Action: MenuSetShape, Arg: int(ShapeTerminator)},
+		{Label: "Input/Output", Action: MenuSetShape, Arg: int(ShapeParallelogram)},
+		{Label: "Database", Action: MenuSetShape, Arg: int(ShapeDatabase)},
+		{Label: "Document", Action: MenuSetShape, Arg: int(ShapeDocument)},
+	}
+}
+
+// labelSubmenu places a line's label: Move Here puts it on the segment
+// nearest where the menu was opened, Center in the middle of the line.
+func labelSubmenu() []MenuItem {
+	return []MenuItem{
+		{Label: "Edit", Action: MenuEditLabel},
+		{Label: "Move Here", Action: MenuSetLabelSegment, Arg: -1},
+		{Label: "Center", Action: MenuSetLabelSegment, Arg: 0},
+	}
+}
+
 func buildMenuItems(box, text, conn int) []MenuItem {
 	var items []MenuItem
 	switch {
@@ -559,6 +595,7 @@ func buildMenuItems(box, text, conn int) []MenuItem {
 			MenuItem{Label: "Edit Title", Action: MenuEditTitle},
 			MenuItem{Label: "Border", Action: MenuSubmenu, Submenu: []MenuItem{
 				{Label: "Style", Action: MenuSubmenu, Submenu: borderStyleSubmenu()},
+				{Label: "Shape", Action: MenuSubmenu, Submenu: shapeSubmenu()},
 				{Label: "Color", Action: MenuSubmenu, Submenu: colorSubmenu()},
 			}},
 			MenuItem{Label: "New Line", Action: MenuNewLine},
@@ -575,6 +612,7 @@ func buildMenuItems(box, text, conn int) []MenuItem {
 	case conn != -1:
 		items = append(items,
 			MenuItem{Label: "New Line", Action: MenuNewLine},
+			MenuItem{Label: "Label", Action: MenuSubmenu, Submenu: labelSubmenu()},
 			MenuItem{Label: "Color", Action: MenuSubmenu, Submenu: colorSubmenu()},
 			MenuItem{Label: "Delete Line", Action: MenuDeleteLine},
 			MenuItem{Separator: true},
@@ -784,8 +822,8 @@ func menuInnerWidth(items []MenuItem) int {
 		if item.Separator {
 			continue
 		}
-		if len(item.Label) > maxLabel {
-			maxLabel = len(item.Label)
+		if w := cv.StringWidth(item.Label); w > maxLabel {
+			maxLabel = w
 		}
 		if len(item.Submenu) > 0 {
 			hasSubmenu = true
@@ -805,6 +843,9 @@ func (m *model) activateMenuItem(action MenuAction, arg int) tea.Cmd {
 		m.closeContextMenu()
 		return nil
 	}
+	box := canvas.BoxIndex(m.menuTargetBox)
+	text := canvas.TextIndex(m.menuTargetText)
+	conn := canvas.ConnectionIndex(m.menuTargetConn)
 
 	m.menuStack = nil
 
@@ -813,8 +854,8 @@ func (m *model) activateMenuItem(action MenuAction, arg int) tea.Cmd {
 
 		return nil
 	case MenuNewBox:
-		boxID := len(canvas.Boxes())
 		canvas.AddBox(m.menuWorldX, m.menuWorldY, "Box")
+		boxID := canvas.BoxID(len(canvas.Boxes()) - 1)
 		addData := AddBoxData{X: m.menuWorldX, Y: m.menuWorldY, Text: "Box", ID: boxID}
 		deleteData := DeleteBoxData{ID: boxID, Connections: nil, Highlights: nil}
 		m.recordAction(ActionAddBox, addData, deleteData)
@@ -830,13 +871,13 @@ func (m *model) activateMenuItem(action MenuAction, arg int) tea.Cmd {
 		m.menuItems = nil
 
 	case MenuEditBox:
-		if m.menuTargetBox >= 0 && m.menuTargetBox < len(canvas.Boxes()) {
+		if box >= 0 {
 			m.selectedBox = m.menuTargetBox
 			m.selectedText = -1
 			m.mode = ModeEditing
-			m.editText = canvas.GetBoxText(m.menuTargetBox)
+			m.editText = canvas.GetBoxText(box)
 			m.originalEditText = m.editText
-			m.editCursorPos = len(m.editText)
+			m.editCursorPos = runeLen(m.editText)
 			m.editSelectionStart = -1
 			m.editSelectionEnd = -1
 			m.syncCursorPositions()
@@ -846,13 +887,13 @@ func (m *model) activateMenuItem(action MenuAction, arg int) tea.Cmd {
 		m.menuItems = nil
 
 	case MenuEditText:
-		if m.menuTargetText >= 0 && m.menuTargetText < len(canvas.Texts()) {
+		if text >= 0 {
 			m.selectedText = m.menuTargetText
 			m.selectedBox = -1
 			m.mode = ModeEditing
-			m.editText = canvas.GetTextText(m.menuTargetText)
+			m.editText = canvas.GetTextText(text)
 			m.originalEditText = m.editText
-			m.editCursorPos = len(m.editText)
+			m.editCursorPos = runeLen(m.editText)
 			m.editSelectionStart = -1
 			m.editSelectionEnd = -1
 			m.syncCursorPositions()
@@ -862,19 +903,19 @@ func (m *model) activateMenuItem(action MenuAction, arg int) tea.Cmd {
 		m.menuItems = nil
 
 	case MenuNewLine:
-		if m.menuTargetBox >= 0 && m.menuTargetBox < len(canvas.Boxes()) {
-			fromBox := canvas.Boxes()[m.menuTargetBox]
-			m.connectionFrom = m.menuTargetBox
+		if box >= 0 {
+			fromBox := canvas.Boxes()[box]
+			m.connectionFrom = box
 			m.connectionFromLine = -1
 			m.connectionFromX, m.connectionFromY = canvas.FindNearestEdgePoint(fromBox, m.menuWorldX, m.menuWorldY)
 			m.connectionWaypoints = nil
 			m.mouseLineDrawing = true
 			m.cursorX, m.cursorY = m.menuX, m.menuY
 			m.ensureCursorInBounds()
-		} else if m.menuTargetConn >= 0 && m.menuTargetConn < len(canvas.Connections()) {
+		} else if conn >= 0 {
 			_, px, py := canvas.FindNearestPointOnConnection(m.menuWorldX, m.menuWorldY)
 			m.connectionFrom = -1
-			m.connectionFromLine = m.menuTargetConn
+			m.connectionFromLine = conn
 			m.connectionFromX, m.connectionFromY = px, py
 			m.connectionWaypoints = nil
 			m.mouseLineDrawing = true
@@ -885,46 +926,82 @@ func (m *model) activateMenuItem(action MenuAction, arg int) tea.Cmd {
 		m.menuItems = nil
 
 	case MenuDeleteBox:
-		m.deleteBoxByID(m.menuTargetBox)
+		m.deleteBoxByID(box)
 		m.selBox, m.selText, m.selConn = -1, -1, -1
 		m.mode = ModeNormal
 		m.menuItems = nil
 
 	case MenuDeleteText:
-		m.deleteTextByID(m.menuTargetText)
+		m.deleteTextByID(text)
 		m.selBox, m.selText, m.selConn = -1, -1, -1
 		m.mode = ModeNormal
 		m.menuItems = nil
 
 	case MenuDeleteLine:
-		m.deleteConnByIdx(m.menuTargetConn)
+		m.deleteConnByIdx(conn)
 		m.selBox, m.selText, m.selConn = -1, -1, -1
 		m.mode = ModeNormal
 		m.menuItems = nil
 
 	case MenuEditTitle:
-		if m.menuTargetBox >= 0 && m.menuTargetBox < len(canvas.Boxes()) {
+		if box >= 0 {
 			m.mode = ModeTitleEdit
 			m.titleEditBoxID = m.menuTargetBox
-			m.titleEditText = canvas.Boxes()[m.menuTargetBox].Title
+			m.titleEditText = canvas.Boxes()[box].Title
 			m.originalTitleText = m.titleEditText
-			m.titleEditCursorPos = len(m.titleEditText)
+			m.titleEditCursorPos = runeLen(m.titleEditText)
 		} else {
 			m.mode = ModeNormal
 		}
 		m.menuItems = nil
 
 	case MenuSetBorderStyle:
-		if m.menuTargetBox >= 0 && m.menuTargetBox < len(canvas.Boxes()) {
-			oldStyle := canvas.Boxes()[m.menuTargetBox].BorderStyle
+		if box >= 0 {
+			oldStyle := canvas.Boxes()[box].BorderStyle
 			newStyle := BorderStyle(arg)
-			canvas.SetBorderStyle(m.menuTargetBox, newStyle)
-			borderData := BorderStyleData{BoxID: m.menuTargetBox, OldStyle: oldStyle, NewStyle: newStyle}
+			canvas.SetBorderStyle(box, newStyle)
+			borderData := BorderStyleData{BoxID: canvas.BoxID(box), OldStyle: oldStyle, NewStyle: newStyle}
 			m.recordAction(ActionChangeBorderStyle, borderData, borderData)
 		}
 		m.mode = ModeNormal
 		m.menuItems = nil
 
+	case MenuSetShape:
+		if box >= 0 && canvas.Boxes()[box].Shape != Shape(arg) {
+			oldShape := canvas.Boxes()[box].Shape
+			oldConnections := canvas.SnapshotConnections()
+			canvas.SetBoxShape(box, Shape(arg))
+			shapeData := ShapeData{
+				BoxID:          canvas.BoxID(box),
+				OldShape:       oldShape,
+				NewShape:       Shape(arg),
+				OldConnections: oldConnections,
+				NewConnections: canvas.SnapshotConnections(),
+			}
+			m.recordAction(ActionChangeShape, shapeData, shapeData)
+		}
+		m.mode = ModeNormal
+		m.menuItems = nil
+
+	case MenuEditLabel:
+		if conn >= 0 {
+			m.startLabelEdit(conn)
+		} else {
+			m.mode = ModeNormal
+		}
+		m.menuItems = nil
+
+	case MenuSetLabelSegment:
+		if conn >= 0 {
+			segment := arg
+			if segment < 0 {
+				segment = canvas.ConnectionSegmentAt(conn, m.menuWorldX, m.menuWorldY)
+			}
+			m.setLabelSegment(conn, segment)
+		}
+		m.mode = ModeNormal
+		m.menuItems = nil
+
 	case MenuSetColor:
 		m.applyMenuColor(arg)
 		m.mode = ModeNormal
@@ -939,20 +1016,23 @@ func (m *model) applyMenuColor(color int) {
 	if canvas == nil {
 		return
 	}
+	box := canvas.BoxIndex(m.menuTargetBox)
+	text := canvas.TextIndex(m.menuTargetText)
+	conn := canvas.ConnectionIndex(m.menuTargetConn)
 	var kind, id, old int
 	switch {
-	case m.menuTargetBox >= 0 && m.menuTargetBox < len(canvas.Boxes()):
-		kind, id = ColorKindBox, m.menuTargetBox
-		old = canvas.Boxes()[id].Color
-		canvas.SetBoxColor(id, color)
-	case m.menuTargetConn >= 0 && m.menuTargetConn < len(canvas.Connections()):
-		kind, id = ColorKindLine, m.menuTargetConn
-		old = canvas.Connections()[id].Color
-		canvas.SetLineColor(id, color)
-	case m.menuTargetText >= 0 && m.menuTargetText < len(canvas.Texts()):
-		kind, id = ColorKindText, m.menuTargetText
-		old = canvas.Texts()[id].Color
-		canvas.SetTextColor(id, color)
+	case box >= 0:
+		kind, id = ColorKindBox, canvas.BoxID(box)
+		old = canvas.Boxes()[box].Color
+		canvas.SetBoxColor(box, color)
+	case conn >= 0:
+		kind, id = ColorKindLine, canvas.ConnectionID(conn)
+		old = canvas.Connections()[conn].Color
+		canvas.SetLineColor(conn, color)
+	case text >= 0:
+		kind, id = ColorKindText, canvas.TextID(text)
+		old = canvas.Texts()[text].Color
+		canvas.SetTextColor(text, color)
 	default:
 		return
 	}
@@ -970,12 +1050,12 @@ func (m *model) deleteBoxByID(boxID int) {
 	box := canvas.Boxes()[boxID]
 	connectedConnections := make([]Connection, 0)
 	for _, connection := range canvas.Connections() {
-		if connection.FromID == boxID || connection.ToID == boxID {
-			connectedConnections = append(connectedConnections, connection)
+		if connection.FromID == box.ID || connection.ToID == box.ID {
+			connectedConnections = append(connectedConnections, connection.Clone())
 		}
 	}
 	highlights := canvas.GetHighlightsForBox(boxID)
-	deleteData := DeleteBoxData{Box: box, ID: boxID, Connections: connectedConnections, Highlights: highlights}
+	deleteData := DeleteBoxData{Box: box, ID: box.ID, Index: boxID, Connections: connectedConnections, Highlights: highlights}
 	addData := AddBoxData{X: box.X, Y: box.Y, Text: box.GetText(), ID: box.ID}
 	m.recordAction(ActionDeleteBox, deleteData, addData)
 	canvas.DeleteBox(boxID)
@@ -989,7 +1069,7 @@ func (m *model) deleteTextByID(textID int) {
 	}
 	text := canvas.Texts()[textID]
 	highlights := canvas.GetHighlightsForText(textID)
-	deleteData := DeleteTextData{Text: text, ID: textID, Highlights: highlights}
+	deleteData := DeleteTextData{Text: text, ID: text.ID, Index: textID, Highlights: highlights}
 	addData := AddTextData{X: text.X, Y: text.Y, Text: text.GetText(), ID: text.ID}
 	m.recordAction(ActionDeleteText, deleteData, addData)
 	canvas.DeleteText(textID)
@@ -1001,7 +1081,7 @@ func (m *model) deleteConnByIdx(connIdx int) {
 	if canvas == nil || connIdx < 0 || connIdx >= len(canvas.Connections()) {
 		return
 	}
-	conn := canvas.Connections()[connIdx]
+	conn := canvas.Connections()[connIdx].Clone()
 	deleteData := AddConnectionData{FromID: conn.FromID, ToID: conn.ToID, Connection: conn}
 	canvas.RemoveSpecificConnection(conn)
 	m.recordAction(ActionDeleteConnection, deleteData, deleteData)
@@ -1014,27 +1094,27 @@ func (m model) overlaySelection(r *RenderResult, panX, panY int) {
 	}
 	var cells []point
 	switch {
-	case m.selBox >= 0 && m.selBox < len(canvas.Boxes()):
-		cells = canvas.GetBoxBorderCells(m.selBox)
-	case m.selText >= 0 && m.selText < len(canvas.Texts()):
-		cells = canvas.GetTextCells(m.selText)
-	case m.selConn >= 0 && m.selConn < len(canvas.Connections()):
-		cells = canvas.GetConnectionCells(m.selConn)
+	case canvas.BoxIndex(m.selBox) >= 0:
+		cells = canvas.GetBoxBorderCells(canvas.BoxIndex(m.selBox))
+	case canvas.TextIndex(m.selText) >= 0:
+		cells = canvas.GetTextCells(canvas.TextIndex(m.selText))
+	case canvas.ConnectionIndex(m.selConn) >= 0:
+		cells = canvas.GetConnectionCells(canvas.ConnectionIndex(m.selConn))
 	}
 
 	for _, id := range m.selectedBoxes {
-		if id >= 0 && id < len(canvas.Boxes()) {
-			cells = append(cells, canvas.GetBoxBorderCells(id)...)
+		if i := canvas.BoxIndex(id); i >= 0 {
+			cells = append(cells, canvas.GetBoxBorderCells(i)...)
 		}
 	}
 	for _, id := range m.selectedTexts {
-		if id >= 0 && id < len(canvas.Texts()) {
-			cells = append(cells, canvas.GetTextCells(id)...)
+		if i := canvas.TextIndex(id); i >= 0 {
+			cells = append(cells, canvas.GetTextCells(i)...)
 		}
 	}
 	for _, id := range m.selectedConnections {
-		if id >= 0 && id < len(canvas.Connections()) {
-			cells = append(cells, canvas.GetConnectionCells(id)...)
+		if i := canvas.ConnectionIndex(id); i >= 0 {
+			cells = append(cells, canvas.GetConnectionCells(i)...)
 		}
 	}
 	for _, cell := range cells {
@@ -1101,7 +1181,7 @@ func (m model) drawMenuLevel(r *RenderResult, level menuLevel) {
 			}
 			setCell(x, py, '│', colorMenuBorder)
 
-			label := []rune(" " + item.Label)
+			label := cv.TextCells(" " + item.Label)
 			for i := 0; i < inner; i++ {
 				ch := ' '
 				if i < len(label) {
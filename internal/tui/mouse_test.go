@@ -1,11 +1,28 @@
 package tui
 
 import (
+	"os"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+// TestMain points the home and cache directories at an empty directory, so
+// initialModel reads no .flermrc and finds no recovery files from the
+// machine the tests run on.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "flerm-test-home")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", dir)
+	os.Setenv("XDG_CACHE_HOME", dir)
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // newTestModel builds a normal-mode model with a known canvas for mouse tests.
 func newTestModel() model {
 	m := initialModel()
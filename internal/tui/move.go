@@ -1,5 +1,7 @@
 package tui
 
+import cv "flerm/internal/canvas"
+
 func (m *model) moveHighlightsOnSelectedObjects(cumulativeDeltaX, cumulativeDeltaY int) point {
 	if len(m.originalHighlights) == 0 {
 		return m.highlightMoveDelta
@@ -24,8 +26,8 @@ func (m *model) moveContainedConnections(deltaX, deltaY int) {
 	}
 
 	selectedConnSet := make(map[int]bool)
-	for _, connIdx := range m.selectedConnections {
-		selectedConnSet[connIdx] = true
+	for _, connID := range m.selectedConnections {
+		selectedConnSet[connID] = true
 	}
 
 	for i := range m.getCanvas().Connections() {
@@ -47,7 +49,7 @@ func (m *model) moveContainedConnections(deltaX, deltaY int) {
 			continue
 		}
 
-		if selectedConnSet[i] {
+		if selectedConnSet[conn.ID] {
 			if conn.FromID == -1 || selectedBoxSet[conn.FromID] {
 				conn.FromX += deltaX
 				conn.FromY += deltaY
@@ -65,19 +67,19 @@ func (m *model) moveContainedConnections(deltaX, deltaY int) {
 }
 
 func (m *model) handleSingleElementMove(deltaX, deltaY int) {
-	if m.selectedBox != -1 {
-		m.getCanvas().MoveBox(m.selectedBox, deltaX, deltaY)
+	if i := m.selectedBoxIndex(); i != -1 {
+		m.getCanvas().MoveBox(i, deltaX, deltaY)
 		if len(m.originalHighlights) > 0 {
-			cumulativeDeltaX := m.getCanvas().Boxes()[m.selectedBox].X - m.originalMoveX
-			cumulativeDeltaY := m.getCanvas().Boxes()[m.selectedBox].Y - m.originalMoveY
+			cumulativeDeltaX := m.getCanvas().Boxes()[i].X - m.originalMoveX
+			cumulativeDeltaY := m.getCanvas().Boxes()[i].Y - m.originalMoveY
 			m.highlightMoveDelta = m.moveHighlightsOnSelectedObjects(cumulativeDeltaX, cumulativeDeltaY)
 		}
 		m.ensureCursorInBounds()
-	} else if m.selectedText != -1 {
-		m.getCanvas().MoveText(m.selectedText, deltaX, deltaY)
+	} else if i := m.selectedTextIndex(); i != -1 {
+		m.getCanvas().MoveText(i, deltaX, deltaY)
 		if len(m.originalHighlights) > 0 {
-			cumulativeDeltaX := m.getCanvas().Texts()[m.selectedText].X - m.originalTextMoveX
-			cumulativeDeltaY := m.getCanvas().Texts()[m.selectedText].Y - m.originalTextMoveY
+			cumulativeDeltaX := m.getCanvas().Texts()[i].X - m.originalTextMoveX
+			cumulativeDeltaY := m.getCanvas().Texts()[i].Y - m.originalTextMoveY
 			m.highlightMoveDelta = m.moveHighlightsOnSelectedObjects(cumulativeDeltaX, cumulativeDeltaY)
 		}
 		m.ensureCursorInBounds()
@@ -108,24 +110,24 @@ func (m *model) finalizeMultiSelect(endX, endY int) {
 	for i, box := range m.getCanvas().Boxes() {
 		boxRight, boxBottom := box.X+box.Width-1, box.Y+box.Height-1
 		if !(boxRight < minX || box.X > maxX || boxBottom < minY || box.Y > maxY) {
-			m.selectedBoxes = append(m.selectedBoxes, i)
-			m.originalBoxPositions[i] = point{X: box.X, Y: box.Y}
-			m.originalBoxConnections[i] = m.getCanvas().GetConnectionsForBox(i)
+			m.selectedBoxes = append(m.selectedBoxes, box.ID)
+			m.originalBoxPositions[box.ID] = point{X: box.X, Y: box.Y}
+			m.originalBoxConnections[box.ID] = m.getCanvas().GetConnectionsForBox(i)
 		}
 	}
-	for i, text := range m.getCanvas().Texts() {
+	for _, text := range m.getCanvas().Texts() {
 		textRight, textBottom := text.X, text.Y
 		for _, line := range text.Lines {
-			if text.X+len(line) > textRight {
-				textRight = text.X + len(line)
+			if text.X+cv.StringWidth(line) > textRight {
+				textRight = text.X + cv.StringWidth(line)
 			}
 		}
 		if len(text.Lines) > 0 {
 			textBottom = text.Y + len(text.Lines) - 1
 		}
 		if !(textRight < minX || text.X > maxX || textBottom < minY || text.Y > maxY) {
-			m.selectedTexts = append(m.selectedTexts, i)
-			m.originalTextPositions[i] = point{X: text.X, Y: text.Y}
+			m.selectedTexts = append(m.selectedTexts, text.ID)
+			m.originalTextPositions[text.ID] = point{X: text.X, Y: text.Y}
 		}
 	}
 	selectedBoxSet := make(map[int]bool)
@@ -162,13 +164,10 @@ func (m *model) finalizeMultiSelect(endX, endY int) {
 		}
 		return totalPoints > 0 && pointsInSelection*2 >= totalPoints
 	}
-	for i, conn := range m.getCanvas().Connections() {
+	for _, conn := range m.getCanvas().Connections() {
 		if shouldSelectConnection(conn) {
-			m.selectedConnections = append(m.selectedConnections, i)
-			connCopy := conn
-			connCopy.Waypoints = make([]point, len(conn.Waypoints))
-			copy(connCopy.Waypoints, conn.Waypoints)
-			m.originalConnections[i] = connCopy
+			m.selectedConnections = append(m.selectedConnections, conn.ID)
+			m.originalConnections[conn.ID] = conn.Clone()
 		}
 	}
 	m.originalHighlights = make(map[point]int)
@@ -194,51 +193,53 @@ func (m *model) finalizeMultiSelect(endX, endY int) {
 
 func (m *model) commitMove() {
 	for _, boxID := range m.selectedBoxes {
-		if boxID < 0 || boxID >= len(m.getCanvas().Boxes()) {
+		i := m.getCanvas().BoxIndex(boxID)
+		if i < 0 {
 			continue
 		}
-		cur := m.getCanvas().Boxes()[boxID]
+		cur := m.getCanvas().Boxes()[i]
 		orig, ok := m.originalBoxPositions[boxID]
 		if ok && (cur.X != orig.X || cur.Y != orig.Y) {
-			moveData := MoveBoxData{ID: boxID, DeltaX: cur.X - orig.X, DeltaY: cur.Y - orig.Y}
+			moveData := MoveBoxData{ID: cur.ID, DeltaX: cur.X - orig.X, DeltaY: cur.Y - orig.Y}
 			originalState := OriginalBoxState{
-				ID: boxID, X: orig.X, Y: orig.Y, Width: cur.Width, Height: cur.Height,
+				ID: cur.ID, X: orig.X, Y: orig.Y, Width: cur.Width, Height: cur.Height,
 				Connections: m.originalBoxConnections[boxID],
 			}
 			m.recordAction(ActionMoveBox, moveData, originalState)
 		}
 	}
 	for _, textID := range m.selectedTexts {
-		if textID < 0 || textID >= len(m.getCanvas().Texts()) {
+		i := m.getCanvas().TextIndex(textID)
+		if i < 0 {
 			continue
 		}
-		cur := m.getCanvas().Texts()[textID]
+		cur := m.getCanvas().Texts()[i]
 		orig, ok := m.originalTextPositions[textID]
 		if ok && (cur.X != orig.X || cur.Y != orig.Y) {
-			moveData := MoveTextData{ID: textID, DeltaX: cur.X - orig.X, DeltaY: cur.Y - orig.Y}
-			m.recordAction(ActionMoveText, moveData, OriginalTextState{ID: textID, X: orig.X, Y: orig.Y})
+			moveData := MoveTextData{ID: cur.ID, DeltaX: cur.X - orig.X, DeltaY: cur.Y - orig.Y}
+			m.recordAction(ActionMoveText, moveData, OriginalTextState{ID: cur.ID, X: orig.X, Y: orig.Y})
 		}
 	}
 
-	if m.selectedBox != -1 && m.selectedBox < len(m.getCanvas().Boxes()) {
-		cur := m.getCanvas().Boxes()[m.selectedBox]
+	if i := m.selectedBoxIndex(); i != -1 {
+		cur := m.getCanvas().Boxes()[i]
 		if cur.X != m.originalMoveX || cur.Y != m.originalMoveY {
-			moveData := MoveBoxData{ID: m.selectedBox, DeltaX: cur.X - m.originalMoveX, DeltaY: cur.Y - m.originalMoveY}
+			moveData := MoveBoxData{ID: cur.ID, DeltaX: cur.X - m.originalMoveX, DeltaY: cur.Y - m.originalMoveY}
 			var highlightCells []HighlightCell
 			for origPos, color := range m.originalHighlights {
 				highlightCells = append(highlightCells, HighlightCell{X: origPos.X, Y: origPos.Y, Color: color})
 			}
 			originalState := OriginalBoxState{
-				ID: m.selectedBox, X: m.originalMoveX, Y: m.originalMoveY, Width: cur.Width, Height: cur.Height,
-				Connections: m.originalBoxConnections[m.selectedBox], Highlights: highlightCells,
+				ID: cur.ID, X: m.originalMoveX, Y: m.originalMoveY, Width: cur.Width, Height: cur.Height,
+				Connections: m.originalBoxConnections[cur.ID], Highlights: highlightCells,
 			}
 			m.recordAction(ActionMoveBox, moveData, originalState)
 		}
-	} else if m.selectedText != -1 && m.selectedText < len(m.getCanvas().Texts()) {
-		cur := m.getCanvas().Texts()[m.selectedText]
+	} else if i := m.selectedTextIndex(); i != -1 {
+		cur := m.getCanvas().Texts()[i]
 		if cur.X != m.originalTextMoveX || cur.Y != m.originalTextMoveY {
-			moveData := MoveTextData{ID: m.selectedText, DeltaX: cur.X - m.originalTextMoveX, DeltaY: cur.Y - m.originalTextMoveY}
-			m.recordAction(ActionMoveText, moveData, OriginalTextState{ID: m.selectedText, X: m.originalTextMoveX, Y: m.originalTextMoveY})
+			moveData := MoveTextData{ID: cur.ID, DeltaX: cur.X - m.originalTextMoveX, DeltaY: cur.Y - m.originalTextMoveY}
+			m.recordAction(ActionMoveText, moveData, OriginalTextState{ID: cur.ID, X: m.originalTextMoveX, Y: m.originalTextMoveY})
 		}
 	}
 	m.mode = ModeNormal
@@ -253,35 +254,36 @@ func (m *model) commitMove() {
 }
 
 func (m *model) handleMultiSelectMove(deltaX, deltaY int) {
+	c := m.getCanvas()
 	for _, boxID := range m.selectedBoxes {
-		m.getCanvas().MoveBoxOnly(boxID, deltaX, deltaY)
+		c.MoveBoxOnly(c.BoxIndex(boxID), deltaX, deltaY)
 	}
 	for _, textID := range m.selectedTexts {
-		m.getCanvas().MoveText(textID, deltaX, deltaY)
+		c.MoveText(c.TextIndex(textID), deltaX, deltaY)
 	}
 	m.moveContainedConnections(deltaX, deltaY)
 	var cumulativeDeltaX, cumulativeDeltaY int
 	if len(m.selectedBoxes) > 0 {
 		boxID := m.selectedBoxes[0]
-		if boxID >= 0 && boxID < len(m.getCanvas().Boxes()) {
+		if i := c.BoxIndex(boxID); i >= 0 {
 			if originalPos, hasOriginal := m.originalBoxPositions[boxID]; hasOriginal {
-				currentBox := m.getCanvas().Boxes()[boxID]
+				currentBox := c.Boxes()[i]
 				cumulativeDeltaX, cumulativeDeltaY = currentBox.X-originalPos.X, currentBox.Y-originalPos.Y
 			}
 		}
 	} else if len(m.selectedTexts) > 0 {
 		textID := m.selectedTexts[0]
-		if textID >= 0 && textID < len(m.getCanvas().Texts()) {
+		if i := c.TextIndex(textID); i >= 0 {
 			if originalPos, hasOriginal := m.originalTextPositions[textID]; hasOriginal {
-				currentText := m.getCanvas().Texts()[textID]
+				currentText := c.Texts()[i]
 				cumulativeDeltaX, cumulativeDeltaY = currentText.X-originalPos.X, currentText.Y-originalPos.Y
 			}
 		}
 	} else if len(m.selectedConnections) > 0 {
-		connIdx := m.selectedConnections[0]
-		if connIdx >= 0 && connIdx < len(m.getCanvas().Connections()) {
-			conn := m.getCanvas().Connections()[connIdx]
-			if originalConn, hasOriginal := m.originalConnections[connIdx]; hasOriginal {
+		connID := m.selectedConnections[0]
+		if i := c.ConnectionIndex(connID); i >= 0 {
+			conn := c.Connections()[i]
+			if originalConn, hasOriginal := m.originalConnections[connID]; hasOriginal {
 				cumulativeDeltaX, cumulativeDeltaY = conn.FromX-originalConn.FromX, conn.FromY-originalConn.FromY
 			}
 		}
@@ -7,6 +7,28 @@ type Buffer struct {
 	filename  string
 	panX      int
 	panY      int
+
+	// savedUndo is the length of undoStack when the chart was last saved or
+	// opened, or -1 once that state can no longer be reached by undoing.
+	savedUndo int
+
+	// diskStamp is the version of filename last loaded or saved, and
+	// diskChanged is set once the file on disk no longer matches it.
+	diskStamp   FileStamp
+	diskChanged bool
+
+	// revision counts the changes made to the buffer; autosave writes it
+	// to recoveryPath whenever it differs from autosavedRevision.
+	revision          int
+	autosavedRevision int
+	recoveryPath      string
+	// treeRoot is the ID of the box last laid out as a tree with y or Y,
+	// growing along treeDir, and hasTree says there is one. With the
+	// treerelayout setting, the tree is laid out again whenever a line is
+	// drawn or pasted.
+	hasTree  bool
+	treeRoot int
+	treeDir  LayoutDirection
 }
 
 type model struct {
@@ -20,7 +42,7 @@ type model struct {
 	mode                   Mode
 	help                   bool
 	helpScroll             int
-	selectedBox            int
+	selectedBox            int // by ID, as are the edit, confirm and menu targets below
 	selectedText           int
 	editText               string
 	editCursorPos          int
@@ -38,16 +60,19 @@ type model struct {
 	fileList               []string
 	selectedFileIndex      int
 	fileOp                 FileOperation
+	exportChoice           exportChoice
 	openInNewBuffer        bool
 	createNewBuffer        bool
 	showingDeleteConfirm   bool
 	confirmAction          ConfirmAction
 	confirmBoxID           int
 	confirmTextID          int
-	confirmConnIdx         int
+	confirmConnID          int
 	confirmHighlightX      int
 	confirmHighlightY      int
 	confirmFileIndex       int
+	salvagePath            string
+	salvageError           string
 	originalMoveX          int
 	originalMoveY          int
 	originalTextMoveX      int
@@ -61,13 +86,13 @@ type model struct {
 	errorMessage           string
 	successMessage         string
 	fromStartup            bool
-	clipboard              *Box
+	clipboard              *Canvas
 	config                 *Config
 	highlightMode          bool
 	selectedColor          int
 	selectionStartX        int
 	selectionStartY        int
-	selectedBoxes          []int
+	selectedBoxes          []int // the multi-selection, by ID, as are the maps below
 	selectedTexts          []int
 	selectedConnections    []int
 	originalBoxPositions   map[int]point
@@ -77,18 +102,26 @@ type model struct {
 	highlightMoveDelta     point
 	originalBoxConnections map[int][]Connection
 	boxJumpInput           string
+	lintIssues             []Issue
+	recovered              []recoveredChart
+	lintIndex              int
 	titleEditBoxID         int
 	titleEditText          string
 	titleEditCursorPos     int
 	titleEditCursorRow     int
 	titleEditCursorCol     int
 	originalTitleText      string
+	labelEditConn          int
+	labelEditText          string
+	labelEditCursorPos     int
+	originalLabelText      string
 	showTooltip            bool
 	tooltipText            string
 	tooltipX               int
 	tooltipY               int
 	tooltipBoxID           int
 
+	// IDs of the object selected with the mouse, kept across edits.
 	selBox  int
 	selText int
 	selConn int
@@ -96,7 +129,7 @@ type model struct {
 	mouseLineDrawing bool
 
 	draggingBox      bool
-	dragBoxID        int
+	dragBoxID        int // by ID, as is dragTextID
 	dragGrabOffsetX  int
 	dragGrabOffsetY  int
 	dragConnSnapshot []Connection
@@ -120,7 +153,7 @@ type model struct {
 	menuIndex      int
 	menuX          int
 	menuY          int
-	menuTargetBox  int
+	menuTargetBox  int // IDs
 	menuTargetText int
 	menuTargetConn int
 	menuWorldX     int
@@ -142,10 +175,22 @@ type menuLevel struct {
 	x, y  int
 }
 
+// Action is an undo record. The ID fields of its data are the stable object
+// IDs (Box.ID, Text.ID, Connection.ID), which are looked up again when the
+// action is undone or redone, so records stay valid after other objects are
+// deleted.
 type Action struct {
 	Type    ActionType
 	Data    interface{}
 	Inverse interface{}
+	// Relayout is the tree layout redone because of the action, if any;
+	// it is undone and redone together with it.
+	Relayout *Relayout
+}
+
+// Relayout holds the arrangements from before and after a tree layout.
+type Relayout struct {
+	Before, After ArrangeData
 }
 
 type AddBoxData struct {
@@ -163,6 +208,7 @@ type AddTextData struct {
 type DeleteBoxData struct {
 	Box         Box
 	ID          int
+	Index       int
 	Connections []Connection
 	Highlights  []HighlightCell
 }
@@ -182,6 +228,7 @@ type EditTextData struct {
 type DeleteTextData struct {
 	Text       Text
 	ID         int
+	Index      int
 	Highlights []HighlightCell
 }
 
@@ -226,7 +273,7 @@ type AddConnectionData struct {
 }
 
 type CycleArrowData struct {
-	ConnIdx int
+	ConnID  int
 	OldConn Connection
 	NewConn Connection
 }
@@ -241,6 +288,25 @@ type BorderStyleData struct {
 	NewStyle BorderStyle
 }
 
+// ShapeData holds every line before and after a box changes shape, since
+// the ends on it move to fit the new outline.
+type ShapeData struct {
+	BoxID          int
+	OldShape       Shape
+	NewShape       Shape
+	OldConnections []Connection
+	NewConnections []Connection
+}
+
+// LabelData is a line's label and where it sits, before and after an edit.
+type LabelData struct {
+	ConnID     int
+	OldLabel   string
+	NewLabel   string
+	OldSegment int
+	NewSegment int
+}
+
 type EditTitleData struct {
 	BoxID    int
 	NewTitle string
@@ -259,3 +325,16 @@ type ColorData struct {
 	OldColor int
 	NewColor int
 }
+
+// ArrangeData is the layout on one side of an arrangement: where each box
+// it moved stood, and a copy of every line.
+type ArrangeData struct {
+	Boxes       []BoxPosition
+	Connections []Connection
+}
+
+type BoxPosition struct {
+	ID int
+	X  int
+	Y  int
+}
@@ -9,102 +9,125 @@ func (m *model) undo() {
 	lastIndex := len(buf.undoStack) - 1
 	action := buf.undoStack[lastIndex]
 	buf.undoStack = buf.undoStack[:lastIndex]
+	buf.revision++
 
+	c := m.getCanvas()
+	if action.Relayout != nil {
+		applyArrangement(c, action.Relayout.Before)
+	}
 	switch action.Type {
 	case ActionAddBox:
 		data := action.Inverse.(DeleteBoxData)
-		m.getCanvas().DeleteBox(data.ID)
+		c.DeleteBox(c.BoxIndex(data.ID))
 	case ActionDeleteBox:
-		data := action.Inverse.(AddBoxData)
-		m.getCanvas().AddBoxWithID(data.X, data.Y, data.Text, data.ID)
-		inverse := action.Data.(DeleteBoxData)
-		for _, connection := range inverse.Connections {
-			m.getCanvas().RestoreConnection(connection)
+		data := action.Data.(DeleteBoxData)
+		c.InsertBox(data.Index, data.Box)
+		for _, connection := range data.Connections {
+			c.RestoreConnection(connection.Clone())
 		}
-		for _, highlight := range inverse.Highlights {
-			m.getCanvas().SetHighlight(highlight.X, highlight.Y, highlight.Color)
+		for _, highlight := range data.Highlights {
+			c.SetHighlight(highlight.X, highlight.Y, highlight.Color)
 		}
 	case ActionEditBox:
 		data := action.Inverse.(EditBoxData)
-		m.getCanvas().SetBoxText(data.ID, data.NewText)
+		c.SetBoxText(c.BoxIndex(data.ID), data.NewText)
 	case ActionEditText:
 		data := action.Inverse.(EditTextData)
-		m.getCanvas().SetTextText(data.ID, data.NewText)
+		c.SetTextText(c.TextIndex(data.ID), data.NewText)
 	case ActionDeleteText:
-		data := action.Inverse.(AddTextData)
-		m.getCanvas().AddTextWithID(data.X, data.Y, data.Text, data.ID)
-		inverse := action.Data.(DeleteTextData)
-		for _, highlight := range inverse.Highlights {
-			m.getCanvas().SetHighlight(highlight.X, highlight.Y, highlight.Color)
+		data := action.Data.(DeleteTextData)
+		c.InsertText(data.Index, data.Text)
+		for _, highlight := range data.Highlights {
+			c.SetHighlight(highlight.X, highlight.Y, highlight.Color)
 		}
 	case ActionResizeBox:
 		data := action.Inverse.(OriginalBoxState)
-		m.getCanvas().SetBoxSize(data.ID, data.Width, data.Height)
+		c.SetBoxSize(c.BoxIndex(data.ID), data.Width, data.Height)
 	case ActionMoveBox:
 		data := action.Inverse.(OriginalBoxState)
 		moveData := action.Data.(MoveBoxData)
 
 		for _, highlight := range data.Highlights {
-			m.getCanvas().ClearHighlight(highlight.X+moveData.DeltaX, highlight.Y+moveData.DeltaY)
+			c.ClearHighlight(highlight.X+moveData.DeltaX, highlight.Y+moveData.DeltaY)
 		}
 
-		m.getCanvas().SetBoxPositionOnly(data.ID, data.X, data.Y)
+		c.SetBoxPositionOnly(c.BoxIndex(data.ID), data.X, data.Y)
 
 		if len(data.Connections) > 0 {
-			m.getCanvas().RestoreConnections(data.Connections)
+			c.RestoreConnections(data.Connections)
 		}
 
 		for _, highlight := range data.Highlights {
-			m.getCanvas().SetHighlight(highlight.X, highlight.Y, highlight.Color)
+			c.SetHighlight(highlight.X, highlight.Y, highlight.Color)
 		}
 	case ActionMoveText:
 		data := action.Inverse.(OriginalTextState)
-		m.getCanvas().SetTextPosition(data.ID, data.X, data.Y)
+		c.SetTextPosition(c.TextIndex(data.ID), data.X, data.Y)
 	case ActionAddConnection:
 		data := action.Inverse.(AddConnectionData)
-		m.getCanvas().RemoveSpecificConnection(data.Connection)
+		c.RemoveSpecificConnection(data.Connection)
 	case ActionDeleteConnection:
 		data := action.Inverse.(AddConnectionData)
-		m.getCanvas().RestoreConnection(data.Connection)
+		c.RestoreConnection(data.Connection.Clone())
 	case ActionCycleArrow:
 		cycleData := action.Inverse.(CycleArrowData)
-		if cycleData.ConnIdx >= 0 && cycleData.ConnIdx < len(m.getCanvas().Connections()) {
-			m.getCanvas().Connections()[cycleData.ConnIdx] = cycleData.OldConn
+		if i := c.ConnectionIndex(cycleData.ConnID); i >= 0 {
+			c.Connections()[i] = cycleData.OldConn.Clone()
 		}
 	case ActionHighlight:
 		data := action.Inverse.(HighlightData)
 		for _, cell := range data.Cells {
 			if cell.Color >= 0 {
-				m.getCanvas().SetHighlight(cell.X, cell.Y, cell.Color)
+				c.SetHighlight(cell.X, cell.Y, cell.Color)
 			} else {
-				m.getCanvas().ClearHighlight(cell.X, cell.Y)
+				c.ClearHighlight(cell.X, cell.Y)
 			}
 		}
 	case ActionChangeBorderStyle:
 		data := action.Inverse.(BorderStyleData)
-		m.getCanvas().SetBorderStyle(data.BoxID, data.OldStyle)
+		c.SetBorderStyle(c.BoxIndex(data.BoxID), data.OldStyle)
+	case ActionChangeShape:
+		data := action.Inverse.(ShapeData)
+		if i := c.BoxIndex(data.BoxID); i >= 0 {
+			c.Boxes()[i].Shape = data.OldShape
+		}
+		c.RestoreConnections(data.OldConnections)
+	case ActionEditLabel:
+		data := action.Inverse.(LabelData)
+		if i := c.ConnectionIndex(data.ConnID); i >= 0 {
+			c.SetConnectionLabel(i, data.OldLabel)
+			c.SetConnectionLabelSegment(i, data.OldSegment)
+		}
 	case ActionEditTitle:
 		data := action.Inverse.(EditTitleData)
-		if data.BoxID >= 0 && data.BoxID < len(m.getCanvas().Boxes()) {
-			m.getCanvas().Boxes()[data.BoxID].Title = data.OldTitle
-			m.getCanvas().Boxes()[data.BoxID].UpdateSize()
+		if i := c.BoxIndex(data.BoxID); i >= 0 {
+			c.Boxes()[i].Title = data.OldTitle
+			c.Boxes()[i].UpdateSize()
 		}
 	case ActionSetColor:
 		data := action.Inverse.(ColorData)
 		m.applyObjectColor(data.Kind, data.ID, data.OldColor)
+	case ActionPaste:
+		c.RemoveGroup(action.Data.(Group))
+	case ActionCut:
+		c.InsertGroup(action.Data.(Group))
+	case ActionArrange:
+		applyArrangement(c, action.Inverse.(ArrangeData))
 	}
 
 	buf.redoStack = append(buf.redoStack, action)
 }
 
+// applyObjectColor sets the color of the object of the given kind and ID.
 func (m *model) applyObjectColor(kind, id, color int) {
+	c := m.getCanvas()
 	switch kind {
 	case ColorKindBox:
-		m.getCanvas().SetBoxColor(id, color)
+		c.SetBoxColor(c.BoxIndex(id), color)
 	case ColorKindLine:
-		m.getCanvas().SetLineColor(id, color)
+		c.SetLineColor(c.ConnectionIndex(id), color)
 	case ColorKindText:
-		m.getCanvas().SetTextColor(id, color)
+		c.SetTextColor(c.TextIndex(id), color)
 	}
 }
 
@@ -117,60 +140,83 @@ func (m *model) redo() {
 	lastIndex := len(buf.redoStack) - 1
 	action := buf.redoStack[lastIndex]
 	buf.redoStack = buf.redoStack[:lastIndex]
+	buf.revision++
 
+	c := m.getCanvas()
 	switch action.Type {
 	case ActionAddBox:
 		data := action.Data.(AddBoxData)
-		m.getCanvas().AddBoxWithID(data.X, data.Y, data.Text, data.ID)
+		c.AddBoxWithID(data.X, data.Y, data.Text, data.ID)
 	case ActionDeleteBox:
 		data := action.Data.(DeleteBoxData)
-		m.getCanvas().DeleteBox(data.ID)
+		c.DeleteBox(c.BoxIndex(data.ID))
 	case ActionEditBox:
 		data := action.Data.(EditBoxData)
-		m.getCanvas().SetBoxText(data.ID, data.NewText)
+		c.SetBoxText(c.BoxIndex(data.ID), data.NewText)
 	case ActionEditText:
 		data := action.Data.(EditTextData)
-		m.getCanvas().SetTextText(data.ID, data.NewText)
+		c.SetTextText(c.TextIndex(data.ID), data.NewText)
 	case ActionDeleteText:
 		data := action.Data.(DeleteTextData)
-		m.getCanvas().DeleteText(data.ID)
+		c.DeleteText(c.TextIndex(data.ID))
 	case ActionResizeBox:
 		data := action.Data.(ResizeBoxData)
-		m.getCanvas().ResizeBox(data.ID, data.DeltaWidth, data.DeltaHeight)
+		c.ResizeBox(c.BoxIndex(data.ID), data.DeltaWidth, data.DeltaHeight)
 	case ActionMoveBox:
 		data := action.Data.(MoveBoxData)
-		m.getCanvas().MoveBox(data.ID, data.DeltaX, data.DeltaY)
+		c.MoveBox(c.BoxIndex(data.ID), data.DeltaX, data.DeltaY)
 	case ActionMoveText:
 		data := action.Data.(MoveTextData)
-		m.getCanvas().MoveText(data.ID, data.DeltaX, data.DeltaY)
+		c.MoveText(c.TextIndex(data.ID), data.DeltaX, data.DeltaY)
 	case ActionAddConnection:
 		data := action.Data.(AddConnectionData)
-		m.getCanvas().RestoreConnection(data.Connection)
+		c.RestoreConnection(data.Connection.Clone())
 	case ActionDeleteConnection:
 		data := action.Data.(AddConnectionData)
-		m.getCanvas().RemoveSpecificConnection(data.Connection)
+		c.RemoveSpecificConnection(data.Connection)
 	case ActionCycleArrow:
 		cycleData := action.Data.(CycleArrowData)
-		if cycleData.ConnIdx >= 0 && cycleData.ConnIdx < len(m.getCanvas().Connections()) {
-			m.getCanvas().Connections()[cycleData.ConnIdx] = cycleData.NewConn
+		if i := c.ConnectionIndex(cycleData.ConnID); i >= 0 {
+			c.Connections()[i] = cycleData.NewConn.Clone()
 		}
 	case ActionHighlight:
 		data := action.Data.(HighlightData)
 		for _, cell := range data.Cells {
-			m.getCanvas().SetHighlight(cell.X, cell.Y, cell.Color)
+			c.SetHighlight(cell.X, cell.Y, cell.Color)
 		}
 	case ActionChangeBorderStyle:
 		data := action.Data.(BorderStyleData)
-		m.getCanvas().SetBorderStyle(data.BoxID, data.NewStyle)
+		c.SetBorderStyle(c.BoxIndex(data.BoxID), data.NewStyle)
+	case ActionChangeShape:
+		data := action.Data.(ShapeData)
+		if i := c.BoxIndex(data.BoxID); i >= 0 {
+			c.Boxes()[i].Shape = data.NewShape
+		}
+		c.RestoreConnections(data.NewConnections)
+	case ActionEditLabel:
+		data := action.Data.(LabelData)
+		if i := c.ConnectionIndex(data.ConnID); i >= 0 {
+			c.SetConnectionLabel(i, data.NewLabel)
+			c.SetConnectionLabelSegment(i, data.NewSegment)
+		}
 	case ActionEditTitle:
 		data := action.Data.(EditTitleData)
-		if data.BoxID >= 0 && data.BoxID < len(m.getCanvas().Boxes()) {
-			m.getCanvas().Boxes()[data.BoxID].Title = data.NewTitle
-			m.getCanvas().Boxes()[data.BoxID].UpdateSize()
+		if i := c.BoxIndex(data.BoxID); i >= 0 {
+			c.Boxes()[i].Title = data.NewTitle
+			c.Boxes()[i].UpdateSize()
 		}
 	case ActionSetColor:
 		data := action.Data.(ColorData)
 		m.applyObjectColor(data.Kind, data.ID, data.NewColor)
+	case ActionPaste:
+		c.InsertGroup(action.Data.(Group))
+	case ActionCut:
+		c.RemoveGroup(action.Data.(Group))
+	case ActionArrange:
+		applyArrangement(c, action.Data.(ArrangeData))
+	}
+	if action.Relayout != nil {
+		applyArrangement(c, action.Relayout.After)
 	}
 
 	buf.undoStack = append(buf.undoStack, action)
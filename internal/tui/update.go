@@ -5,7 +5,7 @@ import (
 )
 
 func (m model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(m.autosaveTick(), m.watchTick())
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -14,6 +14,18 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return m, nil
 
+	case clipboardReadMsg:
+		m.receiveClipboard(msg)
+		return m, nil
+
+	case autosaveMsg:
+		m.autosave()
+		return m, m.autosaveTick()
+
+	case fileCheckMsg:
+		m.applyFileChecks(msg.checks)
+		return m, m.watchTick()
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -21,6 +33,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyMsg:
+		if msg.Paste && m.mode == ModeNormal {
+			m.pasteText(string(msg.Runes))
+			return m, nil
+		}
 		if m.help && m.mode != ModeStartup {
 			switch msg.String() {
 			case "esc", "escape", "q", "?":
@@ -67,8 +83,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleTextInputKey(msg)
 		case ModeBoxJump:
 			return m.handleBoxJumpKey(msg)
+		case ModeLint:
+			return m.handleLintKey(msg)
 		case ModeTitleEdit:
 			return m.handleTitleEditKey(msg)
+		case ModeLabelEdit:
+			return m.handleLabelEditKey(msg)
 		case ModeResize:
 			return m.handleResizeKey(msg)
 		case ModeMultiSelect:
@@ -1,12 +1,24 @@
 package tui
 
 import (
+	"fmt"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
+
+	cv "flerm/internal/canvas"
+	"flerm/internal/config"
 
 	"github.com/atotto/clipboard"
 )
 
+// isChartFile reports whether name is a saved chart, in either the .sav text
+// format or the JSON format.
+func isChartFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".sav") || cv.IsJSONFile(name)
+}
+
 func (m *model) getCurrentBuffer() *Buffer {
 	if len(m.buffers) == 0 {
 		return nil
@@ -21,6 +33,21 @@ func (m *model) getCanvas() *Canvas {
 	return nil
 }
 
+// selectedBoxIndex, selectedTextIndex and titleEditBoxIndex find the box or
+// text being edited, moved or resized, which the model keeps by ID; -1 if it
+// is gone.
+func (m *model) selectedBoxIndex() int {
+	return m.getCanvas().BoxIndex(m.selectedBox)
+}
+
+func (m *model) selectedTextIndex() int {
+	return m.getCanvas().TextIndex(m.selectedText)
+}
+
+func (m *model) titleEditBoxIndex() int {
+	return m.getCanvas().BoxIndex(m.titleEditBoxID)
+}
+
 func (m *model) getPanOffset() (int, int) {
 	if buf := m.getCurrentBuffer(); buf != nil {
 		return buf.panX, buf.panY
@@ -45,6 +72,202 @@ func (m *model) addNewBufferWithPan(canvas *Canvas, filename string, panX, panY
 	m.currentBufferIndex = len(m.buffers) - 1
 }
 
+// openLoadedChart puts a chart that was just loaded from path into the buffer
+// the open was for, and reports any warnings from loading it.
+func (m *model) openLoadedChart(res *cv.LoadResult, path string) {
+	panX, panY := 0, 0
+	if res.Pan != nil {
+		panX, panY = res.Pan.X, res.Pan.Y
+	}
+	if m.fromStartup {
+
+		m.buffers[0] = Buffer{
+			canvas:    res.Canvas,
+			undoStack: []Action{},
+			redoStack: []Action{},
+			filename:  path,
+			panX:      panX,
+			panY:      panY,
+		}
+		m.currentBufferIndex = 0
+		m.fromStartup = false
+	} else if m.openInNewBuffer {
+
+		m.addNewBufferWithPan(res.Canvas, path, panX, panY)
+		m.openInNewBuffer = false
+	} else {
+
+		buf := m.getCurrentBuffer()
+		if buf != nil {
+			buf.canvas = res.Canvas
+			buf.filename = path
+			buf.panX = panX
+			buf.panY = panY
+			buf.undoStack = []Action{}
+			buf.redoStack = []Action{}
+			buf.savedUndo = 0
+		}
+	}
+	if buf := m.getCurrentBuffer(); buf != nil {
+		buf.diskStamp = res.Stamp
+		buf.diskChanged = false
+	}
+	m.errorMessage = ""
+	m.successMessage = loadWarningMessage(res.Warnings)
+}
+
+// loadWarningMessage summarizes the warnings from loading a chart, or returns
+// "" when there are none.
+func loadWarningMessage(warnings []cv.Diagnostic) string {
+	switch len(warnings) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("Opened with a warning: %s", warnings[0])
+	}
+	return fmt.Sprintf("Opened with %d warnings, first: %s", len(warnings), warnings[0])
+}
+
+// saveChart writes the current chart to path with its pan offset, keeping as
+// many backups of the old file as .flermrc asks for. Once saved, the chart's
+// autosaved copy is no longer needed.
+func (m *model) saveChart(path string) error {
+	opts := cv.SaveOptions{Backups: config.DefaultBackups}
+	if m.config != nil {
+		opts.Backups = m.config.Backups
+	}
+	buf := m.getCurrentBuffer()
+	if buf != nil {
+		opts.Pan = &point{X: buf.panX, Y: buf.panY}
+	}
+	if err := m.getCanvas().Save(path, opts); err != nil {
+		return err
+	}
+	if buf != nil {
+		buf.markSaved()
+		discardRecovery(buf)
+		buf.diskStamp, _ = cv.StampFile(path, FileStamp{})
+		buf.diskChanged = false
+	}
+	return nil
+}
+
+// modified reports whether the buffer has changes that haven't been saved.
+func (b *Buffer) modified() bool {
+	return len(b.undoStack) != b.savedUndo
+}
+
+// markSaved records the current undo position as the saved state.
+func (b *Buffer) markSaved() {
+	b.savedUndo = len(b.undoStack)
+}
+
+// bufferName is how the buffer at index i is named in the buffer bar and in
+// prompts.
+func (m *model) bufferName(i int) string {
+	filename := m.buffers[i].filename
+	if filename == "" {
+		return fmt.Sprintf("Buffer %d", i+1)
+	}
+	name := filepath.Base(filename)
+	if strings.HasSuffix(strings.ToLower(name), ".sav") {
+		name = name[:len(name)-4]
+	}
+	return name
+}
+
+// unsavedBuffers names the modified buffers among those at the given
+// indices, or among all buffers when none are given.
+func (m *model) unsavedBuffers(indices ...int) []string {
+	if len(indices) == 0 {
+		for i := range m.buffers {
+			indices = append(indices, i)
+		}
+	}
+	var names []string
+	for _, i := range indices {
+		if i >= 0 && i < len(m.buffers) && m.buffers[i].modified() {
+			names = append(names, m.bufferName(i))
+		}
+	}
+	return names
+}
+
+// unsavedWarning says which of the given buffers (all of them when none are
+// given) have changes that would be lost.
+func (m *model) unsavedWarning(indices ...int) string {
+	return fmt.Sprintf("Unsaved changes in %s will be lost.", strings.Join(m.unsavedBuffers(indices...), ", "))
+}
+
+// confirmDataLoss asks before an action that would throw away unsaved
+// changes in the given buffers (all of them when none are given). It
+// returns false, leaving the editor in ModeConfirm, when the user has to
+// answer first.
+func (m *model) confirmDataLoss(action ConfirmAction, indices ...int) bool {
+	if m.config == nil || !m.config.Confirmations || len(m.unsavedBuffers(indices...)) == 0 {
+		return true
+	}
+	m.mode = ModeConfirm
+	m.confirmAction = action
+	return false
+}
+
+// resetCurrentBuffer replaces the current chart with an empty one.
+func (m *model) resetCurrentBuffer() {
+	buf := m.getCurrentBuffer()
+	if buf == nil {
+		return
+	}
+	discardRecovery(buf)
+	*buf = Buffer{
+		canvas:    cv.NewCanvas(),
+		undoStack: []Action{},
+		redoStack: []Action{},
+	}
+}
+
+// closeCurrentBuffer closes the current chart, going back to the start menu
+// when it was the last one.
+func (m *model) closeCurrentBuffer() {
+	if buf := m.getCurrentBuffer(); buf != nil {
+		discardRecovery(buf)
+	}
+	if len(m.buffers) > 1 {
+		newIndex := m.currentBufferIndex - 1
+		if newIndex < 0 {
+			newIndex = 0
+		}
+		m.buffers = append(m.buffers[:m.currentBufferIndex], m.buffers[m.currentBufferIndex+1:]...)
+		m.currentBufferIndex = newIndex
+	} else {
+		m.buffers = []Buffer{
+			{
+				canvas:    cv.NewCanvas(),
+				undoStack: []Action{},
+				redoStack: []Action{},
+			},
+		}
+		m.currentBufferIndex = 0
+		m.mode = ModeStartup
+	}
+	m.cursorX = 0
+	m.cursorY = 0
+	m.errorMessage = ""
+	m.successMessage = ""
+}
+
+// beginOpen shows the file menu for opening a chart into the current buffer.
+func (m *model) beginOpen() {
+	m.mode = ModeFileInput
+	m.fileOp = FileOpOpen
+	m.filename = ""
+	m.errorMessage = ""
+	m.successMessage = ""
+	m.fromStartup = false
+	m.openInNewBuffer = false
+	m.scanTxtFiles()
+}
+
 func (m *model) recordAction(actionType ActionType, data, inverse interface{}) {
 	buf := m.getCurrentBuffer()
 	if buf == nil {
@@ -55,8 +278,13 @@ func (m *model) recordAction(actionType ActionType, data, inverse interface{}) {
 		Data:    data,
 		Inverse: inverse,
 	}
+	if len(buf.undoStack) < buf.savedUndo {
+		// The saved state was in the redo stack, which this action clears.
+		buf.savedUndo = -1
+	}
 	buf.undoStack = append(buf.undoStack, action)
 	buf.redoStack = buf.redoStack[:0]
+	buf.revision++
 }
 
 func readClipboardText() (string, error) {
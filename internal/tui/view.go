@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	cv "flerm/internal/canvas"
 )
 
 func (m *model) renderBufferBar(width int) string {
@@ -12,36 +14,39 @@ func (m *model) renderBufferBar(width int) string {
 	}
 	var bar strings.Builder
 	bar.WriteString("Open Charts: ")
+	visibleLen := len("Open Charts: ")
 	for i, buf := range m.buffers {
+		// Names are cut so the bar fits, leaving room for the brackets.
+		room := width - visibleLen - 2
+		if i > 0 {
+			room -= 3
+		}
+		if room <= 0 {
+			break
+		}
 		if i > 0 {
 			bar.WriteString(" | ")
+			visibleLen += 3
 		}
-		var bufName string
-		if buf.filename != "" {
-			name := filepath.Base(buf.filename)
-			if strings.HasSuffix(strings.ToLower(name), ".sav") {
-				name = name[:len(name)-4]
-			}
-			bufName = name
-		} else {
-			bufName = fmt.Sprintf("Buffer %d", i+1)
+		bufName := m.bufferName(i)
+		if buf.modified() {
+			bufName += "*"
 		}
+		bufName = cv.TruncateWidth(bufName, room)
+		visibleLen += cv.StringWidth(bufName)
 		if i == m.currentBufferIndex {
 
 			bar.WriteString("\033[32m[\033[0m")
 			bar.WriteString(bufName)
 			bar.WriteString("\033[32m]\033[0m")
+			visibleLen += 2
 		} else {
 			bar.WriteString(bufName)
 		}
 	}
-	currentLen := bar.Len()
 
-	visibleLen := currentLen - (2 * 9)
 	if visibleLen < width {
 		bar.WriteString(strings.Repeat(" ", width-visibleLen))
-	} else {
-		return bar.String()[:width]
 	}
 	return bar.String()
 }
@@ -91,7 +96,7 @@ func (m model) View() string {
 
 	var selectedBox int = -1
 	if m.mode == ModeResize || m.mode == ModeMove {
-		selectedBox = m.selectedBox
+		selectedBox = m.selectedBoxIndex()
 	}
 
 	renderWidth := m.width
@@ -148,15 +153,15 @@ func (m model) View() string {
 		cursorX = 0
 	}
 
-	showCursor := (m.mode != ModeStartup && m.mode != ModeFileInput && m.mode != ModeEditing && m.mode != ModeTextInput && m.mode != ModeTitleEdit)
+	showCursor := (m.mode != ModeStartup && m.mode != ModeFileInput && m.mode != ModeEditing && m.mode != ModeTextInput && m.mode != ModeTitleEdit && m.mode != ModeLabelEdit)
 
 	var editBoxID, editTextID int = -1, -1
 	var editCursorPos int = 0
 	var editText string = ""
 	var editTextX, editTextY int = -1, -1
 	if m.mode == ModeEditing {
-		editBoxID = m.selectedBox
-		editTextID = m.selectedText
+		editBoxID = m.selectedBoxIndex()
+		editTextID = m.selectedTextIndex()
 		editCursorPos = m.editCursorPos
 		editText = m.editText
 	} else if m.mode == ModeTextInput {
@@ -168,7 +173,7 @@ func (m model) View() string {
 		editTextY = m.textInputY
 	} else if m.mode == ModeTitleEdit {
 
-		editBoxID = m.titleEditBoxID
+		editBoxID = m.titleEditBoxIndex()
 		editCursorPos = m.titleEditCursorPos
 		editText = m.titleEditText
 
@@ -203,6 +208,10 @@ func (m model) View() string {
 		m.overlayContextMenu(renderResult)
 	}
 
+	if m.mode == ModeLint {
+		m.overlayLintPanel(renderResult)
+	}
+
 	canvas := renderResult.ApplyColors()
 
 	var result strings.Builder
@@ -227,8 +236,8 @@ func (m model) View() string {
 	case ModeEditing:
 		displayText := strings.ReplaceAll(m.editText, "\n", " ")
 		cursorPos := m.editCursorPos
-		if cursorPos > len(displayText) {
-			cursorPos = len(displayText)
+		if cursorPos > runeLen(displayText) {
+			cursorPos = runeLen(displayText)
 		}
 		var cursorDisplay string
 		if m.hasEditSelection() {
@@ -246,9 +255,9 @@ func (m model) View() string {
 			selected := string(runes[start:end])
 			after := string(runes[end:])
 			cursorDisplay = before + "[" + selected + "]" + after
-		} else if len(displayText) == 0 {
+		} else if runeLen(displayText) == 0 {
 			cursorDisplay = "█"
-		} else if cursorPos >= len(displayText) {
+		} else if cursorPos >= runeLen(displayText) {
 			cursorDisplay = displayText + "█"
 		} else {
 
@@ -262,22 +271,22 @@ func (m model) View() string {
 			selectionHint = fmt.Sprintf(" | %d chars selected", end-start)
 		}
 		if m.selectedBox != -1 {
-			statusLine = fmt.Sprintf("Mode: EDIT | Box %d | Text: %s%s | Home/End, Shift+←→↑↓=select, Ctrl+S=save", m.selectedBox, cursorDisplay, selectionHint)
+			statusLine = fmt.Sprintf("Mode: EDIT | Box %d | Text: %s%s | Home/End, Shift+←→↑↓=select, Ctrl+S=save", m.selectedBoxIndex(), cursorDisplay, selectionHint)
 		} else if m.selectedText != -1 {
-			statusLine = fmt.Sprintf("Mode: EDIT | Text %d | Text: %s%s | Home/End, Shift+←→↑↓=select, Ctrl+S=save", m.selectedText, cursorDisplay, selectionHint)
+			statusLine = fmt.Sprintf("Mode: EDIT | Text %d | Text: %s%s | Home/End, Shift+←→↑↓=select, Ctrl+S=save", m.selectedTextIndex(), cursorDisplay, selectionHint)
 		} else {
 			statusLine = fmt.Sprintf("Mode: EDIT | Text: %s%s | Home/End, Shift+←→↑↓=select, Ctrl+S=save", cursorDisplay, selectionHint)
 		}
 	case ModeTextInput:
 		displayText := strings.ReplaceAll(m.textInputText, "\n", " ")
 		cursorPos := m.textInputCursorPos
-		if cursorPos > len(displayText) {
-			cursorPos = len(displayText)
+		if cursorPos > runeLen(displayText) {
+			cursorPos = runeLen(displayText)
 		}
 		var cursorDisplay string
-		if len(displayText) == 0 {
+		if runeLen(displayText) == 0 {
 			cursorDisplay = "█"
-		} else if cursorPos >= len(displayText) {
+		} else if cursorPos >= runeLen(displayText) {
 			cursorDisplay = displayText + "█"
 		} else {
 
@@ -287,7 +296,7 @@ func (m model) View() string {
 		}
 		statusLine = fmt.Sprintf("Mode: TEXT | Text: %s | ←/→=move cursor, Enter=newline, Ctrl+S=save, Esc=cancel", cursorDisplay)
 	case ModeResize:
-		statusLine = fmt.Sprintf("Mode: RESIZE | Box %d | hjkl/arrows=resize, Enter=finish, Esc=cancel", m.selectedBox)
+		statusLine = fmt.Sprintf("Mode: RESIZE | Box %d | hjkl/arrows=resize, Enter=finish, Esc=cancel", m.selectedBoxIndex())
 	case ModeMove:
 		if len(m.selectedBoxes) > 0 || len(m.selectedTexts) > 0 || len(m.selectedConnections) > 0 || len(m.originalHighlights) > 0 {
 			boxCount := len(m.selectedBoxes)
@@ -303,11 +312,11 @@ func (m model) View() string {
 			if connCount > 0 {
 				parts = append(parts, fmt.Sprintf("%d connections", connCount))
 			}
-			statusLine = fmt.Sprintf("Mode: MOVE | %s | hjkl/arrows=move, Enter=finish, Esc=cancel", strings.Join(parts, ", "))
+			statusLine = fmt.Sprintf("Mode: MOVE | %s | hjkl/arrows=move, c=copy, x=cut, Enter=finish, Esc=cancel", strings.Join(parts, ", "))
 		} else if m.selectedBox != -1 {
-			statusLine = fmt.Sprintf("Mode: MOVE | Box %d | hjkl/arrows=move, Enter=finish, Esc=cancel", m.selectedBox)
+			statusLine = fmt.Sprintf("Mode: MOVE | Box %d | hjkl/arrows=move, Enter=finish, Esc=cancel", m.selectedBoxIndex())
 		} else if m.selectedText != -1 {
-			statusLine = fmt.Sprintf("Mode: MOVE | Text %d | hjkl/arrows=move, Enter=finish, Esc=cancel", m.selectedText)
+			statusLine = fmt.Sprintf("Mode: MOVE | Text %d | hjkl/arrows=move, Enter=finish, Esc=cancel", m.selectedTextIndex())
 		} else {
 			statusLine = "Mode: MOVE | hjkl/arrows=move, Enter=finish, Esc=cancel"
 		}
@@ -320,10 +329,8 @@ func (m model) View() string {
 			opStr = "Save"
 		case FileOpOpen:
 			opStr = "Open"
-		case FileOpSavePNG:
-			opStr = "Export PNG"
-		case FileOpSaveVisualTXT:
-			opStr = "Export Visual TXT"
+		case FileOpExport:
+			opStr = "Export " + m.exportChoice.label
 		}
 		if m.errorMessage != "" {
 			if m.fileOp == FileOpOpen {
@@ -361,31 +368,43 @@ func (m model) View() string {
 		case ConfirmDeleteHighlight:
 			message = "Remove highlight? (y/n)"
 		case ConfirmQuit:
-			message = "Quit Flerm? (y/n)"
+			message = fmt.Sprintf("Quit Flerm? %s (y/n)", m.unsavedWarning())
 		case ConfirmNewChart:
-			message = "Create new chart? Unsaved changes will be lost. (y/n)"
+			message = fmt.Sprintf("Create new chart? %s (y/n)", m.unsavedWarning(m.currentBufferIndex))
 		case ConfirmCloseBuffer:
-			message = "Close current buffer? Unsaved changes will be lost. (y/n)"
+			message = fmt.Sprintf("Close current buffer? %s (y/n)", m.unsavedWarning(m.currentBufferIndex))
+		case ConfirmOpenOverCurrent:
+			message = fmt.Sprintf("Open a chart in place of this one? %s (y/n)", m.unsavedWarning(m.currentBufferIndex))
 		case ConfirmOverwriteFile:
 			message = fmt.Sprintf("File %s already exists. Overwrite? (y/n)", m.filename)
 		case ConfirmChooseExportType:
-			message = "Export as PNG (p) or Visual TXT (t)? Press Esc to cancel"
+			message = exportPrompt()
+		case ConfirmExternalChange:
+			message = fmt.Sprintf("%s changed on disk since it was opened. r=reload it, o=overwrite it, c=open it in a new buffer to compare, Esc=cancel", filepath.Base(m.filename))
+		case ConfirmSalvageLoad:
+			message = fmt.Sprintf("Can't open file: %s. Load what can be salvaged? (y/n)", m.salvageError)
+		case ConfirmRestoreRecovered:
+			message = m.restorePrompt()
 		}
 		statusLine = fmt.Sprintf("Mode: CONFIRM | %s", message)
 	case ModeContextMenu:
 		statusLine = "Mode: MENU | ↑/↓ or hover=navigate, →/Enter=open submenu, ←=back, click=select, Esc/right-click=cancel"
+	case ModeLint:
+		statusLine = m.lintStatus()
 	case ModeBoxJump:
 		statusLine = fmt.Sprintf("Mode: BOX JUMP | Enter box number: %s | Enter=jump, Esc=cancel", m.boxJumpInput)
+	case ModeLabelEdit:
+		statusLine = m.labelStatus()
 	case ModeTitleEdit:
 		displayText := strings.ReplaceAll(m.titleEditText, "\n", " ")
 		cursorPos := m.titleEditCursorPos
-		if cursorPos > len(displayText) {
-			cursorPos = len(displayText)
+		if cursorPos > runeLen(displayText) {
+			cursorPos = runeLen(displayText)
 		}
 		var cursorDisplay string
-		if len(displayText) == 0 {
+		if runeLen(displayText) == 0 {
 			cursorDisplay = "█"
-		} else if cursorPos >= len(displayText) {
+		} else if cursorPos >= runeLen(displayText) {
 			cursorDisplay = displayText + "█"
 		} else {
 
@@ -413,7 +432,7 @@ func (m model) View() string {
 			status += " | Connection from line (select target)"
 		}
 		if m.selectedBox != -1 {
-			status += fmt.Sprintf(" | Selected: Box %d", m.selectedBox)
+			status += fmt.Sprintf(" | Selected: Box %d", m.selectedBoxIndex())
 		}
 		if m.successMessage != "" {
 			status += fmt.Sprintf(" | %s", m.successMessage)
@@ -439,6 +458,28 @@ type tooltipCharInfo struct {
 	origCharIdx int
 }
 
+// appendTooltipWord adds the cells of a word that starts at byte start of
+// the tooltip text. A wide character takes two cells and a combining mark
+// none, joining the character before it, as on the canvas.
+func appendTooltipWord(chars []tooltipCharInfo, word string, start int) []tooltipCharInfo {
+	for i, ch := range word {
+		switch cv.RuneWidth(ch) {
+		case 0:
+			for k := len(chars) - 1; k >= 0; k-- {
+				if chars[k].char != wideCont {
+					chars[k].char = cv.JoinCell(chars[k].char, ch)
+					break
+				}
+			}
+		case 2:
+			chars = append(chars, tooltipCharInfo{ch, start + i}, tooltipCharInfo{wideCont, start + i})
+		default:
+			chars = append(chars, tooltipCharInfo{ch, start + i})
+		}
+	}
+	return chars
+}
+
 func (m model) overlayTooltipOnRenderResult(r *RenderResult) {
 	if !m.showTooltip || m.tooltipText == "" {
 		return
@@ -454,8 +495,8 @@ func (m model) overlayTooltipOnRenderResult(r *RenderResult) {
 
 	longestWord := 0
 	for _, word := range strings.Fields(m.tooltipText) {
-		if len(word) > longestWord {
-			longestWord = len(word)
+		if cv.StringWidth(word) > longestWord {
+			longestWord = cv.StringWidth(word)
 		}
 	}
 
@@ -527,7 +568,7 @@ func (m model) overlayTooltipOnRenderResult(r *RenderResult) {
 	var contentLines [][]tooltipCharInfo
 
 	for _, w := range wordsWithPos {
-		wordLen := len([]rune(w.text))
+		wordLen := cv.StringWidth(w.text)
 
 		if currentLineBuilder.width+wordLen+1 <= contentWidth || currentLineBuilder.width == 0 {
 
@@ -536,18 +577,14 @@ func (m model) overlayTooltipOnRenderResult(r *RenderResult) {
 				currentLineBuilder.width++
 			}
 
-			for i, ch := range w.text {
-				currentLineBuilder.chars = append(currentLineBuilder.chars, tooltipCharInfo{ch, w.startIdx + i})
-			}
+			currentLineBuilder.chars = appendTooltipWord(currentLineBuilder.chars, w.text, w.startIdx)
 			currentLineBuilder.width += wordLen
 		} else {
 
 			contentLines = append(contentLines, currentLineBuilder.chars)
 			currentLineBuilder = lineBuilder{chars: []tooltipCharInfo{}, width: 0}
 
-			for i, ch := range w.text {
-				currentLineBuilder.chars = append(currentLineBuilder.chars, tooltipCharInfo{ch, w.startIdx + i})
-			}
+			currentLineBuilder.chars = appendTooltipWord(currentLineBuilder.chars, w.text, w.startIdx)
 			currentLineBuilder.width = wordLen
 		}
 	}
@@ -635,16 +672,20 @@ func (m model) renderStartupMenu() string {
 	menuItems := []string{
 		"  n: New",
 		"  o: Open",
-		"  q: Quit",
 	}
+	if len(m.recovered) > 0 {
+		menuItems = append(menuItems, m.recoveredLabel(), "  d: Discard unsaved")
+	}
+	menuItems = append(menuItems, "  q: Quit")
 
 	logoWidth := len(logo[0])
 	menuWidth := 0
 	for _, item := range menuItems {
-		if len(item) > menuWidth {
-			menuWidth = len(item)
+		if cv.StringWidth(item) > menuWidth {
+			menuWidth = cv.StringWidth(item)
 		}
 	}
+	itemCells := menuCells(menuItems)
 
 	contentWidth := logoWidth
 	if menuWidth > contentWidth {
@@ -700,8 +741,8 @@ func (m model) renderStartupMenu() string {
 				} else if relY >= 4+len(logo) && relY < 4+len(logo)+len(menuItems) {
 					menuLineIdx := relY - 4 - len(logo)
 					menuX := relX - 1
-					if menuX >= 0 && menuX < len(menuItems[menuLineIdx]) {
-						result.WriteString(string(menuItems[menuLineIdx][menuX]))
+					if menuX >= 0 && menuX < len(itemCells[menuLineIdx]) {
+						result.WriteString(cellText(itemCells[menuLineIdx][menuX]))
 					} else {
 						result.WriteString(" ")
 					}
@@ -723,7 +764,7 @@ func (m model) renderFileMenu() string {
 
 	var menuItems []string
 	if len(m.fileList) == 0 {
-		menuItems = []string{"  (No .sav files found in current directory)"}
+		menuItems = []string{"  (No saved charts found in current directory)"}
 	} else {
 		for i, file := range m.fileList {
 			displayName := file
@@ -753,10 +794,11 @@ func (m model) renderFileMenu() string {
 
 	contentWidth := len(title)
 	for _, item := range menuItems {
-		if len(item) > contentWidth {
-			contentWidth = len(item)
+		if cv.StringWidth(item) > contentWidth {
+			contentWidth = cv.StringWidth(item)
 		}
 	}
+	itemCells := menuCells(menuItems)
 
 	boxWidth := contentWidth + 4
 	boxHeight := len(menuItems) + 4
@@ -804,8 +846,8 @@ func (m model) renderFileMenu() string {
 				} else if relY >= 3 && relY < 3+len(menuItems) {
 					itemIdx := relY - 3
 					itemX := relX - 1
-					if itemX >= 0 && itemX < len(menuItems[itemIdx]) {
-						result.WriteString(string(menuItems[itemIdx][itemX]))
+					if itemX >= 0 && itemX < len(itemCells[itemIdx]) {
+						result.WriteString(cellText(itemCells[itemIdx][itemX]))
 					} else {
 						result.WriteString(" ")
 					}
@@ -822,6 +864,25 @@ func (m model) renderFileMenu() string {
 	return result.String()
 }
 
+// menuCells lays out menu lines one entry per screen column, so file names
+// with wide characters line up with the frame around them.
+func menuCells(items []string) [][]rune {
+	cells := make([][]rune, len(items))
+	for i, item := range items {
+		cells[i] = cv.TextCells(item)
+	}
+	return cells
+}
+
+// cellText is what to print for one laid-out cell. The right half of a wide
+// character prints nothing, since the character already covers it.
+func cellText(r rune) string {
+	if r == wideCont {
+		return ""
+	}
+	return string(r)
+}
+
 func (m model) modeString() string {
 	switch m.mode {
 	case ModeStartup:
@@ -848,6 +909,10 @@ func (m model) modeString() string {
 		return "TITLE"
 	case ModeContextMenu:
 		return "MENU"
+	case ModeLint:
+		return "LINT"
+	case ModeLabelEdit:
+		return "LABEL"
 	default:
 		return "UNKNOWN"
 	}
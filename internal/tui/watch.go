@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	cv "flerm/internal/canvas"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// watchInterval is how often open charts are checked for changes made by
+// other programs.
+const watchInterval = 2 * time.Second
+
+// fileCheck is the result of checking one open chart on disk.
+type fileCheck struct {
+	filename string
+	prev     FileStamp
+	stamp    FileStamp
+	err      error
+}
+
+// fileCheckMsg carries the results of a watchTick.
+type fileCheckMsg struct {
+	checks []fileCheck
+}
+
+// watchTick checks the files behind the open charts after watchInterval.
+// The files are read in the background; only their names and stamps are
+// taken from the model.
+func (m model) watchTick() tea.Cmd {
+	var checks []fileCheck
+	for _, buf := range m.buffers {
+		if buf.filename != "" && !buf.diskStamp.IsZero() && !buf.diskChanged {
+			checks = append(checks, fileCheck{filename: buf.filename, prev: buf.diskStamp})
+		}
+	}
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		for i := range checks {
+			checks[i].stamp, checks[i].err = cv.StampFile(checks[i].filename, checks[i].prev)
+		}
+		return fileCheckMsg{checks: checks}
+	})
+}
+
+// applyFileChecks flags the buffers whose files changed on disk. A file that
+// was only touched keeps its buffer unflagged. Buffers saved or reloaded
+// while the check ran are left alone, since their stamps moved on.
+func (m *model) applyFileChecks(checks []fileCheck) {
+	for _, check := range checks {
+		if check.err != nil {
+			continue
+		}
+		for i := range m.buffers {
+			buf := &m.buffers[i]
+			if buf.filename != check.filename || buf.diskStamp != check.prev || buf.diskChanged {
+				continue
+			}
+			if check.stamp.SameContent(check.prev) {
+				buf.diskStamp = check.stamp
+				continue
+			}
+			buf.diskChanged = true
+			m.errorMessage = fmt.Sprintf("%s changed on disk; saving will ask before overwriting it", filepath.Base(buf.filename))
+		}
+	}
+}
+
+// changedOnDisk reports whether saving the current chart to path would
+// overwrite changes another program made to the file it was loaded from.
+func (m *model) changedOnDisk(path string) bool {
+	buf := m.getCurrentBuffer()
+	if buf == nil || buf.filename == "" || buf.diskStamp.IsZero() || !samePath(buf.filename, path) {
+		return false
+	}
+	if buf.diskChanged {
+		return true
+	}
+	// The last poll may be a couple of seconds old.
+	stamp, err := cv.StampFile(path, buf.diskStamp)
+	if err != nil || stamp.SameContent(buf.diskStamp) {
+		return false
+	}
+	buf.diskChanged = true
+	return true
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// handleExternalChangeKey answers the prompt shown when saving over a chart
+// that changed on disk: reload it, overwrite it, or open the disk version
+// next to this one.
+func (m model) handleExternalChangeKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	path := m.filename
+	switch msg.String() {
+	case "r", "R":
+		res, err := cv.Load(path, cv.LoadOptions{})
+		if err != nil {
+			m.errorMessage = fmt.Sprintf("Error reloading file: %s", err.Error())
+			m.mode = ModeNormal
+			return m, nil
+		}
+		m.openInNewBuffer = false
+		m.fromStartup = false
+		m.openLoadedChart(res, path)
+		if m.successMessage == "" {
+			m.successMessage = fmt.Sprintf("Reloaded %s", filepath.Base(path))
+		}
+	case "o", "O":
+		if err := m.saveChart(path); err != nil {
+			m.errorMessage = fmt.Sprintf("Error saving file: %s", err.Error())
+			m.mode = ModeFileInput
+			return m, nil
+		}
+		if buf := m.getCurrentBuffer(); buf != nil {
+			buf.filename = path
+		}
+		absPath, _ := filepath.Abs(path)
+		m.successMessage = fmt.Sprintf("Saved to %s", absPath)
+		m.errorMessage = ""
+	case "c", "C":
+		res, err := cv.Load(path, cv.LoadOptions{})
+		if err != nil {
+			m.errorMessage = fmt.Sprintf("Error opening file: %s", err.Error())
+			m.mode = ModeNormal
+			return m, nil
+		}
+		m.openInNewBuffer = true
+		m.fromStartup = false
+		m.openLoadedChart(res, path)
+		if m.successMessage == "" {
+			m.successMessage = fmt.Sprintf("Opened the version of %s on disk", filepath.Base(path))
+		}
+	case "esc", "escape", "n", "N":
+	default:
+		return m, nil
+	}
+	m.mode = ModeNormal
+	m.filename = ""
+	return m, nil
+}